@@ -2,10 +2,16 @@
 -- SIATA Contamination Viewer - Complete Database Schema
 -- Version: 2.1 (Standard PostgreSQL)
 -- Date: October 4, 2025
+-- Everything up to the latest_clean_measurements view is the 2.1 baseline,
+-- embedded as internal/migrate/migrations/0001_baseline.up.sql. Later
+-- sections name the migration that adds them; schema changes go in a new
+-- migration there and are mirrored here.
 -- ============================================================================
 
 -- Enable required extensions
 CREATE EXTENSION IF NOT EXISTS postgis;
+CREATE EXTENSION IF NOT EXISTS unaccent;  -- sensor search (migration 0002)
+CREATE EXTENSION IF NOT EXISTS pg_trgm;   -- sensor search ranking (migration 0002)
 
 -- ============================================================================
 -- Helper Functions
@@ -196,3 +202,197 @@ ORDER BY sensor_id, ts DESC, version DESC;
 
 COMMENT ON VIEW latest_clean_measurements IS 'Most recent clean measurement for each sensor';
 
+
+-- ============================================================================
+-- Quality Control
+-- ============================================================================
+
+-- Manual QC judgments submitted by reviewers through the API (migration 0004)
+CREATE TABLE IF NOT EXISTS qc_flags_manual (
+    id              BIGSERIAL PRIMARY KEY,
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    ts              TIMESTAMPTZ NOT NULL,
+    flag            TEXT NOT NULL CHECK (flag IN ('valid', 'invalid')),
+    note            TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT qc_flags_manual_unique UNIQUE (sensor_id, ts)
+);
+
+CREATE TRIGGER qc_flags_manual_set_updated_at
+BEFORE UPDATE ON qc_flags_manual
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX qc_flags_manual_sensor_ts_idx ON qc_flags_manual(sensor_id, ts DESC);
+
+COMMENT ON TABLE qc_flags_manual IS 'Reviewer judgments on raw measurements, consumed by the cleaning pipeline';
+COMMENT ON COLUMN qc_flags_manual.flag IS 'Reviewer judgment: "valid" or "invalid"';
+
+-- ============================================================================
+-- Saved Views
+-- ============================================================================
+
+-- Named query definitions executed server-side for dashboards (migration 0005)
+CREATE TABLE IF NOT EXISTS saved_views (
+    slug            TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    endpoint        TEXT NOT NULL,
+    definition      JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER saved_views_set_updated_at
+BEFORE UPDATE ON saved_views
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+COMMENT ON TABLE saved_views IS 'Persisted API query definitions, validated on creation and executed via /api/v1/views/:slug/execute';
+COMMENT ON COLUMN saved_views.endpoint IS 'Endpoint type the view runs against (measurements, grid_timestamps, realtime_now, sensor_events)';
+COMMENT ON COLUMN saved_views.definition IS 'Sensors, filters and optional relative window of the saved query';
+
+-- ============================================================================
+-- Sensor Location History
+-- ============================================================================
+
+-- Coordinates a sensor has reported over time; the sensors table keeps only
+-- the current location (migration 0006)
+CREATE TABLE IF NOT EXISTS sensor_location_history (
+    id              BIGSERIAL PRIMARY KEY,
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    lat             DOUBLE PRECISION NOT NULL,
+    lon             DOUBLE PRECISION NOT NULL,
+    effective_from  TIMESTAMPTZ NOT NULL,
+    recorded_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT sensor_location_history_unique UNIQUE (sensor_id, effective_from)
+);
+
+CREATE INDEX sensor_location_history_sensor_idx ON sensor_location_history(sensor_id, effective_from DESC);
+
+COMMENT ON TABLE sensor_location_history IS 'Sensor coordinates with the time each became effective, appended by the watcher on relocation';
+COMMENT ON COLUMN sensor_location_history.effective_from IS 'First time the sensor was observed at this location';
+
+-- ============================================================================
+-- Sensor Changes
+-- ============================================================================
+
+-- Field-level history of sensor metadata (migration 0003)
+CREATE TABLE IF NOT EXISTS sensor_changes (
+    id          BIGSERIAL PRIMARY KEY,
+    sensor_id   TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    field       TEXT NOT NULL,
+    old_value   TEXT,
+    new_value   TEXT,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sensor_changes_sensor_idx ON sensor_changes(sensor_id, changed_at DESC, id DESC);
+
+COMMENT ON TABLE sensor_changes IS 'Sensor metadata changes (name, barrio, comuna, ...) detected by the watcher before each upsert';
+COMMENT ON COLUMN sensor_changes.field IS 'Column name, or metadata.<key> for keys of the metadata document';
+
+-- ============================================================================
+-- Measurement Repairs
+-- ============================================================================
+
+-- Audit log of after-the-fact corrections to stored measurements (migration 0007)
+CREATE TABLE IF NOT EXISTS measurement_repairs (
+    id              BIGSERIAL PRIMARY KEY,
+    measurement_id  BIGINT NOT NULL,
+    table_name      TEXT NOT NULL CHECK (table_name IN ('raw_measurements', 'clean_measurements')),
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    ts              TIMESTAMPTZ NOT NULL,
+    old_value_mm    DOUBLE PRECISION,
+    new_value_mm    DOUBLE PRECISION,
+    reason          TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX measurement_repairs_sensor_ts_idx ON measurement_repairs(sensor_id, ts DESC);
+
+COMMENT ON TABLE measurement_repairs IS 'One row per measurement row changed through PATCH /api/v1/admin/measurements';
+COMMENT ON COLUMN measurement_repairs.new_value_mm IS 'Value after the repair; NULL when the value was nulled';
+
+-- ============================================================================
+-- Grid Run Summaries
+-- ============================================================================
+
+-- Outlier-robust network statistics per grid run, computed by the API on the
+-- first listing that includes the run (aggregates of done runs are final;
+-- migration 0008)
+CREATE TABLE IF NOT EXISTS grid_run_summary (
+    grid_run_id             BIGINT PRIMARY KEY REFERENCES grid_runs(id) ON DELETE CASCADE,
+    p90_mm_h                DOUBLE PRECISION NOT NULL,
+    winsorized_mean_mm_h    DOUBLE PRECISION NOT NULL,
+    computed_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE grid_run_summary IS 'Cached p90 and winsorized mean of grid_sensor_aggregates.avg_mm_h per grid run';
+COMMENT ON COLUMN grid_run_summary.winsorized_mean_mm_h IS 'Mean of avg_mm_h after clamping values to the run''s 10th-90th percentile range';
+
+-- ============================================================================
+-- Retention Jobs
+-- ============================================================================
+
+-- Operator-triggered deletions of old measurements; each job records what it
+-- matched and deleted so removals stay auditable (migration 0009)
+CREATE TABLE IF NOT EXISTS retention_jobs (
+    id              BIGSERIAL PRIMARY KEY,
+    table_name      TEXT NOT NULL CHECK (table_name IN ('clean_measurements')),
+    before_ts       TIMESTAMPTZ NOT NULL,
+    dry_run         BOOLEAN NOT NULL DEFAULT FALSE,
+    status          TEXT NOT NULL CHECK (status IN ('running', 'done', 'failed')),
+    matched_rows    BIGINT NOT NULL DEFAULT 0,
+    deleted_rows    BIGINT NOT NULL DEFAULT 0,
+    batches         INTEGER NOT NULL DEFAULT 0,
+    error           TEXT,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at     TIMESTAMPTZ
+);
+
+CREATE INDEX retention_jobs_started_idx ON retention_jobs(started_at DESC);
+
+COMMENT ON TABLE retention_jobs IS 'Retention runs started through POST /api/v1/admin/retention/run';
+COMMENT ON COLUMN retention_jobs.matched_rows IS 'Rows older than before_ts when the job started';
+COMMENT ON COLUMN retention_jobs.deleted_rows IS 'Rows deleted so far; updated after every batch';
+
+-- ============================================================================
+-- Feeds
+-- ============================================================================
+
+-- Feed definitions registered by the watcher on startup, with the outcome of
+-- the latest cycle (migration 0010)
+CREATE TABLE IF NOT EXISTS feeds (
+    name                TEXT PRIMARY KEY,
+    url                 TEXT NOT NULL,
+    network             TEXT,
+    cadence_seconds     INTEGER NOT NULL,
+    last_success_at     TIMESTAMPTZ,
+    last_error_at       TIMESTAMPTZ,
+    last_error          TEXT,
+    registered_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE feeds IS 'Upstream feeds ingested by the watcher; upserted by name on startup';
+COMMENT ON COLUMN feeds.url IS 'Feed URL as configured; may embed credentials, redact before display';
+COMMENT ON COLUMN feeds.cadence_seconds IS 'Interval after which an unchanged reading is stored again (WATCHER_MIN_INTERVAL)';
+
+-- One row per watcher cycle and feed (migration 0011)
+CREATE TABLE IF NOT EXISTS ingest_log (
+    id                  BIGSERIAL PRIMARY KEY,
+    feed_name           TEXT NOT NULL REFERENCES feeds(name) ON DELETE CASCADE,
+    started_at          TIMESTAMPTZ NOT NULL,
+    finished_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    success             BOOLEAN NOT NULL,
+    stations            INTEGER,
+    skipped             INTEGER,
+    inserted            INTEGER,
+    error               TEXT
+);
+
+CREATE INDEX ingest_log_feed_started_idx ON ingest_log(feed_name, started_at DESC);
+
+COMMENT ON TABLE ingest_log IS 'Outcome of every watcher cycle per feed';
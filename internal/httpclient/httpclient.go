@@ -0,0 +1,215 @@
+// Package httpclient builds the outbound HTTP clients of the API and the
+// watcher, so calls to the blob store and the SIATA feeds share connection
+// limits, timeouts, retries and response size caps instead of each call site
+// configuring its own http.Client.
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// ErrResponseTooLarge is returned while reading a response body that exceeds
+// Options.MaxResponseBytes.
+var ErrResponseTooLarge = errors.New("httpclient: response body exceeds the size cap")
+
+const (
+	defaultMaxIdleConnsPerHost = 4
+	defaultMaxConnsPerHost     = 16
+	defaultRetryBaseDelay      = 200 * time.Millisecond
+)
+
+// Attempt describes one round trip, reported to Options.Observe.
+type Attempt struct {
+	Method string
+	Host   string
+	// Retry is 0 for the first attempt and counts retries after it.
+	Retry int
+	// Status is the response status, 0 when the round trip failed.
+	Status   int
+	Err      error
+	Duration time.Duration
+}
+
+// Options configures a client. The zero value gives a pooled client without
+// timeout, retries or size cap.
+type Options struct {
+	// Timeout bounds a whole request, retries and body reads included, like
+	// http.Client.Timeout. 0 means no limit beyond the request context.
+	Timeout time.Duration
+	// HostTimeouts overrides Timeout for requests to a host, keyed by
+	// URL.Host (host or host:port).
+	HostTimeouts map[string]time.Duration
+	// MaxIdleConnsPerHost and MaxConnsPerHost bound the connection pool per
+	// host; 0 selects 4 and 16.
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	// Retries is how many times a GET or HEAD without a body is retried
+	// after a network error, a 429 or a 5xx response. 0 disables retries.
+	Retries int
+	// RetryBaseDelay is the backoff before the first retry, doubling after
+	// each one and jittered between half and the full delay; 0 selects
+	// 200ms.
+	RetryBaseDelay time.Duration
+	// MaxResponseBytes caps response bodies; reading past it fails with
+	// ErrResponseTooLarge. 0 means no cap.
+	MaxResponseBytes int64
+	// Wrap, when set, wraps the pooled transport, e.g. for fault injection.
+	// Retries and caps apply on top of the wrapped transport.
+	Wrap func(http.RoundTripper) http.RoundTripper
+	// Observe, when set, is called after every attempt, for metrics or
+	// tracing.
+	Observe func(Attempt)
+}
+
+// New returns a client configured by opts.
+func New(opts Options) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	if base.MaxIdleConnsPerHost <= 0 {
+		base.MaxIdleConnsPerHost = defaultMaxIdleConnsPerHost
+	}
+	base.MaxConnsPerHost = opts.MaxConnsPerHost
+	if base.MaxConnsPerHost <= 0 {
+		base.MaxConnsPerHost = defaultMaxConnsPerHost
+	}
+
+	var rt http.RoundTripper = base
+	if opts.Wrap != nil {
+		rt = opts.Wrap(rt)
+	}
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = defaultRetryBaseDelay
+	}
+	return &http.Client{Transport: &transport{opts: opts, base: rt}}
+}
+
+type transport struct {
+	opts Options
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	timeout, ok := t.opts.HostTimeouts[req.URL.Host]
+	if !ok {
+		timeout = t.opts.Timeout
+	}
+	if timeout > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(req.Context(), timeout)
+		req = req.WithContext(ctx)
+	}
+
+	resp, err := t.roundTrip(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline must outlive RoundTrip to cover the body, so it is
+	// released when the caller closes it.
+	resp.Body = &cappedBody{
+		ReadCloser: resp.Body,
+		capped:     t.opts.MaxResponseBytes > 0,
+		remaining:  t.opts.MaxResponseBytes,
+		cancel:     cancel,
+	}
+	return resp, nil
+}
+
+// roundTrip sends req, retrying idempotent requests on transient failures.
+func (t *transport) roundTrip(req *http.Request) (*http.Response, error) {
+	retryable := t.opts.Retries > 0 &&
+		(req.Method == http.MethodGet || req.Method == http.MethodHead) &&
+		(req.Body == nil || req.Body == http.NoBody)
+
+	for retry := 0; ; retry++ {
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		if t.opts.Observe != nil {
+			a := Attempt{Method: req.Method, Host: req.URL.Host, Retry: retry, Err: err, Duration: time.Since(start)}
+			if resp != nil {
+				a.Status = resp.StatusCode
+			}
+			t.opts.Observe(a)
+		}
+
+		if !retryable || retry >= t.opts.Retries || !transient(resp, err) || req.Context().Err() != nil {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain a little so the connection can be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+			resp.Body.Close()
+		}
+		if err := sleep(req.Context(), t.backoff(retry)); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// transient reports failures worth retrying.
+func transient(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// backoff is the jittered delay before the retry after attempt retry.
+func (t *transport) backoff(retry int) time.Duration {
+	d := t.opts.RetryBaseDelay << retry
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// cappedBody enforces MaxResponseBytes and releases the request deadline on
+// Close.
+type cappedBody struct {
+	io.ReadCloser
+	capped    bool
+	remaining int64
+	cancel    context.CancelFunc
+}
+
+func (b *cappedBody) Read(p []byte) (int, error) {
+	if !b.capped {
+		return b.ReadCloser.Read(p)
+	}
+	if b.remaining == 0 {
+		// A body of exactly the cap is fine; only a further byte is not.
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *cappedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,165 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flaky answers with the given statuses in turn, then 200 "ok".
+func flaky(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(statuses) {
+			w.WriteHeader(statuses[n-1])
+			return
+		}
+		_, _ = io.WriteString(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		method     string
+		statuses   []int
+		retries    int
+		wantStatus int
+		wantCalls  int32
+	}{
+		{"recovers from 5xx", http.MethodGet, []int{503, 502}, 3, 200, 3},
+		{"retries 429", http.MethodGet, []int{429}, 1, 200, 2},
+		{"gives up with the last response", http.MethodGet, []int{503, 503, 500}, 2, 500, 3},
+		{"does not retry 4xx", http.MethodGet, []int{404}, 3, 404, 1},
+		{"does not retry POST", http.MethodPost, []int{503}, 3, 503, 1},
+		{"retries disabled", http.MethodGet, []int{503}, 0, 503, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, calls := flaky(t, tc.statuses...)
+			var attempts []Attempt
+			client := New(Options{
+				Retries:        tc.retries,
+				RetryBaseDelay: time.Millisecond,
+				Observe:        func(a Attempt) { attempts = append(attempts, a) },
+			})
+
+			req, _ := http.NewRequest(tc.method, srv.URL, nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tc.wantStatus)
+			}
+			if got := calls.Load(); got != tc.wantCalls {
+				t.Errorf("%d calls, want %d", got, tc.wantCalls)
+			}
+			if int32(len(attempts)) != tc.wantCalls {
+				t.Fatalf("observed %d attempts, want %d", len(attempts), tc.wantCalls)
+			}
+			for i, a := range attempts {
+				if a.Retry != i || a.Method != tc.method {
+					t.Errorf("attempt %d reported as %+v", i, a)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryStopsWhenContextEnds(t *testing.T) {
+	srv, calls := flaky(t, 503, 503, 503)
+	client := New(Options{Retries: 3, RetryBaseDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("%d calls, want 1", got)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	tr := &transport{opts: Options{RetryBaseDelay: 100 * time.Millisecond}}
+	for retry := 0; retry < 4; retry++ {
+		full := 100 * time.Millisecond << retry
+		for i := 0; i < 50; i++ {
+			if d := tr.backoff(retry); d < full/2 || d > full {
+				t.Fatalf("backoff(%d) = %v, want within [%v, %v]", retry, d, full/2, full)
+			}
+		}
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	body := strings.Repeat("x", 64)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, body)
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name    string
+		limit   int64
+		wantErr error
+	}{
+		{"uncapped", 0, nil},
+		{"under the cap", 100, nil},
+		{"exactly the cap", 64, nil},
+		{"over the cap", 63, ErrResponseTooLarge},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := New(Options{MaxResponseBytes: tc.limit}).Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			got, err := io.ReadAll(resp.Body)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("read error %v, want %v", err, tc.wantErr)
+			}
+			if tc.wantErr == nil && string(got) != body {
+				t.Errorf("read %d bytes, want %d", len(got), len(body))
+			}
+			if tc.wantErr != nil && int64(len(got)) > tc.limit {
+				t.Errorf("read %d bytes past a cap of %d", len(got), tc.limit)
+			}
+		})
+	}
+}
+
+func TestHostTimeouts(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	// The per-host override wins over the generous default.
+	client := New(Options{Timeout: time.Minute, HostTimeouts: map[string]time.Duration{host: 20 * time.Millisecond}})
+	start := time.Now()
+	if _, err := client.Get(srv.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a deadline error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("request took %v despite a 20ms host timeout", elapsed)
+	}
+}
@@ -0,0 +1,103 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// usage describes the migrate subcommand shared by both services.
+const usage = "usage: migrate up | down [steps] | status"
+
+// Command runs the migrate subcommand: "up" applies pending migrations,
+// "down [steps]" reverts the latest steps (default 1) and "status" lists
+// every migration with when it was applied. Output goes to out.
+func Command(ctx context.Context, databaseURL string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	steps := 1
+	switch args[0] {
+	case "up", "status":
+		if len(args) > 1 {
+			return errors.New(usage)
+		}
+	case "down":
+		if len(args) > 2 {
+			return errors.New(usage)
+		}
+		if len(args) == 2 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid steps %q: %s", args[1], usage)
+			}
+			steps = n
+		}
+	default:
+		return errors.New(usage)
+	}
+
+	r, err := Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer r.Close(context.Background())
+	r.Logf = func(format string, a ...any) { fmt.Fprintf(out, format+"\n", a...) }
+
+	switch args[0] {
+	case "up":
+		done, err := r.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "migrate: schema is up to date")
+		}
+		return nil
+	case "down":
+		done, err := r.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			fmt.Fprintln(out, "migrate: nothing to revert")
+		}
+		return nil
+	}
+
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, st := range statuses {
+		applied := "pending"
+		if st.AppliedAt != nil {
+			applied = st.AppliedAt.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, applied)
+	}
+	return w.Flush()
+}
+
+// Run applies pending migrations at service startup, logging each one.
+func Run(ctx context.Context, databaseURL string) error {
+	r, err := Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer r.Close(context.Background())
+
+	done, err := r.Up(ctx)
+	if err != nil {
+		return err
+	}
+	r.Logf("migrate: %d migrations applied, schema at %04d", len(done), r.migrations[len(r.migrations)-1].Version)
+	return nil
+}
@@ -0,0 +1,333 @@
+// Package migrate applies the schema migrations embedded in the API and
+// watcher binaries, so a deployment brings its own schema changes instead of
+// relying on SQL run by hand before the new binaries start.
+//
+// Migrations are the files migrations/NNNN_name.up.sql, applied in version
+// order, each in its own transaction with search_path set to shizuku, and
+// recorded in shizuku.schema_migrations. An optional NNNN_name.down.sql
+// reverts one. Runners serialise on a Postgres advisory lock, so services
+// starting together apply every migration exactly once.
+//
+// Migration 0001 is the 2.1 schema that used to be applied by hand. Every
+// table added since has its own later migration, written with IF NOT EXISTS
+// so it also applies cleanly to databases built by hand from a newer
+// db/schema.sql.
+package migrate
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed migrations/*.sql
+var files embed.FS
+
+// lockKey is the advisory lock key serialising migration runners.
+const lockKey int64 = 0x5348495a4d494752 // "SHIZMIGR"
+
+// baselineVersion is the migration holding the schema that predates the
+// runner; databases that already have it are adopted rather than migrated.
+const baselineVersion = 1
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one embedded schema change.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// Reversible reports whether the migration has a down file.
+func (m Migration) Reversible() bool {
+	return m.down != ""
+}
+
+// Load returns the embedded migrations in version order.
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		match := fileName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s does not match NNNN_name.(up|down).sql", e.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		raw, err := fs.ReadFile(files, path.Join("migrations", e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d has files named %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(raw)
+		} else {
+			m.down = string(raw)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+		out = append(out, *m)
+	}
+	slices.SortFunc(out, func(a, b Migration) int { return a.Version - b.Version })
+	return out, nil
+}
+
+// Status is a migration and when it was applied, nil while pending.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Runner applies migrations over one dedicated connection.
+type Runner struct {
+	conn       *pgx.Conn
+	migrations []Migration
+	// Logf reports each step; log.Printf by default.
+	Logf func(format string, args ...any)
+}
+
+// Connect opens a runner on databaseURL with the embedded migrations.
+func Connect(ctx context.Context, databaseURL string) (*Runner, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("connect for migrations: %w", err)
+	}
+	return &Runner{conn: conn, migrations: migrations, Logf: log.Printf}, nil
+}
+
+// Close closes the runner's connection.
+func (r *Runner) Close(ctx context.Context) error {
+	return r.conn.Close(ctx)
+}
+
+// Up applies every pending migration in order and returns the ones applied.
+// It waits for any other runner to finish first.
+func (r *Runner) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func() error {
+		if err := r.ensureTable(ctx); err != nil {
+			return err
+		}
+		applied, err := r.applied(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			adopted, err := r.adoptBaseline(ctx)
+			if err != nil {
+				return err
+			}
+			if adopted {
+				applied[baselineVersion] = time.Now()
+			}
+		}
+		r.warnUnknown(applied)
+
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			start := time.Now()
+			if err := r.apply(ctx, m.up, `INSERT INTO shizuku.schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name); err != nil {
+				return fmt.Errorf("apply migration %s: %w", m, err)
+			}
+			r.Logf("migrate: applied %s in %s", m, time.Since(start).Round(time.Millisecond))
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down reverts the latest steps applied migrations, newest first, and
+// returns the ones reverted. It stops at the first migration without a down
+// file.
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func() error {
+		if err := r.ensureTable(ctx); err != nil {
+			return err
+		}
+		applied, err := r.applied(ctx)
+		if err != nil {
+			return err
+		}
+		for i := len(r.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := r.migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if !m.Reversible() {
+				return fmt.Errorf("migration %s cannot be reverted: it has no down file", m)
+			}
+			if err := r.apply(ctx, m.down, `DELETE FROM shizuku.schema_migrations WHERE version = $1`, m.Version); err != nil {
+				return fmt.Errorf("revert migration %s: %w", m, err)
+			}
+			r.Logf("migrate: reverted %s", m)
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every embedded migration with when it was applied. It does
+// not create the bookkeeping table.
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := r.conn.QueryRow(ctx, `SELECT to_regclass('shizuku.schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := map[int]time.Time{}
+	if exists {
+		var err error
+		if applied, err = r.applied(ctx); err != nil {
+			return nil, err
+		}
+		r.warnUnknown(applied)
+	}
+	out := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		st := Status{Migration: m}
+		if at, ok := applied[m.Version]; ok {
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// withLock runs fn holding the migration lock, waiting for it as long as ctx
+// allows.
+func (r *Runner) withLock(ctx context.Context, fn func() error) error {
+	if _, err := r.conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return fmt.Errorf("take migration lock: %w", err)
+	}
+	defer func() {
+		// Unlock even when ctx has expired.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := r.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, lockKey); err != nil {
+			// Closing the session drops the lock with it.
+			_ = r.conn.Close(ctx)
+		}
+	}()
+	return fn()
+}
+
+func (r *Runner) ensureTable(ctx context.Context) error {
+	_, err := r.conn.Exec(ctx, `
+CREATE SCHEMA IF NOT EXISTS shizuku;
+CREATE TABLE IF NOT EXISTS shizuku.schema_migrations (
+    version     INTEGER PRIMARY KEY,
+    name        TEXT NOT NULL,
+    applied_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`)
+	return err
+}
+
+func (r *Runner) applied(ctx context.Context) (map[int]time.Time, error) {
+	rows, err := r.conn.Query(ctx, `SELECT version, applied_at FROM shizuku.schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// adoptBaseline records the baseline as applied, without running it, when
+// the database already holds the hand-applied schema. Only the baseline is
+// adopted: the later migrations still run, creating whatever tables the
+// hand-applied schema predates.
+func (r *Runner) adoptBaseline(ctx context.Context) (bool, error) {
+	var exists bool
+	if err := r.conn.QueryRow(ctx, `SELECT to_regclass('shizuku.sensors') IS NOT NULL`).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, nil
+	}
+	i := slices.IndexFunc(r.migrations, func(m Migration) bool { return m.Version == baselineVersion })
+	if i < 0 {
+		return false, errors.New("baseline migration is missing")
+	}
+	if _, err := r.conn.Exec(ctx, `INSERT INTO shizuku.schema_migrations (version, name) VALUES ($1, $2)`, baselineVersion, r.migrations[i].Name); err != nil {
+		return false, err
+	}
+	r.Logf("migrate: adopted the existing schema as %s", r.migrations[i])
+	return true, nil
+}
+
+// warnUnknown logs applied versions this binary does not embed, the sign of
+// an older binary running against a newer schema.
+func (r *Runner) warnUnknown(applied map[int]time.Time) {
+	for version := range applied {
+		if !slices.ContainsFunc(r.migrations, func(m Migration) bool { return m.Version == version }) {
+			r.Logf("migrate: warning: database has migration %04d, which this binary does not know; it may be older than the schema", version)
+		}
+	}
+}
+
+// apply runs sql and the bookkeeping statement in one transaction.
+func (r *Runner) apply(ctx context.Context, sql, record string, args ...any) error {
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `SET LOCAL search_path = shizuku, public`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
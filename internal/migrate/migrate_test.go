@@ -0,0 +1,197 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/pgtest"
+)
+
+// databaseURLEnv optionally names a disposable database for these tests;
+// without it a container is started.
+const databaseURLEnv = "MIGRATE_TEST_DATABASE_URL"
+
+func TestLoadIsContiguous(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %s: want version %d, versions must be contiguous", m, i+1)
+		}
+		if m.Version > baselineVersion && !m.Reversible() {
+			t.Errorf("migration %s has no down file", m)
+		}
+	}
+}
+
+// Later migrations also run against databases built by hand from a newer
+// db/schema.sql, so every object they create must tolerate existing.
+func TestPostBaselineMigrationsAreIdempotent(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	create := regexp.MustCompile(`(?i)CREATE\s+(TABLE|INDEX|EXTENSION|TRIGGER)\s+(IF\s+NOT\s+EXISTS\s+)?(\w+)`)
+	for _, m := range migrations {
+		if m.Version == baselineVersion {
+			continue
+		}
+		for _, match := range create.FindAllStringSubmatch(m.up, -1) {
+			kind, guarded, name := strings.ToUpper(match[1]), match[2] != "", match[3]
+			switch {
+			case kind == "TRIGGER":
+				if !strings.Contains(m.up, "DROP TRIGGER IF EXISTS "+name) {
+					t.Errorf("migration %s creates trigger %s without dropping it first", m, name)
+				}
+			case !guarded:
+				t.Errorf("migration %s creates %s %s without IF NOT EXISTS", m, strings.ToLower(kind), name)
+			}
+		}
+	}
+}
+
+func TestBaselineIsOriginalSchema(t *testing.T) {
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline := migrations[baselineVersion-1].up
+	for _, table := range []string{"qc_flags_manual", "saved_views", "sensor_location_history", "measurement_repairs", "grid_run_summary", "retention_jobs", "feeds", "ingest_log", "sensor_changes"} {
+		if strings.Contains(baseline, "CREATE TABLE IF NOT EXISTS "+table+" ") {
+			t.Errorf("baseline creates %s, which postdates the 2.1 schema", table)
+		}
+	}
+}
+
+// testDatabase returns the URL of a database with an empty shizuku schema.
+func testDatabase(t *testing.T) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	url, stop, err := pgtest.Start(ctx, databaseURLEnv)
+	if errors.Is(err, pgtest.ErrUnavailable) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(stop)
+	exec(t, url, `DROP SCHEMA IF EXISTS shizuku CASCADE`)
+	t.Cleanup(func() { exec(t, url, `DROP SCHEMA IF EXISTS shizuku CASCADE`) })
+	return url
+}
+
+func exec(t *testing.T, url, sql string) {
+	t.Helper()
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+}
+
+func connect(t *testing.T, url string) *Runner {
+	t.Helper()
+	r, err := Connect(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Logf = t.Logf
+	t.Cleanup(func() { _ = r.Close(context.Background()) })
+	return r
+}
+
+func appliedVersions(t *testing.T, r *Runner) []int {
+	t.Helper()
+	status, err := r.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, st := range status {
+		if st.AppliedAt != nil {
+			versions = append(versions, st.Version)
+		}
+	}
+	return versions
+}
+
+func TestConcurrentRunnersApplyEachMigrationOnce(t *testing.T) {
+	url := testDatabase(t)
+	runners := []*Runner{connect(t, url), connect(t, url)}
+
+	var wg sync.WaitGroup
+	done := make([][]Migration, len(runners))
+	errs := make([]error, len(runners))
+	for i, r := range runners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done[i], errs[i] = r.Up(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("runner %d: %v", i, err)
+		}
+	}
+	migrations, _ := Load()
+	if got := len(done[0]) + len(done[1]); got != len(migrations) {
+		t.Errorf("runners applied %d and %d migrations, want %d in total", len(done[0]), len(done[1]), len(migrations))
+	}
+	if got := appliedVersions(t, runners[0]); len(got) != len(migrations) {
+		t.Errorf("schema_migrations has %v, want every version up to %d", got, len(migrations))
+	}
+}
+
+// A database set up by hand from the 2.1 schema is adopted at the baseline
+// and still gets every table added since.
+func TestAdoptedBaselineGetsLaterTables(t *testing.T) {
+	url := testDatabase(t)
+	migrations, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exec(t, url, `CREATE SCHEMA shizuku; SET search_path = shizuku, public; `+migrations[baselineVersion-1].up)
+
+	r := connect(t, url)
+	done, err := r.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(migrations)-1 {
+		t.Errorf("applied %d migrations after adopting the baseline, want %d", len(done), len(migrations)-1)
+	}
+
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close(ctx)
+	for _, table := range []string{"qc_flags_manual", "saved_views", "sensor_location_history", "measurement_repairs", "grid_run_summary", "retention_jobs", "feeds", "ingest_log", "sensor_changes"} {
+		var exists bool
+		if err := conn.QueryRow(ctx, `SELECT to_regclass('shizuku.'||$1) IS NOT NULL`, table).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("table %s missing after migrating an adopted baseline", table)
+		}
+	}
+}
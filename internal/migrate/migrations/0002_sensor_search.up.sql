@@ -0,0 +1,5 @@
+-- Sensor search (GET /api/v1/core/sensors?q=...) matches names, barrios and
+-- cities ignoring accents and ranks them by trigram similarity. Installed in
+-- public so the unqualified function names resolve for every connection.
+CREATE EXTENSION IF NOT EXISTS unaccent WITH SCHEMA public;
+CREATE EXTENSION IF NOT EXISTS pg_trgm WITH SCHEMA public;
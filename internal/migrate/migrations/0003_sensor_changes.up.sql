@@ -0,0 +1,13 @@
+-- Field-level history of sensor metadata (renames, comuna reassignments),
+-- appended by the watcher before it upserts changed sensors and served by
+-- GET /api/v1/core/sensors/:id/changes.
+CREATE TABLE IF NOT EXISTS sensor_changes (
+    id          BIGSERIAL PRIMARY KEY,
+    sensor_id   TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    field       TEXT NOT NULL,
+    old_value   TEXT,
+    new_value   TEXT,
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS sensor_changes_sensor_idx ON sensor_changes(sensor_id, changed_at DESC, id DESC);
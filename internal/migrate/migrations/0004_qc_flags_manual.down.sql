@@ -0,0 +1 @@
+DROP TABLE IF EXISTS qc_flags_manual;
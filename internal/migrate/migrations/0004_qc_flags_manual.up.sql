@@ -0,0 +1,22 @@
+-- Manual QC judgments submitted by reviewers through the API
+CREATE TABLE IF NOT EXISTS qc_flags_manual (
+    id              BIGSERIAL PRIMARY KEY,
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    ts              TIMESTAMPTZ NOT NULL,
+    flag            TEXT NOT NULL CHECK (flag IN ('valid', 'invalid')),
+    note            TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT qc_flags_manual_unique UNIQUE (sensor_id, ts)
+);
+
+DROP TRIGGER IF EXISTS qc_flags_manual_set_updated_at ON qc_flags_manual;
+CREATE TRIGGER qc_flags_manual_set_updated_at
+BEFORE UPDATE ON qc_flags_manual
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+CREATE INDEX IF NOT EXISTS qc_flags_manual_sensor_ts_idx ON qc_flags_manual(sensor_id, ts DESC);
+
+COMMENT ON TABLE qc_flags_manual IS 'Reviewer judgments on raw measurements, consumed by the cleaning pipeline';
+COMMENT ON COLUMN qc_flags_manual.flag IS 'Reviewer judgment: "valid" or "invalid"';
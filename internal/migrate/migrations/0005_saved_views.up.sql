@@ -0,0 +1,19 @@
+-- Named query definitions executed server-side for dashboards
+CREATE TABLE IF NOT EXISTS saved_views (
+    slug            TEXT PRIMARY KEY,
+    name            TEXT NOT NULL,
+    endpoint        TEXT NOT NULL,
+    definition      JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+DROP TRIGGER IF EXISTS saved_views_set_updated_at ON saved_views;
+CREATE TRIGGER saved_views_set_updated_at
+BEFORE UPDATE ON saved_views
+FOR EACH ROW
+EXECUTE FUNCTION set_updated_at();
+
+COMMENT ON TABLE saved_views IS 'Persisted API query definitions, validated on creation and executed via /api/v1/views/:slug/execute';
+COMMENT ON COLUMN saved_views.endpoint IS 'Endpoint type the view runs against (measurements, grid_timestamps, realtime_now, sensor_events)';
+COMMENT ON COLUMN saved_views.definition IS 'Sensors, filters and optional relative window of the saved query';
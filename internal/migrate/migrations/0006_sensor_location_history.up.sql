@@ -0,0 +1,16 @@
+-- Coordinates a sensor has reported over time; the sensors table keeps only
+-- the current location
+CREATE TABLE IF NOT EXISTS sensor_location_history (
+    id              BIGSERIAL PRIMARY KEY,
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    lat             DOUBLE PRECISION NOT NULL,
+    lon             DOUBLE PRECISION NOT NULL,
+    effective_from  TIMESTAMPTZ NOT NULL,
+    recorded_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT sensor_location_history_unique UNIQUE (sensor_id, effective_from)
+);
+
+CREATE INDEX IF NOT EXISTS sensor_location_history_sensor_idx ON sensor_location_history(sensor_id, effective_from DESC);
+
+COMMENT ON TABLE sensor_location_history IS 'Sensor coordinates with the time each became effective, appended by the watcher on relocation';
+COMMENT ON COLUMN sensor_location_history.effective_from IS 'First time the sensor was observed at this location';
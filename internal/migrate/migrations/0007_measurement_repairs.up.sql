@@ -0,0 +1,17 @@
+-- Audit log of after-the-fact corrections to stored measurements
+CREATE TABLE IF NOT EXISTS measurement_repairs (
+    id              BIGSERIAL PRIMARY KEY,
+    measurement_id  BIGINT NOT NULL,
+    table_name      TEXT NOT NULL CHECK (table_name IN ('raw_measurements', 'clean_measurements')),
+    sensor_id       TEXT NOT NULL REFERENCES sensors(id) ON DELETE CASCADE,
+    ts              TIMESTAMPTZ NOT NULL,
+    old_value_mm    DOUBLE PRECISION,
+    new_value_mm    DOUBLE PRECISION,
+    reason          TEXT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS measurement_repairs_sensor_ts_idx ON measurement_repairs(sensor_id, ts DESC);
+
+COMMENT ON TABLE measurement_repairs IS 'One row per measurement row changed through PATCH /api/v1/admin/measurements';
+COMMENT ON COLUMN measurement_repairs.new_value_mm IS 'Value after the repair; NULL when the value was nulled';
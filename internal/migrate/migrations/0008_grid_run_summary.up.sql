@@ -0,0 +1,11 @@
+-- Outlier-robust network statistics per grid run, computed by the API on the
+-- first listing that includes the run (aggregates of done runs are final)
+CREATE TABLE IF NOT EXISTS grid_run_summary (
+    grid_run_id             BIGINT PRIMARY KEY REFERENCES grid_runs(id) ON DELETE CASCADE,
+    p90_mm_h                DOUBLE PRECISION NOT NULL,
+    winsorized_mean_mm_h    DOUBLE PRECISION NOT NULL,
+    computed_at             TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE grid_run_summary IS 'Cached p90 and winsorized mean of grid_sensor_aggregates.avg_mm_h per grid run';
+COMMENT ON COLUMN grid_run_summary.winsorized_mean_mm_h IS 'Mean of avg_mm_h after clamping values to the run''s 10th-90th percentile range';
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS retention_jobs;
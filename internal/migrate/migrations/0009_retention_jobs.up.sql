@@ -0,0 +1,22 @@
+-- Operator-triggered deletions of old measurements; each job records what it
+-- matched and deleted so removals stay auditable
+CREATE TABLE IF NOT EXISTS retention_jobs (
+    id              BIGSERIAL PRIMARY KEY,
+    table_name      TEXT NOT NULL CHECK (table_name IN ('clean_measurements')),
+    before_ts       TIMESTAMPTZ NOT NULL,
+    dry_run         BOOLEAN NOT NULL DEFAULT FALSE,
+    status          TEXT NOT NULL CHECK (status IN ('running', 'done', 'failed')),
+    matched_rows    BIGINT NOT NULL DEFAULT 0,
+    deleted_rows    BIGINT NOT NULL DEFAULT 0,
+    batches         INTEGER NOT NULL DEFAULT 0,
+    error           TEXT,
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    finished_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS retention_jobs_started_idx ON retention_jobs(started_at DESC);
+
+COMMENT ON TABLE retention_jobs IS 'Retention runs started through POST /api/v1/admin/retention/run';
+COMMENT ON COLUMN retention_jobs.matched_rows IS 'Rows older than before_ts when the job started';
+COMMENT ON COLUMN retention_jobs.deleted_rows IS 'Rows deleted so far; updated after every batch';
@@ -0,0 +1,17 @@
+-- Feed definitions registered by the watcher on startup, with the outcome of
+-- the latest cycle
+CREATE TABLE IF NOT EXISTS feeds (
+    name                TEXT PRIMARY KEY,
+    url                 TEXT NOT NULL,
+    network             TEXT,
+    cadence_seconds     INTEGER NOT NULL,
+    last_success_at     TIMESTAMPTZ,
+    last_error_at       TIMESTAMPTZ,
+    last_error          TEXT,
+    registered_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE feeds IS 'Upstream feeds ingested by the watcher; upserted by name on startup';
+COMMENT ON COLUMN feeds.url IS 'Feed URL as configured; may embed credentials, redact before display';
+COMMENT ON COLUMN feeds.cadence_seconds IS 'Interval after which an unchanged reading is stored again (WATCHER_MIN_INTERVAL)';
@@ -0,0 +1,16 @@
+-- One row per watcher cycle and feed
+CREATE TABLE IF NOT EXISTS ingest_log (
+    id                  BIGSERIAL PRIMARY KEY,
+    feed_name           TEXT NOT NULL REFERENCES feeds(name) ON DELETE CASCADE,
+    started_at          TIMESTAMPTZ NOT NULL,
+    finished_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    success             BOOLEAN NOT NULL,
+    stations            INTEGER,
+    skipped             INTEGER,
+    inserted            INTEGER,
+    error               TEXT
+);
+
+CREATE INDEX IF NOT EXISTS ingest_log_feed_started_idx ON ingest_log(feed_name, started_at DESC);
+
+COMMENT ON TABLE ingest_log IS 'Outcome of every watcher cycle per feed';
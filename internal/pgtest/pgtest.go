@@ -0,0 +1,71 @@
+// Package pgtest provides the disposable Postgres (with PostGIS) behind the
+// integration tests of the API, the watcher and the migration runner. A
+// database named by an environment variable is used as is; otherwise one is
+// started in a container, so the suites run wherever Docker is available.
+package pgtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Image is the container started when no database URL is configured. The
+// schema needs PostGIS.
+const Image = "postgis/postgis:16-3.4"
+
+// ErrUnavailable is returned when no database URL is configured and no
+// container could be started, typically because Docker is missing. Tests
+// should skip rather than fail.
+var ErrUnavailable = errors.New("pgtest: no test database: set the URL variable or make Docker available")
+
+// Start returns the URL of a disposable database. When envVar is set its
+// value is returned and cleanup does nothing; the caller owns that database
+// and must not share it. Otherwise a PostGIS container is started and
+// cleanup terminates it.
+func Start(ctx context.Context, envVar string) (url string, cleanup func(), err error) {
+	if url := os.Getenv(envVar); url != "" {
+		return url, func() {}, nil
+	}
+
+	// testcontainers panics on some hosts without a Docker socket instead of
+	// returning an error.
+	defer func() {
+		if r := recover(); r != nil {
+			url, cleanup, err = "", nil, fmt.Errorf("%w: %v", ErrUnavailable, r)
+		}
+	}()
+
+	container, err := postgres.Run(ctx, Image,
+		postgres.WithDatabase("shizuku_test"),
+		postgres.WithUsername("shizuku"),
+		postgres.WithPassword("shizuku"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).
+				WithStartupTimeout(2*time.Minute),
+		),
+	)
+	if err != nil {
+		if container != nil {
+			_ = container.Terminate(context.Background())
+		}
+		return "", nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	url, err = container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		_ = container.Terminate(context.Background())
+		return "", nil, err
+	}
+	cleanup = func() {
+		_ = container.Terminate(context.Background())
+	}
+	return url, cleanup, nil
+}
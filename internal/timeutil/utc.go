@@ -0,0 +1,43 @@
+// Package timeutil holds the timestamp policy shared by the ingestion paths
+// of the API and the watcher: every stored instant is UTC, and a timestamp
+// without an offset is only accepted when its zone is declared explicitly.
+package timeutil
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNaiveTimestamp rejects a timestamp that has no offset and no declared
+// zone; guessing would store it shifted by the local offset.
+var ErrNaiveTimestamp = errors.New("timestamp has no UTC offset: include one (e.g. Z or -05:00) or declare a timezone")
+
+// naiveLayouts are the offset-less forms accepted when a zone is declared.
+var naiveLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// ParseUTC parses raw and returns it in UTC. RFC3339 timestamps carry their
+// own offset and zone is ignored; naive timestamps are read as wall-clock
+// time in zone and rejected with ErrNaiveTimestamp when zone is nil.
+func ParseUTC(raw string, zone *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		return t.UTC(), nil
+	}
+	for _, layout := range naiveLayouts {
+		if _, err := time.Parse(layout, raw); err != nil {
+			continue
+		}
+		if zone == nil {
+			return time.Time{}, fmt.Errorf("%q: %w", raw, ErrNaiveTimestamp)
+		}
+		t, err := time.ParseInLocation(layout, raw, zone)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q, expected RFC3339", raw)
+}
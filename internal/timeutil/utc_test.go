@@ -0,0 +1,64 @@
+package timeutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseUTC(t *testing.T) {
+	bogota := time.FixedZone("COT", -5*60*60)
+	noon := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		raw     string
+		zone    *time.Location
+		want    time.Time
+		wantErr error
+		// wantAny expects an invalid timestamp error, not ErrNaiveTimestamp.
+		wantAny bool
+	}{
+		{name: "Z", raw: "2025-10-01T12:00:00Z", want: noon},
+		{name: "+00:00", raw: "2025-10-01T12:00:00+00:00", want: noon},
+		{name: "-05:00", raw: "2025-10-01T07:00:00-05:00", want: noon},
+		{name: "offset wins over declared zone", raw: "2025-10-01T12:00:00Z", zone: bogota, want: noon},
+		{name: "fractional seconds", raw: "2025-10-01T12:00:00.123456789Z", want: noon.Add(123456789 * time.Nanosecond)},
+		{name: "fractional seconds with offset", raw: "2025-10-01T07:00:00.5-05:00", want: noon.Add(500 * time.Millisecond)},
+		{name: "naive in declared zone", raw: "2025-10-01T07:00:00", zone: bogota, want: noon},
+		{name: "naive with space in declared zone", raw: "2025-10-01 07:00:00.25", zone: bogota, want: noon.Add(250 * time.Millisecond)},
+		{name: "naive without zone", raw: "2025-10-01T12:00:00", wantErr: ErrNaiveTimestamp},
+		{name: "naive with space without zone", raw: "2025-10-01 12:00:00", wantErr: ErrNaiveTimestamp},
+		// Dates carry no time of day, so they are never read as midnight.
+		{name: "date only", raw: "2025-10-01", wantAny: true},
+		{name: "date only in declared zone", raw: "2025-10-01", zone: bogota, wantAny: true},
+		{name: "empty", raw: "", wantAny: true},
+		{name: "garbage", raw: "yesterday", wantAny: true},
+		{name: "unix seconds", raw: "1759320000", wantAny: true},
+		{name: "out of range", raw: "2025-13-01T12:00:00Z", wantAny: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseUTC(tc.raw, tc.zone)
+			switch {
+			case tc.wantErr != nil:
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("ParseUTC(%q): err %v, want %v", tc.raw, err, tc.wantErr)
+				}
+			case tc.wantAny:
+				if err == nil {
+					t.Fatalf("ParseUTC(%q) = %s, want an error", tc.raw, got)
+				}
+				if errors.Is(err, ErrNaiveTimestamp) {
+					t.Errorf("ParseUTC(%q): %v, want an invalid timestamp error", tc.raw, err)
+				}
+			default:
+				if err != nil {
+					t.Fatalf("ParseUTC(%q): %v", tc.raw, err)
+				}
+				if !got.Equal(tc.want) || got.Location() != time.UTC {
+					t.Errorf("ParseUTC(%q) = %s, want %s in UTC", tc.raw, got, tc.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// Package units holds the rainfall unit conversions shared by the watcher and
+// the API. Feed and clean values are depths per reporting interval, grid
+// aggregates are rates, and mixing the two is the classic factor-of-12 bug
+// (a 5-minute depth read as mm/h), so conversions go through the typed values
+// here instead of inline arithmetic. Durations are plain time.Duration.
+package units
+
+import "time"
+
+// SIATAInterval is the SIATA reporting cadence: each feed value is the depth
+// that fell during one such interval.
+const SIATAInterval = 5 * time.Minute
+
+// Accumulation is a rainfall depth in millimetres.
+type Accumulation float64
+
+// Intensity is a rainfall rate in millimetres per hour.
+type Intensity float64
+
+// MM returns the depth in millimetres.
+func (a Accumulation) MM() float64 { return float64(a) }
+
+// MMPerHour returns the rate in millimetres per hour.
+func (i Intensity) MMPerHour() float64 { return float64(i) }
+
+// Over returns the mean intensity of a depth that fell during d, or 0 for a
+// non-positive d.
+func (a Accumulation) Over(d time.Duration) Intensity {
+	if d <= 0 {
+		return 0
+	}
+	return Intensity(float64(a) * float64(time.Hour) / float64(d))
+}
+
+// Over returns the depth that falls at this intensity during d, or 0 for a
+// non-positive d.
+func (i Intensity) Over(d time.Duration) Accumulation {
+	if d <= 0 {
+		return 0
+	}
+	return Accumulation(float64(i) * float64(d) / float64(time.Hour))
+}
@@ -0,0 +1,90 @@
+package units
+
+import (
+	"math"
+	"testing"
+	"testing/quick"
+	"time"
+)
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+// A 5-minute SIATA depth read as mm/h was off by exactly 12; converting it
+// properly must apply that factor and nothing else.
+func TestSIATAIntervalFactorOfTwelve(t *testing.T) {
+	if got := Accumulation(1).Over(SIATAInterval).MMPerHour(); got != 12 {
+		t.Errorf("1 mm per 5 min = %v mm/h, want 12", got)
+	}
+	if got := Intensity(12).Over(SIATAInterval).MM(); got != 1 {
+		t.Errorf("12 mm/h over 5 min = %v mm, want 1", got)
+	}
+	if got := Intensity(2).Over(24 * time.Hour).MM(); got != 48 {
+		t.Errorf("2 mm/h over a day = %v mm, want 48", got)
+	}
+}
+
+func TestNonPositiveDuration(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Minute} {
+		if got := Accumulation(3).Over(d); got != 0 {
+			t.Errorf("Accumulation.Over(%v) = %v, want 0", d, got)
+		}
+		if got := Intensity(3).Over(d); got != 0 {
+			t.Errorf("Intensity.Over(%v) = %v, want 0", d, got)
+		}
+	}
+}
+
+// duration maps an arbitrary generated value to a positive window between a
+// second and a week.
+func duration(n uint32) time.Duration {
+	return time.Second + time.Duration(n)%(7*24*time.Hour)
+}
+
+// depth maps an arbitrary generated value to a plausible depth in mm.
+func depth(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return math.Mod(math.Abs(v), 500)
+}
+
+func TestRoundTripProperty(t *testing.T) {
+	depthRoundTrip := func(v float64, n uint32) bool {
+		mm, d := depth(v), duration(n)
+		return approxEqual(Accumulation(mm).Over(d).Over(d).MM(), mm)
+	}
+	rateRoundTrip := func(v float64, n uint32) bool {
+		rate, d := depth(v), duration(n)
+		return approxEqual(Intensity(rate).Over(d).Over(d).MMPerHour(), rate)
+	}
+	for name, prop := range map[string]any{"depth": depthRoundTrip, "rate": rateRoundTrip} {
+		if err := quick.Check(prop, nil); err != nil {
+			t.Errorf("%s round trip: %v", name, err)
+		}
+	}
+}
+
+// The same depth spread over a window k times longer is a rate k times lower,
+// and depths add up across consecutive windows at the same rate.
+func TestScalingProperty(t *testing.T) {
+	scaling := func(v float64, n uint32, k uint8) bool {
+		mm, d := depth(v), duration(n)
+		factor := time.Duration(k%16) + 1
+		return approxEqual(
+			Accumulation(mm).Over(d).MMPerHour(),
+			float64(factor)*Accumulation(mm).Over(d*factor).MMPerHour(),
+		)
+	}
+	additive := func(v float64, n, m uint32) bool {
+		rate, d1, d2 := Intensity(depth(v)), duration(n), duration(m)
+		return approxEqual(rate.Over(d1).MM()+rate.Over(d2).MM(), rate.Over(d1+d2).MM())
+	}
+	if err := quick.Check(scaling, nil); err != nil {
+		t.Errorf("scaling: %v", err)
+	}
+	if err := quick.Check(additive, nil); err != nil {
+		t.Errorf("additivity: %v", err)
+	}
+}
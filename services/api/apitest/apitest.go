@@ -0,0 +1,257 @@
+// Package apitest drives the API's gin engine against a seeded, disposable
+// database and compares responses with golden JSON files. A new endpoint is
+// covered by adding a case file and its golden output under testdata.
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/migrate"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/pgtest"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// DatabaseURLEnv optionally names a disposable Postgres (with PostGIS). Seed
+// drops its shizuku schema, so never point it at shared data. Without it a
+// container is started.
+const DatabaseURLEnv = "API_TEST_DATABASE_URL"
+
+// ErrNoTestDatabase is returned when DatabaseURLEnv is unset and no container
+// could be started; callers should skip rather than fail.
+var ErrNoTestDatabase = pgtest.ErrUnavailable
+
+// Seed provides a disposable database (see pgtest.Start), builds the shizuku
+// schema with the embedded migrations, as a deployment would, loads the
+// fixture SQL files in order and returns a Store connected to the result.
+// cleanup closes the store and stops the container, if one was started.
+func Seed(ctx context.Context, fixtures ...string) (store *db.Store, cleanup func(), err error) {
+	store, _, cleanup, err = SeedURL(ctx, fixtures...)
+	return store, cleanup, err
+}
+
+// SeedURL is Seed that also returns the database URL, for tests that change
+// rows between requests with Exec.
+func SeedURL(ctx context.Context, fixtures ...string) (store *db.Store, url string, cleanup func(), err error) {
+	url, stop, err := pgtest.Start(ctx, DatabaseURLEnv)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	defer func() {
+		if err != nil {
+			stop()
+		}
+	}()
+
+	if err := exec(ctx, url, `DROP SCHEMA IF EXISTS shizuku CASCADE`); err != nil {
+		return nil, "", nil, fmt.Errorf("reset schema: %w", err)
+	}
+	if err := migrate.Run(ctx, url); err != nil {
+		return nil, "", nil, err
+	}
+	for _, path := range fixtures {
+		sql, err := os.ReadFile(path)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if err := Exec(ctx, url, string(sql)); err != nil {
+			return nil, "", nil, fmt.Errorf("apply %s: %w", path, err)
+		}
+	}
+
+	store, err = db.New(ctx, url, 1)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return store, url, func() {
+		store.Close()
+		stop()
+	}, nil
+}
+
+// Exec runs fixture-style sql, resolved against the shizuku schema, on the
+// seeded database at url.
+func Exec(ctx context.Context, url, sql string) error {
+	return exec(ctx, url, `SET search_path TO shizuku, public; `+sql)
+}
+
+// exec runs sql on a connection of its own.
+func exec(ctx context.Context, url, sql string) error {
+	conn, err := pgx.Connect(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// Case is one request and the golden file its response is compared with.
+// Cases are stored as JSON under testdata/cases.
+type Case struct {
+	Name   string            `json:"-"`
+	Method string            `json:"method"`
+	Path   string            `json:"path"`
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+	Status int               `json:"status"`
+	// Golden is the expected response body, relative to the case file;
+	// defaults to <name>.golden.json.
+	Golden string `json:"golden,omitempty"`
+
+	dir string
+}
+
+// LoadCases reads every *.json case in dir, sorted by name.
+func LoadCases(dir string) ([]Case, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	cases := make([]Case, 0, len(paths))
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".golden.json") {
+			continue
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var tc Case
+		if err := json.Unmarshal(raw, &tc); err != nil {
+			return nil, fmt.Errorf("decode case %s: %w", path, err)
+		}
+		tc.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		tc.dir = dir
+		if tc.Method == "" {
+			tc.Method = http.MethodGet
+		}
+		if tc.Golden == "" {
+			tc.Golden = tc.Name + ".golden.json"
+		}
+		cases = append(cases, tc)
+	}
+	return cases, nil
+}
+
+// Do sends the case's request through handler and returns the recorded response.
+func (tc Case) Do(handler http.Handler) *httptest.ResponseRecorder {
+	var body *bytes.Reader
+	if len(tc.Body) > 0 {
+		body = bytes.NewReader(tc.Body)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(tc.Method, tc.Path, body)
+	if len(tc.Body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range tc.Header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// Check runs the case and compares status and normalized body with the golden
+// file. With update, the golden file is rewritten instead.
+func (tc Case) Check(handler http.Handler, update bool) error {
+	rec := tc.Do(handler)
+	if rec.Code != tc.Status {
+		return fmt.Errorf("%s: status %d, want %d (body %s)", tc.Name, rec.Code, tc.Status, rec.Body.String())
+	}
+
+	got, err := NormalizeJSON(rec.Body.Bytes())
+	if err != nil {
+		return fmt.Errorf("%s: %w", tc.Name, err)
+	}
+
+	path := filepath.Join(tc.dir, tc.Golden)
+	if update {
+		return os.WriteFile(path, got, 0o644)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", tc.Name, err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+		return fmt.Errorf("%s: response differs from %s:\n%s", tc.Name, tc.Golden, got)
+	}
+	return nil
+}
+
+// volatileTimestamp matches RFC3339 timestamps produced at request time.
+var volatileTimestamp = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+// volatileKeys are fields whose values depend on when the suite runs rather
+// than on the seeded data.
+var volatileKeys = map[string]bool{
+	"created_at":   true,
+	"updated_at":   true,
+	"generated_at": true,
+	"since":        true,
+	"start":        true,
+	"end":          true,
+}
+
+// NormalizeJSON re-indents body with sorted keys, renders timestamps in UTC
+// and replaces those under volatile keys with a placeholder so golden files
+// stay stable across runs and host time zones.
+func NormalizeJSON(body []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %w", err)
+	}
+	v = normalize("", v)
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func normalize(key string, v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			t[k] = normalize(k, val)
+		}
+		return t
+	case []any:
+		for i, val := range t {
+			t[i] = normalize(key, val)
+		}
+		return t
+	case string:
+		if !volatileTimestamp.MatchString(t) {
+			return t
+		}
+		if volatileKeys[key] {
+			return "<timestamp>"
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, t); err == nil {
+			return ts.UTC().Format(time.RFC3339Nano)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,823 @@
+package apitest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/apitest"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	httpserver "github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/http"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current responses")
+
+const casesDir = "testdata/cases"
+
+// newStore seeds a database with testdata/seed.sql and the extra fixtures
+// and returns a store on it with the database URL.
+func newStore(t *testing.T, fixtures ...string) (*db.Store, string) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+	defer cancel()
+
+	store, url, cleanup, err := apitest.SeedURL(ctx, append([]string{"testdata/seed.sql"}, fixtures...)...)
+	if errors.Is(err, apitest.ErrNoTestDatabase) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	t.Cleanup(cleanup)
+	return store, url
+}
+
+// newHandler seeds a database like newStore and returns the API's engine on
+// top of it.
+func newHandler(t *testing.T, fixtures ...string) http.Handler {
+	t.Helper()
+	store, _ := newStore(t, fixtures...)
+	return newEngine(t, store)
+}
+
+// newEngine returns the API's engine on store, configured with the defaults.
+func newEngine(t *testing.T, store *db.Store) http.Handler {
+	t.Helper()
+
+	// The store is already connected; Load only insists on the URLs.
+	t.Setenv("DB_ENV_VARIABLE", "")
+	t.Setenv("DATABASE_URL", "postgresql://apitest@localhost/unused")
+	t.Setenv("VERCEL_BLOB_BASE_URL", "http://127.0.0.1:1")
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+	srv, err := httpserver.New(cfg, store)
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	return srv.Engine()
+}
+
+// defaultCleanModes are the API_DEFAULT_CLEAN values the case suite runs
+// under. Cases directly under testdata/cases must answer the same under
+// every default; those whose response depends on it live in
+// testdata/cases/default_clean_<mode>.
+var defaultCleanModes = []string{"true", "false", "auto"}
+
+// TestCases replays every case under testdata/cases once per deployment
+// default, among them the clean/raw/auto switching and the pagination
+// envelope of the sensor measurements endpoint. Run with -update to rewrite
+// the golden files.
+func TestCases(t *testing.T) {
+	store, _ := newStore(t)
+	shared, err := apitest.LoadCases(casesDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shared) == 0 {
+		t.Fatal("no cases found")
+	}
+	for _, mode := range defaultCleanModes {
+		t.Run("default_clean="+mode, func(t *testing.T) {
+			own, err := apitest.LoadCases(filepath.Join(casesDir, "default_clean_"+mode))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(own) == 0 {
+				t.Fatalf("no cases for default clean=%s", mode)
+			}
+			t.Setenv("API_DEFAULT_CLEAN", mode)
+			handler := newEngine(t, store)
+			for _, tc := range append(slices.Clone(shared), own...) {
+				t.Run(tc.Name, func(t *testing.T) {
+					if err := tc.Check(handler, *update); err != nil {
+						t.Error(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// The accumulation window trails the request time, so its fixture is relative
+// to now() and checked field by field instead of against a golden file.
+func TestSensorAccumulationGappyHour(t *testing.T) {
+	handler := newHandler(t, "testdata/accumulation.sql")
+
+	rec := apitest.Case{Path: "/api/v1/core/sensors/siata_3/accumulation?window=1h"}.Do(handler)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Data struct {
+			TotalMM           float64 `json:"total_mm"`
+			SampleCount       int     `json:"sample_count"`
+			CoveredSeconds    int64   `json:"covered_seconds"`
+			CoverageRatio     float64 `json:"coverage_ratio"`
+			LongestGapSeconds int64   `json:"longest_gap_seconds"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	got := body.Data
+
+	// The sample before the window is left out.
+	if got.SampleCount != 6 || math.Abs(got.TotalMM-3) > 1e-9 {
+		t.Errorf("%v mm from %d samples, want 3 mm from 6", got.TotalMM, got.SampleCount)
+	}
+	// The outage counts for the 10-minute sample gap allowance only: about
+	// 5 + 5 + 5 + 10 + 5 + 5 minutes, the first span shrinking by however
+	// long the request took after seeding.
+	if got.CoveredSeconds < 34*60 || got.CoveredSeconds > 35*60 {
+		t.Errorf("covered %ds, want about %d", got.CoveredSeconds, 35*60)
+	}
+	if got.CoverageRatio < 34.0/60 || got.CoverageRatio > 35.0/60 {
+		t.Errorf("coverage ratio %v, want about %v", got.CoverageRatio, 35.0/60)
+	}
+	if got.LongestGapSeconds != 30*60 {
+		t.Errorf("longest gap %ds, want %d", got.LongestGapSeconds, 30*60)
+	}
+
+	rec = apitest.Case{Path: "/api/v1/core/sensors/siata_3/accumulation?window=2d"}.Do(handler)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("window=2d: status %d, want 400", rec.Code)
+	}
+}
+
+// A single glitching gauge dominates the max and the plain mean of a grid
+// run, but not its p90 and winsorized mean, which are also served from the
+// summary cache on later listings.
+func TestGridRunSummaryDiscountsOutlier(t *testing.T) {
+	store, _ := newStore(t, "testdata/outlier.sql")
+	ctx := context.Background()
+	start := time.Date(2025, 10, 2, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+
+	for pass := 1; pass <= 2; pass++ {
+		page, err := store.ListGridTimestampsWithAggregates(ctx, 10, 0, &start, &end, nil, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Grids) != 1 {
+			t.Fatalf("pass %d: %d runs, want 1", pass, len(page.Grids))
+		}
+		g := page.Grids[0]
+		if g.SensorCount != 11 || g.MaxRainfallMmH == nil || *g.MaxRainfallMmH != 500 {
+			t.Errorf("pass %d: %d sensors, max %v; want 11 and 500", pass, g.SensorCount, g.MaxRainfallMmH)
+		}
+		if g.P90RainfallMmH == nil || math.Abs(*g.P90RainfallMmH-10) > 1e-9 {
+			t.Errorf("pass %d: p90 %v, want 10", pass, g.P90RainfallMmH)
+		}
+		if g.WinsorizedMeanMmH == nil || math.Abs(*g.WinsorizedMeanMmH-6) > 1e-9 {
+			t.Errorf("pass %d: winsorized mean %v, want 6", pass, g.WinsorizedMeanMmH)
+		}
+	}
+}
+
+func TestDuplicateGridRunsPreferLatestDone(t *testing.T) {
+	store, _ := newStore(t, "testdata/duplicates.sql")
+	handler := newEngine(t, store)
+	ctx := context.Background()
+	newer := time.Date(2025, 10, 2, 6, 0, 0, 0, time.UTC)
+	tied := newer.Add(time.Hour)
+
+	preferred := map[time.Time]int{}
+	for _, ts := range []time.Time{newer, tied} {
+		run, err := store.GetGridRunByTimestamp(ctx, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if run.Resolution != 250 || run.Status != "done" {
+			t.Errorf("%s: got the %s %d m run, want the done 250 m run", ts, run.Status, run.Resolution)
+		}
+		preferred[ts] = run.ID
+
+		var body struct {
+			Data struct {
+				ID         int `json:"id"`
+				Resolution int `json:"resolution"`
+			} `json:"data"`
+		}
+		getJSON(t, handler, "/api/v1/grid/"+ts.Format(time.RFC3339), &body)
+		if body.Data.ID != preferred[ts] || body.Data.Resolution != 250 {
+			t.Errorf("%s: endpoint returned run %d (%d m), want run %d (250 m)", ts, body.Data.ID, body.Data.Resolution, preferred[ts])
+		}
+	}
+
+	start, end := newer, tied
+	page, err := store.ListGridTimestampsWithAggregates(ctx, 10, 0, &start, &end, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.TotalCount != 2 || len(page.Grids) != 2 {
+		t.Fatalf("listing has %d rows (total %d), want one per ts", len(page.Grids), page.TotalCount)
+	}
+	for _, g := range page.Grids {
+		if g.ID != preferred[g.Timestamp] {
+			t.Errorf("listing %s: run %d, want %d", g.Timestamp, g.ID, preferred[g.Timestamp])
+		}
+	}
+
+	groups, err := store.ListDuplicateGridRuns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("%d duplicate groups, want 2", len(groups))
+	}
+	for _, g := range groups {
+		var marked []int
+		for _, r := range g.Runs {
+			if r.Preferred {
+				marked = append(marked, r.ID)
+			}
+		}
+		if len(marked) != 1 || marked[0] != preferred[g.Timestamp] {
+			t.Errorf("group %s: preferred %v, want [%d]", g.Timestamp, marked, preferred[g.Timestamp])
+		}
+	}
+}
+
+func TestRepairMeasurements(t *testing.T) {
+	store, _ := newStore(t)
+	t.Setenv("API_WRITE_TOKEN", "repair-token")
+	handler := newEngine(t, store)
+
+	repair := func(body string) *httptest.ResponseRecorder {
+		return apitest.Case{
+			Method: http.MethodPatch,
+			Path:   "/api/v1/admin/measurements",
+			Header: map[string]string{"Authorization": "Bearer repair-token"},
+			Body:   json.RawMessage(body),
+		}.Do(handler)
+	}
+	type result struct {
+		Status    string `json:"status"`
+		RawRows   int64  `json:"raw_rows"`
+		CleanRows int64  `json:"clean_rows"`
+	}
+	var resp struct {
+		Data []result `json:"data"`
+	}
+
+	rec := repair(`[{"sensor_id": "siata_1", "ts": "2025-10-01T07:05:00-05:00", "set_value_mm": 0.5, "reason": "gauge hosed down"}]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("repair: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := []result{{"applied", 1, 1}}; !slices.Equal(resp.Data, want) {
+		t.Errorf("repair: got %+v, want %+v", resp.Data, want)
+	}
+
+	var raw struct {
+		Data []struct {
+			TS      time.Time `json:"ts"`
+			ValueMM *float64  `json:"value_mm"`
+		} `json:"data"`
+	}
+	getJSON(t, handler, "/api/v1/core/sensors/siata_1/measurements?start=2025-10-01T12:04:00Z&end=2025-10-01T12:06:00Z&as_of=2099-01-01T00:00:00Z&clean=false", &raw)
+	if len(raw.Data) != 1 || raw.Data[0].ValueMM == nil || *raw.Data[0].ValueMM != 0.5 {
+		t.Errorf("stored raw row after repair: %+v, want 0.5 mm", raw.Data)
+	}
+
+	// Nothing matched: the sensor has no row at 12:20.
+	rec = repair(`[{"sensor_id": "siata_1", "ts": "2025-10-01T12:20:00Z", "set_null": true, "reason": "typo"}]`)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing row: status %d, want 404: %s", rec.Code, rec.Body.String())
+	}
+	var errBody struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &errBody); err != nil || errBody.Code != "not_found" {
+		t.Errorf("missing row: body %s, want code not_found", rec.Body.String())
+	}
+
+	// A mixed batch applies what matches and reports the rest per entry;
+	// siata_2 only has a raw row.
+	rec = repair(`[
+		{"sensor_id": "siata_2", "ts": "2025-10-01T12:00:00Z", "set_null": true, "reason": "stuck gauge"},
+		{"sensor_id": "siata_2", "ts": "2025-10-01T12:05:00Z", "set_null": true, "reason": "stuck gauge"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("mixed batch: status %d: %s", rec.Code, rec.Body.String())
+	}
+	resp.Data = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if want := []result{{"applied", 1, 0}, {"not_found", 0, 0}}; !slices.Equal(resp.Data, want) {
+		t.Errorf("mixed batch: got %+v, want %+v", resp.Data, want)
+	}
+
+	// Without the write token the endpoint is closed.
+	rec = apitest.Case{
+		Method: http.MethodPatch,
+		Path:   "/api/v1/admin/measurements",
+		Body:   json.RawMessage(`[{"sensor_id": "siata_1", "ts": "2025-10-01T12:00:00Z", "set_null": true, "reason": "r"}]`),
+	}.Do(handler)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("without token: status %d, want 403", rec.Code)
+	}
+}
+
+// TestSnapshotFiltersMatchBaseline checks that filtering the snapshot by ids
+// or bbox in SQL only drops sensors: every row left must equal the row the
+// unfiltered snapshot reports for that sensor.
+func TestSnapshotFiltersMatchBaseline(t *testing.T) {
+	handler := newHandler(t, "testdata/snapshot.sql")
+	const base = "/api/v1/core/snapshot?ts=2025-10-01T13:00:00Z&clean=false&max_age=0"
+
+	type snapshot struct {
+		Data []json.RawMessage `json:"data"`
+		Meta struct {
+			Sensors          int  `json:"sensors"`
+			RequestedSensors *int `json:"requested_sensors"`
+			MatchedSensors   *int `json:"matched_sensors"`
+		} `json:"meta"`
+	}
+	type position struct {
+		ID  string  `json:"id"`
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+	rowsByID := func(t *testing.T, rows []json.RawMessage) (map[string]string, map[string]position) {
+		t.Helper()
+		raw := make(map[string]string, len(rows))
+		pos := make(map[string]position, len(rows))
+		for _, row := range rows {
+			var p position
+			if err := json.Unmarshal(row, &p); err != nil {
+				t.Fatal(err)
+			}
+			norm, err := apitest.NormalizeJSON(row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw[p.ID] = string(norm)
+			pos[p.ID] = p
+		}
+		return raw, pos
+	}
+
+	var baseline snapshot
+	getJSON(t, handler, base, &baseline)
+	want, positions := rowsByID(t, baseline.Data)
+	if len(want) != 5 || baseline.Meta.MatchedSensors != nil {
+		t.Fatalf("baseline: %d sensors, matched_sensors %v; want 5 and no filter meta", len(want), baseline.Meta.MatchedSensors)
+	}
+
+	// The box holds siata_1 (6.25, -75.56) and snap_a (6.28, -75.52); no
+	// sensor sits on its edges.
+	inBox := func(p position) bool {
+		return p.Lon > -75.58 && p.Lon < -75.50 && p.Lat > 6.22 && p.Lat < 6.30
+	}
+	for _, tc := range []struct {
+		name      string
+		query     string
+		requested int // 0 when the filter has no ids
+		keep      func(p position) bool
+	}{
+		{
+			name:      "ids",
+			query:     "&ids=siata_2,snap_b,missing",
+			requested: 3,
+			keep:      func(p position) bool { return p.ID == "siata_2" || p.ID == "snap_b" },
+		},
+		{
+			name:  "bbox",
+			query: "&bbox=-75.58,6.22,-75.50,6.30",
+			keep:  inBox,
+		},
+		{
+			name:      "ids and bbox",
+			query:     "&ids=siata_1,siata_2,snap_a&bbox=-75.58,6.22,-75.50,6.30",
+			requested: 3,
+			keep:      func(p position) bool { return p.ID != "siata_2" && inBox(p) },
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var filtered snapshot
+			getJSON(t, handler, base+tc.query, &filtered)
+			got, _ := rowsByID(t, filtered.Data)
+
+			wantIDs := 0
+			for id, p := range positions {
+				if !tc.keep(p) {
+					if _, ok := got[id]; ok {
+						t.Errorf("%s passed the filter", id)
+					}
+					continue
+				}
+				wantIDs++
+				if got[id] != want[id] {
+					t.Errorf("%s differs from the baseline:\ngot  %s\nwant %s", id, got[id], want[id])
+				}
+			}
+			if len(got) != wantIDs {
+				t.Errorf("%d rows, want %d", len(got), wantIDs)
+			}
+
+			m := filtered.Meta
+			if m.Sensors != wantIDs || m.MatchedSensors == nil || *m.MatchedSensors != wantIDs {
+				t.Errorf("meta sensors %d, matched %v; want %d", m.Sensors, m.MatchedSensors, wantIDs)
+			}
+			switch {
+			case tc.requested == 0 && m.RequestedSensors != nil:
+				t.Errorf("requested_sensors %d without ids", *m.RequestedSensors)
+			case tc.requested > 0 && (m.RequestedSensors == nil || *m.RequestedSensors != tc.requested):
+				t.Errorf("requested_sensors %v, want %d", m.RequestedSensors, tc.requested)
+			}
+		})
+	}
+}
+
+// Bounding boxes include their edges: sensors exactly on any edge or corner
+// are returned, those 0.001° outside are not.
+func TestBBoxEdgesInclusive(t *testing.T) {
+	store, _ := newStore(t, "testdata/bbox_edges.sql")
+	handler := newEngine(t, store)
+	box := db.BBox{MinLon: -75.60, MinLat: 6.20, MaxLon: -75.50, MaxLat: 6.30}
+	const query = "bbox=-75.60,6.20,-75.50,6.30"
+	inside := []string{"edge_e", "edge_n", "edge_s", "edge_w", "siata_1", "siata_2"}
+
+	sorted := func(ids []string) []string {
+		slices.Sort(ids)
+		return ids
+	}
+
+	var now struct {
+		Data struct {
+			Aggregates []struct {
+				SensorID string `json:"sensor_id"`
+			} `json:"sensor_aggregates"`
+		} `json:"data"`
+		Meta struct {
+			SensorsCount int `json:"sensors_count"`
+			NetworkCount int `json:"network_count"`
+		} `json:"meta"`
+	}
+	getJSON(t, handler, "/api/v1/realtime/now?"+query, &now)
+	var ids []string
+	for _, a := range now.Data.Aggregates {
+		ids = append(ids, a.SensorID)
+	}
+	if got := sorted(ids); !slices.Equal(got, inside) {
+		t.Errorf("realtime now: %v, want %v", got, inside)
+	}
+	if now.Meta.SensorsCount != len(inside) || now.Meta.NetworkCount != 10 {
+		t.Errorf("realtime now meta: %d of %d sensors, want %d of 10", now.Meta.SensorsCount, now.Meta.NetworkCount, len(inside))
+	}
+
+	var sensors struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	getJSON(t, handler, "/api/v1/core/sensors/bbox?min_lon=-75.60&min_lat=6.20&max_lon=-75.50&max_lat=6.30", &sensors)
+	ids = nil
+	for _, s := range sensors.Data {
+		ids = append(ids, s.ID)
+	}
+	if got := sorted(ids); !slices.Equal(got, inside) {
+		t.Errorf("sensors/bbox: %v, want %v", got, inside)
+	}
+
+	// siata_2 has no clean rows, so LatestClean leaves it out.
+	latest, err := store.LatestClean(context.Background(), &box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids = nil
+	for _, m := range latest {
+		ids = append(ids, m.SensorID)
+	}
+	if got, want := sorted(ids), []string{"edge_e", "edge_n", "edge_s", "edge_w", "siata_1"}; !slices.Equal(got, want) {
+		t.Errorf("LatestClean: %v, want %v", got, want)
+	}
+}
+
+// getJSON requests path and decodes the JSON response into v.
+func getJSON(t *testing.T, handler http.Handler, path string, v any) {
+	t.Helper()
+	rec := apitest.Case{Path: path}.Do(handler)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", path, rec.Code, rec.Body.String())
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
+
+// pinnedPage is the part of an offset-paginated response the pinning test
+// looks at; rows carry either ts (measurements) or timestamp (grid runs).
+type pinnedPage struct {
+	Data []struct {
+		TS        time.Time `json:"ts"`
+		Timestamp time.Time `json:"timestamp"`
+	} `json:"data"`
+	Meta struct {
+		AsOf string `json:"as_of"`
+	} `json:"meta"`
+	Pagination struct {
+		TotalCount int `json:"total_count"`
+	} `json:"pagination"`
+}
+
+func (p pinnedPage) times() []string {
+	out := make([]string, len(p.Data))
+	for i, row := range p.Data {
+		ts := row.TS
+		if ts.IsZero() {
+			ts = row.Timestamp
+		}
+		out[i] = ts.UTC().Format("15:04")
+	}
+	return out
+}
+
+// Rows inserted between page fetches shift unpinned offset pages, but not
+// pages that pass back the as_of of the first one.
+func TestOffsetPagesPinnedToAsOf(t *testing.T) {
+	store, url := newStore(t, "testdata/pinned.sql")
+	handler := newEngine(t, store)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		name, path, insert string
+		// first page, pinned second page and unpinned second page
+		page1, pinned, shifted []string
+	}{
+		{
+			name: "measurements",
+			path: "/api/v1/core/sensors/siata_4/measurements?start=2025-10-03T00:00:00Z&end=2025-10-04T00:00:00Z&clean=true&limit=3",
+			// An earlier row pushes every later one a position back.
+			insert:  `INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags) VALUES ('siata_4', '2025-10-03T11:55:00Z', 0.2, 0)`,
+			page1:   []string{"12:00", "12:05", "12:10"},
+			pinned:  []string{"12:15", "12:20", "12:25"},
+			shifted: []string{"12:10", "12:15", "12:20"},
+		},
+		{
+			name: "grid timestamps",
+			path: "/api/v1/grid/timestamps?start=2025-10-03T00:00:00Z&end=2025-10-04T00:00:00Z&limit=2",
+			// Runs are listed newest first, so a newer run shifts them.
+			insert:  `INSERT INTO grid_runs (ts, res_m, status) VALUES ('2025-10-03T13:00:00Z', 500, 'done')`,
+			page1:   []string{"12:00", "11:00"},
+			pinned:  []string{"10:00"},
+			shifted: []string{"11:00", "10:00"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var first pinnedPage
+			getJSON(t, handler, tc.path+"&page=1", &first)
+			if got := first.times(); !slices.Equal(got, tc.page1) {
+				t.Fatalf("page 1: %v, want %v", got, tc.page1)
+			}
+			if first.Meta.AsOf == "" {
+				t.Fatal("page 1 has no meta.as_of")
+			}
+
+			if err := apitest.Exec(ctx, url, tc.insert); err != nil {
+				t.Fatal(err)
+			}
+
+			var pinned pinnedPage
+			getJSON(t, handler, tc.path+"&page=2&as_of="+first.Meta.AsOf, &pinned)
+			if got := pinned.times(); !slices.Equal(got, tc.pinned) {
+				t.Errorf("pinned page 2: %v, want %v", got, tc.pinned)
+			}
+			if pinned.Pagination.TotalCount != first.Pagination.TotalCount || pinned.Meta.AsOf != first.Meta.AsOf {
+				t.Errorf("pinned page 2: total %d as of %s, page 1: total %d as of %s",
+					pinned.Pagination.TotalCount, pinned.Meta.AsOf, first.Pagination.TotalCount, first.Meta.AsOf)
+			}
+
+			// A later snapshot sees the new row, so the page repeats one.
+			var shifted pinnedPage
+			getJSON(t, handler, tc.path+"&page=2&as_of=2099-01-01T00:00:00Z", &shifted)
+			if got := shifted.times(); !slices.Equal(got, tc.shifted) {
+				t.Errorf("unpinned page 2: %v, want %v", got, tc.shifted)
+			}
+		})
+	}
+}
+
+// last_n on the legacy endpoint keeps the first rows in the requested order,
+// while the v1 CSV export keeps the newest ones and writes them in the
+// requested order.
+func TestMeasurementSortOrders(t *testing.T) {
+	handler := newHandler(t, "testdata/pinned.sql")
+	const rng = "start=2025-10-03T00:00:00Z&end=2025-10-04T00:00:00Z&clean=true&last_n=2"
+
+	for _, tc := range []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"12:00", "12:05"}},
+		{"&order=asc", []string{"12:00", "12:05"}},
+		{"&order=desc", []string{"12:25", "12:20"}},
+	} {
+		var body struct {
+			Measurements []struct {
+				TS time.Time `json:"ts"`
+			} `json:"measurements"`
+		}
+		getJSON(t, handler, "/sensor/siata_4?"+rng+tc.order, &body)
+		got := make([]string, len(body.Measurements))
+		for i, m := range body.Measurements {
+			got[i] = m.TS.UTC().Format("15:04")
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("legacy%s: %v, want %v", tc.order, got, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		order string
+		want  []string
+	}{
+		{"", []string{"2025-10-03T12:20:00Z", "2025-10-03T12:25:00Z"}},
+		{"&order=asc", []string{"2025-10-03T12:20:00Z", "2025-10-03T12:25:00Z"}},
+		{"&order=desc", []string{"2025-10-03T12:25:00Z", "2025-10-03T12:20:00Z"}},
+	} {
+		rec := apitest.Case{Path: "/api/v1/core/sensors/siata_4/measurements.csv?" + rng + tc.order}.Do(handler)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("csv%s: status %d: %s", tc.order, rec.Code, rec.Body.String())
+		}
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		// The attribution comments come first, then the header.
+		for len(lines) > 0 && strings.HasPrefix(lines[0], "# ") {
+			lines = lines[1:]
+		}
+		var got []string
+		for _, line := range lines[1:] {
+			ts, _, _ := strings.Cut(line, ",")
+			got = append(got, ts)
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("csv%s: %v, want %v", tc.order, got, tc.want)
+		}
+	}
+
+	if rec := (apitest.Case{Path: "/sensor/siata_4?order=newest"}).Do(handler); rec.Code != http.StatusBadRequest {
+		t.Errorf("order=newest: status %d, want 400", rec.Code)
+	}
+}
+
+// CSV exports open with the attribution comments unless the request opts out.
+func TestCSVExportAttribution(t *testing.T) {
+	handler := newHandler(t)
+	const rng = "start=2025-10-01T11:00:00Z&end=2025-10-01T13:00:00Z"
+
+	for _, export := range []struct{ path, header string }{
+		{"/api/v1/core/measurements.csv?ids=siata_1&" + rng, "sensor_id,ts,value_mm,qc_flags,imputation_method,source"},
+		{"/api/v1/core/sensors/siata_1/measurements.csv?" + rng, "ts,value_mm,qc_flags,imputation_method"},
+	} {
+		path := export.path
+		for _, tc := range []struct {
+			query        string
+			wantComments bool
+		}{
+			{"", true},
+			{"&attribution=true", true},
+			{"&attribution=false", false},
+		} {
+			rec := apitest.Case{Path: path + tc.query}.Do(handler)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("%s%s: status %d: %s", path, tc.query, rec.Code, rec.Body.String())
+			}
+			lines := strings.Split(rec.Body.String(), "\n")
+			comments := 0
+			for comments < len(lines) && strings.HasPrefix(lines[comments], "# ") {
+				comments++
+			}
+			if tc.wantComments && (comments == 0 || !strings.HasPrefix(lines[0], "# source: ")) {
+				t.Errorf("%s%s: no attribution before the header:\n%s", path, tc.query, rec.Body.String())
+			}
+			if !tc.wantComments && comments != 0 {
+				t.Errorf("%s%s: %d comment lines, want none", path, tc.query, comments)
+			}
+			if comments == len(lines) || lines[comments] != export.header {
+				t.Errorf("%s%s: the comments are not followed by the header %q:\n%s", path, tc.query, export.header, rec.Body.String())
+			}
+		}
+	}
+}
+
+// The latest run's contours are inlined while small and otherwise redirected
+// to the blob with a too_large indicator.
+func TestRealtimeContoursInlineAndFallback(t *testing.T) {
+	const doc = `{"type":"FeatureCollection","features":[]}`
+	blob := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small.geojson":
+			_, _ = io.WriteString(w, doc)
+		case "/large.geojson":
+			_, _ = io.WriteString(w, `{"type":"FeatureCollection","features":[`+strings.Repeat(`{},`, 40)+`{}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer blob.Close()
+	t.Setenv("API_CONTOURS_INLINE_MAX_BYTES", "64")
+
+	for _, tc := range []struct {
+		name, path string
+		status     int
+	}{
+		{"inline", "/small.geojson", http.StatusOK},
+		{"fallback", "/large.geojson", http.StatusTemporaryRedirect},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			store, url := newStore(t)
+			contoursURL := blob.URL + tc.path
+			if err := apitest.Exec(context.Background(), url,
+				`INSERT INTO grid_runs (ts, res_m, status, blob_url_contours) VALUES ('2025-10-05T12:00:00Z', 500, 'done', '`+contoursURL+`')`); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := apitest.Case{Path: "/api/v1/realtime/contours"}.Do(newEngine(t, store))
+			if rec.Code != tc.status {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+			if tc.status == http.StatusOK {
+				if ct := rec.Header().Get("Content-Type"); ct != "application/geo+json" || rec.Body.String() != doc {
+					t.Errorf("%s %s, want the inline document", ct, rec.Body.String())
+				}
+				return
+			}
+			var body struct {
+				TooLarge    bool   `json:"too_large"`
+				ContoursURL string `json:"contours_url"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if !body.TooLarge || body.ContoursURL != contoursURL || rec.Header().Get("Location") != contoursURL {
+				t.Errorf("fallback %+v, Location %q", body, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// Golden files are compared byte for byte after normalization, so a
+// hand-edited one must already be in normalized form.
+func TestGoldenFilesAreNormalized(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join(casesDir, "*.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	perMode, err := filepath.Glob(filepath.Join(casesDir, "default_clean_*", "*.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, perMode...)
+	if len(perMode) == 0 {
+		t.Error("no per-default golden files found")
+	}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := apitest.NormalizeJSON(raw)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !bytes.Equal(got, raw) {
+			t.Errorf("%s is not normalized; expected:\n%s", path, got)
+		}
+	}
+}
+
+func TestNormalizeJSON(t *testing.T) {
+	got, err := apitest.NormalizeJSON([]byte(`{"b":1,"generated_at":"2026-01-02T03:04:05Z","ts":"2025-10-01T07:00:00-05:00","a":"<x>"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "a": "<x>",
+  "b": 1,
+  "generated_at": "<timestamp>",
+  "ts": "2025-10-01T12:00:00Z"
+}
+`
+	if string(got) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+-- A gappy hour for siata_3, relative to now() since the accumulation endpoint
+-- integrates a trailing window: samples every 5 minutes from 55 to 45 and
+-- from 15 to 5 minutes ago around a 30-minute outage, plus one sample before
+-- the window.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+VALUES ('siata_3', 'Fixture 3', '3', 6.30, -75.55, 'Bello');
+
+INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags)
+SELECT 'siata_3', now() - make_interval(mins => m), 0.5, 0
+FROM unnest(ARRAY[55, 50, 45, 15, 10, 5]) AS m;
+
+INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags)
+VALUES ('siata_3', now() - interval '2 hours', 9.0, 0);
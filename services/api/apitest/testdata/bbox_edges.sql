@@ -0,0 +1,26 @@
+-- Sensors on each edge of the box -75.60,6.20,-75.50,6.30 and 0.001° outside
+-- it. The seed's siata_1 lies inside and siata_2 on the south-west corner.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+VALUES
+    ('edge_w', 'West edge', 'ew', 6.25, -75.60, 'Medellín'),
+    ('edge_e', 'East edge', 'ee', 6.25, -75.50, 'Medellín'),
+    ('edge_s', 'South edge', 'es', 6.20, -75.55, 'Medellín'),
+    ('edge_n', 'North edge', 'en', 6.30, -75.55, 'Medellín'),
+    ('out_w', 'Outside west', 'ow', 6.25, -75.601, 'Medellín'),
+    ('out_e', 'Outside east', 'oe', 6.25, -75.499, 'Medellín'),
+    ('out_s', 'Outside south', 'os', 6.199, -75.55, 'Medellín'),
+    ('out_n', 'Outside north', 'on', 6.301, -75.55, 'Medellín');
+
+INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags)
+SELECT id, '2025-10-05T12:00:00Z', 0.5, 0
+FROM sensors
+WHERE id LIKE 'edge_%' OR id LIKE 'out_%';
+
+-- The latest grid run, with an aggregate for every sensor.
+INSERT INTO grid_runs (ts, res_m, bbox, status)
+VALUES ('2025-10-05T12:00:00Z', 500, '[-75.7, 6.1, -75.4, 6.4]', 'done');
+
+INSERT INTO grid_sensor_aggregates (grid_run_id, sensor_id, ts_start, ts_end, avg_mm_h, measurement_count)
+SELECT r.id, s.id, r.ts - interval '10 minutes', r.ts, 1, 2
+FROM grid_runs r, sensors s
+WHERE r.ts = '2025-10-05T12:00:00Z';
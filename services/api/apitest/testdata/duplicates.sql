@@ -0,0 +1,10 @@
+-- Retried ETL runs. At 06:00 the preferred run is the most recently updated
+-- done one (250 m); the failed retry is newer still but never preferred. At
+-- 07:00 both done runs share updated_at, so the later insert (250 m) wins.
+INSERT INTO grid_runs (ts, res_m, bbox, status, updated_at)
+VALUES
+    ('2025-10-02T06:00:00Z', 500, '[-75.7, 6.1, -75.4, 6.4]', 'done', '2025-10-02T06:10:00Z'),
+    ('2025-10-02T06:00:00Z', 250, '[-75.7, 6.1, -75.4, 6.4]', 'done', '2025-10-02T06:20:00Z'),
+    ('2025-10-02T06:00:00Z', 1000, '[-75.7, 6.1, -75.4, 6.4]', 'failed', '2025-10-02T06:30:00Z'),
+    ('2025-10-02T07:00:00Z', 500, '[-75.7, 6.1, -75.4, 6.4]', 'done', '2025-10-02T07:10:00Z'),
+    ('2025-10-02T07:00:00Z', 250, '[-75.7, 6.1, -75.4, 6.4]', 'done', '2025-10-02T07:10:00Z');
@@ -0,0 +1,15 @@
+-- A grid run whose sensors report 1 to 10 mm/h plus one glitching gauge at
+-- 500 mm/h. With eleven values the 10th and 90th percentiles are exactly 2
+-- and 10, so the winsorized mean is (2 + 2..10 + 10) / 11 = 6.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+SELECT 'outlier_' || n, 'Outlier ' || n, 'o' || n, 6.2 + n / 100.0, -75.6, 'Medellín'
+FROM generate_series(1, 11) AS n;
+
+INSERT INTO grid_runs (ts, res_m, bbox, status)
+VALUES ('2025-10-02T12:00:00Z', 500, '[-75.7, 6.1, -75.4, 6.4]', 'done');
+
+INSERT INTO grid_sensor_aggregates (grid_run_id, sensor_id, ts_start, ts_end, avg_mm_h, measurement_count)
+SELECT r.id, 'outlier_' || n, r.ts - interval '10 minutes', r.ts,
+       CASE WHEN n = 11 THEN 500 ELSE n END, 2
+FROM grid_runs r, generate_series(1, 11) AS n
+WHERE r.ts = '2025-10-02T12:00:00Z';
@@ -0,0 +1,13 @@
+-- Six clean measurements of siata_4, every 5 minutes from 12:00, and three
+-- done grid runs on 2025-10-03, for the pagination and sort order tests. All
+-- are created an hour before the test so they fall inside any as_of it pins.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+VALUES ('siata_4', 'Fixture 4', '4', 6.27, -75.58, 'Medellín');
+
+INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags, created_at)
+SELECT 'siata_4', '2025-10-03T12:00:00Z'::timestamptz + make_interval(mins => m), 0.1, 0, now() - interval '1 hour'
+FROM generate_series(0, 25, 5) AS m;
+
+INSERT INTO grid_runs (ts, res_m, status, created_at)
+SELECT '2025-10-03T00:00:00Z'::timestamptz + make_interval(hours => h), 500, 'done', now() - interval '1 hour'
+FROM unnest(ARRAY[10, 11, 12]) AS h;
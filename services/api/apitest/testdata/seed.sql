@@ -0,0 +1,26 @@
+-- Minimal fixture set for apitest cases: two sensors, raw and clean rows for
+-- one of them and raw rows for the other.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+VALUES
+    ('siata_1', 'Fixture 1', '1', 6.25, -75.56, 'Medellín'),
+    ('siata_2', 'Fixture 2', '2', 6.20, -75.60, 'Envigado');
+
+INSERT INTO raw_measurements (sensor_id, ts, value_mm, variable, source)
+VALUES
+    ('siata_1', '2025-10-01T12:00:00Z', 0.0, 'precipitacion', 'current'),
+    ('siata_1', '2025-10-01T12:05:00Z', 1.5, 'precipitacion', 'current'),
+    ('siata_1', '2025-10-01T12:10:00Z', NULL, 'precipitacion', 'current');
+
+INSERT INTO clean_measurements (sensor_id, ts, value_mm, qc_flags)
+VALUES
+    ('siata_1', '2025-10-01T12:00:00Z', 0.0, 0),
+    ('siata_1', '2025-10-01T12:05:00Z', 1.5, 0);
+
+-- siata_2 has raw rows only, so clean=auto falls back to raw for it.
+INSERT INTO raw_measurements (sensor_id, ts, value_mm, variable, source)
+VALUES
+    ('siata_2', '2025-10-01T12:00:00Z', 2.0, 'precipitacion', 'current');
+
+-- One done grid run, looked up by its timestamp in any UTC offset.
+INSERT INTO grid_runs (ts, res_m, bbox, status)
+VALUES ('2025-10-01T12:00:00Z', 500, '[-75.7, 6.1, -75.4, 6.4]', 'done');
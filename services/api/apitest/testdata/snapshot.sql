@@ -0,0 +1,14 @@
+-- Three more sensors around the seed's two, one far outside the valley, so
+-- snapshot filters by ids and bbox select a real subset of the baseline.
+INSERT INTO sensors (id, name, provider_id, lat, lon, city)
+VALUES
+    ('snap_a', 'Snapshot A', 'sa', 6.28, -75.52, 'Bello'),
+    ('snap_b', 'Snapshot B', 'sb', 6.15, -75.62, 'Sabaneta'),
+    ('snap_c', 'Snapshot C', 'sc', 6.55, -75.30, 'Barbosa');
+
+INSERT INTO raw_measurements (sensor_id, ts, value_mm, variable, source)
+VALUES
+    ('snap_a', '2025-10-01T12:05:00Z', 0.4, 'precipitacion', 'current'),
+    ('snap_a', '2025-10-01T12:10:00Z', 0.6, 'precipitacion', 'current'),
+    ('snap_b', '2025-10-01T11:55:00Z', 3.2, 'precipitacion', 'current'),
+    ('snap_c', '2025-10-01T12:10:00Z', 0.0, 'precipitacion', 'current');
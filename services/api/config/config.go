@@ -6,10 +6,32 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Attribution is the data credit the upstream provider requires downstream
+// consumers to carry.
+type Attribution struct {
+	Source       string `json:"source"`
+	License      string `json:"license,omitempty"`
+	URL          string `json:"url,omitempty"`
+	RetrievedVia string `json:"retrieved_via,omitempty"`
+}
+
+// StatusThresholds reduce the checks of the public status page to states:
+// a value past Warn is yellow and past Crit red. Reporting thresholds are
+// fractions of sensors that reported in the last hour, so lower is worse.
+type StatusThresholds struct {
+	MeasurementAgeWarn time.Duration
+	MeasurementAgeCrit time.Duration
+	GridAgeWarn        time.Duration
+	GridAgeCrit        time.Duration
+	ReportingWarn      float64
+	ReportingCrit      float64
+}
+
 // Config holds environment-driven settings for the REST API.
 type Config struct {
 	DatabaseURL          string
@@ -17,10 +39,56 @@ type Config struct {
 	GridLatestPath       string
 	Port                 int
 	BearerToken          string
+	WriteToken           string
 	DefaultLimit         int
 	DefaultDays          int
 	CORSAllowedOrigins   string
+	CORSWriteOrigins     string
 	CORSAllowCredentials bool
+	CORSMaxAge           int
+	RainThreshold        float64
+	DBMinConns           int
+	DBWarmupTimeout      time.Duration
+	LightConcurrency     int
+	LightQueue           int
+	HeavyConcurrency     int
+	HeavyQueue           int
+	MaxRows              int
+	MaxRangeDays         int
+	// DefaultClean is the clean mode used when a request omits clean:
+	// "true", "false" or "auto".
+	DefaultClean string
+	// MeasurementCacheTTL is how long identical measurement queries share a
+	// result; 0 disables the micro-cache.
+	MeasurementCacheTTL time.Duration
+	// ContoursInlineMaxBytes is the largest contours document served inline
+	// by /api/v1/realtime/contours.
+	ContoursInlineMaxBytes int64
+	// ShedUtilization is the pool utilization (0-1] at which low-priority
+	// endpoints start being rejected; 0 disables load shedding.
+	ShedUtilization float64
+	ShedAcquireWait time.Duration
+	ShedCooldown    time.Duration
+	// GridCheckInterval is how often the API re-checks whether the grid
+	// tables exist; 0 checks only at startup.
+	GridCheckInterval time.Duration
+	// Attribution is attached to the meta of every JSON response and, on
+	// request, to CSV exports.
+	Attribution Attribution
+	// SensorStaleAfter and SensorOfflineAfter are the gaps since a sensor's
+	// latest raw measurement at which /api/v1/core/sensors/status reports it
+	// as stale and offline.
+	SensorStaleAfter   time.Duration
+	SensorOfflineAfter time.Duration
+	// RunMigrations applies pending schema migrations before serving.
+	RunMigrations bool
+	// LocalTime, when set, adds local renderings of the UTC timestamps to
+	// responses whose request names no tz.
+	LocalTime *time.Location
+	// Status configures the public status page: its thresholds and the
+	// requests per second it serves across all clients.
+	Status          StatusThresholds
+	StatusRateLimit float64
 }
 
 // Load reads configuration from environment variables (optionally .env).
@@ -28,10 +96,45 @@ func Load() (Config, error) {
 	_ = godotenv.Load() // ignore missing file
 
 	cfg := Config{
-		GridLatestPath: "grids/latest.json",
-		Port:           8080,
-		DefaultLimit:   200,
-		DefaultDays:    7,
+		GridLatestPath:   "grids/latest.json",
+		Port:             8080,
+		DefaultLimit:     200,
+		DefaultDays:      7,
+		RainThreshold:    0.1,
+		DBMinConns:       2,
+		DBWarmupTimeout:  10 * time.Second,
+		LightConcurrency: 32,
+		LightQueue:       64,
+		HeavyConcurrency: 4,
+		HeavyQueue:       8,
+		MaxRows:          5000,
+		MaxRangeDays:     366,
+		DefaultClean:     "true",
+
+		MeasurementCacheTTL:    2 * time.Second,
+		ContoursInlineMaxBytes: 1 << 20,
+		ShedUtilization:        0.9,
+		ShedAcquireWait:        100 * time.Millisecond,
+		ShedCooldown:           30 * time.Second,
+		GridCheckInterval:      time.Minute,
+		SensorStaleAfter:       30 * time.Minute,
+		SensorOfflineAfter:     6 * time.Hour,
+		StatusRateLimit:        10,
+
+		Status: StatusThresholds{
+			MeasurementAgeWarn: 15 * time.Minute,
+			MeasurementAgeCrit: time.Hour,
+			GridAgeWarn:        30 * time.Minute,
+			GridAgeCrit:        2 * time.Hour,
+			ReportingWarn:      0.8,
+			ReportingCrit:      0.5,
+		},
+
+		Attribution: Attribution{
+			Source:       "SIATA - Sistema de Alerta Temprana de Medellín y el Valle de Aburrá",
+			URL:          "https://siata.gov.co",
+			RetrievedVia: "Retrieved via the Shizuku precipitation API",
+		},
 	}
 
 	// Support Heroku's dynamic database URL naming via DB_ENV_VARIABLE
@@ -88,20 +191,191 @@ func Load() (Config, error) {
 		}
 	}
 
+	if thresholdStr := os.Getenv("RAIN_THRESHOLD"); thresholdStr != "" {
+		if threshold, err := strconv.ParseFloat(thresholdStr, 64); err == nil && threshold >= 0 {
+			cfg.RainThreshold = threshold
+		} else {
+			return cfg, fmt.Errorf("invalid RAIN_THRESHOLD: %s", thresholdStr)
+		}
+	}
+
+	if connsStr := os.Getenv("DB_MIN_CONNS"); connsStr != "" {
+		if conns, err := strconv.Atoi(connsStr); err == nil && conns >= 0 {
+			cfg.DBMinConns = conns
+		} else {
+			return cfg, fmt.Errorf("invalid DB_MIN_CONNS: %s", connsStr)
+		}
+	}
+
+	if warmupStr := os.Getenv("DB_WARMUP_TIMEOUT"); warmupStr != "" {
+		if warmup, err := time.ParseDuration(warmupStr); err == nil && warmup >= 0 {
+			cfg.DBWarmupTimeout = warmup
+		} else {
+			return cfg, fmt.Errorf("invalid DB_WARMUP_TIMEOUT: %s", warmupStr)
+		}
+	}
+
+	if ttlStr := os.Getenv("API_MEASUREMENT_CACHE_TTL"); ttlStr != "" {
+		if ttl, err := time.ParseDuration(ttlStr); err == nil && ttl >= 0 && ttl <= 5*time.Second {
+			cfg.MeasurementCacheTTL = ttl
+		} else {
+			return cfg, fmt.Errorf("invalid API_MEASUREMENT_CACHE_TTL: %s (expected 0 to 5s)", ttlStr)
+		}
+	}
+
+	if maxStr := os.Getenv("API_CONTOURS_INLINE_MAX_BYTES"); maxStr != "" {
+		if n, err := strconv.ParseInt(maxStr, 10, 64); err == nil && n > 0 {
+			cfg.ContoursInlineMaxBytes = n
+		} else {
+			return cfg, fmt.Errorf("invalid API_CONTOURS_INLINE_MAX_BYTES: %s", maxStr)
+		}
+	}
+
+	if utilStr := os.Getenv("API_SHED_UTILIZATION"); utilStr != "" {
+		if util, err := strconv.ParseFloat(utilStr, 64); err == nil && util >= 0 && util <= 1 {
+			cfg.ShedUtilization = util
+		} else {
+			return cfg, fmt.Errorf("invalid API_SHED_UTILIZATION: %s", utilStr)
+		}
+	}
+
+	for _, frac := range []struct {
+		env string
+		dst *float64
+	}{
+		{"API_STATUS_REPORTING_WARN", &cfg.Status.ReportingWarn},
+		{"API_STATUS_REPORTING_CRIT", &cfg.Status.ReportingCrit},
+	} {
+		if str := os.Getenv(frac.env); str != "" {
+			if f, err := strconv.ParseFloat(str, 64); err == nil && f >= 0 && f <= 1 {
+				*frac.dst = f
+			} else {
+				return cfg, fmt.Errorf("invalid %s: %s", frac.env, str)
+			}
+		}
+	}
+
+	if rateStr := os.Getenv("API_STATUS_RATE_LIMIT"); rateStr != "" {
+		if rate, err := strconv.ParseFloat(rateStr, 64); err == nil && rate > 0 {
+			cfg.StatusRateLimit = rate
+		} else {
+			return cfg, fmt.Errorf("invalid API_STATUS_RATE_LIMIT: %s", rateStr)
+		}
+	}
+
+	for _, attr := range []struct {
+		env string
+		dst *string
+	}{
+		{"API_ATTRIBUTION_SOURCE", &cfg.Attribution.Source},
+		{"API_ATTRIBUTION_LICENSE", &cfg.Attribution.License},
+		{"API_ATTRIBUTION_URL", &cfg.Attribution.URL},
+		{"API_ATTRIBUTION_RETRIEVED_VIA", &cfg.Attribution.RetrievedVia},
+	} {
+		if v, ok := os.LookupEnv(attr.env); ok {
+			*attr.dst = strings.TrimSpace(v)
+		}
+	}
+
+	for _, dur := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"API_SHED_ACQUIRE_WAIT", &cfg.ShedAcquireWait},
+		{"API_SHED_COOLDOWN", &cfg.ShedCooldown},
+		{"API_GRID_CHECK_INTERVAL", &cfg.GridCheckInterval},
+		{"API_SENSOR_STALE_AFTER", &cfg.SensorStaleAfter},
+		{"API_SENSOR_OFFLINE_AFTER", &cfg.SensorOfflineAfter},
+		{"API_STATUS_MEASUREMENT_WARN", &cfg.Status.MeasurementAgeWarn},
+		{"API_STATUS_MEASUREMENT_CRIT", &cfg.Status.MeasurementAgeCrit},
+		{"API_STATUS_GRID_WARN", &cfg.Status.GridAgeWarn},
+		{"API_STATUS_GRID_CRIT", &cfg.Status.GridAgeCrit},
+	} {
+		if str := os.Getenv(dur.env); str != "" {
+			if d, err := time.ParseDuration(str); err == nil && d >= 0 {
+				*dur.dst = d
+			} else {
+				return cfg, fmt.Errorf("invalid %s: %s", dur.env, str)
+			}
+		}
+	}
+
+	for _, lim := range []struct {
+		env string
+		dst *int
+		min int
+	}{
+		{"API_LIGHT_CONCURRENCY", &cfg.LightConcurrency, 1},
+		{"API_LIGHT_QUEUE", &cfg.LightQueue, 0},
+		{"API_HEAVY_CONCURRENCY", &cfg.HeavyConcurrency, 1},
+		{"API_HEAVY_QUEUE", &cfg.HeavyQueue, 0},
+		{"API_MAX_ROWS", &cfg.MaxRows, 1},
+		{"API_MAX_RANGE_DAYS", &cfg.MaxRangeDays, 1},
+	} {
+		if str := os.Getenv(lim.env); str != "" {
+			if n, err := strconv.Atoi(str); err == nil && n >= lim.min {
+				*lim.dst = n
+			} else {
+				return cfg, fmt.Errorf("invalid %s: %s", lim.env, str)
+			}
+		}
+	}
+
+	if cleanStr := strings.ToLower(strings.TrimSpace(os.Getenv("API_DEFAULT_CLEAN"))); cleanStr != "" {
+		if cleanStr == "auto" {
+			cfg.DefaultClean = cleanStr
+		} else if clean, err := strconv.ParseBool(cleanStr); err == nil {
+			cfg.DefaultClean = strconv.FormatBool(clean)
+		} else {
+			return cfg, fmt.Errorf("invalid API_DEFAULT_CLEAN: %s", cleanStr)
+		}
+	}
+
+	if zone := strings.TrimSpace(os.Getenv("API_LOCAL_TIME")); zone != "" {
+		if loc, err := time.LoadLocation(zone); err == nil {
+			cfg.LocalTime = loc
+		} else {
+			return cfg, fmt.Errorf("invalid API_LOCAL_TIME: %s", zone)
+		}
+	}
+
 	cfg.BearerToken = os.Getenv("API_BEARER_TOKEN")
+	cfg.WriteToken = os.Getenv("API_WRITE_TOKEN")
 
 	cfg.CORSAllowedOrigins = os.Getenv("CORS_ALLOWED_ORIGINS")
 	if cfg.CORSAllowedOrigins == "" {
 		cfg.CORSAllowedOrigins = "*" // default to allow all
 	}
 
+	// Write/admin routes fall back to the public list unless restricted explicitly
+	cfg.CORSWriteOrigins = os.Getenv("CORS_WRITE_ORIGINS")
+	if cfg.CORSWriteOrigins == "" {
+		cfg.CORSWriteOrigins = cfg.CORSAllowedOrigins
+	}
+
+	if maxAgeStr := os.Getenv("CORS_MAX_AGE"); maxAgeStr != "" {
+		if maxAge, err := strconv.Atoi(maxAgeStr); err == nil && maxAge >= 0 {
+			cfg.CORSMaxAge = maxAge
+		} else {
+			return cfg, fmt.Errorf("invalid CORS_MAX_AGE: %s", maxAgeStr)
+		}
+	}
+
+	if migStr := os.Getenv("RUN_MIGRATIONS"); migStr != "" {
+		if run, err := strconv.ParseBool(migStr); err == nil {
+			cfg.RunMigrations = run
+		} else {
+			return cfg, fmt.Errorf("invalid RUN_MIGRATIONS: %s", migStr)
+		}
+	}
+
 	if credsStr := os.Getenv("CORS_ALLOW_CREDENTIALS"); credsStr != "" {
 		if creds, err := strconv.ParseBool(credsStr); err == nil {
 			cfg.CORSAllowCredentials = creds
 		}
 	}
 
-	return cfg, nil
+	return cfg, cfg.Validate()
 }
 
 // ListenAddr returns the host:port string for the HTTP server.
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// minTokenLength is the shortest bearer or write token accepted.
+const minTokenLength = 16
+
+// ValidationError lists every configuration problem found at startup.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks values that parse but cannot work, reporting all problems
+// at once instead of failing on the first request that hits them.
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(c.DatabaseURL); err != nil || (u.Scheme != "postgresql" && u.Scheme != "postgres") || u.Host == "" {
+		add("DATABASE_URL must be a postgresql:// URL with a host")
+	}
+	if u, err := url.Parse(c.BlobBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("VERCEL_BLOB_BASE_URL must be an absolute http(s) URL, got %q", c.BlobBaseURL)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		add("PORT must be between 1 and 65535, got %d", c.Port)
+	}
+
+	if c.BearerToken != "" && len(c.BearerToken) < minTokenLength {
+		add("API_BEARER_TOKEN must be at least %d characters", minTokenLength)
+	}
+	if c.WriteToken != "" && len(c.WriteToken) < minTokenLength {
+		add("API_WRITE_TOKEN must be at least %d characters", minTokenLength)
+	}
+	if c.WriteToken != "" && c.WriteToken == c.BearerToken {
+		add("API_WRITE_TOKEN must differ from API_BEARER_TOKEN, otherwise every reader can write")
+	}
+
+	for _, origins := range []struct {
+		env, value string
+	}{
+		{"CORS_ALLOWED_ORIGINS", c.CORSAllowedOrigins},
+		{"CORS_WRITE_ORIGINS", c.CORSWriteOrigins},
+	} {
+		for _, origin := range strings.Split(origins.value, ",") {
+			origin = strings.TrimSpace(origin)
+			if origin == "" {
+				continue
+			}
+			if origin == "*" {
+				if c.CORSAllowCredentials {
+					add("%s cannot contain * when CORS_ALLOW_CREDENTIALS is true", origins.env)
+				}
+				continue
+			}
+			if !validOrigin(origin) {
+				add("%s entry %q must be scheme://host[:port] without a path", origins.env, origin)
+			}
+		}
+	}
+
+	if c.Attribution.Source == "" {
+		add("API_ATTRIBUTION_SOURCE must not be empty")
+	}
+	if c.Attribution.URL != "" {
+		if u, err := url.Parse(c.Attribution.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("API_ATTRIBUTION_URL must be an absolute http(s) URL, got %q", c.Attribution.URL)
+		}
+	}
+
+	if c.DefaultLimit > c.MaxRows {
+		add("API_DEFAULT_LIMIT (%d) exceeds API_MAX_ROWS (%d)", c.DefaultLimit, c.MaxRows)
+	}
+	if c.DefaultDays > c.MaxRangeDays {
+		add("API_DEFAULT_DAYS (%d) exceeds API_MAX_RANGE_DAYS (%d)", c.DefaultDays, c.MaxRangeDays)
+	}
+	if c.SensorStaleAfter <= 0 || c.SensorStaleAfter >= c.SensorOfflineAfter {
+		add("API_SENSOR_STALE_AFTER (%s) must be positive and below API_SENSOR_OFFLINE_AFTER (%s)", c.SensorStaleAfter, c.SensorOfflineAfter)
+	}
+	if c.Status.MeasurementAgeWarn <= 0 || c.Status.MeasurementAgeWarn >= c.Status.MeasurementAgeCrit {
+		add("API_STATUS_MEASUREMENT_WARN (%s) must be positive and below API_STATUS_MEASUREMENT_CRIT (%s)", c.Status.MeasurementAgeWarn, c.Status.MeasurementAgeCrit)
+	}
+	if c.Status.GridAgeWarn <= 0 || c.Status.GridAgeWarn >= c.Status.GridAgeCrit {
+		add("API_STATUS_GRID_WARN (%s) must be positive and below API_STATUS_GRID_CRIT (%s)", c.Status.GridAgeWarn, c.Status.GridAgeCrit)
+	}
+	if c.Status.ReportingWarn <= c.Status.ReportingCrit {
+		add("API_STATUS_REPORTING_WARN (%g) must be above API_STATUS_REPORTING_CRIT (%g)", c.Status.ReportingWarn, c.Status.ReportingCrit)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func validOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
+
+// Redacted renders the resolved configuration for the startup log with
+// tokens hidden and the database password stripped.
+func (c Config) Redacted() string {
+	dbURL := "[invalid]"
+	if u, err := url.Parse(c.DatabaseURL); err == nil {
+		dbURL = u.Redacted()
+	}
+	localTime := "(unset)"
+	if c.LocalTime != nil {
+		localTime = c.LocalTime.String()
+	}
+	return fmt.Sprintf(
+		"database_url=%s blob_base_url=%s grid_latest_path=%s port=%d bearer_token=%s write_token=%s "+
+			"default_limit=%d default_days=%d default_clean=%s max_rows=%d max_range_days=%d "+
+			"cors_allowed_origins=%q cors_write_origins=%q cors_allow_credentials=%v cors_max_age=%d "+
+			"rain_threshold=%g db_min_conns=%d db_warmup_timeout=%s measurement_cache_ttl=%s "+
+			"shed_utilization=%g shed_acquire_wait=%s shed_cooldown=%s grid_check_interval=%s "+
+			"sensor_stale_after=%s sensor_offline_after=%s light=%d/%d heavy=%d/%d attribution_source=%q attribution_license=%q attribution_url=%s local_time=%s run_migrations=%v "+
+			"status_measurement=%s/%s status_grid=%s/%s status_reporting=%g/%g status_rate_limit=%g",
+		dbURL, c.BlobBaseURL, c.GridLatestPath, c.Port, redactSecret(c.BearerToken), redactSecret(c.WriteToken),
+		c.DefaultLimit, c.DefaultDays, c.DefaultClean, c.MaxRows, c.MaxRangeDays,
+		c.CORSAllowedOrigins, c.CORSWriteOrigins, c.CORSAllowCredentials, c.CORSMaxAge,
+		c.RainThreshold, c.DBMinConns, c.DBWarmupTimeout, c.MeasurementCacheTTL,
+		c.ShedUtilization, c.ShedAcquireWait, c.ShedCooldown, c.GridCheckInterval,
+		c.SensorStaleAfter, c.SensorOfflineAfter, c.LightConcurrency, c.LightQueue, c.HeavyConcurrency, c.HeavyQueue,
+		c.Attribution.Source, c.Attribution.License, c.Attribution.URL, localTime, c.RunMigrations,
+		c.Status.MeasurementAgeWarn, c.Status.MeasurementAgeCrit, c.Status.GridAgeWarn, c.Status.GridAgeCrit,
+		c.Status.ReportingWarn, c.Status.ReportingCrit, c.StatusRateLimit,
+	)
+}
+
+func redactSecret(s string) string {
+	if s == "" {
+		return "(unset)"
+	}
+	return "[redacted]"
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig() Config {
+	return Config{
+		DatabaseURL:        "postgresql://shizuku:secret@db:5432/shizuku",
+		BlobBaseURL:        "https://blob.example.com",
+		Port:               8080,
+		DefaultLimit:       100,
+		MaxRows:            5000,
+		DefaultDays:        7,
+		MaxRangeDays:       31,
+		SensorStaleAfter:   30 * time.Minute,
+		SensorOfflineAfter: 6 * time.Hour,
+		Attribution:        Attribution{Source: "SIATA"},
+		Status: StatusThresholds{
+			MeasurementAgeWarn: 15 * time.Minute, MeasurementAgeCrit: time.Hour,
+			GridAgeWarn: 30 * time.Minute, GridAgeCrit: 2 * time.Hour,
+			ReportingWarn: 0.8, ReportingCrit: 0.5,
+		},
+	}
+}
+
+func TestValidateAcceptsValidConfig(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidatePerField(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		mutate func(*Config)
+		want   string
+	}{
+		{"database url scheme", func(c *Config) { c.DatabaseURL = "mysql://db/x" }, "DATABASE_URL"},
+		{"database url host", func(c *Config) { c.DatabaseURL = "postgresql:///x" }, "DATABASE_URL"},
+		{"blob url relative", func(c *Config) { c.BlobBaseURL = "blob.example.com" }, "VERCEL_BLOB_BASE_URL"},
+		{"port zero", func(c *Config) { c.Port = 0 }, "PORT"},
+		{"port too high", func(c *Config) { c.Port = 70000 }, "PORT"},
+		{"short bearer token", func(c *Config) { c.BearerToken = "short" }, "API_BEARER_TOKEN"},
+		{"short write token", func(c *Config) { c.WriteToken = "short" }, "API_WRITE_TOKEN must be at least"},
+		{"write token equals bearer", func(c *Config) {
+			c.BearerToken = "0123456789abcdef"
+			c.WriteToken = "0123456789abcdef"
+		}, "must differ"},
+		{"origin with path", func(c *Config) { c.CORSAllowedOrigins = "https://a.example.com/app" }, "CORS_ALLOWED_ORIGINS"},
+		{"write origin without scheme", func(c *Config) { c.CORSWriteOrigins = "a.example.com" }, "CORS_WRITE_ORIGINS"},
+		{"wildcard with credentials", func(c *Config) {
+			c.CORSAllowedOrigins = "*"
+			c.CORSAllowCredentials = true
+		}, "cannot contain *"},
+		{"empty attribution source", func(c *Config) { c.Attribution.Source = "" }, "API_ATTRIBUTION_SOURCE"},
+		{"attribution url", func(c *Config) { c.Attribution.URL = "ftp://x" }, "API_ATTRIBUTION_URL"},
+		{"default limit above max rows", func(c *Config) { c.DefaultLimit = 6000 }, "API_DEFAULT_LIMIT"},
+		{"default days above max range", func(c *Config) { c.DefaultDays = 60 }, "API_DEFAULT_DAYS"},
+		{"stale after offline", func(c *Config) { c.SensorStaleAfter = 7 * time.Hour }, "API_SENSOR_STALE_AFTER"},
+		{"measurement warn after crit", func(c *Config) { c.Status.MeasurementAgeWarn = 2 * time.Hour }, "API_STATUS_MEASUREMENT_WARN"},
+		{"grid warn zero", func(c *Config) { c.Status.GridAgeWarn = 0 }, "API_STATUS_GRID_WARN"},
+		{"reporting warn below crit", func(c *Config) { c.Status.ReportingWarn = 0.4 }, "API_STATUS_REPORTING_WARN"},
+	} {
+		cfg := validConfig()
+		tc.mutate(&cfg)
+		var verr *ValidationError
+		if err := cfg.Validate(); !errors.As(err, &verr) {
+			t.Errorf("%s: got %v, want a ValidationError", tc.name, err)
+			continue
+		}
+		if len(verr.Problems) != 1 || !strings.Contains(verr.Problems[0], tc.want) {
+			t.Errorf("%s: problems %q, want one mentioning %s", tc.name, verr.Problems, tc.want)
+		}
+	}
+}
+
+func TestValidateListsEveryProblem(t *testing.T) {
+	cfg := validConfig()
+	cfg.Port = 0
+	cfg.BlobBaseURL = ""
+	cfg.Attribution.Source = ""
+	var verr *ValidationError
+	if err := cfg.Validate(); !errors.As(err, &verr) || len(verr.Problems) != 3 {
+		t.Errorf("got %v, want three problems", err)
+	}
+}
+
+func TestRedactedHidesSecrets(t *testing.T) {
+	cfg := validConfig()
+	cfg.BearerToken = "bearer-0123456789"
+	cfg.WriteToken = "write-0123456789"
+	out := cfg.Redacted()
+	for _, secret := range []string{"secret", cfg.BearerToken, cfg.WriteToken} {
+		if strings.Contains(out, secret) {
+			t.Errorf("redacted config contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, "db:5432") {
+		t.Errorf("redacted config lost the database host: %s", out)
+	}
+}
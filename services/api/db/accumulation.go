@@ -0,0 +1,16 @@
+package db
+
+import (
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+)
+
+// setWindow records the aggregate window and the accumulation derived from
+// it. grid_sensor_aggregates stores rates while clean measurements are
+// per-interval depths; responses report both.
+func (a *SensorAggregate) setWindow(start, end time.Time) {
+	window := end.Sub(start)
+	a.WindowSeconds = int(window / time.Second)
+	a.AccumulationMM = units.Intensity(a.AvgMmH).Over(window).MM()
+}
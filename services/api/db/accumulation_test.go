@@ -0,0 +1,34 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// Pins the rate-to-depth factor of sensor aggregates: a regression to the
+// per-interval reading shows up as a 12x error on a 5-minute run.
+func TestSensorAggregateSetWindow(t *testing.T) {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		avgMmH      float64
+		window      time.Duration
+		wantSeconds int
+		wantMM      float64
+	}{
+		{12, 5 * time.Minute, 300, 1},
+		{6, time.Hour, 3600, 6},
+		{3, 10 * time.Minute, 600, 0.5},
+		{0, time.Hour, 3600, 0},
+		{12, 0, 0, 0},
+	} {
+		a := SensorAggregate{AvgMmH: tc.avgMmH}
+		a.setWindow(start, start.Add(tc.window))
+		if a.WindowSeconds != tc.wantSeconds || a.AccumulationMM != tc.wantMM {
+			t.Errorf("%v mm/h over %v: window %ds, accumulation %v mm; want %ds, %v mm",
+				tc.avgMmH, tc.window, a.WindowSeconds, a.AccumulationMM, tc.wantSeconds, tc.wantMM)
+		}
+		if a.AvgMmH != tc.avgMmH {
+			t.Errorf("setWindow changed avg_mm_h to %v", a.AvgMmH)
+		}
+	}
+}
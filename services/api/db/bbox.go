@@ -0,0 +1,28 @@
+package db
+
+import "strconv"
+
+// BBox is a lon/lat bounding box used to restrict queries to sensors inside it.
+type BBox struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// Contains reports whether the point lies inside the box (edges included).
+func (b BBox) Contains(lat, lon float64) bool {
+	return lon >= b.MinLon && lon <= b.MaxLon && lat >= b.MinLat && lat <= b.MaxLat
+}
+
+// clause renders " AND <alias>.lon BETWEEN ... AND <alias>.lat BETWEEN ..."
+// with placeholders starting at argPos, returning the clause and its args.
+func (b *BBox) clause(alias string, argPos int) (string, []any) {
+	if b == nil {
+		return "", nil
+	}
+	p := func(i int) string { return "$" + strconv.Itoa(argPos+i) }
+	sql := " AND " + alias + ".lon BETWEEN " + p(0) + " AND " + p(1) +
+		" AND " + alias + ".lat BETWEEN " + p(2) + " AND " + p(3)
+	return sql, []any{b.MinLon, b.MaxLon, b.MinLat, b.MaxLat}
+}
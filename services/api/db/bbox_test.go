@@ -0,0 +1,48 @@
+package db
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestBBoxContainsEdges(t *testing.T) {
+	b := BBox{MinLon: -75.60, MinLat: 6.20, MaxLon: -75.50, MaxLat: 6.30}
+	for _, tc := range []struct {
+		name     string
+		lat, lon float64
+		want     bool
+	}{
+		{"centre", 6.25, -75.55, true},
+		{"west edge", 6.25, -75.60, true},
+		{"east edge", 6.25, -75.50, true},
+		{"south edge", 6.20, -75.55, true},
+		{"north edge", 6.30, -75.55, true},
+		{"south-west corner", 6.20, -75.60, true},
+		{"north-east corner", 6.30, -75.50, true},
+		{"just west", 6.25, -75.601, false},
+		{"just east", 6.25, -75.499, false},
+		{"just south", 6.199, -75.55, false},
+		{"just north", 6.301, -75.55, false},
+	} {
+		if got := b.Contains(tc.lat, tc.lon); got != tc.want {
+			t.Errorf("%s (%v, %v): got %v, want %v", tc.name, tc.lat, tc.lon, got, tc.want)
+		}
+	}
+}
+
+func TestBBoxClause(t *testing.T) {
+	var none *BBox
+	if sql, args := none.clause("s", 1); sql != "" || args != nil {
+		t.Errorf("nil box: got %q %v, want no clause", sql, args)
+	}
+
+	b := &BBox{MinLon: -75.60, MinLat: 6.20, MaxLon: -75.50, MaxLat: 6.30}
+	sql, args := b.clause("s", 2)
+	// BETWEEN includes both bounds, matching Contains.
+	if want := " AND s.lon BETWEEN $2 AND $3 AND s.lat BETWEEN $4 AND $5"; sql != want {
+		t.Errorf("clause %q, want %q", sql, want)
+	}
+	if want := []any{-75.60, -75.50, 6.20, 6.30}; !slices.Equal(args, want) {
+		t.Errorf("args %v, want %v", args, want)
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Domain errors returned by Store methods. Callers should test with errors.Is;
+// the original driver error stays wrapped for logging.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrInvalidInput = errors.New("invalid input")
+	ErrTimeout      = errors.New("timeout")
+	ErrUnavailable  = errors.New("database unavailable")
+	ErrConflict     = errors.New("already exists")
+)
+
+// ErrSensorNotFound is returned when a sensor lookup or write references an
+// unknown sensor.
+var ErrSensorNotFound = fmt.Errorf("sensor %w", ErrNotFound)
+
+// notFound builds an ErrNotFound naming the missing resource.
+func notFound(resource string) error {
+	return fmt.Errorf("%s %w", resource, ErrNotFound)
+}
+
+// mapRowErr is mapErr for single-row lookups, naming the missing resource.
+func mapRowErr(err error, resource string) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return notFound(resource)
+	}
+	return mapErr(err)
+}
+
+// mapErr classifies driver errors into the package's domain errors.
+func mapErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrInvalidInput) ||
+		errors.Is(err, ErrTimeout) || errors.Is(err, ErrUnavailable) ||
+		errors.Is(err, ErrConflict) {
+		return err
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	if errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "57014": // query_canceled (statement_timeout)
+			return fmt.Errorf("%w: %w", ErrTimeout, err)
+		case pgErr.Code == "23505": // unique_violation
+			return fmt.Errorf("%w: %w", ErrConflict, err)
+		case len(pgErr.Code) >= 2 && (pgErr.Code[:2] == "22" || pgErr.Code[:2] == "23"):
+			return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		case len(pgErr.Code) >= 2 && (pgErr.Code[:2] == "08" || pgErr.Code[:2] == "57" || pgErr.Code[:2] == "53"):
+			return fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		return err
+	}
+
+	var connErr *pgconn.ConnectError
+	var netErr *net.OpError
+	if errors.As(err, &connErr) || errors.As(err, &netErr) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,181 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// maxMeasurementCacheEntries bounds the micro-cache; expired entries are
+// swept once it is reached.
+const maxMeasurementCacheEntries = 1024
+
+// measurementLoadTimeout bounds a shared load. The load outlives the caller
+// that started it, so it cannot use that caller's deadline.
+const measurementLoadTimeout = 15 * time.Second
+
+// measurementCache collapses identical concurrent FetchMeasurements calls into
+// one query and keeps the result for a few seconds, so hot sensors embedded
+// on public pages cost one DB hit per TTL.
+type measurementCache struct {
+	ttl   time.Duration
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]measurementCacheEntry
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+type measurementCacheEntry struct {
+	rows    []Measurement
+	expires time.Time
+}
+
+// MeasurementCacheStats counts micro-cache lookups since startup. Hits
+// include callers that joined an in-flight query.
+type MeasurementCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+type cacheBypassKey struct{}
+
+// WithCacheBypass marks ctx so measurement reads skip the micro-cache.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
+
+// EnableMeasurementCache turns on the FetchMeasurements micro-cache with the
+// given TTL; a non-positive ttl leaves it disabled. Call before serving.
+func (s *Store) EnableMeasurementCache(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.mcache = &measurementCache{ttl: ttl, entries: make(map[string]measurementCacheEntry)}
+}
+
+// MeasurementCacheStats reports micro-cache counters; zero when disabled.
+func (s *Store) MeasurementCacheStats() MeasurementCacheStats {
+	if s.mcache == nil {
+		return MeasurementCacheStats{}
+	}
+	return MeasurementCacheStats{Hits: s.mcache.hits.Load(), Misses: s.mcache.misses.Load()}
+}
+
+// cacheKey normalizes a query. Bounds are truncated to the second so requests
+// whose range ends at "now" share an entry; the result is at most a TTL stale
+// anyway.
+func (q MeasurementQuery) cacheKey() string {
+	bound := func(t *time.Time) int64 {
+		if t == nil {
+			return -1
+		}
+		return t.Unix()
+	}
+	after := "-"
+	if q.After != nil {
+		after = fmt.Sprintf("%d/%d", q.After.TS.UnixNano(), q.After.ID)
+	}
+	return fmt.Sprintf("%s|%t|%d|%d|%d|%d|%s|%s|%d|%t", q.SensorID, q.UseClean, q.Limit, q.Offset, bound(q.Since), bound(q.Until), q.Variable, after, bound(q.AsOf), q.Descending)
+}
+
+// fetch returns a copy of the cached rows for q or runs load once for all
+// concurrent callers. The shared load runs on a context detached from ctx,
+// so a caller that disconnects fails alone instead of cancelling the query
+// for everyone who joined it. A ctx marked WithCacheBypass calls load
+// directly.
+func (c *measurementCache) fetch(ctx context.Context, q MeasurementQuery, load func(context.Context) ([]Measurement, error)) ([]Measurement, error) {
+	if cacheBypassed(ctx) {
+		return load(ctx)
+	}
+	key := q.cacheKey()
+	now := time.Now()
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		c.hits.Add(1)
+		recordCacheHit(ctx, key, "hit", len(entry.rows))
+		return append([]Measurement(nil), entry.rows...), nil
+	}
+
+	// led is set only when this caller's load runs; callers that joined an
+	// in-flight query see it unset. The load finishes before its result is
+	// delivered, so reading led afterwards is safe.
+	var led bool
+	ch := c.group.DoChan(key, func() (any, error) {
+		led = true
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), measurementLoadTimeout)
+		defer cancel()
+		rows, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.store(key, rows)
+		return rows, nil
+	})
+	select {
+	case res := <-ch:
+		if !led {
+			c.hits.Add(1)
+		} else {
+			c.misses.Add(1)
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		rows := res.Val.([]Measurement)
+		if !led {
+			// The leader's recorder saw the statement; this caller ran none.
+			recordCacheHit(ctx, key, "shared", len(rows))
+		}
+		return append([]Measurement(nil), rows...), nil
+	case <-ctx.Done():
+		return nil, mapErr(ctx.Err())
+	}
+}
+
+// recordCacheHit notes a read served without a statement in ctx's query
+// recorder, so debug output does not suggest the request skipped the store.
+func recordCacheHit(ctx context.Context, key, kind string, rows int) {
+	rec := queryRecorderFrom(ctx)
+	if rec == nil {
+		return
+	}
+	rec.add(QueryTrace{
+		Query: "measurement cache " + kind,
+		Args:  []string{summarizeArg(key)},
+		Rows:  int64(rows),
+		Cache: kind,
+	})
+}
+
+func (c *measurementCache) store(key string, rows []Measurement) {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxMeasurementCacheEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxMeasurementCacheEntries {
+			return
+		}
+	}
+	c.entries[key] = measurementCacheEntry{rows: rows, expires: now.Add(c.ttl)}
+}
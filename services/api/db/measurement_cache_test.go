@@ -0,0 +1,169 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestCache() *measurementCache {
+	return &measurementCache{ttl: time.Minute, entries: make(map[string]measurementCacheEntry)}
+}
+
+func TestMeasurementCacheHitsAndMisses(t *testing.T) {
+	c := newTestCache()
+	var loads atomic.Int32
+	load := func(context.Context) ([]Measurement, error) {
+		loads.Add(1)
+		return []Measurement{{SensorID: "a", ID: 1}}, nil
+	}
+	q := MeasurementQuery{SensorID: "a", Limit: 10}
+
+	for i := 0; i < 3; i++ {
+		rows, err := c.fetch(context.Background(), q, load)
+		if err != nil || len(rows) != 1 {
+			t.Fatalf("fetch %d: %v, %v", i, rows, err)
+		}
+		rows[0].SensorID = "mutated"
+	}
+	if _, err := c.fetch(context.Background(), MeasurementQuery{SensorID: "b", Limit: 10}, load); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := loads.Load(); got != 2 {
+		t.Errorf("loads = %d, want one per distinct query", got)
+	}
+	if got, want := (MeasurementCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}), (MeasurementCacheStats{Hits: 2, Misses: 2}); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+	rows, _ := c.fetch(context.Background(), q, load)
+	if rows[0].SensorID != "a" {
+		t.Error("a caller's changes leaked into the cached rows")
+	}
+}
+
+func TestMeasurementCacheBypass(t *testing.T) {
+	c := newTestCache()
+	var loads atomic.Int32
+	load := func(context.Context) ([]Measurement, error) {
+		loads.Add(1)
+		return nil, nil
+	}
+	ctx := WithCacheBypass(context.Background())
+	q := MeasurementQuery{SensorID: "a"}
+	for i := 0; i < 2; i++ {
+		if _, err := c.fetch(ctx, q, load); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := loads.Load(); got != 2 {
+		t.Errorf("loads = %d, want every bypassed read to reach the loader", got)
+	}
+	if c.hits.Load() != 0 || c.misses.Load() != 0 || len(c.entries) != 0 {
+		t.Errorf("bypassed reads touched the cache: hits %d, misses %d, entries %d", c.hits.Load(), c.misses.Load(), len(c.entries))
+	}
+}
+
+// A caller that gives up must not fail the others joined on its load.
+func TestMeasurementCacheLoadOutlivesFirstCaller(t *testing.T) {
+	c := newTestCache()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(ctx context.Context) ([]Measurement, error) {
+		close(started)
+		select {
+		case <-release:
+			return []Measurement{{SensorID: "a"}}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	q := MeasurementQuery{SensorID: "a"}
+
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.fetch(firstCtx, q, load)
+		firstErr <- err
+	}()
+	<-started
+
+	joined := make(chan error, 1)
+	go func() {
+		rows, err := c.fetch(context.Background(), q, load)
+		if err == nil && len(rows) != 1 {
+			err = errors.New("no rows")
+		}
+		joined <- err
+	}()
+
+	cancelFirst()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("first caller: got %v, want context.Canceled", err)
+	}
+	// Give the second caller time to join the in-flight load.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-joined; err != nil {
+		t.Errorf("joined caller: %v", err)
+	}
+}
+
+// Reads served without a statement show up in the debug recorder as cache
+// hits; the caller whose load ran is counted as the miss.
+func TestMeasurementCacheRecordsHits(t *testing.T) {
+	c := newTestCache()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	load := func(context.Context) ([]Measurement, error) {
+		close(started)
+		<-release
+		return []Measurement{{SensorID: "a"}, {SensorID: "a"}}, nil
+	}
+	q := MeasurementQuery{SensorID: "a"}
+
+	leader, joiner, later := &QueryRecorder{}, &QueryRecorder{}, &QueryRecorder{}
+	leaderDone := make(chan error, 1)
+	go func() {
+		_, err := c.fetch(WithQueryRecorder(context.Background(), leader), q, load)
+		leaderDone <- err
+	}()
+	<-started
+	joinerDone := make(chan error, 1)
+	go func() {
+		_, err := c.fetch(WithQueryRecorder(context.Background(), joiner), q, load)
+		joinerDone <- err
+	}()
+	// Give the second caller time to join the in-flight load.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	for _, done := range []chan error{leaderDone, joinerDone} {
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := c.fetch(WithQueryRecorder(context.Background(), later), q, load); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := leader.Queries(); len(got) != 0 {
+		t.Errorf("leader recorded %+v, want only its own statement", got)
+	}
+	for name, tc := range map[string]struct {
+		rec  *QueryRecorder
+		want string
+	}{
+		"joiner": {joiner, "shared"},
+		"later":  {later, "hit"},
+	} {
+		got := tc.rec.Queries()
+		if len(got) != 1 || got[0].Cache != tc.want || got[0].Rows != 2 {
+			t.Errorf("%s recorded %+v, want one %q trace with 2 rows", name, got, tc.want)
+		}
+	}
+	if got, want := (MeasurementCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}), (MeasurementCacheStats{Hits: 2, Misses: 1}); got != want {
+		t.Errorf("stats = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,24 @@
+package db
+
+import "time"
+
+// PoolStats is a snapshot of connection pool pressure.
+type PoolStats struct {
+	AcquiredConns int32
+	MaxConns      int32
+	// AcquireCount and AcquireDuration are cumulative since the pool opened;
+	// the average wait over an interval is the ratio of their deltas.
+	AcquireCount    int64
+	AcquireDuration time.Duration
+}
+
+// PoolStats reports the current pool utilization and cumulative acquire waits.
+func (s *Store) PoolStats() PoolStats {
+	st := s.pool.Stat()
+	return PoolStats{
+		AcquiredConns:   st.AcquiredConns(),
+		MaxConns:        st.MaxConns(),
+		AcquireCount:    st.AcquireCount(),
+		AcquireDuration: st.AcquireDuration(),
+	}
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxTraceSQL and maxTraceArg bound how much of a statement and of a string
+// argument a QueryTrace keeps.
+const (
+	maxTraceSQL = 160
+	maxTraceArg = 64
+)
+
+// QueryTrace describes one statement executed for a request.
+type QueryTrace struct {
+	Query      string   `json:"query"`
+	Args       []string `json:"args"`
+	Rows       int64    `json:"rows"`
+	DurationMS float64  `json:"duration_ms"`
+	Error      string   `json:"error,omitempty"`
+	// Cache is "hit" when the rows came from the micro-cache and "shared"
+	// when they came from another request's in-flight query; no statement
+	// ran for this request in either case.
+	Cache string `json:"cache,omitempty"`
+}
+
+// QueryRecorder collects the statements run under a context carrying it. A
+// nil recorder records nothing.
+type QueryRecorder struct {
+	mu      sync.Mutex
+	queries []QueryTrace
+}
+
+// Queries returns the statements recorded so far.
+func (r *QueryRecorder) Queries() []QueryTrace {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]QueryTrace(nil), r.queries...)
+}
+
+func (r *QueryRecorder) add(t QueryTrace) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, t)
+}
+
+type queryRecorderKey struct{}
+
+// WithQueryRecorder makes statements run under ctx report to rec.
+func WithQueryRecorder(ctx context.Context, rec *QueryRecorder) context.Context {
+	return context.WithValue(ctx, queryRecorderKey{}, rec)
+}
+
+func queryRecorderFrom(ctx context.Context) *QueryRecorder {
+	rec, _ := ctx.Value(queryRecorderKey{}).(*QueryRecorder)
+	return rec
+}
+
+// queryTracer feeds the QueryRecorder of the query's context, if any.
+// Contexts without a recorder skip all work.
+type queryTracer struct{}
+
+type queryStartKey struct{}
+
+type queryStart struct {
+	rec   *QueryRecorder
+	sql   string
+	args  []any
+	start time.Time
+}
+
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	rec := queryRecorderFrom(ctx)
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, queryStartKey{}, queryStart{rec: rec, sql: data.SQL, args: data.Args, start: time.Now()})
+}
+
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	qs, ok := ctx.Value(queryStartKey{}).(queryStart)
+	if !ok {
+		return
+	}
+	t := QueryTrace{
+		Query:      summarizeSQL(qs.sql),
+		Args:       make([]string, len(qs.args)),
+		Rows:       data.CommandTag.RowsAffected(),
+		DurationMS: float64(time.Since(qs.start).Microseconds()) / 1000,
+	}
+	for i, arg := range qs.args {
+		t.Args[i] = summarizeArg(arg)
+	}
+	if data.Err != nil {
+		t.Error = data.Err.Error()
+	}
+	qs.rec.add(t)
+}
+
+// summarizeSQL collapses whitespace and truncates a statement.
+func summarizeSQL(sql string) string {
+	s := strings.Join(strings.Fields(sql), " ")
+	if len(s) > maxTraceSQL {
+		s = s[:maxTraceSQL] + "…"
+	}
+	return s
+}
+
+// summarizeArg renders scalars and times by value, long strings truncated,
+// and anything else by type and length only.
+func summarizeArg(arg any) string {
+	switch v := arg.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		if len(v) > maxTraceArg {
+			return fmt.Sprintf("%q… (%d bytes)", v[:maxTraceArg], len(v))
+		}
+		return fmt.Sprintf("%q", v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "NULL"
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case bool, int, int32, int64, float32, float64:
+		return fmt.Sprint(v)
+	case []string:
+		return fmt.Sprintf("[]string(len=%d)", len(v))
+	case []byte:
+		return fmt.Sprintf("[]byte(len=%d)", len(v))
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -11,18 +11,57 @@ import (
 
 // Store wraps database access helpers.
 type Store struct {
-	pool *pgxpool.Pool
+	pool   *pgxpool.Pool
+	mcache *measurementCache
 }
 
-// New creates a Store backed by a pgx pool.
-func New(ctx context.Context, databaseURL string) (*Store, error) {
-	pool, err := pgxpool.New(ctx, databaseURL)
+// New creates a Store backed by a pgx pool keeping at least minConns idle
+// connections once warmed.
+func New(ctx context.Context, databaseURL string, minConns int) (*Store, error) {
+	poolCfg, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	poolCfg.MinConns = int32(minConns)
+	poolCfg.ConnConfig.Tracer = queryTracer{}
+
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, err
 	}
 	return &Store{pool: pool}, nil
 }
 
+// Warm dials and pings up to MinConns connections (at least one) so the first
+// requests don't pay for connection setup. It returns how many connections
+// were established before an error or ctx expiry.
+func (s *Store) Warm(ctx context.Context) (int, error) {
+	n := int(s.pool.Config().MinConns)
+	if n < 1 {
+		n = 1
+	}
+
+	conns := make([]*pgxpool.Conn, 0, n)
+	defer func() {
+		for _, conn := range conns {
+			conn.Release()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		conn, err := s.pool.Acquire(ctx)
+		if err != nil {
+			return len(conns), mapErr(err)
+		}
+		if err := conn.Ping(ctx); err != nil {
+			conn.Release()
+			return len(conns), mapErr(err)
+		}
+		conns = append(conns, conn)
+	}
+	return len(conns), nil
+}
+
 // Close releases the pool resources.
 func (s *Store) Close() {
 	if s.pool != nil {
@@ -47,15 +86,17 @@ type Sensor struct {
 
 const listSensorsSQL = `
     SELECT id, name, provider_id, lat, lon, city, subbasin, barrio, metadata, created_at, updated_at
-    FROM shizuku.sensors
-    ORDER BY id
+    FROM shizuku.sensors s
+    WHERE true
 `
 
-// ListSensors returns all sensor metadata.
-func (s *Store) ListSensors(ctx context.Context) ([]Sensor, error) {
-	rows, err := s.pool.Query(ctx, listSensorsSQL)
+// ListSensors returns sensor metadata ordered by id, optionally restricted to
+// sensors inside bbox.
+func (s *Store) ListSensors(ctx context.Context, bbox *BBox) ([]Sensor, error) {
+	clause, args := bbox.clause("s", 1)
+	rows, err := s.pool.Query(ctx, listSensorsSQL+clause+" ORDER BY id", args...)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -75,24 +116,87 @@ func (s *Store) ListSensors(ctx context.Context) ([]Sensor, error) {
 			&sensor.CreatedAt,
 			&sensor.UpdatedAt,
 		); err != nil {
-			return nil, err
+			return nil, mapErr(err)
+		}
+		sensors = append(sensors, sensor)
+	}
+	return sensors, mapErr(rows.Err())
+}
+
+// ListSensorsInBBox returns the sensors whose coordinates fall inside bbox,
+// edges included, ordered by id.
+func (s *Store) ListSensorsInBBox(ctx context.Context, bbox BBox) ([]Sensor, error) {
+	return s.ListSensors(ctx, &bbox)
+}
+
+// ListSensorsPage returns one page of sensors ordered by id, optionally
+// restricted to sensors inside bbox, together with the number of sensors
+// matching the filter.
+func (s *Store) ListSensorsPage(ctx context.Context, bbox *BBox, limit, offset int) ([]Sensor, int, error) {
+	clause, args := bbox.clause("s", 1)
+
+	var total int
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM shizuku.sensors s WHERE true"+clause, args...).Scan(&total); err != nil {
+		return nil, 0, mapErr(err)
+	}
+	if offset >= total {
+		return []Sensor{}, total, nil
+	}
+
+	argPos := len(args) + 1
+	query := listSensorsSQL + clause + " ORDER BY id LIMIT $" + strconv.Itoa(argPos) + " OFFSET $" + strconv.Itoa(argPos+1)
+	rows, err := s.pool.Query(ctx, query, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, mapErr(err)
+	}
+	defer rows.Close()
+
+	sensors := make([]Sensor, 0, limit)
+	for rows.Next() {
+		var sensor Sensor
+		if err := rows.Scan(
+			&sensor.ID,
+			&sensor.Name,
+			&sensor.ProviderID,
+			&sensor.Lat,
+			&sensor.Lon,
+			&sensor.City,
+			&sensor.Subbasin,
+			&sensor.Barrio,
+			&sensor.Metadata,
+			&sensor.CreatedAt,
+			&sensor.UpdatedAt,
+		); err != nil {
+			return nil, 0, mapErr(err)
 		}
 		sensors = append(sensors, sensor)
 	}
-	return sensors, rows.Err()
+	if err := rows.Err(); err != nil {
+		return nil, 0, mapErr(err)
+	}
+	return sensors, total, nil
 }
 
-// Measurement represents either a clean or raw measurement.
+// Measurement represents either a clean or raw measurement. ValueMM is nil
+// for rows whose value was nulled, e.g. by a measurement repair.
 type Measurement struct {
+	// ID is the row id, used as the keyset tie-breaker for rows sharing a
+	// timestamp; it is not part of the API payload.
+	ID               int64     `json:"-"`
 	SensorID         string    `json:"sensor_id"`
 	Timestamp        time.Time `json:"ts"`
-	ValueMM          float64   `json:"value_mm"`
+	ValueMM          *float64  `json:"value_mm"`
 	QCFlags          *int32    `json:"qc_flags,omitempty"`
 	ImputationMethod *string   `json:"imputation_method,omitempty"`
 	Quality          *float64  `json:"quality,omitempty"`
 	Source           *string   `json:"source,omitempty"`
+	Variable         *string   `json:"variable,omitempty"`
 }
 
+// DefaultVariable is the measured variable served when none is requested.
+// The clean table only carries this variable.
+const DefaultVariable = "precipitacion"
+
 // MeasurementQuery holds filters for retrieving measurements.
 type MeasurementQuery struct {
 	SensorID string
@@ -100,22 +204,65 @@ type MeasurementQuery struct {
 	Limit    int
 	Since    *time.Time
 	Until    *time.Time
+	// Variable filters raw rows by their variable column; ignored for clean
+	// queries, which only hold DefaultVariable.
+	Variable string
+	// After resumes the series strictly after the given row (keyset
+	// pagination over ts, id), in the direction of the query.
+	After *MeasurementCursor
+	// Descending orders rows newest first, so Limit keeps the newest rows.
+	Descending bool
+	// Offset skips rows for page-based pagination.
+	Offset int
+	// AsOf, when set, hides rows created after it, so offset pages fetched
+	// while new rows arrive stay consistent.
+	AsOf *time.Time
+}
+
+// MeasurementCursor identifies a row position within one sensor's series.
+type MeasurementCursor struct {
+	TS time.Time
+	ID int64
 }
 
 const cleanMeasurementsBase = `
-    SELECT sensor_id, ts, value_mm, qc_flags, imputation_method, NULL::double precision AS quality, NULL::text AS source
+    SELECT sensor_id, ts, value_mm, qc_flags, imputation_method, NULL::double precision AS quality, NULL::text AS source, NULL::text AS variable, id
     FROM shizuku.clean_measurements
     WHERE sensor_id = $1
 `
 
 const rawMeasurementsBase = `
-    SELECT sensor_id, ts, value_mm, NULL::integer AS qc_flags, NULL::text AS imputation_method, quality, source
+    SELECT sensor_id, ts, value_mm, NULL::integer AS qc_flags, NULL::text AS imputation_method, quality::double precision, source, variable, id
     FROM shizuku.raw_measurements
     WHERE sensor_id = $1
 `
 
-// FetchMeasurements returns measurements for a sensor based on the query.
-func (s *Store) FetchMeasurements(ctx context.Context, q MeasurementQuery) ([]Measurement, error) {
+// sql builds the measurement query and its positional arguments.
+func (q MeasurementQuery) sql() (string, []any) {
+	filtered, args := q.filtered()
+	argPos := len(args) + 1
+
+	order := " ORDER BY ts, id"
+	if q.Descending {
+		order = " ORDER BY ts DESC, id DESC"
+	}
+	limit := ""
+	if q.Limit > 0 {
+		limit = " LIMIT $" + strconv.Itoa(argPos)
+		args = append(args, q.Limit)
+		argPos++
+	}
+	if q.Offset > 0 {
+		limit += " OFFSET $" + strconv.Itoa(argPos)
+		args = append(args, q.Offset)
+	}
+
+	return filtered + order + limit, args
+}
+
+// filtered builds the unordered, unlimited query selecting every row that
+// matches the filters.
+func (q MeasurementQuery) filtered() (string, []any) {
 	base := cleanMeasurementsBase
 	if !q.UseClean {
 		base = rawMeasurementsBase
@@ -134,22 +281,96 @@ func (s *Store) FetchMeasurements(ctx context.Context, q MeasurementQuery) ([]Me
 		args = append(args, *q.Until)
 		argPos++
 	}
-	order := " ORDER BY ts"
-	limit := ""
-	if q.Limit > 0 {
-		limit = " LIMIT $" + strconv.Itoa(argPos)
-		args = append(args, q.Limit)
+	if !q.UseClean && q.Variable != "" {
+		clause += " AND variable = $" + strconv.Itoa(argPos)
+		args = append(args, q.Variable)
+		argPos++
+	}
+	if q.AsOf != nil {
+		clause += " AND created_at <= $" + strconv.Itoa(argPos)
+		args = append(args, *q.AsOf)
+		argPos++
+	}
+	if q.After != nil {
+		cmp := " > "
+		if q.Descending {
+			cmp = " < "
+		}
+		clause += " AND (ts, id)" + cmp + "($" + strconv.Itoa(argPos) + ", $" + strconv.Itoa(argPos+1) + ")"
+		args = append(args, q.After.TS, q.After.ID)
 	}
 
-	sql := base + clause + order + limit
+	return base + clause, args
+}
 
-	rows, err := s.pool.Query(ctx, sql, args...)
+// FetchMeasurements returns measurements for a sensor based on the query.
+// When the micro-cache is enabled, identical queries within its TTL share one
+// database round trip unless ctx carries WithCacheBypass.
+func (s *Store) FetchMeasurements(ctx context.Context, q MeasurementQuery) ([]Measurement, error) {
+	if s.mcache == nil {
+		return s.fetchMeasurements(ctx, q)
+	}
+	return s.mcache.fetch(ctx, q, func(ctx context.Context) ([]Measurement, error) {
+		return s.fetchMeasurements(ctx, q)
+	})
+}
+
+func (s *Store) fetchMeasurements(ctx context.Context, q MeasurementQuery) ([]Measurement, error) {
+	measurements := make([]Measurement, 0)
+	err := s.StreamMeasurements(ctx, q, func(m Measurement) error {
+		measurements = append(measurements, m)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
+	return measurements, nil
+}
+
+// FetchMeasurementsPage returns one page of measurements selected by q's
+// Limit and Offset, together with the number of rows matching the filters.
+func (s *Store) FetchMeasurementsPage(ctx context.Context, q MeasurementQuery) ([]Measurement, int, error) {
+	filtered, args := q.filtered()
+
+	var total int
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM ("+filtered+") m", args...).Scan(&total); err != nil {
+		return nil, 0, mapErr(err)
+	}
+	if q.Offset >= total {
+		return []Measurement{}, total, nil
+	}
+
+	measurements, err := s.FetchMeasurements(ctx, q)
+	if err != nil {
+		return nil, 0, err
+	}
+	return measurements, total, nil
+}
+
+// HasMeasurements reports whether the query matches at least one row.
+func (s *Store) HasMeasurements(ctx context.Context, q MeasurementQuery) (bool, error) {
+	q.Limit = 1
+	sql, args := q.sql()
+
+	var exists bool
+	if err := s.pool.QueryRow(ctx, "SELECT EXISTS ("+sql+")", args...).Scan(&exists); err != nil {
+		return false, mapErr(err)
+	}
+	return exists, nil
+}
+
+// StreamMeasurements runs the query and calls fn for each row in timestamp
+// order without buffering the series. Iteration stops at the first error
+// returned by fn.
+func (s *Store) StreamMeasurements(ctx context.Context, q MeasurementQuery, fn func(Measurement) error) error {
+	sql, args := q.sql()
+
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return mapErr(err)
+	}
 	defer rows.Close()
 
-	measurements := make([]Measurement, 0)
 	for rows.Next() {
 		var m Measurement
 		if err := rows.Scan(
@@ -160,24 +381,32 @@ func (s *Store) FetchMeasurements(ctx context.Context, q MeasurementQuery) ([]Me
 			&m.ImputationMethod,
 			&m.Quality,
 			&m.Source,
+			&m.Variable,
+			&m.ID,
 		); err != nil {
-			return nil, err
+			return mapErr(err)
+		}
+		if err := fn(m); err != nil {
+			return err
 		}
-		measurements = append(measurements, m)
 	}
-	return measurements, rows.Err()
+	return mapErr(rows.Err())
 }
 
 const latestCleanSQL = `
-    SELECT sensor_id, ts, value_mm, qc_flags, imputation_method
-    FROM shizuku.latest_clean_measurements
+    SELECT l.sensor_id, l.ts, l.value_mm, l.qc_flags, l.imputation_method
+    FROM shizuku.latest_clean_measurements l
+    JOIN shizuku.sensors s ON s.id = l.sensor_id
+    WHERE true
 `
 
-// LatestClean returns the latest clean measurement per sensor.
-func (s *Store) LatestClean(ctx context.Context) ([]Measurement, error) {
-	rows, err := s.pool.Query(ctx, latestCleanSQL)
+// LatestClean returns the latest clean measurement per sensor, optionally
+// restricted to sensors inside bbox.
+func (s *Store) LatestClean(ctx context.Context, bbox *BBox) ([]Measurement, error) {
+	clause, args := bbox.clause("s", 1)
+	rows, err := s.pool.Query(ctx, latestCleanSQL+clause, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -185,11 +414,20 @@ func (s *Store) LatestClean(ctx context.Context) ([]Measurement, error) {
 	for rows.Next() {
 		var m Measurement
 		if err := rows.Scan(&m.SensorID, &m.Timestamp, &m.ValueMM, &m.QCFlags, &m.ImputationMethod); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
 		data = append(data, m)
 	}
-	return data, rows.Err()
+	return data, mapErr(rows.Err())
+}
+
+// CountSensors returns the number of sensors in the network.
+func (s *Store) CountSensors(ctx context.Context) (int, error) {
+	var count int
+	if err := s.pool.QueryRow(ctx, "SELECT COUNT(*) FROM shizuku.sensors").Scan(&count); err != nil {
+		return 0, mapErr(err)
+	}
+	return count, nil
 }
 
 // GridInfo represents grid metadata from the database.
@@ -208,7 +446,7 @@ type GridInfo struct {
 }
 
 const availableGridsSQL = `
-	SELECT ts
+	SELECT DISTINCT ts
 	FROM shizuku.grid_runs
 	WHERE status = 'done'
 	ORDER BY ts ASC
@@ -218,7 +456,7 @@ const availableGridsSQL = `
 func (s *Store) GetAvailableGridTimestamps(ctx context.Context) ([]time.Time, error) {
 	rows, err := s.pool.Query(ctx, availableGridsSQL)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -226,17 +464,18 @@ func (s *Store) GetAvailableGridTimestamps(ctx context.Context) ([]time.Time, er
 	for rows.Next() {
 		var ts time.Time
 		if err := rows.Scan(&ts); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
 		timestamps = append(timestamps, ts)
 	}
-	return timestamps, rows.Err()
+	return timestamps, mapErr(rows.Err())
 }
 
 const gridByTimestampSQL = `
     SELECT id, ts, res_m, bbox, crs, blob_url_json, blob_url_contours, status, message, created_at, updated_at
     FROM shizuku.grid_runs
     WHERE ts = $1 AND status = 'done'
+    ORDER BY ` + preferredRunOrder + `
     LIMIT 1
 `
 
@@ -259,7 +498,7 @@ func (s *Store) GetGridByTimestamp(ctx context.Context, timestamp time.Time) (*G
 		&g.CreatedAt,   // created_at
 		&g.UpdatedAt,   // updated_at
 	); err != nil {
-		return nil, err
+		return nil, mapRowErr(err, "grid")
 	}
 
 	// Parse bounds JSON array if present
@@ -284,6 +523,10 @@ type SensorSnapshot struct {
 	Lon        float64 `json:"lon"`
 	City       *string `json:"city,omitempty"`
 
+	// LocationEffectiveFrom is set when the position was resolved from the
+	// location history instead of the sensor's current coordinates.
+	LocationEffectiveFrom *time.Time `json:"location_effective_from,omitempty"`
+
 	// Measurement fields (may be nil if no measurement exists <= requested ts)
 	Ts         *time.Time `json:"ts,omitempty"`
 	ValueMM    *float64   `json:"value_mm,omitempty"`
@@ -293,11 +536,24 @@ type SensorSnapshot struct {
 	Source     *string    `json:"source,omitempty"`
 }
 
+// SnapshotFilter narrows the sensors a snapshot covers. The zero value covers
+// every sensor.
+type SnapshotFilter struct {
+	// IDs keeps only the listed sensors when non-empty.
+	IDs []string
+	// BBox keeps only sensors inside the box, tested against the coordinates
+	// the snapshot reports (the historical ones with resolveLocation).
+	BBox *BBox
+}
+
 // SnapshotAtTimestamp returns one row per sensor with the latest measurement
 // at-or-before the given timestamp. If useClean is true the query reads from
 // clean_measurements; otherwise it reads raw_measurements. Measurement fields
-// are nullable when no measurement exists.
-func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean bool) ([]SensorSnapshot, error) {
+// are nullable when no measurement exists. With resolveLocation, coordinates
+// come from the location history entry effective at ts when one exists.
+// The filter is applied to the sensor set before the measurement lookup, so
+// narrow snapshots only probe the sensors they return.
+func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean, resolveLocation bool, filter SnapshotFilter) ([]SensorSnapshot, error) {
 	// Build lateral subquery depending on clean/raw
 	var sub string
 	if useClean {
@@ -319,15 +575,41 @@ func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean
 		)`
 	}
 
-	sql := `SELECT sensors.id, sensors.name, sensors.provider_id, sensors.lat, sensors.lon, sensors.city,
+	loc := `(SELECT NULL::double precision AS lat, NULL::double precision AS lon, NULL::timestamptz AS effective_from)`
+	if resolveLocation {
+		loc = `(
+			SELECT lat, lon, effective_from
+			FROM shizuku.sensor_location_history
+			WHERE sensor_id = sensors.id AND effective_from <= $1
+			ORDER BY effective_from DESC
+			LIMIT 1
+		)`
+	}
+
+	sql := `SELECT sensors.id, sensors.name, sensors.provider_id,
+		COALESCE(h.lat, sensors.lat), COALESCE(h.lon, sensors.lon), sensors.city, h.effective_from,
 		m.ts, m.value_mm, m.qc_flags, m.imputation_method, m.quality, m.source
 		FROM shizuku.sensors
+		LEFT JOIN LATERAL ` + loc + ` h ON true
 		LEFT JOIN LATERAL ` + sub + ` m ON true
-		ORDER BY sensors.id`
+		WHERE true`
+
+	args := []any{ts}
+	if len(filter.IDs) > 0 {
+		args = append(args, filter.IDs)
+		sql += ` AND sensors.id = ANY($` + strconv.Itoa(len(args)) + `::text[])`
+	}
+	if b := filter.BBox; b != nil {
+		p := func(i int) string { return "$" + strconv.Itoa(len(args)+i) }
+		sql += ` AND COALESCE(h.lon, sensors.lon) BETWEEN ` + p(1) + ` AND ` + p(2) +
+			` AND COALESCE(h.lat, sensors.lat) BETWEEN ` + p(3) + ` AND ` + p(4)
+		args = append(args, b.MinLon, b.MaxLon, b.MinLat, b.MaxLat)
+	}
+	sql += ` ORDER BY sensors.id`
 
-	rows, err := s.pool.Query(ctx, sql, ts)
+	rows, err := s.pool.Query(ctx, sql, args...)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -348,6 +630,7 @@ func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean
 			&rec.Lat,
 			&rec.Lon,
 			&rec.City,
+			&rec.LocationEffectiveFrom,
 			&mTs,
 			&mValue,
 			&mQc,
@@ -355,7 +638,7 @@ func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean
 			&mQuality,
 			&mSource,
 		); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
 
 		rec.Ts = mTs
@@ -368,7 +651,7 @@ func (s *Store) SnapshotAtTimestamp(ctx context.Context, ts time.Time, useClean
 		out = append(out, rec)
 	}
 
-	return out, rows.Err()
+	return out, mapErr(rows.Err())
 }
 
 // AveragesResult holds average precipitation values for different windows.
@@ -394,7 +677,7 @@ func (s *Store) GetAverages(ctx context.Context) (*AveragesResult, error) {
 	row := s.pool.QueryRow(ctx, averagesSQL)
 	var a3, a6, a12, a24 *float64
 	if err := row.Scan(&a3, &a6, &a12, &a24); err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	return &AveragesResult{
 		Avg3h:  a3,
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// DuplicateGridRun is one member of a group of grid runs sharing a ts.
+type DuplicateGridRun struct {
+	ID        int       `json:"id"`
+	Status    string    `json:"status"`
+	Preferred bool      `json:"preferred"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// DuplicateGridGroup lists the runs recorded for a single grid timestamp.
+type DuplicateGridGroup struct {
+	Timestamp time.Time          `json:"timestamp"`
+	Runs      []DuplicateGridRun `json:"runs"`
+}
+
+// ListDuplicateGridRuns returns every ts with more than one grid run, newest
+// first. Within a group the run the API serves is marked preferred.
+func (s *Store) ListDuplicateGridRuns(ctx context.Context) ([]DuplicateGridGroup, error) {
+	query := `
+		WITH dup AS (
+			SELECT ts
+			FROM shizuku.grid_runs
+			GROUP BY ts
+			HAVING COUNT(*) > 1
+		)
+		SELECT g.ts, g.id, g.status, g.created_at, g.updated_at,
+		       g.id = (
+		           SELECT p.id FROM shizuku.grid_runs p
+		           WHERE p.ts = g.ts AND p.status = 'done'
+		           ORDER BY ` + preferredRunOrder + `
+		           LIMIT 1
+		       ) AS preferred
+		FROM shizuku.grid_runs g
+		JOIN dup ON dup.ts = g.ts
+		ORDER BY g.ts DESC, g.updated_at DESC, g.id DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	groups := make([]DuplicateGridGroup, 0)
+	for rows.Next() {
+		var ts time.Time
+		var run DuplicateGridRun
+		var preferred *bool
+		if err := rows.Scan(&ts, &run.ID, &run.Status, &run.CreatedAt, &run.UpdatedAt, &preferred); err != nil {
+			return nil, mapErr(err)
+		}
+		run.Preferred = preferred != nil && *preferred
+
+		if n := len(groups); n == 0 || !groups[n-1].Timestamp.Equal(ts) {
+			groups = append(groups, DuplicateGridGroup{Timestamp: ts})
+		}
+		groups[len(groups)-1].Runs = append(groups[len(groups)-1].Runs, run)
+	}
+
+	return groups, mapErr(rows.Err())
+}
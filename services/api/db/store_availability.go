@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// VariableFacet counts a sensor's raw rows for one measured variable.
+type VariableFacet struct {
+	Variable string    `json:"variable"`
+	Count    int       `json:"count"`
+	FirstTS  time.Time `json:"first_ts"`
+	LastTS   time.Time `json:"last_ts"`
+}
+
+// SeriesSpan is the time extent of a measurement series.
+type SeriesSpan struct {
+	Count   int        `json:"count"`
+	FirstTS *time.Time `json:"first_ts"`
+	LastTS  *time.Time `json:"last_ts"`
+}
+
+// SensorAvailability describes what data exists for a sensor.
+type SensorAvailability struct {
+	SensorID  string          `json:"sensor_id"`
+	Raw       SeriesSpan      `json:"raw"`
+	Clean     SeriesSpan      `json:"clean"`
+	Variables []VariableFacet `json:"variables"`
+}
+
+// GetSensorAvailability returns the raw and clean extents of a sensor's data
+// and the variables present in its raw rows.
+func (s *Store) GetSensorAvailability(ctx context.Context, sensorID string) (*SensorAvailability, error) {
+	out := &SensorAvailability{SensorID: sensorID, Variables: make([]VariableFacet, 0)}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT variable, COUNT(*), MIN(ts), MAX(ts)
+		FROM shizuku.raw_measurements
+		WHERE sensor_id = $1
+		GROUP BY variable
+		ORDER BY variable
+	`, sensorID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f VariableFacet
+		if err := rows.Scan(&f.Variable, &f.Count, &f.FirstTS, &f.LastTS); err != nil {
+			return nil, mapErr(err)
+		}
+		out.Variables = append(out.Variables, f)
+
+		out.Raw.Count += f.Count
+		if out.Raw.FirstTS == nil || f.FirstTS.Before(*out.Raw.FirstTS) {
+			first := f.FirstTS
+			out.Raw.FirstTS = &first
+		}
+		if out.Raw.LastTS == nil || f.LastTS.After(*out.Raw.LastTS) {
+			last := f.LastTS
+			out.Raw.LastTS = &last
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapErr(err)
+	}
+
+	if err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*), MIN(ts), MAX(ts)
+		FROM shizuku.clean_measurements
+		WHERE sensor_id = $1
+	`, sensorID).Scan(&out.Clean.Count, &out.Clean.FirstTS, &out.Clean.LastTS); err != nil {
+		return nil, mapErr(err)
+	}
+
+	return out, nil
+}
+
+// CleanCoverageUntil returns the latest clean timestamp of each sensor, i.e.
+// how far the QC pipeline has processed it. Sensors without clean rows are
+// absent from the map.
+func (s *Store) CleanCoverageUntil(ctx context.Context, sensorIDs []string) (map[string]time.Time, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT sensor_id, MAX(ts)
+		FROM shizuku.clean_measurements
+		WHERE sensor_id = ANY($1)
+		GROUP BY sensor_id
+	`, sensorIDs)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	out := make(map[string]time.Time, len(sensorIDs))
+	for rows.Next() {
+		var id string
+		var ts time.Time
+		if err := rows.Scan(&id, &ts); err != nil {
+			return nil, mapErr(err)
+		}
+		out[id] = ts.UTC()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, mapErr(err)
+	}
+	return out, nil
+}
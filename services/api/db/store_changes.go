@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SensorChange is one metadata field of a sensor that the watcher saw change.
+// Field is a sensors column or metadata.<key>; Old and New are null when the
+// value was unset.
+type SensorChange struct {
+	Field     string    `json:"field"`
+	Old       *string   `json:"old"`
+	New       *string   `json:"new"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ListSensorChanges returns one page of a sensor's metadata changes, newest
+// first, together with the total number of changes recorded for it.
+func (s *Store) ListSensorChanges(ctx context.Context, sensorID string, limit, offset int) ([]SensorChange, int, error) {
+	var total int
+	if err := s.pool.QueryRow(ctx, `SELECT COUNT(*) FROM shizuku.sensor_changes WHERE sensor_id = $1`, sensorID).Scan(&total); err != nil {
+		return nil, 0, mapErr(err)
+	}
+	if offset >= total {
+		return []SensorChange{}, total, nil
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT field, old_value, new_value, changed_at
+		FROM shizuku.sensor_changes
+		WHERE sensor_id = $1
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, sensorID, limit, offset)
+	if err != nil {
+		return nil, 0, mapErr(err)
+	}
+	defer rows.Close()
+
+	changes := make([]SensorChange, 0, limit)
+	for rows.Next() {
+		var ch SensorChange
+		if err := rows.Scan(&ch.Field, &ch.Old, &ch.New, &ch.ChangedAt); err != nil {
+			return nil, 0, mapErr(err)
+		}
+		changes = append(changes, ch)
+	}
+	return changes, total, mapErr(rows.Err())
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ComparePoint is one timestamp of a sensor's raw and clean series side by
+// side. A side without a row at the timestamp has null value fields; qc_flags
+// and imputation_method come from the clean row.
+type ComparePoint struct {
+	Ts               time.Time `json:"ts"`
+	RawValueMM       *float64  `json:"raw_value_mm"`
+	CleanValueMM     *float64  `json:"clean_value_mm"`
+	QCFlags          *int32    `json:"qc_flags"`
+	ImputationMethod *string   `json:"imputation_method"`
+	// InRaw and InClean tell a missing row from a row with a null value.
+	InRaw   bool `json:"-"`
+	InClean bool `json:"-"`
+}
+
+// CompareRawClean returns the raw and clean precipitation rows of a sensor in
+// [start, end] joined on timestamp, oldest first, at most limit points. Where
+// several raw sources share a timestamp the current feed wins; of several
+// clean versions the latest wins.
+func (s *Store) CompareRawClean(ctx context.Context, sensorID string, start, end time.Time, limit int) ([]ComparePoint, error) {
+	rows, err := s.pool.Query(ctx, `
+		WITH raw AS (
+			SELECT DISTINCT ON (ts) sensor_id, ts, value_mm
+			FROM shizuku.raw_measurements
+			WHERE sensor_id = $1 AND ts >= $2 AND ts <= $3 AND variable = $4
+			ORDER BY ts, (source = 'current') DESC, id DESC
+		), clean AS (
+			SELECT DISTINCT ON (ts) sensor_id, ts, value_mm, qc_flags, imputation_method
+			FROM shizuku.clean_measurements
+			WHERE sensor_id = $1 AND ts >= $2 AND ts <= $3
+			ORDER BY ts, version DESC
+		)
+		SELECT COALESCE(r.ts, c.ts) AS ts, r.value_mm, c.value_mm, c.qc_flags, c.imputation_method,
+		       r.ts IS NOT NULL, c.ts IS NOT NULL
+		FROM raw r
+		FULL OUTER JOIN clean c ON c.sensor_id = r.sensor_id AND c.ts = r.ts
+		ORDER BY 1
+		LIMIT $5
+	`, sensorID, start, end, DefaultVariable, limit)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	points := make([]ComparePoint, 0)
+	for rows.Next() {
+		var p ComparePoint
+		if err := rows.Scan(&p.Ts, &p.RawValueMM, &p.CleanValueMM, &p.QCFlags, &p.ImputationMethod, &p.InRaw, &p.InClean); err != nil {
+			return nil, mapErr(err)
+		}
+		points = append(points, p)
+	}
+	return points, mapErr(rows.Err())
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// CityTotals is the clean rainfall of one city's sensors in two windows.
+type CityTotals struct {
+	City            string
+	CurrentMM       float64
+	PreviousMM      float64
+	CurrentSensors  int
+	PreviousSensors int
+}
+
+// CompareCityTotals sums clean value_mm per city over the half-open windows
+// [curStart, curEnd) and [prevStart, prevEnd) with one conditional
+// aggregation. Sensors without a city are grouped under "".
+func (s *Store) CompareCityTotals(ctx context.Context, curStart, curEnd, prevStart, prevEnd time.Time) ([]CityTotals, error) {
+	query := `
+		SELECT COALESCE(s.city, ''),
+			COALESCE(SUM(m.value_mm) FILTER (WHERE m.ts >= $1 AND m.ts < $2), 0),
+			COALESCE(SUM(m.value_mm) FILTER (WHERE m.ts >= $3 AND m.ts < $4), 0),
+			COUNT(DISTINCT m.sensor_id) FILTER (WHERE m.ts >= $1 AND m.ts < $2),
+			COUNT(DISTINCT m.sensor_id) FILTER (WHERE m.ts >= $3 AND m.ts < $4)
+		FROM shizuku.clean_measurements m
+		JOIN shizuku.sensors s ON s.id = m.sensor_id
+		WHERE (m.ts >= $1 AND m.ts < $2) OR (m.ts >= $3 AND m.ts < $4)
+		GROUP BY 1
+		ORDER BY 1
+	`
+
+	rows, err := s.pool.Query(ctx, query, curStart, curEnd, prevStart, prevEnd)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	totals := make([]CityTotals, 0)
+	for rows.Next() {
+		var t CityTotals
+		if err := rows.Scan(&t.City, &t.CurrentMM, &t.PreviousMM, &t.CurrentSensors, &t.PreviousSensors); err != nil {
+			return nil, mapErr(err)
+		}
+		totals = append(totals, t)
+	}
+	return totals, mapErr(rows.Err())
+}
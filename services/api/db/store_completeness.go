@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// DailyCompleteness compares a sensor's clean sample count on one local day
+// with the count expected at the nominal interval. Ratio is nil when nothing
+// was expected, e.g. for a sliver of a day shorter than the interval.
+type DailyCompleteness struct {
+	Date     string   `json:"date"`
+	Expected int      `json:"expected"`
+	Actual   int      `json:"actual"`
+	Ratio    *float64 `json:"ratio"`
+}
+
+// SensorCompleteness counts a sensor's clean measurements per calendar day,
+// with days cut in tz, over [start, end). Each day's expected count is the
+// part of the day inside the range divided by interval, so partial first and
+// last days are not penalised. Days come from generate_series, so days
+// without any sample are returned with actual 0.
+func (s *Store) SensorCompleteness(ctx context.Context, sensorID string, start, end time.Time, tz string, interval time.Duration) ([]DailyCompleteness, error) {
+	query := `
+		WITH days AS (
+			SELECT d::date AS day,
+			       GREATEST(d AT TIME ZONE $4::text, $2::timestamptz) AS lo,
+			       LEAST((d + interval '1 day') AT TIME ZONE $4::text, $3::timestamptz) AS hi
+			FROM generate_series(
+				($2::timestamptz AT TIME ZONE $4::text)::date::timestamp,
+				($3::timestamptz AT TIME ZONE $4::text)::date::timestamp,
+				interval '1 day'
+			) d
+		), counted AS (
+			SELECT days.day,
+			       FLOOR(EXTRACT(EPOCH FROM days.hi - days.lo)::float8 / $5::float8)::int AS expected,
+			       COUNT(m.ts)::int AS actual
+			FROM days
+			LEFT JOIN shizuku.clean_measurements m
+			       ON m.sensor_id = $1 AND m.ts >= days.lo AND m.ts < days.hi
+			WHERE days.lo < days.hi
+			GROUP BY days.day, days.lo, days.hi
+		)
+		SELECT to_char(day, 'YYYY-MM-DD'), expected, actual,
+		       CASE WHEN expected > 0 THEN actual::float8 / expected END
+		FROM counted
+		ORDER BY day
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID, start, end, tz, interval.Seconds())
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	days := make([]DailyCompleteness, 0)
+	for rows.Next() {
+		var d DailyCompleteness
+		if err := rows.Scan(&d.Date, &d.Expected, &d.Actual, &d.Ratio); err != nil {
+			return nil, mapErr(err)
+		}
+		days = append(days, d)
+	}
+	return days, mapErr(rows.Err())
+}
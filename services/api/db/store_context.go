@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// DailyTotal is a sensor's clean rainfall on one local calendar day.
+type DailyTotal struct {
+	Date    time.Time `json:"date"`
+	TotalMM float64   `json:"total_mm"`
+}
+
+// SensorDayTotal sums a sensor's clean value_mm over [start, end). Total is
+// nil when the sensor has no clean rows in the span.
+func (s *Store) SensorDayTotal(ctx context.Context, sensorID string, start, end time.Time) (*float64, int, error) {
+	var total *float64
+	var n int
+	err := s.pool.QueryRow(ctx, `
+		SELECT SUM(value_mm), COUNT(*)
+		FROM shizuku.clean_measurements
+		WHERE sensor_id = $1 AND ts >= $2 AND ts < $3
+	`, sensorID, start, end).Scan(&total, &n)
+	if err != nil {
+		return nil, 0, mapErr(err)
+	}
+	return total, n, nil
+}
+
+// SensorCalendarDayTotals returns a sensor's daily clean totals, with days
+// cut in tz, for the calendar day of date in every year before date's year.
+// A positive windowDays widens the match to days within that many days of the
+// same day of year (wrapping across the new year).
+func (s *Store) SensorCalendarDayTotals(ctx context.Context, sensorID string, date time.Time, tz string, windowDays int) ([]DailyTotal, error) {
+	yearStart := time.Date(date.Year(), 1, 1, 0, 0, 0, 0, date.Location())
+	query := `
+		WITH daily AS (
+			SELECT (ts AT TIME ZONE $2)::date AS d, SUM(value_mm) AS total_mm
+			FROM shizuku.clean_measurements
+			WHERE sensor_id = $1 AND ts < $3
+			GROUP BY 1
+		)
+		SELECT d, total_mm
+		FROM daily
+		WHERE ($4 = 0 AND EXTRACT(MONTH FROM d)::int = $5 AND EXTRACT(DAY FROM d)::int = $6)
+		   OR ($4 > 0 AND LEAST(
+				ABS(EXTRACT(DOY FROM d)::int - $7),
+				365 - ABS(EXTRACT(DOY FROM d)::int - $7)
+			) <= $4)
+		ORDER BY d
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID, tz, yearStart, windowDays, int(date.Month()), date.Day(), date.YearDay())
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	totals := make([]DailyTotal, 0)
+	for rows.Next() {
+		var t DailyTotal
+		if err := rows.Scan(&t.Date, &t.TotalMM); err != nil {
+			return nil, mapErr(err)
+		}
+		totals = append(totals, t)
+	}
+	return totals, mapErr(rows.Err())
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Feed is an upstream feed registered by the watcher with its recent health.
+type Feed struct {
+	Name           string     `json:"name"`
+	URL            string     `json:"url"`
+	Network        *string    `json:"network"`
+	CadenceSeconds int        `json:"cadence_seconds"`
+	LastSuccessAt  *time.Time `json:"last_success_at"`
+	LastErrorAt    *time.Time `json:"last_error_at"`
+	LastError      *string    `json:"last_error"`
+	RegisteredAt   time.Time  `json:"registered_at"`
+	// Cycles and Errors count ingest_log rows since the window start passed
+	// to ListFeeds.
+	Cycles int `json:"cycles"`
+	Errors int `json:"errors"`
+}
+
+// ListFeeds returns every registered feed with its cycle and error counts
+// from ingest_log since the given time.
+func (s *Store) ListFeeds(ctx context.Context, since time.Time) ([]Feed, error) {
+	query := `
+		SELECT f.name, f.url, f.network, f.cadence_seconds,
+			f.last_success_at, f.last_error_at, f.last_error, f.registered_at,
+			COUNT(l.id), COUNT(l.id) FILTER (WHERE NOT l.success)
+		FROM shizuku.feeds f
+		LEFT JOIN shizuku.ingest_log l ON l.feed_name = f.name AND l.started_at >= $1
+		GROUP BY f.name
+		ORDER BY f.name
+	`
+
+	rows, err := s.pool.Query(ctx, query, since)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	feeds := make([]Feed, 0)
+	for rows.Next() {
+		var f Feed
+		if err := rows.Scan(
+			&f.Name, &f.URL, &f.Network, &f.CadenceSeconds,
+			&f.LastSuccessAt, &f.LastErrorAt, &f.LastError, &f.RegisteredAt,
+			&f.Cycles, &f.Errors,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, mapErr(rows.Err())
+}
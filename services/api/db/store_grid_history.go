@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+)
+
+// SensorGridAggregatePoint is one grid run seen from a single sensor. The
+// sensor fields are nil when the sensor did not contribute to the run.
+type SensorGridAggregatePoint struct {
+	GridRunID        int64     `json:"grid_run_id"`
+	Timestamp        time.Time `json:"ts"`
+	Contributed      bool      `json:"contributed"`
+	AvgMmH           *float64  `json:"avg_mm_h"`
+	AccumulationMM   *float64  `json:"accumulation_mm"`
+	WindowSeconds    *int      `json:"window_seconds"`
+	MinValueMm       *float64  `json:"min_value_mm"`
+	MaxValueMm       *float64  `json:"max_value_mm"`
+	MeasurementCount *int      `json:"measurement_count"`
+	NetworkAvgMmH    *float64  `json:"network_avg_mm_h"`
+}
+
+// GetSensorGridAggregateHistory returns the sensor's aggregate for every
+// preferred done grid run in [start, end], oldest first, alongside the
+// run's network-wide average. Runs the sensor was absent from are kept as
+// gaps. A non-nil after resumes strictly after that run timestamp.
+func (s *Store) GetSensorGridAggregateHistory(ctx context.Context, sensorID string, start, end time.Time, after *time.Time, limit int) ([]SensorGridAggregatePoint, error) {
+	query := `
+		SELECT g.id, g.ts, gsa.sensor_id IS NOT NULL,
+			gsa.avg_mm_h, gsa.min_value_mm, gsa.max_value_mm, gsa.measurement_count,
+			gsa.ts_start, gsa.ts_end,
+			net.avg_mm_h
+		FROM ` + doneGridRunsSQL + ` g
+		LEFT JOIN shizuku.grid_sensor_aggregates gsa
+			ON gsa.grid_run_id = g.id AND gsa.sensor_id = $1
+		LEFT JOIN LATERAL (
+			SELECT AVG(avg_mm_h) AS avg_mm_h
+			FROM shizuku.grid_sensor_aggregates
+			WHERE grid_run_id = g.id
+		) net ON true
+		WHERE g.ts >= $2 AND g.ts <= $3
+			AND ($5::timestamptz IS NULL OR g.ts > $5)
+		ORDER BY g.ts
+		LIMIT $4
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID, start, end, limit, after)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	points := make([]SensorGridAggregatePoint, 0)
+	for rows.Next() {
+		var p SensorGridAggregatePoint
+		var tsStart, tsEnd *time.Time
+		if err := rows.Scan(
+			&p.GridRunID,
+			&p.Timestamp,
+			&p.Contributed,
+			&p.AvgMmH,
+			&p.MinValueMm,
+			&p.MaxValueMm,
+			&p.MeasurementCount,
+			&tsStart,
+			&tsEnd,
+			&p.NetworkAvgMmH,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		if p.AvgMmH != nil && tsStart != nil && tsEnd != nil {
+			window := tsEnd.Sub(*tsStart)
+			seconds := int(window / time.Second)
+			accumulation := units.Intensity(*p.AvgMmH).Over(window).MM()
+			p.WindowSeconds = &seconds
+			p.AccumulationMM = &accumulation
+		}
+		points = append(points, p)
+	}
+	return points, mapErr(rows.Err())
+}
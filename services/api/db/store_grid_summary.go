@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// Winsorization bounds: sensor rates are clamped to this percentile range
+// before averaging so a single glitching gauge cannot dominate the mean.
+const (
+	winsorLow  = 0.1
+	winsorHigh = 0.9
+)
+
+// computeRunSummariesSQL fills grid_run_summary for the given runs that have
+// aggregates but no summary yet.
+const computeRunSummariesSQL = `
+	WITH missing AS (
+		SELECT unnest($1::bigint[]) AS id
+		EXCEPT
+		SELECT grid_run_id FROM shizuku.grid_run_summary
+	), pct AS (
+		SELECT gsa.grid_run_id,
+		       percentile_cont($2) WITHIN GROUP (ORDER BY gsa.avg_mm_h) AS lo,
+		       percentile_cont($3) WITHIN GROUP (ORDER BY gsa.avg_mm_h) AS hi
+		FROM shizuku.grid_sensor_aggregates gsa
+		JOIN missing ON missing.id = gsa.grid_run_id
+		GROUP BY gsa.grid_run_id
+	)
+	INSERT INTO shizuku.grid_run_summary (grid_run_id, p90_mm_h, winsorized_mean_mm_h)
+	SELECT pct.grid_run_id, pct.hi, AVG(LEAST(GREATEST(gsa.avg_mm_h, pct.lo), pct.hi))
+	FROM pct
+	JOIN shizuku.grid_sensor_aggregates gsa ON gsa.grid_run_id = pct.grid_run_id
+	GROUP BY pct.grid_run_id, pct.hi
+	ON CONFLICT (grid_run_id) DO NOTHING
+`
+
+// attachRunSummaries sets the p90 and winsorized mean of each grid, computing
+// and persisting them for runs seen for the first time.
+func (s *Store) attachRunSummaries(ctx context.Context, grids []GridTimestampResult, gridIDs []int) error {
+	if _, err := s.pool.Exec(ctx, computeRunSummariesSQL, gridIDs, winsorLow, winsorHigh); err != nil {
+		return mapErr(err)
+	}
+
+	rows, err := s.pool.Query(ctx, `
+		SELECT grid_run_id, p90_mm_h, winsorized_mean_mm_h
+		FROM shizuku.grid_run_summary
+		WHERE grid_run_id = ANY($1)
+	`, gridIDs)
+	if err != nil {
+		return mapErr(err)
+	}
+	defer rows.Close()
+
+	type summary struct{ p90, wmean float64 }
+	byID := make(map[int]summary, len(gridIDs))
+	for rows.Next() {
+		var id int
+		var sm summary
+		if err := rows.Scan(&id, &sm.p90, &sm.wmean); err != nil {
+			return mapErr(err)
+		}
+		byID[id] = sm
+	}
+	if err := rows.Err(); err != nil {
+		return mapErr(err)
+	}
+
+	for i := range grids {
+		if sm, ok := byID[grids[i].ID]; ok {
+			p90, wmean := sm.p90, sm.wmean
+			grids[i].P90RainfallMmH = &p90
+			grids[i].WinsorizedMeanMmH = &wmean
+		}
+	}
+	return nil
+}
+
+// LatestGridSummary is the headline of the latest done grid run.
+type LatestGridSummary struct {
+	GridRunID      int       `json:"grid_run_id"`
+	Timestamp      time.Time `json:"timestamp"`
+	SensorCount    int       `json:"sensor_count"`
+	MaxRainfallMmH *float64  `json:"max_rainfall_mm_h"`
+}
+
+// GetLatestGridSummary returns the latest done grid run with its sensor count
+// and maximum sensor rate from grid_sensor_aggregates. It returns ErrNotFound
+// when no run has finished yet.
+func (s *Store) GetLatestGridSummary(ctx context.Context) (*LatestGridSummary, error) {
+	query := `
+		SELECT g.id, g.ts, COUNT(gsa.sensor_id), MAX(gsa.avg_mm_h)
+		FROM (
+			SELECT id, ts
+			FROM shizuku.grid_runs
+			WHERE status = 'done'
+			ORDER BY ts DESC, ` + preferredRunOrder + `
+			LIMIT 1
+		) g
+		LEFT JOIN shizuku.grid_sensor_aggregates gsa ON gsa.grid_run_id = g.id
+		GROUP BY g.id, g.ts
+	`
+
+	var sum LatestGridSummary
+	if err := s.pool.QueryRow(ctx, query).Scan(
+		&sum.GridRunID,
+		&sum.Timestamp,
+		&sum.SensorCount,
+		&sum.MaxRainfallMmH,
+	); err != nil {
+		return nil, mapErr(err)
+	}
+	return &sum, nil
+}
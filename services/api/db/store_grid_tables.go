@@ -0,0 +1,22 @@
+package db
+
+import "context"
+
+// gridTables are the tables written by the grid ETL. Deployments that run
+// only the watcher and the API do not have them.
+var gridTables = []string{"grid_runs", "grid_sensor_aggregates"}
+
+// GridTablesPresent reports whether every grid ETL table exists in the
+// shizuku schema.
+func (s *Store) GridTablesPresent(ctx context.Context) (bool, error) {
+	var n int
+	err := s.pool.QueryRow(ctx, `
+		SELECT COUNT(*)
+		FROM information_schema.tables
+		WHERE table_schema = 'shizuku' AND table_name = ANY($1)
+	`, gridTables).Scan(&n)
+	if err != nil {
+		return false, mapErr(err)
+	}
+	return n == len(gridTables), nil
+}
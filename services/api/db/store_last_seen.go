@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SensorLastSeen is a sensor with the timestamp of its latest raw
+// measurement, nil when it has none.
+type SensorLastSeen struct {
+	ID     string     `json:"id"`
+	Name   *string    `json:"name,omitempty"`
+	City   *string    `json:"city,omitempty"`
+	LastTS *time.Time `json:"last_ts"`
+}
+
+// ListSensorLastSeen returns every sensor ordered by id with its latest raw
+// measurement timestamp, found in one DISTINCT ON pass over raw_measurements.
+func (s *Store) ListSensorLastSeen(ctx context.Context) ([]SensorLastSeen, error) {
+	query := `
+		SELECT s.id, s.name, s.city, l.ts
+		FROM shizuku.sensors s
+		LEFT JOIN (
+			SELECT DISTINCT ON (sensor_id) sensor_id, ts
+			FROM shizuku.raw_measurements
+			ORDER BY sensor_id, ts DESC
+		) l ON l.sensor_id = s.id
+		ORDER BY s.id
+	`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]SensorLastSeen, 0)
+	for rows.Next() {
+		var ls SensorLastSeen
+		if err := rows.Scan(&ls.ID, &ls.Name, &ls.City, &ls.LastTS); err != nil {
+			return nil, mapErr(err)
+		}
+		out = append(out, ls)
+	}
+	return out, mapErr(rows.Err())
+}
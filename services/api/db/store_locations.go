@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SensorLocation is a position a sensor reported from EffectiveFrom onwards.
+type SensorLocation struct {
+	Lat           float64    `json:"lat"`
+	Lon           float64    `json:"lon"`
+	EffectiveFrom time.Time  `json:"effective_from"`
+	EffectiveTo   *time.Time `json:"effective_to,omitempty"`
+}
+
+// ListSensorLocations returns a sensor's location history, oldest first, with
+// each entry closed by the next one's effective_from.
+func (s *Store) ListSensorLocations(ctx context.Context, sensorID string) ([]SensorLocation, error) {
+	query := `
+		SELECT lat, lon, effective_from,
+		       LEAD(effective_from) OVER (ORDER BY effective_from) AS effective_to
+		FROM shizuku.sensor_location_history
+		WHERE sensor_id = $1
+		ORDER BY effective_from
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	locations := make([]SensorLocation, 0)
+	for rows.Next() {
+		var l SensorLocation
+		if err := rows.Scan(&l.Lat, &l.Lon, &l.EffectiveFrom, &l.EffectiveTo); err != nil {
+			return nil, mapErr(err)
+		}
+		locations = append(locations, l)
+	}
+
+	return locations, mapErr(rows.Err())
+}
@@ -0,0 +1,60 @@
+package db
+
+import "context"
+
+// earthRadiusKm is the mean Earth radius used for great-circle distances.
+const earthRadiusKm = 6371.0088
+
+// SensorDistance is a sensor found by NearestSensors with its great-circle
+// distance from the query point.
+type SensorDistance struct {
+	Sensor
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// NearestSensors returns the limit sensors closest to lat/lon by haversine
+// distance, nearest first. Sensors at 0,0 have no known position and are
+// skipped.
+func (s *Store) NearestSensors(ctx context.Context, lat, lon float64, limit int) ([]SensorDistance, error) {
+	rows, err := s.pool.Query(ctx, `
+		SELECT * FROM (
+			SELECT id, name, provider_id, lat, lon, city, subbasin, barrio, metadata, created_at, updated_at,
+			       (2 * $4::float8 * asin(sqrt(
+			           power(sin(radians(s.lat - $1::float8) / 2), 2) +
+			           cos(radians($1::float8)) * cos(radians(s.lat)) *
+			           power(sin(radians(s.lon - $2::float8) / 2), 2)
+			       )))::float8 AS distance_km
+			FROM shizuku.sensors s
+			WHERE NOT (s.lat = 0 AND s.lon = 0)
+		) d
+		ORDER BY distance_km, id
+		LIMIT $3
+	`, lat, lon, limit, earthRadiusKm)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	sensors := make([]SensorDistance, 0, limit)
+	for rows.Next() {
+		var d SensorDistance
+		if err := rows.Scan(
+			&d.ID,
+			&d.Name,
+			&d.ProviderID,
+			&d.Lat,
+			&d.Lon,
+			&d.City,
+			&d.Subbasin,
+			&d.Barrio,
+			&d.Metadata,
+			&d.CreatedAt,
+			&d.UpdatedAt,
+			&d.DistanceKm,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		sensors = append(sensors, d)
+	}
+	return sensors, mapErr(rows.Err())
+}
@@ -0,0 +1,85 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ManualFlag is a reviewer judgment on a single raw measurement.
+type ManualFlag struct {
+	SensorID  string    `json:"sensor_id"`
+	Timestamp time.Time `json:"ts"`
+	Flag      string    `json:"flag"`
+	Note      *string   `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpsertManualFlag records (or replaces) the reviewer judgment for a
+// sensor/timestamp pair and returns the stored row.
+func (s *Store) UpsertManualFlag(ctx context.Context, f ManualFlag) (*ManualFlag, error) {
+	query := `
+		INSERT INTO shizuku.qc_flags_manual (sensor_id, ts, flag, note)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (sensor_id, ts) DO UPDATE
+		SET flag = EXCLUDED.flag,
+		    note = EXCLUDED.note
+		RETURNING sensor_id, ts, flag, note, created_at, updated_at
+	`
+
+	var out ManualFlag
+	err := s.pool.QueryRow(ctx, query, f.SensorID, f.Timestamp, f.Flag, f.Note).Scan(
+		&out.SensorID,
+		&out.Timestamp,
+		&out.Flag,
+		&out.Note,
+		&out.CreatedAt,
+		&out.UpdatedAt,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return nil, ErrSensorNotFound
+		}
+		return nil, mapErr(err)
+	}
+
+	return &out, nil
+}
+
+// ListManualFlags returns the reviewer judgments for a sensor, newest first.
+func (s *Store) ListManualFlags(ctx context.Context, sensorID string) ([]ManualFlag, error) {
+	query := `
+		SELECT sensor_id, ts, flag, note, created_at, updated_at
+		FROM shizuku.qc_flags_manual
+		WHERE sensor_id = $1
+		ORDER BY ts DESC
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	flags := make([]ManualFlag, 0)
+	for rows.Next() {
+		var f ManualFlag
+		if err := rows.Scan(
+			&f.SensorID,
+			&f.Timestamp,
+			&f.Flag,
+			&f.Note,
+			&f.CreatedAt,
+			&f.UpdatedAt,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		flags = append(flags, f)
+	}
+
+	return flags, mapErr(rows.Err())
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// QualityBucket counts raw rows carrying one quality value.
+type QualityBucket struct {
+	Quality int `json:"quality"`
+	Count   int `json:"count"`
+}
+
+// QualitySummary aggregates the provider quality score of a sensor's raw rows.
+// Mean and Min are nil when no row in the range has a quality; NullFraction
+// is nil when the range has no rows at all.
+type QualitySummary struct {
+	Rows         int             `json:"rows"`
+	NullRows     int             `json:"null_rows"`
+	NullFraction *float64        `json:"null_fraction"`
+	Mean         *float64        `json:"mean"`
+	Min          *int            `json:"min"`
+	Buckets      []QualityBucket `json:"buckets"`
+}
+
+// SensorQuality summarises raw_measurements.quality for a sensor over
+// [start, end]. Quality is an integer code, so the histogram has one bucket
+// per distinct value. A single grouped query returns the per-value counts
+// plus a grand total row.
+func (s *Store) SensorQuality(ctx context.Context, sensorID string, start, end time.Time) (QualitySummary, error) {
+	query := `
+		SELECT GROUPING(quality) = 1 AS total, quality, COUNT(*)::int,
+		       AVG(quality)::float8, MIN(quality)
+		FROM shizuku.raw_measurements
+		WHERE sensor_id = $1 AND ts >= $2 AND ts <= $3
+		GROUP BY GROUPING SETS ((quality), ())
+		ORDER BY total, quality
+	`
+
+	rows, err := s.pool.Query(ctx, query, sensorID, start, end)
+	if err != nil {
+		return QualitySummary{}, mapErr(err)
+	}
+	defer rows.Close()
+
+	sum := QualitySummary{Buckets: make([]QualityBucket, 0)}
+	for rows.Next() {
+		var (
+			total   bool
+			quality *int
+			count   int
+			mean    *float64
+			lowest  *int
+		)
+		if err := rows.Scan(&total, &quality, &count, &mean, &lowest); err != nil {
+			return QualitySummary{}, mapErr(err)
+		}
+		switch {
+		case total:
+			sum.Rows, sum.Mean, sum.Min = count, mean, lowest
+		case quality == nil:
+			sum.NullRows = count
+		default:
+			sum.Buckets = append(sum.Buckets, QualityBucket{Quality: *quality, Count: count})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return QualitySummary{}, mapErr(err)
+	}
+
+	if sum.Rows > 0 {
+		f := float64(sum.NullRows) / float64(sum.Rows)
+		sum.NullFraction = &f
+	}
+	return sum, nil
+}
+
+// CurrentQuality returns the quality of the sensor's latest raw row that has
+// one, or nil when none does.
+func (s *Store) CurrentQuality(ctx context.Context, sensorID string) (*int, error) {
+	var quality int
+	err := s.pool.QueryRow(ctx, `
+		SELECT quality
+		FROM shizuku.raw_measurements
+		WHERE sensor_id = $1 AND quality IS NOT NULL
+		ORDER BY ts DESC
+		LIMIT 1
+	`, sensorID).Scan(&quality)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &quality, nil
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// MeasurementRepair is a correction to the value stored for a sensor at a
+// timestamp. A nil ValueMM nulls the value.
+type MeasurementRepair struct {
+	SensorID  string
+	Timestamp time.Time
+	ValueMM   *float64
+	Reason    string
+}
+
+// Repair statuses reported per requested row.
+const (
+	RepairApplied  = "applied"
+	RepairNotFound = "not_found"
+)
+
+// RepairResult reports what a single repair changed.
+type RepairResult struct {
+	SensorID  string    `json:"sensor_id"`
+	Timestamp time.Time `json:"ts"`
+	Status    string    `json:"status"`
+	RawRows   int64     `json:"raw_rows"`
+	CleanRows int64     `json:"clean_rows"`
+}
+
+// repairSQL updates the matching rows of one measurement table and writes an
+// audit row per changed row, capturing the value it replaced.
+const repairSQL = `
+	WITH old AS (
+		SELECT id, value_mm
+		FROM shizuku.%[1]s
+		WHERE sensor_id = $1 AND ts = $2
+		FOR UPDATE
+	), upd AS (
+		UPDATE shizuku.%[1]s m
+		SET value_mm = $3
+		FROM old
+		WHERE m.id = old.id
+		RETURNING m.id, old.value_mm AS old_value_mm
+	)
+	INSERT INTO shizuku.measurement_repairs
+		(measurement_id, table_name, sensor_id, ts, old_value_mm, new_value_mm, reason)
+	SELECT id, '%[1]s', $1, $2, old_value_mm, $3, $4
+	FROM upd
+`
+
+var (
+	repairRawSQL   = fmt.Sprintf(repairSQL, "raw_measurements")
+	repairCleanSQL = fmt.Sprintf(repairSQL, "clean_measurements")
+)
+
+// RepairMeasurements applies the repairs to raw_measurements and, where rows
+// exist, clean_measurements in a single transaction. Repairs that match no
+// row in either table are reported as not found; they do not abort the batch.
+func (s *Store) RepairMeasurements(ctx context.Context, repairs []MeasurementRepair) ([]RepairResult, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer tx.Rollback(ctx)
+
+	results := make([]RepairResult, 0, len(repairs))
+	for _, r := range repairs {
+		res := RepairResult{SensorID: r.SensorID, Timestamp: r.Timestamp}
+
+		tag, err := tx.Exec(ctx, repairRawSQL, r.SensorID, r.Timestamp, r.ValueMM, r.Reason)
+		if err != nil {
+			return nil, mapErr(err)
+		}
+		res.RawRows = tag.RowsAffected()
+
+		tag, err = tx.Exec(ctx, repairCleanSQL, r.SensorID, r.Timestamp, r.ValueMM, r.Reason)
+		if err != nil {
+			return nil, mapErr(err)
+		}
+		res.CleanRows = tag.RowsAffected()
+
+		res.Status = RepairApplied
+		if res.RawRows == 0 && res.CleanRows == 0 {
+			res.Status = RepairNotFound
+		}
+		results = append(results, res)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, mapErr(err)
+	}
+	return results, nil
+}
@@ -0,0 +1,196 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// retentionLockKey is the advisory lock held while a retention job runs, so
+// only one job deletes at a time across all API instances.
+const retentionLockKey int64 = 0x5348495a524554 // "SHIZRET"
+
+// Retention job statuses.
+const (
+	RetentionRunning = "running"
+	RetentionDone    = "done"
+	RetentionFailed  = "failed"
+)
+
+// RetentionTables lists the tables retention jobs may delete from. Raw rows
+// are pruned by the archiver.
+var RetentionTables = []string{"clean_measurements"}
+
+// RetentionJob is the persisted state of a retention run.
+type RetentionJob struct {
+	ID          int64      `json:"id"`
+	Table       string     `json:"table"`
+	Before      time.Time  `json:"before"`
+	DryRun      bool       `json:"dry_run"`
+	Status      string     `json:"status"`
+	MatchedRows int64      `json:"matched_rows"`
+	DeletedRows int64      `json:"deleted_rows"`
+	Batches     int        `json:"batches"`
+	Error       *string    `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// RetentionRun is a started retention job holding the advisory lock. Run
+// must be called exactly once; it releases the lock when it returns.
+type RetentionRun struct {
+	Job  RetentionJob
+	conn *pgxpool.Conn
+}
+
+const retentionJobColumns = `id, table_name, before_ts, dry_run, status, matched_rows, deleted_rows, batches, error, started_at, updated_at, finished_at`
+
+func scanRetentionJob(row interface{ Scan(...any) error }) (RetentionJob, error) {
+	var j RetentionJob
+	err := row.Scan(&j.ID, &j.Table, &j.Before, &j.DryRun, &j.Status, &j.MatchedRows, &j.DeletedRows, &j.Batches, &j.Error, &j.StartedAt, &j.UpdatedAt, &j.FinishedAt)
+	return j, err
+}
+
+// StartRetention takes the retention lock, counts the rows of table older
+// than before and records a running job. It fails with ErrConflict while
+// another job holds the lock. Dry runs are recorded as done immediately and
+// need no Run.
+func (s *Store) StartRetention(ctx context.Context, table string, before time.Time, dryRun bool) (*RetentionRun, error) {
+	if !slices.Contains(RetentionTables, table) {
+		return nil, fmt.Errorf("%w: retention is not supported for table %q", ErrInvalidInput, table)
+	}
+
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	var locked bool
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, retentionLockKey).Scan(&locked); err != nil {
+		conn.Release()
+		return nil, mapErr(err)
+	}
+	if !locked {
+		conn.Release()
+		return nil, fmt.Errorf("%w: a retention job is already running", ErrConflict)
+	}
+	run := &RetentionRun{conn: conn}
+
+	var matched int64
+	countSQL := fmt.Sprintf(`SELECT COUNT(*) FROM shizuku.%s WHERE ts < $1`, table)
+	if err := conn.QueryRow(ctx, countSQL, before).Scan(&matched); err != nil {
+		run.unlock()
+		return nil, mapErr(err)
+	}
+
+	status := RetentionRunning
+	if dryRun {
+		status = RetentionDone
+	}
+	row := conn.QueryRow(ctx, `
+		INSERT INTO shizuku.retention_jobs (table_name, before_ts, dry_run, status, matched_rows, finished_at)
+		VALUES ($1, $2, $3, $4, $5, CASE WHEN $3 THEN NOW() END)
+		RETURNING `+retentionJobColumns,
+		table, before, dryRun, status, matched)
+	run.Job, err = scanRetentionJob(row)
+	if err != nil {
+		run.unlock()
+		return nil, mapErr(err)
+	}
+	if dryRun {
+		run.unlock()
+	}
+	return run, nil
+}
+
+// Run deletes the job's rows in batches of batchSize, recording progress
+// after every batch, until none are left or ctx ends. The final state is
+// written with a short independent deadline so a cancelled ctx still marks
+// the job failed.
+func (r *RetentionRun) Run(ctx context.Context, batchSize int) (RetentionJob, error) {
+	defer r.unlock()
+
+	deleteSQL := fmt.Sprintf(`
+		DELETE FROM shizuku.%[1]s
+		WHERE id IN (
+			SELECT id FROM shizuku.%[1]s
+			WHERE ts < $1
+			ORDER BY ts
+			LIMIT $2
+		)`, r.Job.Table)
+
+	var runErr error
+	for {
+		tag, err := r.conn.Exec(ctx, deleteSQL, r.Job.Before, batchSize)
+		if err != nil {
+			runErr = mapErr(err)
+			break
+		}
+		if tag.RowsAffected() == 0 {
+			break
+		}
+		r.Job.DeletedRows += tag.RowsAffected()
+		r.Job.Batches++
+		if _, err := r.conn.Exec(ctx, `
+			UPDATE shizuku.retention_jobs
+			SET deleted_rows = $2, batches = $3, updated_at = NOW()
+			WHERE id = $1`, r.Job.ID, r.Job.DeletedRows, r.Job.Batches); err != nil {
+			runErr = mapErr(err)
+			break
+		}
+	}
+
+	status := RetentionDone
+	var errText *string
+	if runErr != nil {
+		status = RetentionFailed
+		msg := runErr.Error()
+		errText = &msg
+	}
+
+	finishCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	row := r.conn.QueryRow(finishCtx, `
+		UPDATE shizuku.retention_jobs
+		SET status = $2, deleted_rows = $3, batches = $4, error = $5, updated_at = NOW(), finished_at = NOW()
+		WHERE id = $1
+		RETURNING `+retentionJobColumns,
+		r.Job.ID, status, r.Job.DeletedRows, r.Job.Batches, errText)
+	job, err := scanRetentionJob(row)
+	if err != nil {
+		if runErr == nil {
+			runErr = mapErr(err)
+		}
+		return r.Job, runErr
+	}
+	r.Job = job
+	return job, runErr
+}
+
+// unlock releases the advisory lock and returns the connection to the pool.
+func (r *RetentionRun) unlock() {
+	if r.conn == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := r.conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, retentionLockKey); err != nil {
+		// A session lock we cannot release must not return to the pool.
+		_ = r.conn.Conn().Close(ctx)
+	}
+	r.conn.Release()
+	r.conn = nil
+}
+
+// GetRetentionJob returns a retention job by id.
+func (s *Store) GetRetentionJob(ctx context.Context, id int64) (*RetentionJob, error) {
+	row := s.pool.QueryRow(ctx, `SELECT `+retentionJobColumns+` FROM shizuku.retention_jobs WHERE id = $1`, id)
+	job, err := scanRetentionJob(row)
+	if err != nil {
+		return nil, mapRowErr(err, "retention job")
+	}
+	return &job, nil
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"strings"
+)
+
+// SensorMatch is a sensor found by SearchSensors. Match names the field the
+// query matched (name, barrio or city, checked in that order) and Score is
+// the trigram similarity of the best matching field, from 0 to 1.
+type SensorMatch struct {
+	Sensor
+	Match string  `json:"match"`
+	Score float64 `json:"score"`
+}
+
+// likeEscaper escapes the LIKE wildcards of a search term.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchSensors returns the sensors whose name, barrio or city contains q,
+// ignoring case and accents, optionally restricted to bbox. Results are
+// ordered by similarity to q, best first, then by id.
+func (s *Store) SearchSensors(ctx context.Context, q string, bbox *BBox) ([]SensorMatch, error) {
+	clause, args := bbox.clause("s", 3)
+	query := `
+		SELECT * FROM (
+			SELECT id, name, provider_id, lat, lon, city, subbasin, barrio, metadata, created_at, updated_at,
+			       CASE
+			           WHEN unaccent(COALESCE(s.name, '')) ILIKE unaccent($2::text) THEN 'name'
+			           WHEN unaccent(COALESCE(s.barrio, '')) ILIKE unaccent($2::text) THEN 'barrio'
+			           ELSE 'city'
+			       END AS match,
+			       GREATEST(
+			           similarity(lower(unaccent(COALESCE(s.name, ''))), lower(unaccent($1::text))),
+			           similarity(lower(unaccent(COALESCE(s.barrio, ''))), lower(unaccent($1::text))),
+			           similarity(lower(unaccent(COALESCE(s.city, ''))), lower(unaccent($1::text)))
+			       )::float8 AS score
+			FROM shizuku.sensors s
+			WHERE (unaccent(COALESCE(s.name, '')) ILIKE unaccent($2::text)
+			    OR unaccent(COALESCE(s.barrio, '')) ILIKE unaccent($2::text)
+			    OR unaccent(COALESCE(s.city, '')) ILIKE unaccent($2::text))` + clause + `
+		) matches
+		ORDER BY score DESC, id
+	`
+	args = append([]any{q, "%" + likeEscaper.Replace(q) + "%"}, args...)
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	matches := make([]SensorMatch, 0)
+	for rows.Next() {
+		var m SensorMatch
+		if err := rows.Scan(
+			&m.ID,
+			&m.Name,
+			&m.ProviderID,
+			&m.Lat,
+			&m.Lon,
+			&m.City,
+			&m.Subbasin,
+			&m.Barrio,
+			&m.Metadata,
+			&m.CreatedAt,
+			&m.UpdatedAt,
+			&m.Match,
+			&m.Score,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		matches = append(matches, m)
+	}
+	return matches, mapErr(rows.Err())
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SourceBreakdown summarises the raw rows written by one ingest source.
+type SourceBreakdown struct {
+	Source    string    `json:"source"`
+	RowCount  int       `json:"row_count"`
+	NullCount int       `json:"null_count"`
+	FirstTS   time.Time `json:"first_ts"`
+	LastTS    time.Time `json:"last_ts"`
+}
+
+// SensorSourceBreakdown groups a sensor's raw measurements by source within
+// [since, until]. Sources without rows are omitted.
+func (s *Store) SensorSourceBreakdown(ctx context.Context, sensorID string, since *time.Time, until time.Time) ([]SourceBreakdown, error) {
+	query := `
+		SELECT source, COUNT(*), COUNT(*) FILTER (WHERE value_mm IS NULL), MIN(ts), MAX(ts)
+		FROM shizuku.raw_measurements
+		WHERE sensor_id = $1
+		  AND ($2::timestamptz IS NULL OR ts >= $2)
+		  AND ts <= $3
+		GROUP BY source
+		ORDER BY source
+	`
+	return s.querySourceBreakdown(ctx, query, sensorID, since, until)
+}
+
+// NetworkSourceBreakdown groups all raw measurements by source within
+// [since, until]. Sources without rows are omitted.
+func (s *Store) NetworkSourceBreakdown(ctx context.Context, since *time.Time, until time.Time) ([]SourceBreakdown, error) {
+	query := `
+		SELECT source, COUNT(*), COUNT(*) FILTER (WHERE value_mm IS NULL), MIN(ts), MAX(ts)
+		FROM shizuku.raw_measurements
+		WHERE ($1::timestamptz IS NULL OR ts >= $1)
+		  AND ts <= $2
+		GROUP BY source
+		ORDER BY source
+	`
+	return s.querySourceBreakdown(ctx, query, since, until)
+}
+
+func (s *Store) querySourceBreakdown(ctx context.Context, query string, args ...any) ([]SourceBreakdown, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	out := make([]SourceBreakdown, 0)
+	for rows.Next() {
+		var b SourceBreakdown
+		if err := rows.Scan(&b.Source, &b.RowCount, &b.NullCount, &b.FirstTS, &b.LastTS); err != nil {
+			return nil, mapErr(err)
+		}
+		out = append(out, b)
+	}
+
+	return out, mapErr(rows.Err())
+}
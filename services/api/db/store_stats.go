@@ -0,0 +1,36 @@
+package db
+
+import "context"
+
+// SensorStats summarizes a sensor's value_mm over a query window. The
+// statistics are nil when the window holds no non-null values.
+type SensorStats struct {
+	Samples int      `json:"samples"`
+	Min     *float64 `json:"min"`
+	Max     *float64 `json:"max"`
+	Mean    *float64 `json:"mean"`
+	Sum     *float64 `json:"sum"`
+	P95     *float64 `json:"p95"`
+}
+
+// SensorStats aggregates the rows matched by q (Limit and Offset are ignored)
+// in one query. excludeImputed leaves out clean rows with an
+// imputation_method; raw rows are never imputed.
+func (s *Store) SensorStats(ctx context.Context, q MeasurementQuery, excludeImputed bool) (*SensorStats, error) {
+	filtered, args := q.filtered()
+	query := `
+		SELECT COUNT(value_mm), MIN(value_mm), MAX(value_mm), AVG(value_mm), SUM(value_mm),
+			percentile_cont(0.95) WITHIN GROUP (ORDER BY value_mm)
+		FROM (` + filtered + `) m`
+	if excludeImputed {
+		query += `
+		WHERE m.imputation_method IS NULL`
+	}
+
+	var st SensorStats
+	err := s.pool.QueryRow(ctx, query, args...).Scan(&st.Samples, &st.Min, &st.Max, &st.Mean, &st.Sum, &st.P95)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	return &st, nil
+}
@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// IngestRun is one watcher cycle recorded in ingest_log.
+type IngestRun struct {
+	Feed       string    `json:"feed"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Success    bool      `json:"success"`
+	Error      *string   `json:"error,omitempty"`
+}
+
+// StatusFacts are the cheap health figures behind the public status page.
+type StatusFacts struct {
+	// LatestMeasurement is the newest raw timestamp, nil on an empty table.
+	LatestMeasurement *time.Time
+	SensorsTotal      int
+	// SensorsReporting counts sensors with a raw row since the window start.
+	SensorsReporting int
+	// LastIngest is the latest watcher cycle, nil before the first one.
+	LastIngest *IngestRun
+}
+
+// GetStatusFacts gathers the status page figures using index-backed lookups
+// only, counting sensors that reported since the given time.
+func (s *Store) GetStatusFacts(ctx context.Context, since time.Time) (StatusFacts, error) {
+	var facts StatusFacts
+	err := s.pool.QueryRow(ctx, `
+		SELECT (SELECT ts FROM shizuku.raw_measurements ORDER BY ts DESC LIMIT 1),
+		       (SELECT COUNT(*)::int FROM shizuku.sensors),
+		       (SELECT COUNT(DISTINCT sensor_id)::int FROM shizuku.raw_measurements WHERE ts >= $1)
+	`, since).Scan(&facts.LatestMeasurement, &facts.SensorsTotal, &facts.SensorsReporting)
+	if err != nil {
+		return StatusFacts{}, mapErr(err)
+	}
+
+	var run IngestRun
+	err = s.pool.QueryRow(ctx, `
+		SELECT l.feed_name, l.started_at, l.finished_at, l.success, l.error
+		FROM shizuku.feeds f
+		CROSS JOIN LATERAL (
+			SELECT *
+			FROM shizuku.ingest_log
+			WHERE feed_name = f.name
+			ORDER BY started_at DESC
+			LIMIT 1
+		) l
+		ORDER BY l.started_at DESC
+		LIMIT 1
+	`).Scan(&run.Feed, &run.StartedAt, &run.FinishedAt, &run.Success, &run.Error)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+	case err != nil:
+		return StatusFacts{}, mapErr(err)
+	default:
+		facts.LastIngest = &run
+	}
+	return facts, nil
+}
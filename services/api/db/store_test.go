@@ -0,0 +1,35 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMeasurementQueryOrder(t *testing.T) {
+	after := &MeasurementCursor{TS: time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC), ID: 7}
+	for _, tc := range []struct {
+		name       string
+		descending bool
+		wantOrder  string
+		wantAfter  string
+	}{
+		{"ascending", false, " ORDER BY ts, id LIMIT $4", "(ts, id) > ($2, $3)"},
+		// Newest first, so LIMIT keeps the newest rows and a cursor resumes
+		// towards older ones.
+		{"descending", true, " ORDER BY ts DESC, id DESC LIMIT $4", "(ts, id) < ($2, $3)"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args := MeasurementQuery{SensorID: "s1", UseClean: true, Limit: 50, After: after, Descending: tc.descending}.sql()
+			if !strings.HasSuffix(sql, tc.wantOrder) {
+				t.Errorf("query does not end with %q:\n%s", tc.wantOrder, sql)
+			}
+			if !strings.Contains(sql, tc.wantAfter) {
+				t.Errorf("query lacks %q:\n%s", tc.wantAfter, sql)
+			}
+			if len(args) != 4 || args[3] != 50 {
+				t.Errorf("args %v", args)
+			}
+		})
+	}
+}
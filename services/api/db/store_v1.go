@@ -10,37 +10,73 @@ import (
 
 // SensorAggregate represents aggregated sensor data for a grid run
 type SensorAggregate struct {
-	SensorID         string   `json:"sensor_id"`
-	AvgMmH           float64  `json:"avg_mm_h"`
-	MeasurementCount int      `json:"measurement_count"`
-	MinValueMm       float64  `json:"min_value_mm"`
-	MaxValueMm       float64  `json:"max_value_mm"`
-	Sensor           *Sensor  `json:"sensor,omitempty"` // Optional enrichment
+	SensorID string  `json:"sensor_id"`
+	AvgMmH   float64 `json:"avg_mm_h"`
+	// AccumulationMM is the rainfall depth over the aggregate window implied
+	// by AvgMmH; WindowSeconds is that window's length.
+	AccumulationMM   float64 `json:"accumulation_mm"`
+	WindowSeconds    int     `json:"window_seconds"`
+	MeasurementCount int     `json:"measurement_count"`
+	MinValueMm       float64 `json:"min_value_mm"`
+	MaxValueMm       float64 `json:"max_value_mm"`
+	Sensor           *Sensor `json:"sensor,omitempty"` // Optional enrichment
 }
 
 type GridTimestampResult struct {
-	ID             int                `json:"id"`
-	Timestamp      time.Time          `json:"timestamp"`
-	Resolution     int                `json:"resolution"`
-	Status         string             `json:"status"`
-	GridJSONURL    *string            `json:"grid_json_url,omitempty"`
-	ContoursURL    *string            `json:"contours_url,omitempty"`
-	SensorCount    int                `json:"sensor_count"`
-	AvgRainfallMmH *float64           `json:"avg_rainfall_mm_h,omitempty"`
-	MaxRainfallMmH *float64           `json:"max_rainfall_mm_h,omitempty"`
-	CreatedAt      time.Time          `json:"created_at"`
-	Sensors        []SensorAggregate  `json:"sensors,omitempty"` // Optional enrichment
+	ID             int       `json:"id"`
+	Timestamp      time.Time `json:"timestamp"`
+	Resolution     int       `json:"resolution"`
+	Status         string    `json:"status"`
+	GridJSONURL    *string   `json:"grid_json_url,omitempty"`
+	ContoursURL    *string   `json:"contours_url,omitempty"`
+	SensorCount    int       `json:"sensor_count"`
+	AvgRainfallMmH *float64  `json:"avg_rainfall_mm_h,omitempty"`
+	MaxRainfallMmH *float64  `json:"max_rainfall_mm_h,omitempty"`
+	// P90RainfallMmH and WinsorizedMeanMmH are robust to single-sensor
+	// glitches that dominate MaxRainfallMmH.
+	P90RainfallMmH    *float64          `json:"p90_rainfall_mm_h,omitempty"`
+	WinsorizedMeanMmH *float64          `json:"winsorized_mean_mm_h,omitempty"`
+	CreatedAt         time.Time         `json:"created_at"`
+	Sensors           []SensorAggregate `json:"sensors,omitempty"` // Optional enrichment
 }
 
+// preferredRunOrder ranks grid runs sharing a ts (left behind by a retried
+// ETL): the most recently updated run wins and the highest id breaks ties.
+const preferredRunOrder = "updated_at DESC, id DESC"
+
+// doneGridRunsSQL is a derived table holding the preferred done run per ts.
+const doneGridRunsSQL = `(
+	SELECT DISTINCT ON (ts) *
+	FROM shizuku.grid_runs
+	WHERE status = 'done'
+	ORDER BY ts, ` + preferredRunOrder + `
+)`
+
 type GridTimestampsPage struct {
 	Grids      []GridTimestampResult `json:"grids"`
 	TotalCount int                   `json:"total_count"`
 }
 
-func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, offset int, startTime, endTime *time.Time, includeSensors bool) (*GridTimestampsPage, error) {
+// ListGridTimestampsWithAggregates returns one offset page of done grid
+// runs, newest first. A non-nil asOf hides runs created after it, so pages
+// stay stable while the ETL adds runs.
+func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, offset int, startTime, endTime, asOf *time.Time, includeSensors bool) (*GridTimestampsPage, error) {
 	conditions := []string{"g.status = 'done'"}
 	args := []any{}
 
+	// The snapshot bound applies before picking the preferred run per ts,
+	// so a retried run created later cannot replace or hide an earlier one.
+	runs := doneGridRunsSQL
+	if asOf != nil {
+		args = append(args, *asOf)
+		runs = `(
+	SELECT DISTINCT ON (ts) *
+	FROM shizuku.grid_runs
+	WHERE status = 'done' AND created_at <= $1
+	ORDER BY ts, ` + preferredRunOrder + `
+)`
+	}
+
 	if startTime != nil {
 		conditions = append(conditions, "g.ts >= $"+strconv.Itoa(len(args)+1))
 		args = append(args, *startTime)
@@ -55,10 +91,10 @@ func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, off
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	countSQL := "SELECT COUNT(*) FROM shizuku.grid_runs g " + whereClause
+	countSQL := "SELECT COUNT(*) FROM " + runs + " g " + whereClause
 	var totalCount int
 	if err := s.pool.QueryRow(ctx, countSQL, args...).Scan(&totalCount); err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 
 	limitPos := len(args) + 1
@@ -69,7 +105,7 @@ func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, off
 	query.WriteString("SELECT g.id, g.ts, g.res_m, g.status, g.blob_url_json, g.blob_url_contours, ")
 	query.WriteString("COALESCE(COUNT(gsa.sensor_id), 0) AS sensor_count, AVG(gsa.avg_mm_h) AS avg_rainfall, ")
 	query.WriteString("MAX(gsa.avg_mm_h) AS max_rainfall, g.created_at ")
-	query.WriteString("FROM shizuku.grid_runs g ")
+	query.WriteString("FROM " + runs + " g ")
 	query.WriteString("LEFT JOIN shizuku.grid_sensor_aggregates gsa ON gsa.grid_run_id = g.id ")
 	query.WriteString(whereClause + " ")
 	query.WriteString("GROUP BY g.id, g.ts, g.res_m, g.status, g.blob_url_json, g.blob_url_contours, g.created_at ")
@@ -78,13 +114,13 @@ func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, off
 
 	rows, err := s.pool.Query(ctx, query.String(), args...)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
 	grids := make([]GridTimestampResult, 0, limit)
 	gridIDs := make([]int, 0, limit)
-	
+
 	for rows.Next() {
 		var g GridTimestampResult
 		if err := rows.Scan(
@@ -99,20 +135,26 @@ func (s *Store) ListGridTimestampsWithAggregates(ctx context.Context, limit, off
 			&g.MaxRainfallMmH,
 			&g.CreatedAt,
 		); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
 		grids = append(grids, g)
 		gridIDs = append(gridIDs, g.ID)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, mapErr(err)
+	}
+
+	if len(gridIDs) > 0 {
+		if err := s.attachRunSummaries(ctx, grids, gridIDs); err != nil {
+			return nil, err
+		}
 	}
 
 	// If sensor enrichment is requested, fetch sensor aggregates with sensor details
 	if includeSensors && len(gridIDs) > 0 {
 		if err := s.enrichGridsWithSensors(ctx, grids, gridIDs); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
 	}
 
@@ -124,7 +166,7 @@ func (s *Store) enrichGridsWithSensors(ctx context.Context, grids []GridTimestam
 	// Build query with IN clause for grid IDs
 	query := `
 		SELECT gsa.grid_run_id, gsa.sensor_id, gsa.avg_mm_h, gsa.measurement_count, 
-		       gsa.min_value_mm, gsa.max_value_mm,
+		       gsa.min_value_mm, gsa.max_value_mm, gsa.ts_start, gsa.ts_end,
 		       s.id, s.name, s.provider_id, s.lat, s.lon, s.city, s.subbasin, s.barrio, s.created_at, s.updated_at
 		FROM shizuku.grid_sensor_aggregates gsa
 		INNER JOIN shizuku.sensors s ON s.id = gsa.sensor_id
@@ -134,7 +176,7 @@ func (s *Store) enrichGridsWithSensors(ctx context.Context, grids []GridTimestam
 
 	rows, err := s.pool.Query(ctx, query, gridIDs)
 	if err != nil {
-		return err
+		return mapErr(err)
 	}
 	defer rows.Close()
 
@@ -145,6 +187,7 @@ func (s *Store) enrichGridsWithSensors(ctx context.Context, grids []GridTimestam
 		var gridRunID int
 		var agg SensorAggregate
 		var sensor Sensor
+		var tsStart, tsEnd time.Time
 
 		if err := rows.Scan(
 			&gridRunID,
@@ -153,6 +196,8 @@ func (s *Store) enrichGridsWithSensors(ctx context.Context, grids []GridTimestam
 			&agg.MeasurementCount,
 			&agg.MinValueMm,
 			&agg.MaxValueMm,
+			&tsStart,
+			&tsEnd,
 			&sensor.ID,
 			&sensor.Name,
 			&sensor.ProviderID,
@@ -164,15 +209,16 @@ func (s *Store) enrichGridsWithSensors(ctx context.Context, grids []GridTimestam
 			&sensor.CreatedAt,
 			&sensor.UpdatedAt,
 		); err != nil {
-			return err
+			return mapErr(err)
 		}
 
+		agg.setWindow(tsStart, tsEnd)
 		agg.Sensor = &sensor
 		sensorsByGrid[gridRunID] = append(sensorsByGrid[gridRunID], agg)
 	}
 
 	if err := rows.Err(); err != nil {
-		return err
+		return mapErr(err)
 	}
 
 	// Attach sensors to their respective grids
@@ -206,6 +252,7 @@ func (s *Store) GetGridRunByTimestamp(ctx context.Context, timestamp time.Time)
 		       status, message, created_at, updated_at
 		FROM shizuku.grid_runs
 		WHERE ts = $1 AND status = 'done'
+		ORDER BY ` + preferredRunOrder + `
 		LIMIT 1
 	`
 
@@ -226,7 +273,7 @@ func (s *Store) GetGridRunByTimestamp(ctx context.Context, timestamp time.Time)
 		&g.CreatedAt,
 		&g.UpdatedAt,
 	); err != nil {
-		return nil, err
+		return nil, mapRowErr(err, "grid")
 	}
 
 	if len(bboxJSON) > 0 {
@@ -243,6 +290,8 @@ func (s *Store) GetSensorAggregatesByTimestamp(ctx context.Context, timestamp ti
 		       gsa.measurement_count,
 		       gsa.min_value_mm,
 		       gsa.max_value_mm,
+		       gsa.ts_start,
+		       gsa.ts_end,
 		       s.id,
 		       s.name,
 		       s.provider_id,
@@ -254,15 +303,19 @@ func (s *Store) GetSensorAggregatesByTimestamp(ctx context.Context, timestamp ti
 		       s.created_at,
 		       s.updated_at
 		FROM shizuku.grid_sensor_aggregates gsa
-		JOIN shizuku.grid_runs g ON g.id = gsa.grid_run_id
 		JOIN shizuku.sensors s ON s.id = gsa.sensor_id
-		WHERE g.ts = $1 AND g.status = 'done'
+		WHERE gsa.grid_run_id = (
+			SELECT id FROM shizuku.grid_runs
+			WHERE ts = $1 AND status = 'done'
+			ORDER BY ` + preferredRunOrder + `
+			LIMIT 1
+		)
 		ORDER BY gsa.avg_mm_h DESC
 	`
 
 	rows, err := s.pool.Query(ctx, query, timestamp)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -270,13 +323,16 @@ func (s *Store) GetSensorAggregatesByTimestamp(ctx context.Context, timestamp ti
 	for rows.Next() {
 		var agg SensorAggregate
 		var sensor Sensor
-		
+		var tsStart, tsEnd time.Time
+
 		if err := rows.Scan(
 			&agg.SensorID,
 			&agg.AvgMmH,
 			&agg.MeasurementCount,
 			&agg.MinValueMm,
 			&agg.MaxValueMm,
+			&tsStart,
+			&tsEnd,
 			&sensor.ID,
 			&sensor.Name,
 			&sensor.ProviderID,
@@ -288,23 +344,29 @@ func (s *Store) GetSensorAggregatesByTimestamp(ctx context.Context, timestamp ti
 			&sensor.CreatedAt,
 			&sensor.UpdatedAt,
 		); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
-		
+
+		agg.setWindow(tsStart, tsEnd)
 		agg.Sensor = &sensor
 		aggregates = append(aggregates, agg)
 	}
 
-	return aggregates, rows.Err()
+	return aggregates, mapErr(rows.Err())
 }
 
-func (s *Store) GetSensorAggregatesByGridRunID(ctx context.Context, gridRunID int) ([]SensorAggregate, error) {
+// GetSensorAggregatesByGridRunID returns the aggregates of a grid run, optionally
+// restricted to sensors inside bbox.
+func (s *Store) GetSensorAggregatesByGridRunID(ctx context.Context, gridRunID int, bbox *BBox) ([]SensorAggregate, error) {
+	clause, args := bbox.clause("s", 2)
 	query := `
 		SELECT gsa.sensor_id,
 		       gsa.avg_mm_h,
 		       gsa.measurement_count,
 		       gsa.min_value_mm,
 		       gsa.max_value_mm,
+		       gsa.ts_start,
+		       gsa.ts_end,
 		       s.id,
 		       s.name,
 		       s.provider_id,
@@ -317,13 +379,13 @@ func (s *Store) GetSensorAggregatesByGridRunID(ctx context.Context, gridRunID in
 		       s.updated_at
 		FROM shizuku.grid_sensor_aggregates gsa
 		JOIN shizuku.sensors s ON s.id = gsa.sensor_id
-		WHERE gsa.grid_run_id = $1
+		WHERE gsa.grid_run_id = $1` + clause + `
 		ORDER BY gsa.avg_mm_h DESC
 	`
 
-	rows, err := s.pool.Query(ctx, query, gridRunID)
+	rows, err := s.pool.Query(ctx, query, append([]any{gridRunID}, args...)...)
 	if err != nil {
-		return nil, err
+		return nil, mapErr(err)
 	}
 	defer rows.Close()
 
@@ -331,13 +393,16 @@ func (s *Store) GetSensorAggregatesByGridRunID(ctx context.Context, gridRunID in
 	for rows.Next() {
 		var agg SensorAggregate
 		var sensor Sensor
-		
+		var tsStart, tsEnd time.Time
+
 		if err := rows.Scan(
 			&agg.SensorID,
 			&agg.AvgMmH,
 			&agg.MeasurementCount,
 			&agg.MinValueMm,
 			&agg.MaxValueMm,
+			&tsStart,
+			&tsEnd,
 			&sensor.ID,
 			&sensor.Name,
 			&sensor.ProviderID,
@@ -349,14 +414,15 @@ func (s *Store) GetSensorAggregatesByGridRunID(ctx context.Context, gridRunID in
 			&sensor.CreatedAt,
 			&sensor.UpdatedAt,
 		); err != nil {
-			return nil, err
+			return nil, mapErr(err)
 		}
-		
+
+		agg.setWindow(tsStart, tsEnd)
 		agg.Sensor = &sensor
 		aggregates = append(aggregates, agg)
 	}
 
-	return aggregates, rows.Err()
+	return aggregates, mapErr(rows.Err())
 }
 
 func (s *Store) GetLatestGrid(ctx context.Context) (*GridRun, error) {
@@ -366,7 +432,7 @@ func (s *Store) GetLatestGrid(ctx context.Context) (*GridRun, error) {
 		       status, message, created_at, updated_at
 		FROM shizuku.grid_runs
 		WHERE status = 'done'
-		ORDER BY ts DESC
+		ORDER BY ts DESC, ` + preferredRunOrder + `
 		LIMIT 1
 	`
 
@@ -387,7 +453,7 @@ func (s *Store) GetLatestGrid(ctx context.Context) (*GridRun, error) {
 		&g.CreatedAt,
 		&g.UpdatedAt,
 	); err != nil {
-		return nil, err
+		return nil, mapRowErr(err, "grid")
 	}
 
 	if len(bboxJSON) > 0 {
@@ -420,8 +486,83 @@ func (s *Store) GetSensor(ctx context.Context, sensorID string) (*Sensor, error)
 		&sensor.CreatedAt,
 		&sensor.UpdatedAt,
 	); err != nil {
-		return nil, err
+		return nil, mapRowErr(err, "sensor")
 	}
 
 	return &sensor, nil
 }
+
+// CountRainingSensors returns how many sensors have a latest clean value at or
+// above the given threshold (mm) taken at or after since, optionally only
+// those inside bbox. A sensor whose last reading is older than since is not
+// raining now, however wet that reading was.
+func (s *Store) CountRainingSensors(ctx context.Context, threshold float64, since time.Time, bbox *BBox) (int, error) {
+	clause, bboxArgs := bbox.clause("s", 3)
+	query := `
+		SELECT COUNT(*)
+		FROM shizuku.latest_clean_measurements l
+		JOIN shizuku.sensors s ON s.id = l.sensor_id
+		WHERE l.value_mm >= $1 AND l.ts >= $2` + clause + `
+	`
+
+	args := append([]any{threshold, since}, bboxArgs...)
+	var count int
+	if err := s.pool.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, mapErr(err)
+	}
+
+	return count, nil
+}
+
+// GridProvenance describes the clean measurements that fed a grid run.
+type GridProvenance struct {
+	WindowStart         *time.Time `json:"window_start"`
+	WindowEnd           *time.Time `json:"window_end"`
+	ContributingSensors int        `json:"contributing_sensors"`
+	MeasurementCount    int        `json:"measurement_count"`
+	ImputedCount        int        `json:"imputed_count"`
+}
+
+// GetGridProvenance returns the measurement window and counts behind a grid
+// run. The window comes from the run's sensor aggregates, which the ETL writes
+// as [ts_start, ts_end) for the grid interval; imputed measurements are counted
+// over that window for the contributing sensors.
+func (s *Store) GetGridProvenance(ctx context.Context, gridRunID int) (*GridProvenance, error) {
+	query := `
+		WITH agg AS (
+			SELECT MIN(ts_start) AS window_start,
+			       MAX(ts_end) AS window_end,
+			       COUNT(*) FILTER (WHERE measurement_count > 0) AS sensors,
+			       COALESCE(SUM(measurement_count), 0) AS measurements
+			FROM shizuku.grid_sensor_aggregates
+			WHERE grid_run_id = $1
+		)
+		SELECT agg.window_start,
+		       agg.window_end,
+		       agg.sensors,
+		       agg.measurements,
+		       (
+		           SELECT COUNT(*)
+		           FROM shizuku.clean_measurements cm
+		           JOIN shizuku.grid_sensor_aggregates gsa
+		             ON gsa.sensor_id = cm.sensor_id AND gsa.grid_run_id = $1
+		           WHERE cm.ts >= agg.window_start
+		             AND cm.ts < agg.window_end
+		             AND cm.imputation_method IS NOT NULL
+		       ) AS imputed
+		FROM agg
+	`
+
+	var p GridProvenance
+	if err := s.pool.QueryRow(ctx, query, gridRunID).Scan(
+		&p.WindowStart,
+		&p.WindowEnd,
+		&p.ContributingSensors,
+		&p.MeasurementCount,
+		&p.ImputedCount,
+	); err != nil {
+		return nil, mapErr(err)
+	}
+
+	return &p, nil
+}
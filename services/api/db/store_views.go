@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// ViewDefinition is the stored query behind a saved view.
+type ViewDefinition struct {
+	Sensors []string          `json:"sensors,omitempty"`
+	Filters map[string]string `json:"filters,omitempty"`
+	Window  string            `json:"window,omitempty"`
+	// Bucket sums each series into buckets of this width (e.g. 1h).
+	Bucket string `json:"bucket,omitempty"`
+}
+
+// SavedView is a named query definition that dashboards execute by slug.
+type SavedView struct {
+	Slug       string         `json:"slug"`
+	Name       string         `json:"name"`
+	Endpoint   string         `json:"endpoint"`
+	Definition ViewDefinition `json:"definition"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// CreateView stores a new saved view. ErrConflict is returned when the slug
+// is already taken.
+func (s *Store) CreateView(ctx context.Context, v SavedView) (*SavedView, error) {
+	query := `
+		INSERT INTO shizuku.saved_views (slug, name, endpoint, definition)
+		VALUES ($1, $2, $3, $4)
+		RETURNING slug, name, endpoint, definition, created_at, updated_at
+	`
+
+	var out SavedView
+	err := s.pool.QueryRow(ctx, query, v.Slug, v.Name, v.Endpoint, v.Definition).Scan(
+		&out.Slug,
+		&out.Name,
+		&out.Endpoint,
+		&out.Definition,
+		&out.CreatedAt,
+		&out.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+
+	return &out, nil
+}
+
+// GetView returns the saved view with the given slug.
+func (s *Store) GetView(ctx context.Context, slug string) (*SavedView, error) {
+	query := `
+		SELECT slug, name, endpoint, definition, created_at, updated_at
+		FROM shizuku.saved_views
+		WHERE slug = $1
+	`
+
+	var v SavedView
+	err := s.pool.QueryRow(ctx, query, slug).Scan(
+		&v.Slug,
+		&v.Name,
+		&v.Endpoint,
+		&v.Definition,
+		&v.CreatedAt,
+		&v.UpdatedAt,
+	)
+	if err != nil {
+		return nil, mapRowErr(err, "view")
+	}
+
+	return &v, nil
+}
+
+// ListViews returns all saved views ordered by slug.
+func (s *Store) ListViews(ctx context.Context) ([]SavedView, error) {
+	query := `
+		SELECT slug, name, endpoint, definition, created_at, updated_at
+		FROM shizuku.saved_views
+		ORDER BY slug
+	`
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	views := make([]SavedView, 0)
+	for rows.Next() {
+		var v SavedView
+		if err := rows.Scan(
+			&v.Slug,
+			&v.Name,
+			&v.Endpoint,
+			&v.Definition,
+			&v.CreatedAt,
+			&v.UpdatedAt,
+		); err != nil {
+			return nil, mapErr(err)
+		}
+		views = append(views, v)
+	}
+
+	return views, mapErr(rows.Err())
+}
+
+// DeleteView removes a saved view, returning ErrNotFound if it does not exist.
+func (s *Store) DeleteView(ctx context.Context, slug string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM shizuku.saved_views WHERE slug = $1`, slug)
+	if err != nil {
+		return mapErr(err)
+	}
+	if tag.RowsAffected() == 0 {
+		return notFound("view")
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"context"
+	"time"
+)
+
+// SensorWindowTotal is a sensor's clean rainfall over a recent window.
+// AccumulationMM is nil when the sensor has no clean rows in the window.
+type SensorWindowTotal struct {
+	ID               string   `json:"id"`
+	Name             *string  `json:"name,omitempty"`
+	Lat              float64  `json:"lat"`
+	Lon              float64  `json:"lon"`
+	City             *string  `json:"city,omitempty"`
+	AccumulationMM   *float64 `json:"accumulation_mm"`
+	MeasurementCount int      `json:"measurement_count"`
+}
+
+// SensorWindowTotals sums every sensor's clean value_mm over (since, until].
+// All sensors are returned, including those without data in the window.
+func (s *Store) SensorWindowTotals(ctx context.Context, since, until time.Time) ([]SensorWindowTotal, error) {
+	query := `
+		SELECT s.id, s.name, s.lat, s.lon, s.city, w.total_mm, COALESCE(w.n, 0)
+		FROM shizuku.sensors s
+		LEFT JOIN (
+			SELECT sensor_id, SUM(value_mm) AS total_mm, COUNT(*) AS n
+			FROM shizuku.clean_measurements
+			WHERE ts > $1 AND ts <= $2
+			GROUP BY sensor_id
+		) w ON w.sensor_id = s.id
+		ORDER BY s.id
+	`
+
+	rows, err := s.pool.Query(ctx, query, since, until)
+	if err != nil {
+		return nil, mapErr(err)
+	}
+	defer rows.Close()
+
+	totals := make([]SensorWindowTotal, 0)
+	for rows.Next() {
+		var t SensorWindowTotal
+		if err := rows.Scan(&t.ID, &t.Name, &t.Lat, &t.Lon, &t.City, &t.AccumulationMM, &t.MeasurementCount); err != nil {
+			return nil, mapErr(err)
+		}
+		totals = append(totals, t)
+	}
+	return totals, mapErr(rows.Err())
+}
@@ -0,0 +1,119 @@
+// Package export holds the writers shared by the API's file export endpoints.
+package export
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// utf8BOM lets Excel detect UTF-8 when opening a CSV directly.
+const utf8BOM = "\xef\xbb\xbf"
+
+// excelTimeLayout is a timestamp layout Excel parses as a date-time cell.
+const excelTimeLayout = "2006-01-02 15:04:05"
+
+// CSVOptions controls locale-dependent CSV formatting. The zero value writes
+// comma-separated RFC3339 UTC timestamps with '.' decimals and no BOM.
+type CSVOptions struct {
+	// Delimiter separates fields; ',' when zero.
+	Delimiter rune
+	// DecimalComma renders numbers with ',' as decimal separator.
+	DecimalComma bool
+	// ExcelTime renders timestamps as "YYYY-MM-DD HH:MM:SS" in Location
+	// instead of RFC3339 UTC.
+	ExcelTime bool
+	// Location is the zone for ExcelTime timestamps; UTC when nil.
+	Location *time.Location
+	// BOM prefixes the output with a UTF-8 byte order mark.
+	BOM bool
+}
+
+// Validate rejects option combinations that produce ambiguous files.
+func (o CSVOptions) Validate() error {
+	if o.Delimiter != 0 && o.Delimiter != ',' && o.Delimiter != ';' {
+		return errors.New("delimiter must be ',' or ';'")
+	}
+	if o.DecimalComma && (o.Delimiter == 0 || o.Delimiter == ',') {
+		return errors.New("decimal=',' requires delimiter=';'")
+	}
+	return nil
+}
+
+// CSVWriter writes records with locale-aware number and time formatting.
+type CSVWriter struct {
+	out  io.Writer
+	w    *csv.Writer
+	opts CSVOptions
+}
+
+// NewCSVWriter validates opts, writes the optional BOM and returns a writer.
+func NewCSVWriter(w io.Writer, opts CSVOptions) (*CSVWriter, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.BOM {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return nil, err
+		}
+	}
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	return &CSVWriter{out: w, w: cw, opts: opts}, nil
+}
+
+// Write writes one record.
+func (w *CSVWriter) Write(record []string) error {
+	return w.w.Write(record)
+}
+
+// Flush writes any buffered data and reports write errors.
+func (w *CSVWriter) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// Comment flushes pending records and writes a "# text" line. Comment lines
+// carry stream metadata such as resume cursors; readers should skip them.
+func (w *CSVWriter) Comment(text string) error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.out, "# "+text+"\n")
+	return err
+}
+
+// Float formats a number using the configured decimal separator.
+func (w *CSVWriter) Float(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if w.opts.DecimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+// FloatPtr formats an optional number, rendering nil as an empty field.
+func (w *CSVWriter) FloatPtr(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return w.Float(*v)
+}
+
+// Time formats a timestamp as RFC3339 UTC or, with ExcelTime, as local
+// "YYYY-MM-DD HH:MM:SS" in the configured location.
+func (w *CSVWriter) Time(t time.Time) string {
+	if !w.opts.ExcelTime {
+		return t.UTC().Format(time.RFC3339)
+	}
+	loc := w.opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(excelTimeLayout)
+}
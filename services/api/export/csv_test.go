@@ -0,0 +1,163 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTS = time.Date(2025, 10, 1, 17, 5, 0, 0, time.UTC)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skip(err)
+	}
+	return loc
+}
+
+// writeRow writes a header and one row using the writer's formatting.
+func writeRow(t *testing.T, opts CSVOptions) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := 1.25
+	for _, record := range [][]string{
+		{"sensor_id", "ts", "value_mm", "empty"},
+		{"siata_1", w.Time(testTS), w.FloatPtr(&value), w.FloatPtr(nil)},
+	} {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestCSVWriterEncoding(t *testing.T) {
+	bogota := mustLoad(t, "America/Bogota")
+	for _, tc := range []struct {
+		name string
+		opts CSVOptions
+		want string
+	}{
+		{
+			name: "defaults",
+			want: "sensor_id,ts,value_mm,empty\nsiata_1,2025-10-01T17:05:00Z,1.25,\n",
+		},
+		{
+			name: "semicolon with decimal comma",
+			opts: CSVOptions{Delimiter: ';', DecimalComma: true},
+			want: "sensor_id;ts;value_mm;empty\nsiata_1;2025-10-01T17:05:00Z;1,25;\n",
+		},
+		{
+			name: "semicolon with decimal point",
+			opts: CSVOptions{Delimiter: ';'},
+			want: "sensor_id;ts;value_mm;empty\nsiata_1;2025-10-01T17:05:00Z;1.25;\n",
+		},
+		{
+			name: "excel time defaults to UTC",
+			opts: CSVOptions{ExcelTime: true},
+			want: "sensor_id,ts,value_mm,empty\nsiata_1,2025-10-01 17:05:00,1.25,\n",
+		},
+		{
+			name: "excel time in a zone",
+			opts: CSVOptions{ExcelTime: true, Location: bogota},
+			want: "sensor_id,ts,value_mm,empty\nsiata_1,2025-10-01 12:05:00,1.25,\n",
+		},
+		{
+			// Location only applies to excel timestamps.
+			name: "rfc3339 ignores the zone",
+			opts: CSVOptions{Location: bogota},
+			want: "sensor_id,ts,value_mm,empty\nsiata_1,2025-10-01T17:05:00Z,1.25,\n",
+		},
+		{
+			name: "bom",
+			opts: CSVOptions{BOM: true},
+			want: "\xef\xbb\xbfsensor_id,ts,value_mm,empty\nsiata_1,2025-10-01T17:05:00Z,1.25,\n",
+		},
+		{
+			name: "excel locale",
+			opts: CSVOptions{Delimiter: ';', DecimalComma: true, ExcelTime: true, Location: bogota, BOM: true},
+			want: "\xef\xbb\xbfsensor_id;ts;value_mm;empty\nsiata_1;2025-10-01 12:05:00;1,25;\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := writeRow(t, tc.opts); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// A decimal-comma file must still parse back into the same fields.
+func TestCSVWriterDecimalCommaRoundTrip(t *testing.T) {
+	out := writeRow(t, CSVOptions{Delimiter: ';', DecimalComma: true})
+	r := csv.NewReader(strings.NewReader(out))
+	r.Comma = ';'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(records[1]) != 4 || records[1][2] != "1,25" {
+		t.Errorf("records %q", records)
+	}
+}
+
+func TestCSVWriterFloat(t *testing.T) {
+	plain, _ := NewCSVWriter(&bytes.Buffer{}, CSVOptions{})
+	comma, _ := NewCSVWriter(&bytes.Buffer{}, CSVOptions{Delimiter: ';', DecimalComma: true})
+	for _, tc := range []struct {
+		v           float64
+		plain, comm string
+	}{
+		{0, "0", "0"},
+		{12, "12", "12"},
+		{-0.5, "-0.5", "-0,5"},
+		{1234567.125, "1234567.125", "1234567,125"},
+		{1e-7, "0.0000001", "0,0000001"},
+	} {
+		if got := plain.Float(tc.v); got != tc.plain {
+			t.Errorf("Float(%v) = %q, want %q", tc.v, got, tc.plain)
+		}
+		if got := comma.Float(tc.v); got != tc.comm {
+			t.Errorf("decimal comma Float(%v) = %q, want %q", tc.v, got, tc.comm)
+		}
+	}
+}
+
+func TestCSVOptionsValidate(t *testing.T) {
+	for name, opts := range map[string]CSVOptions{
+		"tab delimiter":                {Delimiter: '\t'},
+		"decimal comma, default comma": {DecimalComma: true},
+		"decimal comma, comma":         {Delimiter: ',', DecimalComma: true},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+		var buf bytes.Buffer
+		if _, err := NewCSVWriter(&buf, CSVOptions{BOM: true, Delimiter: opts.Delimiter, DecimalComma: opts.DecimalComma}); err == nil || buf.Len() != 0 {
+			t.Errorf("%s: writer created or wrote %q", name, buf.String())
+		}
+	}
+}
+
+func TestCSVWriterComment(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewCSVWriter(&buf, CSVOptions{Delimiter: ';'})
+	_ = w.Write([]string{"a", "b"})
+	if err := w.Comment("next_cursor=abc"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "a;b\n# next_cursor=abc\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,47 @@
+// Package geohash encodes coordinates as geohash cells, used to cluster
+// sensors for low zoom levels.
+package geohash
+
+// base32 is the geohash alphabet (no a, i, l, o).
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Encode returns the geohash of lat/lon with precision characters. Each
+// character adds 5 bits, alternating longitude and latitude halvings starting
+// with longitude; e.g. (57.64911, 10.40744) at precision 11 is "u4pruydqqvj".
+func Encode(lat, lon float64, precision int) string {
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	even := true
+	bit, ch := 0, 0
+	for len(hash) < precision {
+		if even {
+			mid := (lonLo + lonHi) / 2
+			if lon >= mid {
+				ch = ch<<1 | 1
+				lonLo = mid
+			} else {
+				ch <<= 1
+				lonHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				latLo = mid
+			} else {
+				ch <<= 1
+				latHi = mid
+			}
+		}
+		even = !even
+
+		bit++
+		if bit == 5 {
+			hash = append(hash, base32[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
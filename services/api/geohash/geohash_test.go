@@ -0,0 +1,36 @@
+package geohash
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	for _, tc := range []struct {
+		lat, lon  float64
+		precision int
+		want      string
+	}{
+		{57.64911, 10.40744, 11, "u4pruydqqvj"},
+		{42.605, -5.603, 5, "ezs42"},
+		{0, 0, 5, "s0000"},
+		{-90, -180, 5, "00000"},
+		{90, 180, 5, "zzzzz"},
+		{57.64911, 10.40744, 0, ""},
+	} {
+		if got := Encode(tc.lat, tc.lon, tc.precision); got != tc.want {
+			t.Errorf("Encode(%v, %v, %d) = %q, want %q", tc.lat, tc.lon, tc.precision, got, tc.want)
+		}
+	}
+}
+
+// A shorter geohash is the cell containing the longer one, which is what
+// lets clusters at a coarse precision group the finer ones.
+func TestEncodePrefix(t *testing.T) {
+	full := Encode(6.2442, -75.5812, 12)
+	for p := 1; p < 12; p++ {
+		if got := Encode(6.2442, -75.5812, p); !strings.HasPrefix(full, got) || len(got) != p {
+			t.Errorf("precision %d: %q is not a prefix of %q", p, got, full)
+		}
+	}
+}
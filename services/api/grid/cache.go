@@ -0,0 +1,79 @@
+package grid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Cache fetches grid documents from blob storage and keeps the parsed result.
+// Grid blobs are immutable per run, so entries never expire; the oldest entry
+// is evicted once maxEntries is reached.
+type Cache struct {
+	client     *http.Client
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*Grid
+	order   []string
+}
+
+// NewCache creates a grid cache holding up to maxEntries parsed grids.
+func NewCache(client *http.Client, maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &Cache{
+		client:     client,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*Grid),
+	}
+}
+
+// Get returns the grid stored at url, downloading and parsing it on a miss.
+func (c *Cache) Get(ctx context.Context, url string) (*Grid, error) {
+	c.mu.Lock()
+	if g, ok := c.entries[url]; ok {
+		c.mu.Unlock()
+		return g, nil
+	}
+	c.mu.Unlock()
+
+	g, err := c.fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[url]; !ok {
+		if len(c.order) >= c.maxEntries {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			delete(c.entries, oldest)
+		}
+		c.order = append(c.order, url)
+	}
+	c.entries[url] = g
+	return g, nil
+}
+
+func (c *Cache) fetch(ctx context.Context, url string) (*Grid, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch grid: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch grid: unexpected status %s", resp.Status)
+	}
+
+	return Decode(resp.Body)
+}
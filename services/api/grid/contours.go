@@ -0,0 +1,112 @@
+package grid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrContoursTooLarge is returned when a contours document exceeds the inline
+// size limit.
+var ErrContoursTooLarge = errors.New("contours document too large to inline")
+
+// ContoursCache fetches contour GeoJSON documents and keeps the raw bytes per
+// grid run. Documents over maxBytes are remembered as too large so the blob
+// is not downloaded again for that run.
+type ContoursCache struct {
+	client     *http.Client
+	maxBytes   int64
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[int]json.RawMessage // nil value marks a too-large document
+	order   []int
+}
+
+// NewContoursCache creates a cache of up to maxEntries documents no larger
+// than maxBytes each.
+func NewContoursCache(client *http.Client, maxBytes int64, maxEntries int) *ContoursCache {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &ContoursCache{
+		client:     client,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		entries:    make(map[int]json.RawMessage),
+	}
+}
+
+// Get returns the FeatureCollection of run runID stored at url, or
+// ErrContoursTooLarge when it exceeds the size limit.
+func (c *ContoursCache) Get(ctx context.Context, runID int, url string) (json.RawMessage, error) {
+	c.mu.Lock()
+	doc, ok := c.entries[runID]
+	c.mu.Unlock()
+	if ok {
+		if doc == nil {
+			return nil, ErrContoursTooLarge
+		}
+		return doc, nil
+	}
+
+	doc, err := c.fetch(ctx, url)
+	if err != nil && !errors.Is(err, ErrContoursTooLarge) {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if _, ok := c.entries[runID]; !ok {
+		if len(c.order) >= c.maxEntries {
+			oldest := c.order[0]
+			c.order = c.order[1:]
+			delete(c.entries, oldest)
+		}
+		c.order = append(c.order, runID)
+	}
+	c.entries[runID] = doc
+	c.mu.Unlock()
+
+	return doc, err
+}
+
+func (c *ContoursCache) fetch(ctx context.Context, url string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch contours: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch contours: unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > c.maxBytes {
+		return nil, ErrContoursTooLarge
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, c.maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch contours: %w", err)
+	}
+	if int64(len(body)) > c.maxBytes {
+		return nil, ErrContoursTooLarge
+	}
+
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(body, &head); err != nil || head.Type != "FeatureCollection" {
+		return nil, errors.New("fetch contours: document is not a GeoJSON FeatureCollection")
+	}
+	return json.RawMessage(bytes.TrimSpace(body)), nil
+}
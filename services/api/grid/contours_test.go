@@ -0,0 +1,92 @@
+package grid
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+const smallContours = `{"type":"FeatureCollection","features":[]}`
+
+// blobServer serves smallContours at /small, a document over 64 bytes at
+// /large (chunked, so without a Content-Length) and a non-GeoJSON document at
+// /other, counting requests per path.
+func blobServer(t *testing.T) (*httptest.Server, map[string]*atomic.Int32) {
+	t.Helper()
+	hits := map[string]*atomic.Int32{"/small": {}, "/large": {}, "/other": {}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, ok := hits[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		n.Add(1)
+		switch r.URL.Path {
+		case "/small":
+			_, _ = w.Write([]byte(smallContours))
+		case "/large":
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(`{"type":"FeatureCollection","features":[` + strings.Repeat(`{}`, 64) + `]}`))
+		case "/other":
+			_, _ = w.Write([]byte(`{"type":"Feature"}`))
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, hits
+}
+
+func TestContoursCacheInline(t *testing.T) {
+	srv, hits := blobServer(t)
+	cache := NewContoursCache(srv.Client(), 64, 4)
+
+	for i := 0; i < 2; i++ {
+		doc, err := cache.Get(context.Background(), 1, srv.URL+"/small")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(doc) != smallContours {
+			t.Errorf("got %s, want %s", doc, smallContours)
+		}
+	}
+	if n := hits["/small"].Load(); n != 1 {
+		t.Errorf("blob fetched %d times, want once", n)
+	}
+}
+
+// Too-large documents fall back to the blob URL; the verdict is cached so the
+// blob is not downloaded on every request.
+func TestContoursCacheTooLarge(t *testing.T) {
+	srv, hits := blobServer(t)
+	cache := NewContoursCache(srv.Client(), 64, 4)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.Background(), 2, srv.URL+"/large"); !errors.Is(err, ErrContoursTooLarge) {
+			t.Fatalf("got %v, want ErrContoursTooLarge", err)
+		}
+	}
+	if n := hits["/large"].Load(); n != 1 {
+		t.Errorf("blob fetched %d times, want once", n)
+	}
+}
+
+// Other failures are not cached, so a later request retries the blob.
+func TestContoursCacheRejectsOtherDocuments(t *testing.T) {
+	srv, hits := blobServer(t)
+	cache := NewContoursCache(srv.Client(), 64, 4)
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Get(context.Background(), 3, srv.URL+"/other"); err == nil || errors.Is(err, ErrContoursTooLarge) {
+			t.Fatalf("got %v, want a document error", err)
+		}
+	}
+	if n := hits["/other"].Load(); n != 2 {
+		t.Errorf("blob fetched %d times, want 2", n)
+	}
+	if _, err := cache.Get(context.Background(), 4, srv.URL+"/missing"); err == nil {
+		t.Error("404 accepted")
+	}
+}
@@ -0,0 +1,119 @@
+package grid
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrNoSensors is returned when a coverage mask is requested for a run without
+// any contributing sensors.
+var ErrNoSensors = errors.New("grid run has no contributing sensors")
+
+// Point is a sensor location in WGS84 degrees.
+type Point struct {
+	Lon float64
+	Lat float64
+}
+
+// Coverage band codes returned by Classify.
+const (
+	BandNear   int8 = 0
+	BandMedium int8 = 1
+	BandFar    int8 = 2
+)
+
+// DistanceGrid returns, for every grid cell, the distance in metres from the
+// cell centre to the nearest point, indexed like g.Data. Distances use an
+// equirectangular approximation, which is accurate to well under a percent at
+// city scale.
+func DistanceGrid(g *Grid, points []Point) ([][]int32, error) {
+	if len(points) == 0 {
+		return nil, ErrNoSensors
+	}
+	const earthRadius = 6371008.8
+
+	lons := make([]float64, len(g.X))
+	for col, x := range g.X {
+		lons[col], _ = MercatorToWGS84(x, 0)
+	}
+
+	out := make([][]int32, len(g.Y))
+	for row, y := range g.Y {
+		_, lat := MercatorToWGS84(0, y)
+		cosLat := math.Cos(lat * math.Pi / 180)
+		out[row] = make([]int32, len(g.X))
+		for col, lon := range lons {
+			best := math.Inf(1)
+			for _, p := range points {
+				dx := (lon - p.Lon) * cosLat
+				dy := lat - p.Lat
+				if d := dx*dx + dy*dy; d < best {
+					best = d
+				}
+			}
+			out[row][col] = int32(math.Round(math.Sqrt(best) * math.Pi / 180 * earthRadius))
+		}
+	}
+	return out, nil
+}
+
+// Classify buckets a distance grid into near (<= near), medium (<= far) and
+// far bands.
+func Classify(dist [][]int32, near, far float64) [][]int8 {
+	out := make([][]int8, len(dist))
+	for row, cells := range dist {
+		out[row] = make([]int8, len(cells))
+		for col, d := range cells {
+			switch {
+			case float64(d) <= near:
+				out[row][col] = BandNear
+			case float64(d) <= far:
+				out[row][col] = BandMedium
+			default:
+				out[row][col] = BandFar
+			}
+		}
+	}
+	return out
+}
+
+// CoverageCache keeps distance grids keyed by grid run. Runs are immutable, so
+// entries never expire; the oldest is evicted once maxEntries is reached.
+type CoverageCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[int][][]int32
+	order   []int
+}
+
+// NewCoverageCache creates a cache holding up to maxEntries distance grids.
+func NewCoverageCache(maxEntries int) *CoverageCache {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &CoverageCache{maxEntries: maxEntries, entries: make(map[int][][]int32)}
+}
+
+// Get returns the cached distance grid for a run.
+func (c *CoverageCache) Get(runID int) ([][]int32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d, ok := c.entries[runID]
+	return d, ok
+}
+
+// Put stores a distance grid, evicting the oldest entry when full.
+func (c *CoverageCache) Put(runID int, dist [][]int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[runID]; !ok {
+		if len(c.order) >= c.maxEntries {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, runID)
+	}
+	c.entries[runID] = dist
+}
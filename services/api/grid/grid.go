@@ -0,0 +1,220 @@
+package grid
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Grid mirrors the grid.json.gz document uploaded by the ETL service.
+// Data is indexed as Data[row][col] where rows follow Y and columns follow X
+// (both in EPSG:3857 metres).
+type Grid struct {
+	Timestamp           string          `json:"timestamp"`
+	ResM                int             `json:"res_m"`
+	BBox3857            []float64       `json:"bbox_3857"`
+	BBoxWGS84           []float64       `json:"bbox_wgs84"`
+	IntensityClasses    json.RawMessage `json:"intensity_classes,omitempty"`
+	IntensityThresholds json.RawMessage `json:"intensity_thresholds,omitempty"`
+	X                   []float64       `json:"x"`
+	Y                   []float64       `json:"y"`
+	Data                [][]float64     `json:"data"`
+}
+
+var (
+	// ErrResolutionMismatch is returned when two grids use different cell sizes.
+	ErrResolutionMismatch = errors.New("grid resolutions do not match")
+	// ErrNoOverlap is returned when two grids do not share any cells.
+	ErrNoOverlap = errors.New("grids do not overlap")
+	// ErrMisaligned is returned when grid cells do not line up on a common lattice.
+	ErrMisaligned = errors.New("grid cells are not aligned")
+)
+
+// Decode parses a grid document, transparently handling gzip payloads.
+func Decode(r io.Reader) (*Grid, error) {
+	br := bufio.NewReader(r)
+	var src io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("open gzip grid: %w", err)
+		}
+		defer gz.Close()
+		src = gz
+	}
+
+	var g Grid
+	if err := json.NewDecoder(src).Decode(&g); err != nil {
+		return nil, fmt.Errorf("decode grid: %w", err)
+	}
+	if err := g.validate(); err != nil {
+		return nil, err
+	}
+	return &g, nil
+}
+
+func (g *Grid) validate() error {
+	if len(g.Y) != len(g.Data) {
+		return fmt.Errorf("grid has %d rows but %d y coordinates", len(g.Data), len(g.Y))
+	}
+	for i, row := range g.Data {
+		if len(row) != len(g.X) {
+			return fmt.Errorf("grid row %d has %d cells but %d x coordinates", i, len(row), len(g.X))
+		}
+	}
+	return nil
+}
+
+// Step returns the cell spacing along each axis. Grids built with
+// numpy.linspace have spacing close to, but not exactly, ResM.
+func (g *Grid) Step() (dx, dy float64) {
+	if len(g.X) > 1 {
+		dx = (g.X[len(g.X)-1] - g.X[0]) / float64(len(g.X)-1)
+	}
+	if len(g.Y) > 1 {
+		dy = (g.Y[len(g.Y)-1] - g.Y[0]) / float64(len(g.Y)-1)
+	}
+	return dx, dy
+}
+
+// Diff returns a grid holding later minus earlier over the extent both grids
+// cover. The grids must share a resolution and their cells must line up.
+func Diff(later, earlier *Grid) (*Grid, error) {
+	if later.ResM != earlier.ResM {
+		return nil, fmt.Errorf("%w: %d m vs %d m", ErrResolutionMismatch, later.ResM, earlier.ResM)
+	}
+
+	xl, xe, nx, err := overlap(later.X, earlier.X)
+	if err != nil {
+		return nil, err
+	}
+	yl, ye, ny, err := overlap(later.Y, earlier.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Grid{
+		Timestamp: later.Timestamp,
+		ResM:      later.ResM,
+		X:         append([]float64(nil), later.X[xl:xl+nx]...),
+		Y:         append([]float64(nil), later.Y[yl:yl+ny]...),
+		Data:      make([][]float64, ny),
+	}
+	for r := 0; r < ny; r++ {
+		row := make([]float64, nx)
+		for col := 0; col < nx; col++ {
+			row[col] = later.Data[yl+r][xl+col] - earlier.Data[ye+r][xe+col]
+		}
+		out.Data[r] = row
+	}
+
+	minX, minY := out.X[0], out.Y[0]
+	maxX, maxY := out.X[nx-1], out.Y[ny-1]
+	out.BBox3857 = []float64{minX, minY, maxX, maxY}
+	west, south := MercatorToWGS84(minX, minY)
+	east, north := MercatorToWGS84(maxX, maxY)
+	out.BBoxWGS84 = []float64{west, south, east, north}
+
+	return out, nil
+}
+
+// overlap finds the shared run of coordinates between two ascending axes,
+// returning the start index in each axis and the run length.
+func overlap(a, b []float64) (startA, startB, n int, err error) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 0, 0, ErrNoOverlap
+	}
+	step := axisStep(a)
+	if stepB := axisStep(b); step == 0 || stepB == 0 || math.Abs(step-stepB) > step*1e-3 {
+		return 0, 0, 0, ErrMisaligned
+	}
+
+	lo := math.Max(a[0], b[0])
+	hi := math.Min(a[len(a)-1], b[len(b)-1])
+	tol := step / 2
+	if lo > hi+tol {
+		return 0, 0, 0, ErrNoOverlap
+	}
+
+	startA = int(math.Round((lo - a[0]) / step))
+	startB = int(math.Round((lo - b[0]) / step))
+	if math.Abs(a[startA]-b[startB]) > step*0.01 {
+		return 0, 0, 0, ErrMisaligned
+	}
+
+	n = int(math.Round((hi-lo)/step)) + 1
+	if startA+n > len(a) {
+		n = len(a) - startA
+	}
+	if startB+n > len(b) {
+		n = len(b) - startB
+	}
+	return startA, startB, n, nil
+}
+
+func axisStep(axis []float64) float64 {
+	if len(axis) < 2 {
+		return 0
+	}
+	return (axis[len(axis)-1] - axis[0]) / float64(len(axis)-1)
+}
+
+// MercatorToWGS84 converts EPSG:3857 metres to lon/lat degrees.
+func MercatorToWGS84(x, y float64) (lon, lat float64) {
+	const earthRadius = 6378137.0
+	lon = x / earthRadius * 180 / math.Pi
+	lat = (2*math.Atan(math.Exp(y/earthRadius)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// WGS84ToMercator converts lon/lat degrees to EPSG:3857 metres.
+func WGS84ToMercator(lon, lat float64) (x, y float64) {
+	const earthRadius = 6378137.0
+	x = lon * math.Pi / 180 * earthRadius
+	y = math.Log(math.Tan(math.Pi/4+lat*math.Pi/360)) * earthRadius
+	return x, y
+}
+
+// Sample returns the value of the cell containing lon/lat. ok is false when
+// the point lies outside the grid or the cell holds no finite value.
+func (g *Grid) Sample(lon, lat float64) (value float64, ok bool) {
+	x, y := WGS84ToMercator(lon, lat)
+	col, ok := cellIndex(g.X, x)
+	if !ok {
+		return 0, false
+	}
+	row, ok := cellIndex(g.Y, y)
+	if !ok {
+		return 0, false
+	}
+	value = g.Data[row][col]
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
+
+// cellIndex finds the cell of an axis of evenly spaced cell centres containing
+// v, allowing half a step beyond the outer centres.
+func cellIndex(axis []float64, v float64) (int, bool) {
+	if len(axis) == 0 {
+		return 0, false
+	}
+	if len(axis) == 1 {
+		return 0, v == axis[0]
+	}
+	// Descending axes have a negative step, which the division handles.
+	step := axisStep(axis)
+	if step == 0 {
+		return 0, false
+	}
+	i := int(math.Round((v - axis[0]) / step))
+	if i < 0 || i >= len(axis) {
+		return 0, false
+	}
+	return i, true
+}
@@ -0,0 +1,105 @@
+package grid
+
+import (
+	"errors"
+	"testing"
+)
+
+// testGrid builds an nx by ny grid of 500 m cells starting at (x0, y0) whose
+// cell values are value(col, row).
+func testGrid(x0, y0 float64, nx, ny int, value func(col, row int) float64) *Grid {
+	g := &Grid{ResM: 500, X: make([]float64, nx), Y: make([]float64, ny), Data: make([][]float64, ny)}
+	for i := range g.X {
+		g.X[i] = x0 + float64(i)*500
+	}
+	for r := range g.Y {
+		g.Y[r] = y0 + float64(r)*500
+		g.Data[r] = make([]float64, nx)
+		for col := range g.Data[r] {
+			g.Data[r][col] = value(col, r)
+		}
+	}
+	return g
+}
+
+func TestDiff(t *testing.T) {
+	const x0, y0 = -8420000, 690000
+	later := testGrid(x0, y0, 4, 3, func(col, row int) float64 { return float64(10*row + col) })
+	for _, tc := range []struct {
+		name     string
+		earlier  *Grid
+		wantErr  error
+		wantX0   float64
+		wantY0   float64
+		wantData [][]float64
+	}{
+		{
+			name:     "identical extent",
+			earlier:  testGrid(x0, y0, 4, 3, func(col, row int) float64 { return 1 }),
+			wantX0:   x0,
+			wantY0:   y0,
+			wantData: [][]float64{{-1, 0, 1, 2}, {9, 10, 11, 12}, {19, 20, 21, 22}},
+		},
+		{
+			// Shifted by two columns and one row: the shared cells are
+			// columns 2-3 and rows 1-2 of later.
+			name:     "partial overlap",
+			earlier:  testGrid(x0+1000, y0+500, 4, 3, func(col, row int) float64 { return float64(col) }),
+			wantX0:   x0 + 1000,
+			wantY0:   y0 + 500,
+			wantData: [][]float64{{12, 12}, {22, 22}},
+		},
+		{
+			name:    "half-cell offset",
+			earlier: testGrid(x0+250, y0, 4, 3, func(col, row int) float64 { return 0 }),
+			wantErr: ErrMisaligned,
+		},
+		{
+			name:    "different step",
+			earlier: &Grid{ResM: 500, X: []float64{x0, x0 + 700, x0 + 1400}, Y: later.Y, Data: [][]float64{{0, 0, 0}, {0, 0, 0}, {0, 0, 0}}},
+			wantErr: ErrMisaligned,
+		},
+		{
+			name:    "disjoint",
+			earlier: testGrid(x0+5000, y0, 4, 3, func(col, row int) float64 { return 0 }),
+			wantErr: ErrNoOverlap,
+		},
+		{
+			name:    "resolution mismatch",
+			earlier: &Grid{ResM: 1000, X: later.X, Y: later.Y, Data: later.Data},
+			wantErr: ErrResolutionMismatch,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Diff(later, tc.earlier)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("got %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.X[0] != tc.wantX0 || got.Y[0] != tc.wantY0 {
+				t.Errorf("origin (%v, %v), want (%v, %v)", got.X[0], got.Y[0], tc.wantX0, tc.wantY0)
+			}
+			if err := got.validate(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Data) != len(tc.wantData) {
+				t.Fatalf("%d rows, want %d", len(got.Data), len(tc.wantData))
+			}
+			for r, want := range tc.wantData {
+				for col, v := range want {
+					if got.Data[r][col] != v {
+						t.Errorf("cell (%d, %d) = %v, want %v", col, r, got.Data[r][col], v)
+					}
+				}
+			}
+			if bb := got.BBox3857; len(bb) != 4 || bb[0] != got.X[0] || bb[3] != got.Y[len(got.Y)-1] {
+				t.Errorf("bbox %v does not match the axes", bb)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package grid
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"sync"
+)
+
+// rampStop maps a precipitation value (mm) to a colour. Stops follow the ETL
+// intensity classes (trace, light, moderate, heavy, violent).
+type rampStop struct {
+	Value float64
+	Color color.RGBA
+}
+
+var colorRamp = []rampStop{
+	{0.0, color.RGBA{68, 1, 84, 255}},
+	{0.2, color.RGBA{59, 82, 139, 255}},
+	{2.5, color.RGBA{33, 145, 140, 255}},
+	{7.6, color.RGBA{94, 201, 98, 255}},
+	{50.0, color.RGBA{253, 231, 37, 255}},
+}
+
+// RampColor returns the interpolated ramp colour for a value in mm.
+func RampColor(v float64) color.RGBA {
+	if math.IsNaN(v) {
+		return color.RGBA{}
+	}
+	if v <= colorRamp[0].Value {
+		return colorRamp[0].Color
+	}
+	for i := 1; i < len(colorRamp); i++ {
+		hi := colorRamp[i]
+		if v <= hi.Value {
+			lo := colorRamp[i-1]
+			t := (v - lo.Value) / (hi.Value - lo.Value)
+			return color.RGBA{
+				R: lerp8(lo.Color.R, hi.Color.R, t),
+				G: lerp8(lo.Color.G, hi.Color.G, t),
+				B: lerp8(lo.Color.B, hi.Color.B, t),
+				A: 255,
+			}
+		}
+	}
+	return colorRamp[len(colorRamp)-1].Color
+}
+
+func lerp8(a, b uint8, t float64) uint8 {
+	return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+}
+
+// RenderPNG draws the grid through the colour ramp into a PNG of the given
+// width using nearest-neighbour resampling. The height keeps the grid aspect
+// ratio; north is up.
+func RenderPNG(g *Grid, width int) ([]byte, error) {
+	nx, ny := len(g.X), len(g.Y)
+	if nx == 0 || ny == 0 {
+		return nil, errors.New("grid is empty")
+	}
+	height := int(math.Round(float64(width) * float64(ny) / float64(nx)))
+	if height < 1 {
+		height = 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for py := 0; py < height; py++ {
+		row := ny - 1 - int(float64(py)*float64(ny)/float64(height))
+		for px := 0; px < width; px++ {
+			col := int(float64(px) * float64(nx) / float64(width))
+			img.SetRGBA(px, py, RampColor(g.Data[row][col]))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type thumbnailKey struct {
+	RunID int
+	Width int
+}
+
+// ThumbnailCache keeps encoded PNG thumbnails keyed by grid run and width.
+type ThumbnailCache struct {
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[thumbnailKey][]byte
+	order   []thumbnailKey
+}
+
+// NewThumbnailCache creates a cache holding up to maxEntries thumbnails.
+func NewThumbnailCache(maxEntries int) *ThumbnailCache {
+	if maxEntries <= 0 {
+		maxEntries = 1
+	}
+	return &ThumbnailCache{maxEntries: maxEntries, entries: make(map[thumbnailKey][]byte)}
+}
+
+// Get returns the cached thumbnail for a run and width.
+func (c *ThumbnailCache) Get(runID, width int) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.entries[thumbnailKey{runID, width}]
+	return b, ok
+}
+
+// Put stores a thumbnail, evicting the oldest entry when full.
+func (c *ThumbnailCache) Put(runID, width int, data []byte) {
+	key := thumbnailKey{runID, width}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		if len(c.order) >= c.maxEntries {
+			delete(c.entries, c.order[0])
+			c.order = c.order[1:]
+		}
+		c.order = append(c.order, key)
+	}
+	c.entries[key] = data
+}
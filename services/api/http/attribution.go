@@ -0,0 +1,73 @@
+package http
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+// attributable is implemented by documents that place the attribution
+// themselves instead of in a meta object; GeoJSON collections carry it as a
+// foreign member.
+type attributable interface {
+	setAttribution(a config.Attribution)
+}
+
+// withAttribution adds the configured data credit to a JSON response body:
+// as meta.attribution on gin.H documents, creating meta when absent, or
+// through setAttribution. respondJSON calls it so no endpoint can drop the
+// credit.
+func (s *Server) withAttribution(body any) any {
+	switch b := body.(type) {
+	case gin.H:
+		meta, ok := b["meta"].(gin.H)
+		if !ok {
+			meta = gin.H{}
+			b["meta"] = meta
+		}
+		meta["attribution"] = s.cfg.Attribution
+	case attributable:
+		b.setAttribution(s.cfg.Attribution)
+	}
+	return body
+}
+
+// parseCSVAttribution reads the attribution flag of CSV exports. The credit
+// lines are written by default, as every other format carries the credit;
+// clients whose CSV reader takes comments for data opt out explicitly with
+// attribution=false.
+func parseCSVAttribution(c *gin.Context) (bool, error) {
+	raw := c.Query("attribution")
+	if raw == "" {
+		return true, nil
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("invalid attribution parameter")
+	}
+	return val, nil
+}
+
+// writeAttributionComments writes the attribution as "# key: value" lines
+// ahead of a CSV export's header.
+func (s *Server) writeAttributionComments(w *export.CSVWriter) error {
+	a := s.cfg.Attribution
+	for _, line := range []struct{ key, value string }{
+		{"source", a.Source},
+		{"license", a.License},
+		{"url", a.URL},
+		{"retrieved_via", a.RetrievedVia},
+	} {
+		if line.value == "" {
+			continue
+		}
+		if err := w.Comment(line.key + ": " + line.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+var testAttribution = config.Attribution{
+	Source:       "SIATA",
+	License:      "CC BY 4.0",
+	RetrievedVia: "shizuku",
+}
+
+// respond runs respondJSON on a fresh context and decodes the body.
+func respond(t *testing.T, s *Server, body any) map[string]any {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	s.respondJSON(c, body)
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	return out
+}
+
+func TestRespondJSONAddsAttribution(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{cfg: config.Config{Attribution: testAttribution}}
+
+	for name, body := range map[string]any{
+		"with meta":    gin.H{"data": []int{1}, "meta": gin.H{"count": 1}},
+		"without meta": gin.H{"data": []int{1}},
+	} {
+		out := respond(t, s, body)
+		meta, ok := out["meta"].(map[string]any)
+		if !ok {
+			t.Errorf("%s: no meta in %v", name, out)
+			continue
+		}
+		attr, _ := meta["attribution"].(map[string]any)
+		if attr["source"] != "SIATA" || attr["license"] != "CC BY 4.0" {
+			t.Errorf("%s: meta.attribution = %v", name, meta["attribution"])
+		}
+		if name == "with meta" && meta["count"] != float64(1) {
+			t.Errorf("%s: existing meta lost: %v", name, meta)
+		}
+	}
+
+	out := respond(t, s, &struct {
+		featureCollection
+		ExcludedSensors int `json:"excluded_sensors"`
+	}{featureCollection: featureCollection{Type: "FeatureCollection", Features: []feature{}}})
+	attr, _ := out["attribution"].(map[string]any)
+	if attr["source"] != "SIATA" {
+		t.Errorf("GeoJSON: attribution = %v, want a foreign member", out["attribution"])
+	}
+	if _, ok := out["meta"]; ok {
+		t.Error("GeoJSON: got a meta object")
+	}
+}
+
+func TestCSVAttributionIsOptOut(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{cfg: config.Config{Attribution: testAttribution}}
+
+	for _, tc := range []struct {
+		query   string
+		want    bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"attribution=true", true, false},
+		{"attribution=false", false, false},
+		{"attribution=0", false, false},
+		{"attribution=maybe", false, true},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/?"+tc.query, nil)
+		got, err := parseCSVAttribution(c)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: err = %v, want error %t", tc.query, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %t, want %t", tc.query, got, tc.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	w, err := export.NewCSVWriter(&buf, export.CSVOptions{Delimiter: ','})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writeAttributionComments(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]string{"ts", "value_mm"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := "# source: SIATA\n# license: CC BY 4.0\n# retrieved_via: shizuku\nts,value_mm\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if strings.Contains(buf.String(), "# url") {
+		t.Error("empty url written")
+	}
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+)
+
+// scope is the access level granted to a request by its bearer token.
+type scope int
+
+const (
+	scopeNone scope = iota
+	scopeRead
+	scopeWrite
+)
+
+const scopeContextKey = "auth_scope"
+
+// publicRoutes are served without a token even when API_BEARER_TOKEN is set.
+var publicRoutes = map[string]bool{
+	"/api/v1/status": true,
+}
+
+// bearerAuthMiddleware resolves the request's bearer token into a scope.
+// When API_BEARER_TOKEN is unset reads are anonymous, as are publicRoutes;
+// the write token always grants read access as well.
+func bearerAuthMiddleware(cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := scopeNone
+		if cfg.BearerToken == "" || publicRoutes[c.FullPath()] {
+			granted = scopeRead
+		}
+
+		auth := c.GetHeader("Authorization")
+		if strings.HasPrefix(auth, "Bearer ") {
+			token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+			switch {
+			case cfg.WriteToken != "" && token == cfg.WriteToken:
+				granted = scopeWrite
+			case cfg.BearerToken != "" && token == cfg.BearerToken:
+				granted = scopeRead
+			}
+		}
+
+		if granted == scopeNone {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		c.Set(scopeContextKey, granted)
+		c.Next()
+	}
+}
+
+// requireScope rejects requests whose token does not grant at least min.
+func requireScope(min scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, _ := c.Get(scopeContextKey)
+		if s, ok := granted.(scope); !ok || s < min {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope for this endpoint"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// cleanMode selects which measurement table a request reads from.
+type cleanMode string
+
+const (
+	cleanTrue  cleanMode = "true"
+	cleanFalse cleanMode = "false"
+	// cleanAuto reads clean data where the QC pipeline produced any for the
+	// requested window and falls back to raw data otherwise.
+	cleanAuto cleanMode = "auto"
+)
+
+// resolveCleanMode reads the clean query parameter (true, false or auto),
+// falling back to def when it is absent.
+func resolveCleanMode(c *gin.Context, def string) (cleanMode, error) {
+	raw := strings.ToLower(strings.TrimSpace(c.Query("clean")))
+	if raw == "" {
+		raw = def
+	}
+	if raw == string(cleanAuto) {
+		return cleanAuto, nil
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		return "", errors.New("invalid clean parameter, expected true, false or auto")
+	}
+	if val {
+		return cleanTrue, nil
+	}
+	return cleanFalse, nil
+}
+
+// resolveClean is resolveCleanMode with the deployment default (API_DEFAULT_CLEAN).
+func (s *Server) resolveClean(c *gin.Context) (cleanMode, error) {
+	return resolveCleanMode(c, s.cfg.DefaultClean)
+}
+
+// strict reports whether the mode only ever reads the clean table.
+func (m cleanMode) strict() bool {
+	return m == cleanTrue
+}
+
+// useCleanFor decides the table for one query. Auto mode checks whether the
+// clean table has rows for the query's sensor and window; queries for a
+// non-default variable always read raw rows.
+func (s *Server) useCleanFor(ctx context.Context, mode cleanMode, q db.MeasurementQuery) (bool, error) {
+	return useCleanWith(ctx, s.store, mode, q)
+}
+
+// useCleanWith is useCleanFor against any store that can answer
+// HasMeasurements.
+func useCleanWith(ctx context.Context, store interface {
+	HasMeasurements(ctx context.Context, q db.MeasurementQuery) (bool, error)
+}, mode cleanMode, q db.MeasurementQuery) (bool, error) {
+	switch mode {
+	case cleanTrue:
+		return true, nil
+	case cleanFalse:
+		return false, nil
+	}
+	if q.Variable != "" && q.Variable != db.DefaultVariable {
+		return false, nil
+	}
+	q.UseClean = true
+	return store.HasMeasurements(ctx, q)
+}
+
+// parseNoCoverage reads the no_coverage flag, which skips the
+// clean_coverage_until lookup on hot paths.
+func parseNoCoverage(c *gin.Context) (bool, error) {
+	raw := c.Query("no_coverage")
+	if raw == "" {
+		return false, nil
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("invalid no_coverage parameter")
+	}
+	return val, nil
+}
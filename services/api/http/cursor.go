@@ -0,0 +1,84 @@
+package http
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// cursorSep separates the fields of an encoded cursor; it cannot appear in
+// sensor ids or timestamps.
+const cursorSep = "\x1f"
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// measurementCursor is the opaque keyset position of a row in a measurement
+// export stream or a sensor's measurement pages.
+type measurementCursor struct {
+	SensorID string
+	UseClean bool
+	db.MeasurementCursor
+}
+
+// encode renders the cursor as a URL-safe token.
+func (mc measurementCursor) encode() string {
+	table := "raw"
+	if mc.UseClean {
+		table = "clean"
+	}
+	raw := strings.Join([]string{
+		mc.SensorID,
+		table,
+		mc.TS.UTC().Format(time.RFC3339Nano),
+		strconv.FormatInt(mc.ID, 10),
+	}, cursorSep)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// encodeRunCursor renders the timestamp of the last grid run of a page as a
+// cursor token; grid runs are unique per timestamp.
+func encodeRunCursor(ts time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(ts.UTC().Format(time.RFC3339Nano)))
+}
+
+// decodeRunCursor parses a token produced by encodeRunCursor.
+func decodeRunCursor(token string) (time.Time, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, errInvalidCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, string(b))
+	if err != nil {
+		return time.Time{}, errInvalidCursor
+	}
+	return ts, nil
+}
+
+// decodeMeasurementCursor parses a token produced by measurementCursor.encode.
+func decodeMeasurementCursor(token string) (measurementCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return measurementCursor{}, errInvalidCursor
+	}
+	parts := strings.Split(string(b), cursorSep)
+	if len(parts) != 4 || parts[0] == "" || (parts[1] != "raw" && parts[1] != "clean") {
+		return measurementCursor{}, errInvalidCursor
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[2])
+	if err != nil {
+		return measurementCursor{}, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil {
+		return measurementCursor{}, errInvalidCursor
+	}
+	return measurementCursor{
+		SensorID:          parts[0],
+		UseClean:          parts[1] == "clean",
+		MeasurementCursor: db.MeasurementCursor{TS: ts, ID: id},
+	}, nil
+}
@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+const debugRecorderKey = "debug_recorder"
+
+// wantsDebug reports whether the request asked for debug output.
+func wantsDebug(c *gin.Context) bool {
+	return c.Query("debug") == "true"
+}
+
+// debugRecorderMiddleware attaches a query recorder to debug=true requests
+// made with the write token. It runs after authentication; other requests
+// carry no recorder and pay nothing.
+func debugRecorderMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wantsDebug(c) {
+			if granted, _ := c.Get(scopeContextKey); granted == scopeWrite {
+				rec := &db.QueryRecorder{}
+				c.Set(debugRecorderKey, rec)
+				c.Request = c.Request.WithContext(db.WithQueryRecorder(c.Request.Context(), rec))
+			}
+		}
+		c.Next()
+	}
+}
+
+// debugCaptureMiddleware buffers debug=true responses and, when
+// debugRecorderMiddleware attached a recorder, adds the recorded queries to
+// JSON object bodies under "debug". It runs outside errorMiddleware so error
+// responses carry them too. Other bodies are passed through unchanged.
+func debugCaptureMiddleware(cfg config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !wantsDebug(c) {
+			c.Next()
+			return
+		}
+
+		orig := c.Writer
+		w := &debugWriter{ResponseWriter: orig}
+		c.Writer = w
+		c.Next()
+		c.Writer = orig
+
+		if !w.Written() {
+			if w.status != 0 {
+				orig.WriteHeader(w.status)
+			}
+			return
+		}
+		body := w.buf.Bytes()
+		if v, ok := c.Get(debugRecorderKey); ok && strings.HasPrefix(orig.Header().Get("Content-Type"), "application/json") {
+			if out, ok := withDebug(body, v.(*db.QueryRecorder), cfg); ok {
+				body = out
+			}
+		}
+		orig.Header().Del("Content-Length")
+		orig.WriteHeader(w.Status())
+		_, _ = orig.Write(body)
+	}
+}
+
+// withDebug adds the recorded queries to a JSON object body. Configured
+// tokens are scrubbed from the debug output.
+func withDebug(body []byte, rec *db.QueryRecorder, cfg config.Config) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+
+	queries := rec.Queries()
+	if queries == nil {
+		queries = []db.QueryTrace{}
+	}
+	scrub := func(s string) string {
+		for _, token := range []string{cfg.BearerToken, cfg.WriteToken} {
+			if token != "" {
+				s = strings.ReplaceAll(s, token, "[redacted]")
+			}
+		}
+		return s
+	}
+	for i := range queries {
+		queries[i].Query = scrub(queries[i].Query)
+		queries[i].Error = scrub(queries[i].Error)
+		for j := range queries[i].Args {
+			queries[i].Args[j] = scrub(queries[i].Args[j])
+		}
+	}
+
+	doc["debug"] = gin.H{"queries": queries, "query_count": len(queries)}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// debugWriter holds a response in memory so debugCaptureMiddleware can
+// rewrite it. Flushes are deferred until the response is complete.
+type debugWriter struct {
+	gin.ResponseWriter
+	buf     bytes.Buffer
+	status  int
+	written bool
+}
+
+func (w *debugWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *debugWriter) WriteHeaderNow() {
+	if !w.written {
+		w.written = true
+		if w.status == 0 {
+			w.status = 200
+		}
+	}
+}
+
+func (w *debugWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	return w.buf.Write(b)
+}
+
+func (w *debugWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return w.buf.WriteString(s)
+}
+
+func (w *debugWriter) Status() int {
+	if w.status == 0 {
+		return 200
+	}
+	return w.status
+}
+
+func (w *debugWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.buf.Len()
+}
+
+func (w *debugWriter) Written() bool {
+	return w.written
+}
+
+func (w *debugWriter) Flush() {}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
+)
+
+// statusForError maps domain errors to an HTTP status and a stable error code.
+func statusForError(err error) (int, string) {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, db.ErrInvalidInput),
+		errors.Is(err, grid.ErrResolutionMismatch),
+		errors.Is(err, grid.ErrNoOverlap),
+		errors.Is(err, grid.ErrMisaligned):
+		return http.StatusBadRequest, "invalid_input"
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict, "conflict"
+	case errors.Is(err, db.ErrTimeout):
+		return http.StatusGatewayTimeout, "timeout"
+	case errors.Is(err, errGridDisabled):
+		return http.StatusNotImplemented, "grid_disabled"
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable, "unavailable"
+	default:
+		return http.StatusInternalServerError, "internal"
+	}
+}
+
+// errorMiddleware renders the last error a handler attached with c.Error,
+// unless the handler already wrote a response. Unclassified errors can carry
+// driver or query details, so clients get a generic message and the log the
+// detail.
+func errorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		status, code := statusForError(err)
+		msg := err.Error()
+		if status == http.StatusInternalServerError {
+			log.Printf("internal error on %s %s: %v", c.Request.Method, c.FullPath(), err)
+			msg = "internal server error"
+		}
+		c.JSON(status, gin.H{"error": msg, "code": code})
+	}
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
+)
+
+func TestStatusForError(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{db.ErrNotFound, http.StatusNotFound, "not_found"},
+		{db.ErrSensorNotFound, http.StatusNotFound, "not_found"},
+		{db.ErrInvalidInput, http.StatusBadRequest, "invalid_input"},
+		{grid.ErrResolutionMismatch, http.StatusBadRequest, "invalid_input"},
+		{grid.ErrNoOverlap, http.StatusBadRequest, "invalid_input"},
+		{grid.ErrMisaligned, http.StatusBadRequest, "invalid_input"},
+		{db.ErrConflict, http.StatusConflict, "conflict"},
+		{db.ErrTimeout, http.StatusGatewayTimeout, "timeout"},
+		{errGridDisabled, http.StatusNotImplemented, "grid_disabled"},
+		{db.ErrUnavailable, http.StatusServiceUnavailable, "unavailable"},
+		{fmt.Errorf("load sensor s1: %w", db.ErrNotFound), http.StatusNotFound, "not_found"},
+		{fmt.Errorf("query: %w", fmt.Errorf("pool: %w", db.ErrTimeout)), http.StatusGatewayTimeout, "timeout"},
+		{context.Canceled, http.StatusInternalServerError, "internal"},
+		{errors.New("ERROR: relation \"shizuku.foo\" does not exist (SQLSTATE 42P01)"), http.StatusInternalServerError, "internal"},
+	} {
+		status, code := statusForError(tc.err)
+		if status != tc.status || code != tc.code {
+			t.Errorf("%v: got %d %s, want %d %s", tc.err, status, code, tc.status, tc.code)
+		}
+	}
+}
+
+func TestErrorMiddlewareHidesInternalDetail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{fmt.Errorf("sensor s1: %w", db.ErrNotFound), http.StatusNotFound, "sensor s1: not found"},
+		{errors.New("ERROR: relation \"shizuku.foo\" does not exist (SQLSTATE 42P01)"), http.StatusInternalServerError, "internal server error"},
+	} {
+		engine := gin.New()
+		engine.Use(errorMiddleware())
+		engine.GET("/x", func(c *gin.Context) { c.Error(tc.err) })
+
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest("GET", "/x", nil))
+		var body struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != tc.status || body.Error != tc.message {
+			t.Errorf("%v: got %d %q, want %d %q", tc.err, rec.Code, body.Error, tc.status, tc.message)
+		}
+	}
+}
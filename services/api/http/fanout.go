@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// maxFanOutConcurrency bounds how many per-sensor queries run at once.
+const maxFanOutConcurrency = 4
+
+// sensorWarning reports a sensor that was dropped from a multi-sensor result.
+type sensorWarning struct {
+	SensorID string `json:"sensor_id"`
+	Code     string `json:"code"`
+	Error    string `json:"error"`
+}
+
+// sensorResult pairs a sensor id with the data fetched for it.
+type sensorResult struct {
+	SensorID string `json:"sensor_id"`
+	Data     any    `json:"data"`
+}
+
+// fanOutSensors runs fn for every sensor id with bounded concurrency and
+// isolates failures: successful results are returned in input order and
+// per-sensor errors become warnings. Errors that mean the database itself is
+// unavailable are returned as fatal so the caller can answer with a 5xx.
+func fanOutSensors(ctx context.Context, ids []string, fn func(ctx context.Context, sensorID string) (any, error)) ([]sensorResult, []sensorWarning, error) {
+	type outcome struct {
+		data any
+		err  error
+	}
+	outcomes := make([]outcome, len(ids))
+
+	sem := make(chan struct{}, maxFanOutConcurrency)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			data, err := fn(ctx, id)
+			outcomes[i] = outcome{data: data, err: err}
+		}(i, id)
+	}
+	wg.Wait()
+
+	results := make([]sensorResult, 0, len(ids))
+	warnings := make([]sensorWarning, 0)
+	for i, out := range outcomes {
+		if out.err == nil {
+			results = append(results, sensorResult{SensorID: ids[i], Data: out.data})
+			continue
+		}
+		if errors.Is(out.err, db.ErrUnavailable) {
+			return nil, nil, out.err
+		}
+		_, code := statusForError(out.err)
+		warnings = append(warnings, sensorWarning{SensorID: ids[i], Code: code, Error: out.err.Error()})
+	}
+
+	return results, warnings, nil
+}
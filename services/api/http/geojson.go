@@ -0,0 +1,93 @@
+package http
+
+import (
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// geoJSONContentType is the media type of GeoJSON responses (RFC 7946).
+const geoJSONContentType = "application/geo+json"
+
+// featureCollection is a GeoJSON FeatureCollection.
+type featureCollection struct {
+	Type        string              `json:"type"`
+	Features    []feature           `json:"features"`
+	Attribution *config.Attribution `json:"attribution,omitempty"`
+}
+
+func (fc *featureCollection) setAttribution(a config.Attribution) {
+	fc.Attribution = &a
+}
+
+// feature is a GeoJSON Feature with a Point geometry.
+type feature struct {
+	Type       string         `json:"type"`
+	ID         string         `json:"id,omitempty"`
+	Geometry   point          `json:"geometry"`
+	Properties map[string]any `json:"properties"`
+}
+
+// point is a GeoJSON Point. Coordinates are [lon, lat], per RFC 7946.
+type point struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+func newFeatureCollection(capacity int) featureCollection {
+	return featureCollection{Type: "FeatureCollection", Features: make([]feature, 0, capacity)}
+}
+
+func newPointFeature(id string, lat, lon float64, props map[string]any) feature {
+	return feature{
+		Type:       "Feature",
+		ID:         id,
+		Geometry:   point{Type: "Point", Coordinates: [2]float64{lon, lat}},
+		Properties: props,
+	}
+}
+
+// atNullIsland reports coordinates of exactly 0,0, which providers send for
+// stations without a known position.
+func atNullIsland(lat, lon float64) bool {
+	return lat == 0 && lon == 0
+}
+
+// sensorFeature renders a sensor as a Point feature with its metadata as
+// properties. Unset metadata is left out rather than sent as null.
+func sensorFeature(s db.Sensor) feature {
+	props := map[string]any{"id": s.ID}
+	for key, value := range map[string]*string{
+		"name":        s.Name,
+		"provider_id": s.ProviderID,
+		"city":        s.City,
+		"subbasin":    s.Subbasin,
+		"barrio":      s.Barrio,
+	} {
+		if value != nil {
+			props[key] = *value
+		}
+	}
+	return newPointFeature(s.ID, s.Lat, s.Lon, props)
+}
+
+// aggregateFeature renders a grid sensor aggregate as a Point feature at its
+// sensor's position, with the rainfall figures and sensor name as properties.
+// ok is false when the aggregate has no usable coordinates.
+func aggregateFeature(agg db.SensorAggregate) (f feature, ok bool) {
+	if agg.Sensor == nil || atNullIsland(agg.Sensor.Lat, agg.Sensor.Lon) {
+		return feature{}, false
+	}
+	props := map[string]any{
+		"sensor_id":         agg.SensorID,
+		"avg_mm_h":          agg.AvgMmH,
+		"min_value_mm":      agg.MinValueMm,
+		"max_value_mm":      agg.MaxValueMm,
+		"accumulation_mm":   agg.AccumulationMM,
+		"window_seconds":    agg.WindowSeconds,
+		"measurement_count": agg.MeasurementCount,
+	}
+	if agg.Sensor.Name != nil {
+		props["name"] = *agg.Sensor.Name
+	}
+	return newPointFeature(agg.SensorID, agg.Sensor.Lat, agg.Sensor.Lon, props), true
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// Medellín: a latitude and longitude that cannot be mistaken for each other.
+const testLat, testLon = 6.2518, -75.5636
+
+// encodedCoordinates returns the geometry coordinates of f as a client
+// decoding the JSON would see them.
+func encodedCoordinates(t *testing.T, f feature) []float64 {
+	t.Helper()
+	body, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Geometry struct {
+			Type        string    `json:"type"`
+			Coordinates []float64 `json:"coordinates"`
+		} `json:"geometry"`
+	}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Geometry.Type != "Point" {
+		t.Errorf("geometry type %q, want Point", decoded.Geometry.Type)
+	}
+	return decoded.Geometry.Coordinates
+}
+
+func assertLonLat(t *testing.T, coords []float64) {
+	t.Helper()
+	if len(coords) != 2 || coords[0] != testLon || coords[1] != testLat {
+		t.Errorf("coordinates %v, want [lon, lat] = [%v, %v]", coords, testLon, testLat)
+	}
+}
+
+func TestSensorFeatureCoordinateOrder(t *testing.T) {
+	name := "Estación"
+	f := sensorFeature(db.Sensor{ID: "s1", Name: &name, Lat: testLat, Lon: testLon})
+	assertLonLat(t, encodedCoordinates(t, f))
+	if f.ID != "s1" || f.Properties["name"] != name {
+		t.Errorf("feature %+v", f)
+	}
+	if _, ok := f.Properties["city"]; ok {
+		t.Error("unset city rendered as a property")
+	}
+}
+
+func TestAggregateFeature(t *testing.T) {
+	f, ok := aggregateFeature(db.SensorAggregate{
+		SensorID: "s1",
+		AvgMmH:   2.5,
+		Sensor:   &db.Sensor{ID: "s1", Lat: testLat, Lon: testLon},
+	})
+	if !ok {
+		t.Fatal("aggregate with coordinates skipped")
+	}
+	assertLonLat(t, encodedCoordinates(t, f))
+	if f.Properties["avg_mm_h"] != 2.5 || f.Properties["sensor_id"] != "s1" {
+		t.Errorf("properties %v", f.Properties)
+	}
+
+	for name, agg := range map[string]db.SensorAggregate{
+		"no sensor":   {SensorID: "s2"},
+		"null island": {SensorID: "s3", Sensor: &db.Sensor{ID: "s3"}},
+	} {
+		if _, ok := aggregateFeature(agg); ok {
+			t.Errorf("%s: aggregate rendered", name)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errGridDisabled is returned by grid-backed code while the grid ETL tables
+// are absent (a measurements-only deployment).
+var errGridDisabled = errors.New("grid data is not available in this deployment")
+
+// gridMode reports "enabled" or "disabled" for /readyz.
+func (s *Server) gridMode() string {
+	if s.gridDisabled.Load() {
+		return "disabled"
+	}
+	return "enabled"
+}
+
+// checkGridTables updates grid mode from the database, logging transitions.
+// A failed check leaves the mode unchanged.
+func (s *Server) checkGridTables(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	present, err := s.store.GridTablesPresent(checkCtx)
+	if err != nil {
+		log.Printf("warning: grid table check failed: %v", err)
+		return
+	}
+	if prev := s.gridDisabled.Swap(!present); prev == present {
+		log.Printf("grid mode: %s", s.gridMode())
+	}
+}
+
+// watchGridTables checks for the grid tables at startup and then every
+// GridCheckInterval, so enabling the grid ETL later needs no restart.
+func (s *Server) watchGridTables(ctx context.Context) {
+	s.checkGridTables(ctx)
+	if s.cfg.GridCheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.GridCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkGridTables(ctx)
+		}
+	}
+}
+
+// requireGrid rejects grid routes with 501 grid_disabled while grid mode is
+// disabled.
+func (s *Server) requireGrid() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.gridDisabled.Load() {
+			c.Error(errGridDisabled)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"context"
+	encjson "encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
+)
+
+// gridPreviewTimeout bounds the blob pointer fetch behind the summary
+// endpoints, so a slow blob store delays them by at most this long.
+const gridPreviewTimeout = 3 * time.Second
+
+// gridPreviewMaxBytes caps the pointer document, a few hundred bytes of JSON.
+const gridPreviewMaxBytes = 1 << 20
+
+var gridPreviewClient = httpclient.New(httpclient.Options{
+	Timeout:          gridPreviewTimeout,
+	MaxResponseBytes: gridPreviewMaxBytes,
+	Observe:          observeOutbound,
+})
+
+// gridLatestURL is the blob URL of the ETL's latest grid pointer.
+func (s *Server) gridLatestURL() string {
+	return strings.TrimRight(s.cfg.BlobBaseURL, "/") + "/" + strings.TrimLeft(s.cfg.GridLatestPath, "/")
+}
+
+// gridPreviewURL reads the latest grid pointer from the blob store and
+// returns its preview JPEG URL, or "" when the pointer carries none.
+func (s *Server) gridPreviewURL(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gridPreviewTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.gridLatestURL(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := gridPreviewClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch grid pointer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch grid pointer: unexpected status %s", resp.Status)
+	}
+
+	var ptr struct {
+		GridPreviewJPEGURL string `json:"grid_preview_jpeg_url"`
+		PreviewJPEGURL     string `json:"preview_jpeg_url"`
+	}
+	if err := encjson.NewDecoder(resp.Body).Decode(&ptr); err != nil {
+		return "", fmt.Errorf("decode grid pointer: %w", err)
+	}
+	// ETL may store grid_preview_jpeg_url or preview_jpeg_url
+	if ptr.GridPreviewJPEGURL != "" {
+		return ptr.GridPreviewJPEGURL, nil
+	}
+	return ptr.PreviewJPEGURL, nil
+}
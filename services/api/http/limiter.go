@@ -0,0 +1,188 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Request classes share the database pool; heavy requests are bounded
+// separately so they cannot starve the cheap realtime/core endpoints.
+const (
+	classLight = "light"
+	classHeavy = "heavy"
+)
+
+// retryAfterSeconds is sent with 429 responses when a class queue is full.
+const retryAfterSeconds = 5
+
+// heavyRoutes lists the route patterns that fan out over many rows or sensors:
+// exports and long historical queries. They are bounded by the heavy class
+// limiter and are the ones shed while the pool is saturated.
+var heavyRoutes = map[string]bool{
+	"/api/v1/bootstrap":                         true,
+	"/api/v1/core/comparison":                   true,
+	"/api/v1/core/measurements":                 true,
+	"/api/v1/core/measurements.csv":             true,
+	"/api/v1/core/sensors/:id/accumulation":     true,
+	"/api/v1/core/sensors/:id/compare":          true,
+	"/api/v1/core/sensors/:id/completeness":     true,
+	"/api/v1/core/sensors/:id/context":          true,
+	"/api/v1/core/sensors/:id/events":           true,
+	"/api/v1/core/sensors/:id/grid-aggregates":  true,
+	"/api/v1/core/sensors/:id/measurements":     true,
+	"/api/v1/core/sensors/:id/measurements.csv": true,
+	"/api/v1/core/sensors/:id/quality":          true,
+	"/api/v1/core/sensors/:id/sources":          true,
+	"/api/v1/core/sensors/:id/stats":            true,
+	"/api/v1/core/sources":                      true,
+	"/api/v1/grid/:timestamp/coverage":          true,
+	"/api/v1/grid/:timestamp/validation":        true,
+	"/api/v1/grid/diff":                         true,
+	"/api/v1/views/:slug/execute":               true,
+	"/sensor/:sensor_id":                        true,
+}
+
+// lightRoutes lists the bounded lookups served by the light class. Every
+// registered route must appear in exactly one of heavyRoutes, lightRoutes or
+// unclassedRoutes so a new endpoint cannot silently skip classification.
+var lightRoutes = map[string]bool{
+	"/api/v1/admin/feeds":                   true,
+	"/api/v1/admin/grid/duplicates":         true,
+	"/api/v1/admin/measurements":            true,
+	"/api/v1/admin/retention/jobs/:id":      true,
+	"/api/v1/admin/retention/run":           true,
+	"/api/v1/admin/usage":                   true,
+	"/api/v1/core/measurements/flag":        true,
+	"/api/v1/core/qc-flags":                 true,
+	"/api/v1/core/sensors":                  true,
+	"/api/v1/core/sensors.geojson":          true,
+	"/api/v1/core/sensors/:id":              true,
+	"/api/v1/core/sensors/:id/availability": true,
+	"/api/v1/core/sensors/:id/changes":      true,
+	"/api/v1/core/sensors/:id/flags":        true,
+	"/api/v1/core/sensors/:id/locations":    true,
+	"/api/v1/core/sensors/bbox":             true,
+	"/api/v1/core/sensors/clusters":         true,
+	"/api/v1/core/sensors/nearest":          true,
+	"/api/v1/core/sensors/status":           true,
+	"/api/v1/core/snapshot":                 true,
+	"/api/v1/dashboard/summary":             true,
+	"/api/v1/grid/:timestamp":               true,
+	"/api/v1/grid/:timestamp/contours":      true,
+	"/api/v1/grid/:timestamp/sensors":       true,
+	"/api/v1/grid/:timestamp/thumbnail.png": true,
+	"/api/v1/grid/timestamps":               true,
+	"/api/v1/metrics/rainfall":              true,
+	"/api/v1/realtime/classification":       true,
+	"/api/v1/realtime/contours":             true,
+	"/api/v1/realtime/legend":               true,
+	"/api/v1/realtime/now":                  true,
+	"/api/v1/realtime/snapshot":             true,
+	"/api/v1/realtime/summary":              true,
+	"/api/v1/status":                        true,
+	"/api/v1/views":                         true,
+	"/api/v1/views/:slug":                   true,
+	"/dashboard/summary":                    true,
+	"/grid/:timestamp":                      true,
+	"/grid/available":                       true,
+	"/grid/latest":                          true,
+	"/now":                                  true,
+	"/sensor":                               true,
+	"/snapshot":                             true,
+}
+
+// unclassedRoutes are never limited: probes must always answer.
+var unclassedRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// classLimiter bounds in-flight requests of one class. Requests beyond the
+// concurrency limit wait in a queue of bounded length; once that is full they
+// are rejected.
+type classLimiter struct {
+	name  string
+	slots chan struct{}
+	queue chan struct{}
+}
+
+func newClassLimiter(name string, concurrency, queueLen int) *classLimiter {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if queueLen < 0 {
+		queueLen = 0
+	}
+	return &classLimiter{
+		name:  name,
+		slots: make(chan struct{}, concurrency),
+		queue: make(chan struct{}, queueLen),
+	}
+}
+
+// acquire takes a slot, waiting in the queue if needed. It returns false when
+// the queue is full or the request is cancelled while waiting.
+func (l *classLimiter) acquire(c *gin.Context) bool {
+	select {
+	case l.slots <- struct{}{}:
+		inflightRequests.WithLabelValues(l.name).Inc()
+		return true
+	default:
+	}
+
+	select {
+	case l.queue <- struct{}{}:
+	default:
+		return false
+	}
+	queuedRequests.WithLabelValues(l.name).Inc()
+	defer func() {
+		<-l.queue
+		queuedRequests.WithLabelValues(l.name).Dec()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		inflightRequests.WithLabelValues(l.name).Inc()
+		return true
+	case <-c.Request.Context().Done():
+		return false
+	}
+}
+
+func (l *classLimiter) release() {
+	<-l.slots
+	inflightRequests.WithLabelValues(l.name).Dec()
+}
+
+// concurrencyMiddleware routes each request through the limiter of its class.
+func concurrencyMiddleware(light, heavy *classLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" || unclassedRoutes[route] {
+			c.Next()
+			return
+		}
+
+		limiter := light
+		if heavyRoutes[route] {
+			limiter = heavy
+		}
+
+		if !limiter.acquire(c) {
+			rejectedRequests.WithLabelValues(limiter.name).Inc()
+			c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "too many " + limiter.name + " requests in flight, retry later",
+				"code":  "overloaded",
+			})
+			return
+		}
+		defer limiter.release()
+
+		c.Next()
+	}
+}
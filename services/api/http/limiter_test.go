@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEveryRouteIsClassified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New()}
+	s.registerRoutes()
+
+	registered := map[string]bool{}
+	for _, r := range s.engine.Routes() {
+		registered[r.Path] = true
+		n := 0
+		for _, class := range []map[string]bool{heavyRoutes, lightRoutes, unclassedRoutes} {
+			if class[r.Path] {
+				n++
+			}
+		}
+		switch n {
+		case 0:
+			t.Errorf("%s %s is in no request class", r.Method, r.Path)
+		case 1:
+		default:
+			t.Errorf("%s %s is in %d request classes", r.Method, r.Path, n)
+		}
+	}
+
+	for _, class := range []map[string]bool{heavyRoutes, lightRoutes, unclassedRoutes} {
+		for path := range class {
+			if !registered[path] {
+				t.Errorf("classified route %s is not registered", path)
+			}
+		}
+	}
+}
+
+// slowEngine serves a heavy and a light route whose handlers block until
+// release is closed, standing in for a slow store.
+func slowEngine(light, heavy *classLimiter) (*gin.Engine, chan struct{}, chan struct{}) {
+	gin.SetMode(gin.TestMode)
+	entered := make(chan struct{}, 8)
+	release := make(chan struct{})
+	slow := func(c *gin.Context) {
+		entered <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	}
+
+	engine := gin.New()
+	engine.Use(concurrencyMiddleware(light, heavy))
+	engine.GET("/api/v1/core/measurements", slow)
+	engine.GET("/api/v1/realtime/now", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine, entered, release
+}
+
+func serveAsync(engine *gin.Engine, path string) <-chan *httptest.ResponseRecorder {
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		done <- rec
+	}()
+	return done
+}
+
+func TestConcurrencyMiddlewareRejectsWhenQueueFull(t *testing.T) {
+	light := newClassLimiter(classLight, 4, 4)
+	heavy := newClassLimiter(classHeavy, 1, 0)
+	engine, entered, release := slowEngine(light, heavy)
+
+	first := serveAsync(engine, "/api/v1/core/measurements")
+	select {
+	case <-entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("first heavy request never reached the handler")
+	}
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/core/measurements", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second heavy request: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want 5", got)
+	}
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Code != "overloaded" {
+		t.Errorf("code = %q, want overloaded", body.Code)
+	}
+
+	// A saturated heavy class must not hold up light requests.
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/realtime/now", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("light request while heavy is saturated: status %d, want 200", rec.Code)
+	}
+
+	close(release)
+	if rec := <-first; rec.Code != http.StatusOK {
+		t.Errorf("first heavy request: status %d, want 200", rec.Code)
+	}
+}
+
+func TestConcurrencyMiddlewareQueuedRequestProceeds(t *testing.T) {
+	light := newClassLimiter(classLight, 4, 4)
+	heavy := newClassLimiter(classHeavy, 1, 1)
+	engine, entered, release := slowEngine(light, heavy)
+
+	first := serveAsync(engine, "/api/v1/core/measurements")
+	<-entered
+	second := serveAsync(engine, "/api/v1/core/measurements")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(heavy.queue) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("second heavy request never queued")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(release)
+	for i, done := range []<-chan *httptest.ResponseRecorder{first, second} {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Errorf("request %d: status %d, want 200", i+1, rec.Code)
+		}
+	}
+}
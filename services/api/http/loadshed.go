@@ -0,0 +1,130 @@
+package http
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// shedSampleInterval is how often pool stats are re-evaluated.
+const shedSampleInterval = time.Second
+
+// shedRecoverFactor scales the entry thresholds into the lower exit
+// thresholds, so shedding does not flap around a single value.
+const shedRecoverFactor = 0.75
+
+var (
+	loadShedding = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "shizuku_api",
+		Name:      "load_shedding",
+		Help:      "1 while low-priority endpoints are rejected because the DB pool is saturated.",
+	})
+
+	shedRequests = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "shizuku_api",
+		Name:      "shed_requests_total",
+		Help:      "Low-priority requests rejected with 503 during load shedding.",
+	})
+)
+
+// loadShedder decides from pool stats whether low-priority requests should be
+// rejected. It enters shedding when utilization or the average acquire wait
+// crosses its threshold, and leaves only after both have stayed below the
+// recover thresholds for the cooldown.
+type loadShedder struct {
+	stats       func() db.PoolStats
+	utilization float64
+	wait        time.Duration
+	cooldown    time.Duration
+
+	mu        sync.Mutex
+	sampledAt time.Time
+	last      db.PoolStats
+	shedding  bool
+	calmSince time.Time
+	now       func() time.Time
+}
+
+func newLoadShedder(stats func() db.PoolStats, utilization float64, wait, cooldown time.Duration) *loadShedder {
+	return &loadShedder{
+		stats:       stats,
+		utilization: utilization,
+		wait:        wait,
+		cooldown:    cooldown,
+		now:         time.Now,
+	}
+}
+
+// active re-samples the pool at most once per shedSampleInterval and reports
+// whether shedding is in effect.
+func (l *loadShedder) active() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.sampledAt) < shedSampleInterval {
+		return l.shedding
+	}
+
+	cur := l.stats()
+	var util float64
+	if cur.MaxConns > 0 {
+		util = float64(cur.AcquiredConns) / float64(cur.MaxConns)
+	}
+	var wait time.Duration
+	if n := cur.AcquireCount - l.last.AcquireCount; n > 0 && !l.sampledAt.IsZero() {
+		wait = (cur.AcquireDuration - l.last.AcquireDuration) / time.Duration(n)
+	}
+	l.last, l.sampledAt = cur, now
+
+	hot := util >= l.utilization || (l.wait > 0 && wait >= l.wait)
+	calm := util < l.utilization*shedRecoverFactor &&
+		(l.wait <= 0 || wait < time.Duration(float64(l.wait)*shedRecoverFactor))
+
+	switch {
+	case !l.shedding && hot:
+		l.shedding = true
+		l.calmSince = time.Time{}
+		loadShedding.Set(1)
+		log.Printf("load shedding on: pool utilization %.2f, avg acquire wait %s", util, wait)
+	case l.shedding && !calm:
+		l.calmSince = time.Time{}
+	case l.shedding && calm:
+		if l.calmSince.IsZero() {
+			l.calmSince = now
+		} else if now.Sub(l.calmSince) >= l.cooldown {
+			l.shedding = false
+			loadShedding.Set(0)
+			log.Printf("load shedding off: pool utilization %.2f, avg acquire wait %s", util, wait)
+		}
+	}
+	return l.shedding
+}
+
+// loadShedMiddleware rejects heavy routes with 503 while the shedder is
+// active; realtime and single-resource lookups keep being served. A nil
+// shedder disables it.
+func loadShedMiddleware(shedder *loadShedder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if shedder == nil || !heavyRoutes[route] || !shedder.active() {
+			c.Next()
+			return
+		}
+
+		shedRequests.Inc()
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+			"error": "database is saturated; historical and export endpoints are paused, realtime endpoints remain available",
+			"code":  "unavailable",
+		})
+	}
+}
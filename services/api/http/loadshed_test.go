@@ -0,0 +1,118 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// fakePool feeds the shedder scripted pool stats on a manual clock.
+type fakePool struct {
+	stats db.PoolStats
+	now   time.Time
+}
+
+func (p *fakePool) set(acquired int32, acquires int64, wait time.Duration) {
+	p.stats.AcquiredConns = acquired
+	p.stats.AcquireCount += acquires
+	p.stats.AcquireDuration += time.Duration(acquires) * wait
+}
+
+func newTestShedder(p *fakePool) *loadShedder {
+	p.stats.MaxConns = 10
+	p.now = time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	l := newLoadShedder(func() db.PoolStats { return p.stats }, 0.9, 100*time.Millisecond, 30*time.Second)
+	l.now = func() time.Time { return p.now }
+	return l
+}
+
+func TestLoadShedderHysteresis(t *testing.T) {
+	p := &fakePool{}
+	l := newTestShedder(p)
+
+	steps := []struct {
+		name     string
+		advance  time.Duration
+		acquired int32
+		acquires int64
+		wait     time.Duration
+		want     bool
+	}{
+		{"idle pool", 0, 2, 10, time.Millisecond, false},
+		{"utilization at threshold", time.Second, 9, 10, time.Millisecond, true},
+		{"cached within the sample interval", 500 * time.Millisecond, 0, 0, 0, true},
+		{"between recover and entry thresholds", 500 * time.Millisecond, 8, 10, time.Millisecond, true},
+		{"calm starts the cooldown", time.Second, 2, 10, time.Millisecond, true},
+		{"still cooling down", 20 * time.Second, 2, 10, time.Millisecond, true},
+		{"cooldown elapsed", 10 * time.Second, 2, 10, time.Millisecond, false},
+		{"slow acquires alone", time.Second, 2, 10, 200 * time.Millisecond, true},
+	}
+	for _, st := range steps {
+		p.now = p.now.Add(st.advance)
+		if st.acquires > 0 || st.acquired > 0 {
+			p.set(st.acquired, st.acquires, st.wait)
+		}
+		if got := l.active(); got != st.want {
+			t.Errorf("%s: active = %t, want %t", st.name, got, st.want)
+		}
+	}
+}
+
+func TestLoadShedderCooldownResetsOnSpike(t *testing.T) {
+	p := &fakePool{}
+	l := newTestShedder(p)
+
+	p.set(10, 1, 0)
+	if !l.active() {
+		t.Fatal("saturated pool not shed")
+	}
+	for _, acquired := range []int32{2, 8, 2} {
+		p.now = p.now.Add(20 * time.Second)
+		p.set(acquired, 1, 0)
+		l.active()
+	}
+	// The 8/10 sample was not calm, so the cooldown restarted at the last
+	// sample and has not elapsed.
+	p.now = p.now.Add(20 * time.Second)
+	if !l.active() {
+		t.Error("shedding stopped although the cooldown restarted")
+	}
+	p.now = p.now.Add(20 * time.Second)
+	if l.active() {
+		t.Error("shedding still on after a full calm cooldown")
+	}
+}
+
+func TestLoadShedMiddlewareShedsHeavyRoutesOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &fakePool{}
+	l := newTestShedder(p)
+	p.set(10, 1, 0)
+
+	engine := gin.New()
+	engine.Use(loadShedMiddleware(l))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	engine.GET("/api/v1/core/measurements", ok)
+	engine.GET("/sensor/:sensor_id", ok)
+	engine.GET("/api/v1/realtime/now", ok)
+
+	for path, want := range map[string]int{
+		"/api/v1/core/measurements": http.StatusServiceUnavailable,
+		"/sensor/a":                 http.StatusServiceUnavailable,
+		"/api/v1/realtime/now":      http.StatusOK,
+	} {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", path, rec.Code, want)
+		}
+		if want == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", path)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package http
+
+import (
+	"bytes"
+	encjson "encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// localTimeContextKey holds the *time.Location of API_LOCAL_TIME, whose
+// renderings are added next to the UTC timestamps of a response when the
+// request names no zone of its own.
+const localTimeContextKey = "local_time"
+
+// tzContextKey holds the *time.Location that the response's timestamps are
+// rendered in instead of UTC, when the request names one with tz.
+const tzContextKey = "tz"
+
+// localTimeOff disables a deployment-wide API_LOCAL_TIME for one request.
+const localTimeOff = "off"
+
+// ownTZRoutes read tz themselves: it sets the calendar-day boundaries of
+// completeness, comparison and context, and the zone of Excel timestamps in
+// CSV exports. Their timestamps are not re-rendered.
+var ownTZRoutes = map[string]bool{
+	"/api/v1/core/comparison":                   true,
+	"/api/v1/core/measurements.csv":             true,
+	"/api/v1/core/sensors/:id/completeness":     true,
+	"/api/v1/core/sensors/:id/context":          true,
+	"/api/v1/core/sensors/:id/measurements.csv": true,
+}
+
+// timeZoneMiddleware resolves the zone the response's timestamps are
+// rendered in and rejects unknown zones before the handler runs. tz is the
+// parameter; local_time is its deprecated alias, and local_time=off only
+// drops the API_LOCAL_TIME default. Without either, API_LOCAL_TIME adds
+// _local siblings.
+func (s *Server) timeZoneMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ownTZRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+		loc, explicit, err := s.resolveTimeZone(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error() + ", expected an IANA zone such as America/Bogota"})
+			return
+		}
+		switch {
+		case explicit && loc != nil:
+			c.Set(tzContextKey, loc)
+		case !explicit && loc != nil:
+			c.Set(localTimeContextKey, loc)
+		}
+		c.Next()
+	}
+}
+
+// resolveTimeZone returns the zone named by tz or local_time and whether the
+// request named one, or the configured default when it did not. A nil zone
+// leaves timestamps in UTC.
+func (s *Server) resolveTimeZone(c *gin.Context) (*time.Location, bool, error) {
+	param, name := "tz", c.Query("tz")
+	if name == "" {
+		raw, ok := c.GetQuery("local_time")
+		if !ok {
+			return s.cfg.LocalTime, false, nil
+		}
+		if raw == "" || strings.EqualFold(raw, localTimeOff) {
+			return nil, true, nil
+		}
+		param, name = "local_time", raw
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false, fmt.Errorf("unknown %s %q", param, name)
+	}
+	return loc, true, nil
+}
+
+// respondJSON writes a 200 JSON response with the configured attribution.
+// Bodies that need shaping are converted to generic JSON once and then get
+// qc_flag_names when the request set expand_flags (see addFlagNames), depths
+// in the requested units (see convertUnits), and their timestamps rendered in
+// the request's tz (see renderInZone) or, without one, _local siblings in
+// API_LOCAL_TIME (see addLocalTimes).
+func (s *Server) respondJSON(c *gin.Context, body any) {
+	body = s.withAttribution(body)
+
+	expand := c.GetBool(expandFlagsContextKey)
+	units := c.GetString(unitsContextKey)
+	tz, _ := c.Get(tzContextKey)
+	local, _ := c.Get(localTimeContextKey)
+	if units == unitsMM {
+		// Millimetres are the stored unit; only meta has to say so.
+		if h, ok := body.(gin.H); ok {
+			if meta, ok := h["meta"].(gin.H); ok {
+				meta["units"] = unitsMM
+			}
+		}
+	}
+	if !expand && units != unitsInches && tz == nil && local == nil {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+
+	doc, ok := genericJSON(body)
+	if !ok {
+		c.JSON(http.StatusOK, body)
+		return
+	}
+	if expand {
+		addFlagNames(doc)
+	}
+	if units == unitsInches {
+		convertUnits(doc)
+	}
+	if tz != nil {
+		renderInZone(doc, tz.(*time.Location))
+		setMeta(doc, "tz", tz.(*time.Location).String())
+	} else if local != nil {
+		addLocalTimes(doc, local.(*time.Location))
+	}
+	c.JSON(http.StatusOK, doc)
+}
+
+// setMeta sets meta[key] on a generic JSON document unless the handler
+// already set it.
+func setMeta(doc any, key string, value any) {
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return
+	}
+	meta, ok := m["meta"].(map[string]any)
+	if !ok {
+		return
+	}
+	if _, taken := meta[key]; !taken {
+		meta[key] = value
+	}
+}
+
+// renderInZone rewrites every RFC3339 timestamp string of a generic JSON
+// document in loc.
+func renderInZone(v any, loc *time.Location) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if s, ok := field.(string); ok {
+				if t, ok := parseTimestampString(s); ok {
+					v[k] = t.In(loc).Format(time.RFC3339Nano)
+				}
+				continue
+			}
+			renderInZone(field, loc)
+		}
+	case []any:
+		for i, item := range v {
+			if s, ok := item.(string); ok {
+				if t, ok := parseTimestampString(s); ok {
+					v[i] = t.In(loc).Format(time.RFC3339Nano)
+				}
+				continue
+			}
+			renderInZone(item, loc)
+		}
+	}
+}
+
+// genericJSON round-trips body through encoding/json into maps, slices and
+// json.Numbers so response shaping can rewrite it.
+func genericJSON(body any) (any, bool) {
+	raw, err := encjson.Marshal(body)
+	if err != nil {
+		return nil, false
+	}
+	dec := encjson.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber() // keep integers such as ids and counts exact
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// addLocalTimes gives every string field of a generic JSON document holding
+// a UTC RFC3339 timestamp, e.g. "ts", a "ts_local" sibling with the same
+// instant rendered in loc. The UTC fields are left untouched.
+func addLocalTimes(v any, loc *time.Location) {
+	switch v := v.(type) {
+	case map[string]any:
+		local := make(map[string]string)
+		for k, field := range v {
+			if s, ok := field.(string); ok {
+				if t, ok := parseUTCTimestamp(s); ok && !strings.HasSuffix(k, "_local") {
+					local[k+"_local"] = t.In(loc).Format(time.RFC3339Nano)
+				}
+				continue
+			}
+			addLocalTimes(field, loc)
+		}
+		for k, s := range local {
+			if _, taken := v[k]; !taken {
+				v[k] = s
+			}
+		}
+	case []any:
+		for _, item := range v {
+			addLocalTimes(item, loc)
+		}
+	}
+}
+
+// formatTimestamp renders t as RFC3339 in UTC, whatever location it carries
+// (database times come back in the server's local zone). Handlers format
+// timestamps through it so tz can re-render every one of them.
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// parseUTCTimestamp reports whether s is an RFC3339 timestamp at UTC, the
+// form every timestamp of the API is rendered in.
+func parseUTCTimestamp(s string) (time.Time, bool) {
+	t, ok := parseTimestampString(s)
+	if !ok {
+		return time.Time{}, false
+	}
+	if _, offset := t.Zone(); offset != 0 {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// parseTimestampString reports whether s is an RFC3339 timestamp with any
+// offset.
+func parseTimestampString(s string) (time.Time, bool) {
+	if len(s) < len("2006-01-02T15:04:05Z") || s[4] != '-' {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+)
+
+// zoneEngine serves one fixed document on a shaped route and on a route
+// that reads tz itself.
+func zoneEngine(t *testing.T, defaultZone *time.Location) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	s := &Server{cfg: config.Config{LocalTime: defaultZone}}
+	doc := func(c *gin.Context) {
+		s.respondJSON(c, gin.H{
+			"data": []gin.H{{"ts": "2024-05-01T17:00:00Z", "value_mm": 2.54, "qc_flags": 0}},
+			"meta": gin.H{"start": "2024-05-01T00:00:00Z"},
+		})
+	}
+	engine := gin.New()
+	v1 := engine.Group("/api/v1")
+	v1.Use(s.timeZoneMiddleware())
+	v1.GET("/core/sensors/:id/measurements", unitsMiddleware(), expandFlagsMiddleware(), doc)
+	v1.GET("/core/comparison", doc)
+	return engine
+}
+
+func get(t *testing.T, engine *gin.Engine, target string) (int, map[string]any) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+	var out map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s: decode %q: %v", target, rec.Body.String(), err)
+	}
+	return rec.Code, out
+}
+
+func firstRow(out map[string]any) map[string]any {
+	return out["data"].([]any)[0].(map[string]any)
+}
+
+func TestTimeZoneRendering(t *testing.T) {
+	bogota, err := time.LoadLocation("America/Bogota")
+	if err != nil {
+		t.Skip(err)
+	}
+	const measurements = "/api/v1/core/sensors/a/measurements"
+
+	for _, tc := range []struct {
+		name      string
+		def       *time.Location
+		target    string
+		wantTS    string
+		wantLocal any
+		wantTZ    any
+	}{
+		{"UTC by default", nil, measurements, "2024-05-01T17:00:00Z", nil, nil},
+		{"tz rewrites in place", nil, measurements + "?tz=America/Bogota", "2024-05-01T12:00:00-05:00", nil, "America/Bogota"},
+		{"local_time is an alias of tz", nil, measurements + "?local_time=America/Bogota", "2024-05-01T12:00:00-05:00", nil, "America/Bogota"},
+		{"API_LOCAL_TIME adds siblings", bogota, measurements, "2024-05-01T17:00:00Z", "2024-05-01T12:00:00-05:00", nil},
+		{"tz replaces the default siblings", bogota, measurements + "?tz=UTC", "2024-05-01T17:00:00Z", nil, "UTC"},
+		{"local_time=off drops the default", bogota, measurements + "?local_time=off", "2024-05-01T17:00:00Z", nil, nil},
+		{"bucketing routes keep UTC", nil, "/api/v1/core/comparison?tz=America/Bogota", "2024-05-01T17:00:00Z", nil, nil},
+	} {
+		code, out := get(t, zoneEngine(t, tc.def), tc.target)
+		if code != http.StatusOK {
+			t.Errorf("%s: status %d", tc.name, code)
+			continue
+		}
+		row := firstRow(out)
+		if row["ts"] != tc.wantTS {
+			t.Errorf("%s: ts = %v, want %s", tc.name, row["ts"], tc.wantTS)
+		}
+		if row["ts_local"] != tc.wantLocal {
+			t.Errorf("%s: ts_local = %v, want %v", tc.name, row["ts_local"], tc.wantLocal)
+		}
+		if meta := out["meta"].(map[string]any); meta["tz"] != tc.wantTZ {
+			t.Errorf("%s: meta.tz = %v, want %v", tc.name, meta["tz"], tc.wantTZ)
+		}
+	}
+}
+
+func TestTimeZoneRejectsUnknownZones(t *testing.T) {
+	engine := zoneEngine(t, nil)
+	for _, target := range []string{
+		"/api/v1/core/sensors/a/measurements?tz=Mars/Olympus",
+		"/api/v1/core/sensors/a/measurements?local_time=Mars/Olympus",
+	} {
+		if code, _ := get(t, engine, target); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, code)
+		}
+	}
+}
+
+// Flags, units and tz are applied to one converted document.
+func TestRespondJSONCombinesShaping(t *testing.T) {
+	_, out := get(t, zoneEngine(t, nil), "/api/v1/core/sensors/a/measurements?tz=America/Bogota&units=in&expand_flags=true")
+	row := firstRow(out)
+	if row["ts"] != "2024-05-01T12:00:00-05:00" {
+		t.Errorf("ts = %v", row["ts"])
+	}
+	if row["value_in"] != 0.1 {
+		t.Errorf("value_in = %v, want 0.1", row["value_in"])
+	}
+	if _, ok := row["qc_flag_names"]; !ok {
+		t.Error("qc_flag_names missing")
+	}
+	meta := out["meta"].(map[string]any)
+	if meta["units"] != "in" || meta["tz"] != "America/Bogota" || meta["start"] != "2024-04-30T19:00:00-05:00" {
+		t.Errorf("meta = %v", meta)
+	}
+}
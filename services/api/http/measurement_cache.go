@@ -0,0 +1,79 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// cacheBypassHeader skips the measurement micro-cache for one request. Only
+// honoured for write-scoped tokens so anonymous clients cannot defeat the
+// hot-key protection.
+const cacheBypassHeader = "X-Cache-Bypass"
+
+// cacheBypassMiddleware marks the request context when a privileged caller
+// asks to bypass the measurement micro-cache.
+func cacheBypassMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader(cacheBypassHeader) != "" {
+			if granted, _ := c.Get(scopeContextKey); granted == scopeWrite {
+				c.Request = c.Request.WithContext(db.WithCacheBypass(c.Request.Context()))
+			}
+		}
+		c.Next()
+	}
+}
+
+var (
+	measurementCacheHitsDesc = prometheus.NewDesc("shizuku_api_measurement_cache_hits_total",
+		"Measurement reads served from the micro-cache or a shared in-flight query.", nil, nil)
+	measurementCacheMissesDesc = prometheus.NewDesc("shizuku_api_measurement_cache_misses_total",
+		"Measurement reads that queried the database.", nil, nil)
+)
+
+// measurementCacheCollector reports the micro-cache counters of the store it
+// currently points at.
+type measurementCacheCollector struct {
+	store atomic.Pointer[db.Store]
+}
+
+func (m *measurementCacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- measurementCacheHitsDesc
+	ch <- measurementCacheMissesDesc
+}
+
+func (m *measurementCacheCollector) Collect(ch chan<- prometheus.Metric) {
+	var st db.MeasurementCacheStats
+	if store := m.store.Load(); store != nil {
+		st = store.MeasurementCacheStats()
+	}
+	ch <- prometheus.MustNewConstMetric(measurementCacheHitsDesc, prometheus.CounterValue, float64(st.Hits))
+	ch <- prometheus.MustNewConstMetric(measurementCacheMissesDesc, prometheus.CounterValue, float64(st.Misses))
+}
+
+// registerMeasurementCacheMetrics exposes store's micro-cache counters on
+// reg. When a previous Server already registered them, the existing
+// collector is repointed at store so /metrics never reports a stale one.
+func registerMeasurementCacheMetrics(reg prometheus.Registerer, store *db.Store) error {
+	collector := &measurementCacheCollector{}
+	collector.store.Store(store)
+	err := reg.Register(collector)
+	var already prometheus.AlreadyRegisteredError
+	if errors.As(err, &already) {
+		existing, ok := already.ExistingCollector.(*measurementCacheCollector)
+		if !ok {
+			return fmt.Errorf("measurement cache metrics: registered by %T", already.ExistingCollector)
+		}
+		existing.store.Store(store)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("measurement cache metrics: %w", err)
+	}
+	return nil
+}
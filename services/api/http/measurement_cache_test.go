@@ -0,0 +1,52 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// A second Server on the same registry repoints the counters at its own
+// store instead of leaving them on the first one.
+func TestRegisterMeasurementCacheMetricsFollowsLatestStore(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first, second := &db.Store{}, &db.Store{}
+	if err := registerMeasurementCacheMetrics(reg, first); err != nil {
+		t.Fatal(err)
+	}
+	if err := registerMeasurementCacheMetrics(reg, second); err != nil {
+		t.Fatalf("second registration: %v", err)
+	}
+
+	probe := &measurementCacheCollector{}
+	err := reg.Register(probe)
+	already, ok := err.(prometheus.AlreadyRegisteredError)
+	if !ok {
+		t.Fatalf("register probe: %v, want AlreadyRegisteredError", err)
+	}
+	if got := already.ExistingCollector.(*measurementCacheCollector).store.Load(); got != second {
+		t.Error("collector still reports the first store")
+	}
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 2 {
+		t.Errorf("gathered %d metric families, want hits and misses", len(families))
+	}
+}
+
+// A conflicting collector under the same names is an error, not a panic.
+func TestRegisterMeasurementCacheMetricsConflict(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "shizuku_api_measurement_cache_hits_total",
+		Help: "Measurement reads served from the micro-cache or a shared in-flight query.",
+	}))
+	if err := registerMeasurementCacheMetrics(reg, &db.Store{}); err == nil {
+		t.Error("conflicting registration succeeded")
+	}
+}
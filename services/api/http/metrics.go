@@ -0,0 +1,46 @@
+package http
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
+)
+
+var (
+	inflightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "shizuku_api",
+		Name:      "inflight_requests",
+		Help:      "Requests currently holding a concurrency slot, by request class.",
+	}, []string{"class"})
+
+	queuedRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "shizuku_api",
+		Name:      "queued_requests",
+		Help:      "Requests waiting for a concurrency slot, by request class.",
+	}, []string{"class"})
+
+	rejectedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shizuku_api",
+		Name:      "rejected_requests_total",
+		Help:      "Requests rejected with 429 because their class queue was full.",
+	}, []string{"class"})
+
+	outboundRequests = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "shizuku_api",
+		Name:      "outbound_request_duration_seconds",
+		Help:      "Outbound HTTP attempts (blob store), by host and status code; code is \"error\" for failed round trips.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"host", "code"})
+)
+
+// observeOutbound records one outbound HTTP attempt.
+func observeOutbound(a httpclient.Attempt) {
+	code := "error"
+	if a.Err == nil {
+		code = strconv.Itoa(a.Status)
+	}
+	outboundRequests.WithLabelValues(a.Host, code).Observe(a.Duration.Seconds())
+}
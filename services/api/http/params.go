@@ -0,0 +1,353 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/timeutil"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+// maxFutureSkew bounds how far past "now" a requested range end may reach.
+// Anything later is clamped so far-future ends don't defeat caching.
+const maxFutureSkew = 5 * time.Minute
+
+// futureRangeWarning is returned alongside empty results for ranges that lie
+// entirely in the future.
+const futureRangeWarning = "requested range is entirely in the future; no data available"
+
+// timeRange is a validated start/end window parsed from query parameters.
+// End is always set (defaulting to now); Start is optional.
+type timeRange struct {
+	Start   *time.Time
+	End     time.Time
+	Future  bool
+	Warning string
+}
+
+// rangeError reports an invalid start/end combination, echoing both values.
+type rangeError struct {
+	Message string
+	Start   string
+	End     string
+}
+
+func (e *rangeError) Error() string {
+	return e.Message
+}
+
+// parseTimestamp parses an RFC3339 timestamp and normalizes it to UTC, so the
+// same instant written as Z, +00:00 or -05:00 compares equal everywhere.
+// Timestamps without an offset are rejected (timeutil.ErrNaiveTimestamp).
+// An unescaped "+" in a query string decodes to a space; a space in the
+// offset position is read back as "+".
+func parseTimestamp(raw string) (time.Time, error) {
+	if n := len(raw); n > 6 && raw[n-6] == ' ' {
+		raw = raw[:n-6] + "+" + raw[n-5:]
+	}
+	return timeutil.ParseUTC(raw, nil)
+}
+
+// parseTimeRange reads the start/end query parameters and applies the shared
+// range policy: start must not be after end, end defaults to now and is
+// clamped to now+maxFutureSkew, and ranges starting after that point are
+// flagged as Future so handlers can short-circuit with an empty result.
+func parseTimeRange(c *gin.Context) (timeRange, error) {
+	return parseTimeRangeAt(c, time.Now().UTC())
+}
+
+// parseTimeRangeAt is parseTimeRange with the current time given.
+func parseTimeRangeAt(c *gin.Context, now time.Time) (timeRange, error) {
+	limit := now.Add(maxFutureSkew)
+	startStr := c.Query("start")
+	endStr := c.Query("end")
+
+	rng := timeRange{End: now}
+
+	if startStr != "" {
+		t, err := parseTimestamp(startStr)
+		if err != nil {
+			return rng, &rangeError{Message: "invalid start timestamp, expected RFC3339", Start: startStr, End: endStr}
+		}
+		rng.Start = &t
+	}
+
+	if endStr != "" {
+		t, err := parseTimestamp(endStr)
+		if err != nil {
+			return rng, &rangeError{Message: "invalid end timestamp, expected RFC3339", Start: startStr, End: endStr}
+		}
+		rng.End = t
+	} else if rng.Start != nil && rng.Start.After(now) {
+		rng.End = *rng.Start
+	}
+
+	if rng.Start != nil && rng.Start.After(rng.End) {
+		return rng, &rangeError{Message: "start must be before or equal to end", Start: startStr, End: endStr}
+	}
+
+	if rng.Start != nil && rng.Start.After(limit) {
+		rng.Future = true
+		rng.Warning = futureRangeWarning
+	}
+
+	if rng.End.After(limit) {
+		rng.End = limit
+	}
+
+	return rng, nil
+}
+
+// respondRangeError writes a 400 for errors produced by parseTimeRange.
+func respondRangeError(c *gin.Context, err error) {
+	var rerr *rangeError
+	if errors.As(err, &rerr) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": rerr.Message,
+			"start": rerr.Start,
+			"end":   rerr.End,
+		})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+}
+
+// parseBBox reads the optional bbox query parameter formatted as
+// min_lon,min_lat,max_lon,max_lat. A nil box is returned when absent.
+func parseBBox(c *gin.Context) (*db.BBox, error) {
+	raw := c.Query("bbox")
+	if raw == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must have 4 comma-separated values (min_lon,min_lat,max_lon,max_lat), got %d", len(parts))
+	}
+
+	var vals [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("bbox value %q is not a number", part)
+		}
+		vals[i] = v
+	}
+
+	b := &db.BBox{MinLon: vals[0], MinLat: vals[1], MaxLon: vals[2], MaxLat: vals[3]}
+	switch {
+	case b.MinLat < -90 || b.MaxLat > 90:
+		return nil, errors.New("bbox latitudes must be within [-90, 90]")
+	case b.MinLon < -180 || b.MaxLon > 180:
+		return nil, errors.New("bbox longitudes must be within [-180, 180]")
+	case b.MinLon > b.MaxLon:
+		return nil, errors.New("bbox min_lon must not exceed max_lon")
+	case b.MinLat > b.MaxLat:
+		return nil, errors.New("bbox min_lat must not exceed max_lat")
+	}
+	return b, nil
+}
+
+// parseBBoxParams reads a required box given as the separate min_lon,
+// min_lat, max_lon and max_lat query parameters. Each minimum must be
+// strictly below its maximum.
+func parseBBoxParams(c *gin.Context) (db.BBox, error) {
+	names := [4]string{"min_lon", "min_lat", "max_lon", "max_lat"}
+	var vals [4]float64
+	for i, name := range names {
+		raw := strings.TrimSpace(c.Query(name))
+		if raw == "" {
+			return db.BBox{}, fmt.Errorf("%s is required", name)
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return db.BBox{}, fmt.Errorf("%s value %q is not a number", name, raw)
+		}
+		vals[i] = v
+	}
+
+	b := db.BBox{MinLon: vals[0], MinLat: vals[1], MaxLon: vals[2], MaxLat: vals[3]}
+	switch {
+	case b.MinLat < -90 || b.MaxLat > 90:
+		return db.BBox{}, errors.New("latitudes must be within [-90, 90]")
+	case b.MinLon < -180 || b.MaxLon > 180:
+		return db.BBox{}, errors.New("longitudes must be within [-180, 180]")
+	case b.MinLon >= b.MaxLon:
+		return db.BBox{}, errors.New("min_lon must be less than max_lon")
+	case b.MinLat >= b.MaxLat:
+		return db.BBox{}, errors.New("min_lat must be less than max_lat")
+	}
+	return b, nil
+}
+
+// parseInclude reads the comma-separated include parameter, rejecting values
+// outside allowed.
+func parseInclude(c *gin.Context, allowed ...string) (map[string]bool, error) {
+	include := make(map[string]bool)
+	for _, part := range strings.Split(c.Query("include"), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !containsString(allowed, part) {
+			return nil, fmt.Errorf("unsupported include %q (allowed: %s)", part, strings.Join(allowed, ", "))
+		}
+		include[part] = true
+	}
+	return include, nil
+}
+
+// parseVariable reads the variable filter, defaulting to precipitation. Only
+// raw rows carry a variable column, so clean queries accept the default only.
+func parseVariable(c *gin.Context, useClean bool) (string, error) {
+	variable := strings.TrimSpace(c.DefaultQuery("variable", db.DefaultVariable))
+	if variable == "" {
+		variable = db.DefaultVariable
+	}
+	if useClean && variable != db.DefaultVariable {
+		return "", fmt.Errorf("variable %q is only available for raw measurements (clean=false); clean data carries %s only", variable, db.DefaultVariable)
+	}
+	return variable, nil
+}
+
+// checkRangeSpan rejects ranges longer than maxDays so a single request can't
+// scan years of rows. Open-ended ranges (no start) are bounded by the row
+// limit instead.
+func checkRangeSpan(rng timeRange, maxDays int) error {
+	if rng.Start == nil || maxDays <= 0 {
+		return nil
+	}
+	span := rng.End.Sub(*rng.Start)
+	if span > time.Duration(maxDays)*24*time.Hour {
+		return &rangeError{
+			Message: fmt.Sprintf("requested range spans %.0f days, maximum is %d; split the request", span.Hours()/24, maxDays),
+			Start:   rng.Start.Format(time.RFC3339),
+			End:     rng.End.Format(time.RFC3339),
+		}
+	}
+	return nil
+}
+
+// clampLimit caps a requested row limit at maxRows.
+func clampLimit(limit, maxRows int) int {
+	if maxRows > 0 && limit > maxRows {
+		return maxRows
+	}
+	return limit
+}
+
+// setNextLink adds an RFC 8288 Link header pointing at the page after the
+// keyset cursor, keeping every other query parameter of the current request.
+// A non-nil start is pinned so a relative last_n_days window cannot move
+// under the following pages.
+func setNextLink(c *gin.Context, cursor string, start *time.Time) {
+	next := *c.Request.URL
+	query := next.Query()
+	query.Set("cursor", cursor)
+	if start != nil {
+		query.Set("start", start.UTC().Format(time.RFC3339Nano))
+		query.Del("last_n_days")
+	}
+	next.RawQuery = query.Encode()
+	c.Header("Link", "<"+next.RequestURI()+">; rel=\"next\"")
+}
+
+// parseCSVOptions reads the CSV locale parameters shared by export endpoints:
+// delimiter (, or ;), decimal (. or ,), timestamp (rfc3339 or excel), tz (IANA
+// zone for excel timestamps) and bom (bool). Defaults keep plain RFC4180 CSV.
+func parseCSVOptions(c *gin.Context) (export.CSVOptions, error) {
+	var opts export.CSVOptions
+
+	switch d := c.DefaultQuery("delimiter", ","); d {
+	case ",", ";":
+		opts.Delimiter = rune(d[0])
+	default:
+		return opts, errors.New("delimiter must be ',' or ';'")
+	}
+
+	switch d := c.DefaultQuery("decimal", "."); d {
+	case ".":
+	case ",":
+		opts.DecimalComma = true
+	default:
+		return opts, errors.New("decimal must be '.' or ','")
+	}
+
+	switch f := c.DefaultQuery("timestamp", "rfc3339"); f {
+	case "rfc3339":
+	case "excel":
+		opts.ExcelTime = true
+	default:
+		return opts, errors.New("timestamp must be rfc3339 or excel")
+	}
+
+	if tz := c.Query("tz"); tz != "" {
+		if !opts.ExcelTime {
+			return opts, errors.New("tz only applies to timestamp=excel")
+		}
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return opts, fmt.Errorf("unknown tz %q", tz)
+		}
+		opts.Location = loc
+	}
+
+	if raw := c.Query("bom"); raw != "" {
+		bom, err := strconv.ParseBool(raw)
+		if err != nil {
+			return opts, errors.New("invalid bom parameter")
+		}
+		opts.BOM = bom
+	}
+
+	return opts, opts.Validate()
+}
+
+// parseLocation reads the optional tz parameter as an IANA zone, defaulting
+// to UTC.
+func parseLocation(c *gin.Context) (*time.Location, error) {
+	tz := c.Query("tz")
+	if tz == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("unknown tz %q", tz)
+	}
+	return loc, nil
+}
+
+// parseOrder reads the order parameter, asc (default) or desc, and reports
+// whether rows should come newest first.
+func parseOrder(c *gin.Context) (bool, error) {
+	switch c.DefaultQuery("order", "asc") {
+	case "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	}
+	return false, errors.New("invalid order, expected asc or desc")
+}
+
+// parseAsOf reads the as_of snapshot bound of offset pagination. The first
+// page omits it and gets the current second, which clients pass back on later
+// pages so rows inserted in between cannot shift them.
+func parseAsOf(c *gin.Context) (time.Time, error) {
+	raw := c.Query("as_of")
+	if raw == "" {
+		return time.Now().UTC().Truncate(time.Second), nil
+	}
+	t, err := parseTimestamp(raw)
+	if err != nil {
+		return time.Time{}, errors.New("invalid as_of, expected RFC3339")
+	}
+	return t, nil
+}
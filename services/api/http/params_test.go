@@ -0,0 +1,230 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+func TestSetNextLinkUsesKeysetCursor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	boundary := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	start := boundary.Add(-48 * time.Hour)
+	cur := measurementCursor{SensorID: "s1", MeasurementCursor: db.MeasurementCursor{TS: boundary, ID: 42}}
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest("GET", "/sensor/s1?last_n_days=2&last_n=10&clean=false", nil)
+	setNextLink(c, cur.encode(), &start)
+
+	link := rec.Header().Get("Link")
+	raw, ok := strings.CutSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+	if !ok {
+		t.Fatalf("Link = %q", link)
+	}
+	next, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := next.Query()
+	got, err := decodeMeasurementCursor(q.Get("cursor"))
+	if err != nil || got != cur {
+		t.Errorf("cursor = %+v (%v), want %+v", got, err, cur)
+	}
+	// The boundary timestamp is not skipped: the page resumes by (ts, id)
+	// rather than at a later start.
+	if q.Get("start") != start.Format(time.RFC3339Nano) {
+		t.Errorf("start = %q, want the pinned window start %s", q.Get("start"), start.Format(time.RFC3339Nano))
+	}
+	if q.Has("last_n_days") {
+		t.Error("last_n_days kept next to the pinned start")
+	}
+	if q.Get("last_n") != "10" || q.Get("clean") != "false" {
+		t.Errorf("other parameters lost: %v", q)
+	}
+}
+
+func TestRunCursorRoundTrip(t *testing.T) {
+	ts := time.Date(2025, 10, 1, 12, 0, 0, 500, time.UTC)
+	got, err := decodeRunCursor(encodeRunCursor(ts))
+	if err != nil || !got.Equal(ts) {
+		t.Errorf("got %v (%v), want %v", got, err, ts)
+	}
+	if _, err := decodeRunCursor("not a cursor"); err == nil {
+		t.Error("malformed cursor accepted")
+	}
+}
+
+func TestParseCSVOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	parse := func(query string) (export.CSVOptions, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/export?"+query, nil)
+		return parseCSVOptions(c)
+	}
+
+	opts, err := parse("")
+	if err != nil || opts != (export.CSVOptions{Delimiter: ','}) {
+		t.Errorf("defaults: %+v (%v)", opts, err)
+	}
+
+	opts, err = parse("delimiter=%3B&decimal=%2C&timestamp=excel&tz=America/Bogota&bom=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Delimiter != ';' || !opts.DecimalComma || !opts.ExcelTime || !opts.BOM || opts.Location == nil || opts.Location.String() != "America/Bogota" {
+		t.Errorf("excel locale: %+v", opts)
+	}
+
+	for _, query := range []string{
+		"delimiter=%7C",
+		"decimal=%2C",
+		"decimal=%3B&delimiter=%3B",
+		"timestamp=unix",
+		"tz=America/Bogota",
+		"timestamp=excel&tz=Mars/Olympus",
+		"bom=maybe",
+	} {
+		if _, err := parse(query); err == nil {
+			t.Errorf("%s: accepted", query)
+		}
+	}
+}
+
+// The same instant written as Z, +00:00 (also with the '+' decoded to a
+// space, as an unescaped query string delivers it) and -05:00 parses to one
+// UTC time, so equality lookups match the same rows.
+func TestParseTimestampNormalizesOffsets(t *testing.T) {
+	want := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, raw := range []string{
+		"2025-10-01T12:00:00Z",
+		"2025-10-01T12:00:00+00:00",
+		"2025-10-01T12:00:00 00:00",
+		"2025-10-01T07:00:00-05:00",
+		"2025-10-01T17:30:00+05:30",
+	} {
+		got, err := parseTimestamp(raw)
+		if err != nil {
+			t.Errorf("%s: %v", raw, err)
+			continue
+		}
+		if got != want || got.Location() != time.UTC {
+			t.Errorf("%s: got %v, want %v in UTC", raw, got, want)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, query := range []string{
+		"start=2025-10-01T11:00:00Z&end=2025-10-01T12:00:00Z",
+		"start=2025-10-01T11:00:00+00:00&end=2025-10-01T12:00:00%2B00:00",
+		"start=2025-10-01T06:00:00-05:00&end=2025-10-01T07:00:00-05:00",
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/m?"+query, nil)
+		rng, err := parseTimeRange(c)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if rng.Start == nil || *rng.Start != want.Add(-time.Hour) || rng.End != want {
+			t.Errorf("%s: got %v..%v", query, rng.Start, rng.End)
+		}
+	}
+}
+
+func TestParseTimeRangeClamps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	limit := now.Add(maxFutureSkew)
+	at := func(s string) *time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &ts
+	}
+
+	for _, tc := range []struct {
+		name       string
+		query      string
+		wantStart  *time.Time
+		wantEnd    time.Time
+		wantFuture bool
+	}{
+		{name: "end defaults to now", query: "", wantEnd: now},
+		{name: "past range kept", query: "start=2025-10-01T10:00:00Z&end=2025-10-01T11:00:00Z", wantStart: at("2025-10-01T10:00:00Z"), wantEnd: *at("2025-10-01T11:00:00Z")},
+		{name: "start equal to end", query: "start=2025-10-01T11:00:00Z&end=2025-10-01T11:00:00Z", wantStart: at("2025-10-01T11:00:00Z"), wantEnd: *at("2025-10-01T11:00:00Z")},
+		{name: "end clamped to now+5m", query: "start=2025-10-01T11:00:00Z&end=2025-10-02T00:00:00Z", wantStart: at("2025-10-01T11:00:00Z"), wantEnd: limit},
+		{name: "end exactly at the boundary", query: "end=2025-10-01T12:05:00Z", wantEnd: limit},
+		{name: "end just past the boundary", query: "end=2025-10-01T12:05:01Z", wantEnd: limit},
+		{name: "start exactly at the boundary", query: "start=2025-10-01T12:05:00Z", wantStart: &limit, wantEnd: limit},
+		{name: "start just past the boundary", query: "start=2025-10-01T12:05:01Z", wantStart: at("2025-10-01T12:05:01Z"), wantEnd: limit, wantFuture: true},
+		{name: "range entirely in the future", query: "start=2025-10-02T00:00:00Z&end=2025-10-03T00:00:00Z", wantStart: at("2025-10-02T00:00:00Z"), wantEnd: limit, wantFuture: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "/m?"+tc.query, nil)
+			rng, err := parseTimeRangeAt(c, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case tc.wantStart == nil && rng.Start != nil:
+				t.Errorf("start %s, want none", rng.Start)
+			case tc.wantStart != nil && (rng.Start == nil || !rng.Start.Equal(*tc.wantStart)):
+				t.Errorf("start %v, want %s", rng.Start, tc.wantStart)
+			}
+			if !rng.End.Equal(tc.wantEnd) {
+				t.Errorf("end %s, want %s", rng.End, tc.wantEnd)
+			}
+			if rng.Future != tc.wantFuture {
+				t.Errorf("future %v, want %v", rng.Future, tc.wantFuture)
+			}
+			wantWarning := ""
+			if tc.wantFuture {
+				wantWarning = futureRangeWarning
+			}
+			if rng.Warning != wantWarning {
+				t.Errorf("warning %q, want %q", rng.Warning, wantWarning)
+			}
+		})
+	}
+}
+
+func TestParseTimeRangeRejectsStartAfterEnd(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	const start, end = "2025-10-01T07:00:00-05:00", "2025-10-01T11:59:59Z"
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest("GET", "/m?"+url.Values{"start": {start}, "end": {end}}.Encode(), nil)
+	_, err := parseTimeRangeAt(c, now)
+	var rerr *rangeError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("err %v, want a rangeError", err)
+	}
+
+	respondRangeError(c, err)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", rec.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	// Both values are echoed as sent, not normalized to UTC.
+	if body["start"] != start || body["end"] != end || body["error"] == "" {
+		t.Errorf("body %v, want the error with start %q and end %q", body, start, end)
+	}
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	encjson "encoding/json"
+	"math/bits"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// expandFlagsContextKey is set by expandFlagsMiddleware when a request asks
+// for qc_flags to be decoded.
+const expandFlagsContextKey = "expand_flags"
+
+// qcFlag is one bit of the clean_measurements.qc_flags bitmask.
+type qcFlag struct {
+	Bit         int    `json:"bit"`
+	Value       int32  `json:"value"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// qcFlagRegistry lists the bits the cleaner sets, in bit order. It mirrors
+// the *_FLAG constants of services/cleaner/pipeline.py and must be extended
+// together with them.
+var qcFlagRegistry = []qcFlag{
+	{Bit: 0, Value: 1, Name: "outlier", Description: "Value outside the configured min/max bounds"},
+	{Bit: 1, Value: 2, Name: "imputed", Description: "Value was imputed; imputation_method names the strategy"},
+	{Bit: 2, Value: 4, Name: "poor_quality", Description: "Raw quality score below the threshold; the value was replaced"},
+}
+
+// qcFlagNames decodes a qc_flags bitmask into flag names, lowest bit first.
+// Bits missing from the registry come back as unknown_bit_N.
+func qcFlagNames(flags int32) []string {
+	names := make([]string, 0, bits.OnesCount32(uint32(flags)))
+	for bit := 0; bit < 32; bit++ {
+		if uint32(flags)&(1<<bit) == 0 {
+			continue
+		}
+		name := "unknown_bit_" + strconv.Itoa(bit)
+		for _, f := range qcFlagRegistry {
+			if f.Bit == bit {
+				name = f.Name
+				break
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// expandFlagsMiddleware reads the expand_flags parameter of measurement
+// endpoints and rejects values that are not booleans.
+func expandFlagsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if raw := c.Query("expand_flags"); raw != "" {
+			expand, err := strconv.ParseBool(raw)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid expand_flags parameter"})
+				return
+			}
+			if expand {
+				c.Set(expandFlagsContextKey, true)
+			}
+		}
+		c.Next()
+	}
+}
+
+// addFlagNames gives every object of a generic JSON document that carries
+// qc_flags a qc_flag_names sibling, null when qc_flags is.
+func addFlagNames(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, field := range v {
+			addFlagNames(field)
+		}
+		flags, ok := v["qc_flags"]
+		if !ok {
+			return
+		}
+		v["qc_flag_names"] = nil
+		if n, ok := flags.(encjson.Number); ok {
+			if i, err := strconv.ParseInt(n.String(), 10, 32); err == nil {
+				v["qc_flag_names"] = qcFlagNames(int32(i))
+			}
+		}
+	case []any:
+		for _, item := range v {
+			addFlagNames(item)
+		}
+	}
+}
+
+// handleV1QCFlags returns the qc_flags registry so clients can build a legend.
+// GET /api/v1/core/qc-flags
+func (s *Server) handleV1QCFlags(c *gin.Context) {
+	s.respondJSON(c, gin.H{
+		"data": qcFlagRegistry,
+		"meta": gin.H{"count": len(qcFlagRegistry)},
+	})
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
+)
+
+// rainClassUnknown labels sensors without data in the classification window.
+const rainClassUnknown = "unknown"
+
+// rainClass is one intensity band. A rate belongs to the last class whose
+// MinMmH it reaches.
+type rainClass struct {
+	Name   string
+	MinMmH float64
+}
+
+// defaultRainClasses is the single source of the intensity bands used by the
+// classification and legend endpoints. Bounds follow the common light /
+// moderate / heavy / violent split; colours come from the grid colour ramp so
+// labels match the rendered maps.
+var defaultRainClasses = []rainClass{
+	{"dry", 0},
+	{"drizzle", 0.1},
+	{"rain", 2.5},
+	{"heavy", 7.6},
+	{"violent", 50},
+}
+
+// parseRainClasses returns the default classes with any bound overridden by a
+// query parameter named after the class (e.g. heavy=10). Bounds must stay
+// strictly increasing; the dry bound is fixed at 0.
+func parseRainClasses(c *gin.Context) ([]rainClass, error) {
+	classes := make([]rainClass, len(defaultRainClasses))
+	copy(classes, defaultRainClasses)
+	for i := 1; i < len(classes); i++ {
+		v := c.Query(classes[i].Name)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			return nil, fmt.Errorf("invalid %s threshold, expected a positive mm/h value", classes[i].Name)
+		}
+		classes[i].MinMmH = f
+	}
+	for i := 1; i < len(classes); i++ {
+		if classes[i].MinMmH <= classes[i-1].MinMmH {
+			return nil, errors.New("rain class thresholds must be strictly increasing")
+		}
+	}
+	return classes, nil
+}
+
+// classifyRain returns the class name for an intensity in mm/h.
+func classifyRain(classes []rainClass, mmH float64) string {
+	name := classes[0].Name
+	for _, cl := range classes {
+		if mmH >= cl.MinMmH {
+			name = cl.Name
+		}
+	}
+	return name
+}
+
+// rainLegend describes classes as legend entries with their colour and
+// half-open [min_mm_h, max_mm_h) range; the last class has no upper bound.
+func rainLegend(classes []rainClass) []gin.H {
+	legend := make([]gin.H, 0, len(classes)+1)
+	for i, cl := range classes {
+		entry := gin.H{
+			"class":    cl.Name,
+			"min_mm_h": cl.MinMmH,
+			"color":    rampHex(cl.MinMmH),
+		}
+		if i+1 < len(classes) {
+			entry["max_mm_h"] = classes[i+1].MinMmH
+		}
+		legend = append(legend, entry)
+	}
+	return append(legend, gin.H{"class": rainClassUnknown, "color": "#9e9e9e"})
+}
+
+// rampHex renders the grid ramp colour for v as #rrggbb.
+func rampHex(v float64) string {
+	c := grid.RampColor(v)
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
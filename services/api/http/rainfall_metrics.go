@@ -0,0 +1,218 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+const (
+	// rainfallMetricsTTL bounds how often a scrape reaches the database.
+	rainfallMetricsTTL = 10 * time.Second
+	// reportingWindow is how recent a sensor's latest clean value must be for
+	// the sensor to count as reporting.
+	reportingWindow = time.Hour
+	// maxLabelLength truncates label values taken from sensor metadata.
+	maxLabelLength = 128
+)
+
+var (
+	sensorRainDesc = prometheus.NewDesc(
+		"shizuku_sensor_rain_mm",
+		"Latest clean precipitation value per sensor, in mm, for sensors reporting within the last hour.",
+		[]string{"sensor_id", "city", "subbasin"}, nil,
+	)
+	networkAvgDesc = prometheus.NewDesc(
+		"shizuku_network_avg_mm_h",
+		"Average rainfall rate across sensor aggregates of the latest grid, in mm/h.",
+		nil, nil,
+	)
+	gridAgeDesc = prometheus.NewDesc(
+		"shizuku_latest_grid_age_seconds",
+		"Seconds since the timestamp of the latest completed grid run.",
+		nil, nil,
+	)
+	reportingDesc = prometheus.NewDesc(
+		"shizuku_sensors_reporting",
+		"Sensors whose latest clean measurement is within the last hour.",
+		nil, nil,
+	)
+)
+
+// rainfallSample is one sensor reading exported as a gauge.
+type rainfallSample struct {
+	sensorID string
+	city     string
+	subbasin string
+	valueMM  float64
+}
+
+// rainfallSnapshot is the data behind one scrape.
+type rainfallSnapshot struct {
+	samples    []rainfallSample
+	reporting  int
+	networkAvg *float64
+	gridTS     *time.Time
+}
+
+// rainfallCollector exposes rainfall data as Prometheus gauges, refreshing
+// it from the Store at most once per TTL.
+type rainfallCollector struct {
+	store *db.Store
+	ttl   time.Duration
+	// gridEnabled reports whether the grid tables exist; the grid gauges are
+	// left out while they do not.
+	gridEnabled func() bool
+
+	mu        sync.Mutex
+	fetchedAt time.Time
+	snapshot  *rainfallSnapshot
+}
+
+func (rc *rainfallCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sensorRainDesc
+	ch <- networkAvgDesc
+	ch <- gridAgeDesc
+	ch <- reportingDesc
+}
+
+func (rc *rainfallCollector) Collect(ch chan<- prometheus.Metric) {
+	snap, err := rc.get()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(sensorRainDesc, err)
+		return
+	}
+
+	for _, sm := range snap.samples {
+		ch <- prometheus.MustNewConstMetric(sensorRainDesc, prometheus.GaugeValue, sm.valueMM, sm.sensorID, sm.city, sm.subbasin)
+	}
+	ch <- prometheus.MustNewConstMetric(reportingDesc, prometheus.GaugeValue, float64(snap.reporting))
+	if snap.networkAvg != nil {
+		ch <- prometheus.MustNewConstMetric(networkAvgDesc, prometheus.GaugeValue, *snap.networkAvg)
+	}
+	if snap.gridTS != nil {
+		ch <- prometheus.MustNewConstMetric(gridAgeDesc, prometheus.GaugeValue, time.Since(*snap.gridTS).Seconds())
+	}
+}
+
+// get returns the cached snapshot, refreshing it once the TTL has passed.
+func (rc *rainfallCollector) get() (*rainfallSnapshot, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.snapshot != nil && time.Since(rc.fetchedAt) < rc.ttl {
+		return rc.snapshot, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	snap, err := rc.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rc.snapshot = snap
+	rc.fetchedAt = time.Now()
+	return snap, nil
+}
+
+func (rc *rainfallCollector) fetch(ctx context.Context) (*rainfallSnapshot, error) {
+	sensors, err := rc.store.ListSensors(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]db.Sensor, len(sensors))
+	for _, s := range sensors {
+		byID[s.ID] = s
+	}
+
+	latest, err := rc.store.LatestClean(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &rainfallSnapshot{samples: make([]rainfallSample, 0, len(latest))}
+	cutoff := time.Now().Add(-reportingWindow)
+	for _, m := range latest {
+		if m.ValueMM == nil {
+			continue
+		}
+		// A stale reading would keep a rain alert firing long after the
+		// sensor went quiet; such sensors are left out of the gauge.
+		if !m.Timestamp.After(cutoff) {
+			continue
+		}
+		sensor := byID[m.SensorID]
+		snap.reporting++
+		snap.samples = append(snap.samples, rainfallSample{
+			sensorID: sanitizeLabel(m.SensorID),
+			city:     sanitizeLabel(derefString(sensor.City)),
+			subbasin: sanitizeLabel(derefString(sensor.Subbasin)),
+			valueMM:  *m.ValueMM,
+		})
+	}
+
+	if !rc.gridEnabled() {
+		return snap, nil
+	}
+	grid, err := rc.store.GetLatestGrid(ctx)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return snap, nil
+	case err != nil:
+		return nil, err
+	}
+	ts := grid.Timestamp
+	snap.gridTS = &ts
+
+	aggregates, err := rc.store.GetSensorAggregatesByGridRunID(ctx, grid.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(aggregates) > 0 {
+		var sum float64
+		for _, agg := range aggregates {
+			sum += agg.AvgMmH
+		}
+		avg := sum / float64(len(aggregates))
+		snap.networkAvg = &avg
+	}
+
+	return snap, nil
+}
+
+// sanitizeLabel makes free-text metadata safe and bounded as a label value:
+// invalid UTF-8 and control characters are dropped and whitespace collapsed.
+func sanitizeLabel(v string) string {
+	v = strings.ToValidUTF8(v, "")
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, v)
+	v = strings.Join(strings.Fields(v), " ")
+	if len(v) > maxLabelLength {
+		v = strings.ToValidUTF8(v[:maxLabelLength], "")
+	}
+	return v
+}
+
+// rainfallMetricsHandler serves the rainfall gauges from a dedicated registry
+// so they stay separate from the process metrics on /metrics.
+func rainfallMetricsHandler(store *db.Store, gridEnabled func() bool) gin.HandlerFunc {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(&rainfallCollector{store: store, ttl: rainfallMetricsTTL, gridEnabled: gridEnabled})
+	h := promhttp.HandlerFor(reg, promhttp.HandlerOpts{ErrorHandling: promhttp.HTTPErrorOnError})
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// protobufContentType is negotiated on endpoints that offer the binary
+// encoding described in docs/realtime_now.proto.
+const protobufContentType = "application/x-protobuf"
+
+// protobuf encodes the response as the RealtimeNow message of
+// docs/realtime_now.proto. Field numbers below must stay in sync with it.
+func (r *realtimeNow) protobuf() []byte {
+	var b []byte
+	if r.Grid != nil {
+		var g []byte
+		g = appendVarint(g, 1, uint64(r.Grid.ID))
+		g = appendVarint(g, 2, uint64(r.Grid.Timestamp.Unix()))
+		g = appendVarint(g, 3, uint64(r.Grid.Resolution))
+		g = appendString(g, 4, r.Grid.Status)
+		b = appendMessage(b, 1, g)
+	}
+	for _, agg := range r.Aggregates {
+		b = appendMessage(b, 2, encodeSensorAggregate(agg))
+	}
+	for _, m := range r.Latest {
+		b = appendMessage(b, 3, encodeLatestValue(m))
+	}
+	return appendMessage(b, 4, r.encodeMeta())
+}
+
+func encodeSensorAggregate(agg db.SensorAggregate) []byte {
+	var b []byte
+	b = appendString(b, 1, agg.SensorID)
+	b = appendDouble(b, 2, agg.AvgMmH)
+	b = appendDouble(b, 3, agg.AccumulationMM)
+	b = appendVarint(b, 4, uint64(agg.WindowSeconds))
+	b = appendVarint(b, 5, uint64(agg.MeasurementCount))
+	b = appendDouble(b, 6, agg.MinValueMm)
+	b = appendDouble(b, 7, agg.MaxValueMm)
+	if agg.Sensor != nil {
+		// Optional fields are written even when zero so presence survives.
+		b = appendFixed64(b, 8, math.Float64bits(agg.Sensor.Lat))
+		b = appendFixed64(b, 9, math.Float64bits(agg.Sensor.Lon))
+	}
+	return b
+}
+
+func encodeLatestValue(m db.Measurement) []byte {
+	var b []byte
+	b = appendString(b, 1, m.SensorID)
+	b = appendVarint(b, 2, uint64(m.Timestamp.Unix()))
+	if m.ValueMM != nil {
+		b = appendFixed64(b, 3, math.Float64bits(*m.ValueMM))
+	}
+	return b
+}
+
+func (r *realtimeNow) encodeMeta() []byte {
+	var b []byte
+	if r.Grid != nil {
+		b = appendVarint(b, 1, uint64(r.Grid.Timestamp.Unix()))
+	}
+	b = appendVarint(b, 2, uint64(r.SensorsCount))
+	b = appendVarint(b, 3, uint64(r.NetworkCount))
+	b = appendVarint(b, 4, uint64(r.RainingSensors))
+	b = appendDouble(b, 5, r.RainThreshold)
+	b = appendVarint(b, 6, uint64(r.GeneratedAt.Unix()))
+	if r.Grid == nil {
+		b = appendVarint(b, 7, 1)
+	}
+	if r.BBox != nil {
+		var box []byte
+		box = appendDouble(box, 1, r.BBox.MinLon)
+		box = appendDouble(box, 2, r.BBox.MinLat)
+		box = appendDouble(box, 3, r.BBox.MaxLon)
+		box = appendDouble(box, 4, r.BBox.MaxLat)
+		b = appendMessage(b, 8, box)
+	}
+	return b
+}
+
+// The append helpers follow proto3 semantics: implicit-presence fields are
+// skipped when they hold the zero value.
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendFixed64(b, num, math.Float64bits(v))
+}
+
+func appendFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// nowView is the content shared by both encodings of realtime now, with
+// timestamps as Unix seconds.
+type nowView struct {
+	GridID, GridTS            int64
+	GridResolution            int64
+	GridStatus                string
+	Aggregates                []aggView
+	Latest                    []latestView
+	Sensors, Network, Raining int64
+	Threshold                 float64
+	GeneratedAt, Timestamp    int64
+	GridDisabled              bool
+	BBox                      *db.BBox
+}
+
+type aggView struct {
+	SensorID               string
+	AvgMmH, AccumulationMM float64
+	WindowSeconds, Count   int64
+	Min, Max               float64
+	Lat, Lon               *float64
+}
+
+type latestView struct {
+	SensorID string
+	TS       int64
+	Value    *float64
+}
+
+// fields decodes one protobuf message into its fields by number: varints and
+// fixed64s as uint64, length-delimited fields as []byte.
+func fields(t *testing.T, b []byte) map[protowire.Number][]any {
+	t.Helper()
+	out := make(map[protowire.Number][]any)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatalf("bad tag: %v", protowire.ParseError(n))
+		}
+		b = b[n:]
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("field %d: unexpected wire type %d", num, typ)
+		}
+		if n < 0 {
+			t.Fatalf("field %d: %v", num, protowire.ParseError(n))
+		}
+		b = b[n:]
+		out[num] = append(out[num], v)
+	}
+	return out
+}
+
+func varint(f map[protowire.Number][]any, num protowire.Number) int64 {
+	if v, ok := f[num]; ok {
+		return int64(v[0].(uint64))
+	}
+	return 0
+}
+
+func double(f map[protowire.Number][]any, num protowire.Number) float64 {
+	if v, ok := f[num]; ok {
+		return math.Float64frombits(v[0].(uint64))
+	}
+	return 0
+}
+
+func optDouble(f map[protowire.Number][]any, num protowire.Number) *float64 {
+	if _, ok := f[num]; !ok {
+		return nil
+	}
+	v := double(f, num)
+	return &v
+}
+
+func str(f map[protowire.Number][]any, num protowire.Number) string {
+	if v, ok := f[num]; ok {
+		return string(v[0].([]byte))
+	}
+	return ""
+}
+
+func viewFromProtobuf(t *testing.T, b []byte) nowView {
+	msg := fields(t, b)
+	var v nowView
+	if g, ok := msg[1]; ok {
+		grid := fields(t, g[0].([]byte))
+		v.GridID, v.GridTS = varint(grid, 1), varint(grid, 2)
+		v.GridResolution, v.GridStatus = varint(grid, 3), str(grid, 4)
+	}
+	for _, raw := range msg[2] {
+		a := fields(t, raw.([]byte))
+		v.Aggregates = append(v.Aggregates, aggView{
+			SensorID: str(a, 1), AvgMmH: double(a, 2), AccumulationMM: double(a, 3),
+			WindowSeconds: varint(a, 4), Count: varint(a, 5), Min: double(a, 6), Max: double(a, 7),
+			Lat: optDouble(a, 8), Lon: optDouble(a, 9),
+		})
+	}
+	for _, raw := range msg[3] {
+		l := fields(t, raw.([]byte))
+		v.Latest = append(v.Latest, latestView{SensorID: str(l, 1), TS: varint(l, 2), Value: optDouble(l, 3)})
+	}
+	meta := fields(t, msg[4][0].([]byte))
+	v.Timestamp = varint(meta, 1)
+	v.Sensors, v.Network, v.Raining = varint(meta, 2), varint(meta, 3), varint(meta, 4)
+	v.Threshold, v.GeneratedAt = double(meta, 5), varint(meta, 6)
+	v.GridDisabled = varint(meta, 7) == 1
+	if box, ok := meta[8]; ok {
+		bb := fields(t, box[0].([]byte))
+		v.BBox = &db.BBox{MinLon: double(bb, 1), MinLat: double(bb, 2), MaxLon: double(bb, 3), MaxLat: double(bb, 4)}
+	}
+	return v
+}
+
+func viewFromJSON(t *testing.T, doc any) nowView {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		Data struct {
+			Grid *struct {
+				ID         int64     `json:"id"`
+				Timestamp  time.Time `json:"timestamp"`
+				Resolution int64     `json:"resolution"`
+				Status     string    `json:"status"`
+			} `json:"grid"`
+			Aggregates []struct {
+				SensorID         string  `json:"sensor_id"`
+				AvgMmH           float64 `json:"avg_mm_h"`
+				AccumulationMM   float64 `json:"accumulation_mm"`
+				WindowSeconds    int64   `json:"window_seconds"`
+				MeasurementCount int64   `json:"measurement_count"`
+				MinValueMm       float64 `json:"min_value_mm"`
+				MaxValueMm       float64 `json:"max_value_mm"`
+				Sensor           *struct {
+					Lat float64 `json:"lat"`
+					Lon float64 `json:"lon"`
+				} `json:"sensor"`
+			} `json:"sensor_aggregates"`
+			Latest []struct {
+				SensorID string    `json:"sensor_id"`
+				TS       time.Time `json:"ts"`
+				ValueMM  *float64  `json:"value_mm"`
+			} `json:"latest"`
+		} `json:"data"`
+		Meta struct {
+			SensorsCount    int64     `json:"sensors_count"`
+			NetworkCount    int64     `json:"network_count"`
+			RainingSensors  int64     `json:"raining_sensors"`
+			RainThresholdMM float64   `json:"rain_threshold_mm"`
+			GeneratedAt     time.Time `json:"generated_at"`
+			Timestamp       time.Time `json:"timestamp"`
+			GridMode        string    `json:"grid_mode"`
+			BBox            *db.BBox  `json:"bbox"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	var v nowView
+	if g := body.Data.Grid; g != nil {
+		v.GridID, v.GridTS, v.GridResolution, v.GridStatus = g.ID, g.Timestamp.Unix(), g.Resolution, g.Status
+		v.Timestamp = body.Meta.Timestamp.Unix()
+	}
+	for _, a := range body.Data.Aggregates {
+		av := aggView{
+			SensorID: a.SensorID, AvgMmH: a.AvgMmH, AccumulationMM: a.AccumulationMM,
+			WindowSeconds: a.WindowSeconds, Count: a.MeasurementCount, Min: a.MinValueMm, Max: a.MaxValueMm,
+		}
+		if a.Sensor != nil {
+			av.Lat, av.Lon = &a.Sensor.Lat, &a.Sensor.Lon
+		}
+		v.Aggregates = append(v.Aggregates, av)
+	}
+	for _, l := range body.Data.Latest {
+		v.Latest = append(v.Latest, latestView{SensorID: l.SensorID, TS: l.TS.Unix(), Value: l.ValueMM})
+	}
+	v.Sensors, v.Network, v.Raining = body.Meta.SensorsCount, body.Meta.NetworkCount, body.Meta.RainingSensors
+	v.Threshold, v.GeneratedAt = body.Meta.RainThresholdMM, body.Meta.GeneratedAt.Unix()
+	v.GridDisabled = body.Meta.GridMode == "disabled"
+	v.BBox = body.Meta.BBox
+	return v
+}
+
+func TestRealtimeNowEncodingsAgree(t *testing.T) {
+	ts := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	value := 0.4
+	for name, now := range map[string]*realtimeNow{
+		"grid mode": {
+			Grid: &db.GridRun{ID: 7, Timestamp: ts, Resolution: 250, Status: "done"},
+			Aggregates: []db.SensorAggregate{
+				{SensorID: "a", AvgMmH: 4.8, AccumulationMM: 0.4, WindowSeconds: 300, MeasurementCount: 1, MinValueMm: 0.4, MaxValueMm: 0.4,
+					Sensor: &db.Sensor{ID: "a", Lat: 6.25, Lon: -75.56}},
+				// A dry sensor on the equator keeps its zero coordinates.
+				{SensorID: "b", Sensor: &db.Sensor{ID: "b"}},
+				{SensorID: "c", AvgMmH: 1.2},
+			},
+			SensorsCount: 3, NetworkCount: 40, RainingSensors: 1, RainThreshold: 0.1,
+			GeneratedAt: ts.Add(time.Minute),
+			BBox:        &db.BBox{MinLon: -75.7, MinLat: 6.1, MaxLon: -75.4, MaxLat: 6.4},
+		},
+		"grid disabled": {
+			Latest: []db.Measurement{
+				{SensorID: "a", Timestamp: ts, ValueMM: &value},
+				{SensorID: "b", Timestamp: ts.Add(-5 * time.Minute)},
+			},
+			SensorsCount: 2, NetworkCount: 40, RainThreshold: 0.1,
+			GeneratedAt: ts.Add(time.Minute),
+		},
+	} {
+		fromJSON := viewFromJSON(t, now.document())
+		fromProto := viewFromProtobuf(t, now.protobuf())
+		if !reflect.DeepEqual(fromJSON, fromProto) {
+			t.Errorf("%s: encodings differ\n json:     %+v\n protobuf: %+v", name, fromJSON, fromProto)
+		}
+	}
+}
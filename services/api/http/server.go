@@ -2,41 +2,86 @@ package http
 
 import (
 	"context"
-	encjson "encoding/json"
 	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
 )
 
 // Server bundles router and dependencies for the REST API.
 type Server struct {
-	cfg    config.Config
-	store  *db.Store
-	engine *gin.Engine
+	cfg      config.Config
+	store    *db.Store
+	engine   *gin.Engine
+	grids    *grid.Cache
+	thumbs   *grid.ThumbnailCache
+	coverage *grid.CoverageCache
+	contours *grid.ContoursCache
+	status   *statusPage
+	ready    atomic.Bool
+	// gridDisabled is set while the grid ETL tables are absent.
+	gridDisabled atomic.Bool
 }
 
+// blobMaxBytes caps grid and contour documents fetched from the blob store.
+const blobMaxBytes = 256 << 20
+
 // New constructs a server with routes and middleware.
-func New(cfg config.Config, store *db.Store) *Server {
+func New(cfg config.Config, store *db.Store) (*Server, error) {
 	gin.SetMode(gin.ReleaseMode)
 	engine := gin.New()
 	engine.Use(gin.Recovery())
 	engine.Use(gin.Logger())
+	engine.Use(debugCaptureMiddleware(cfg))
+	engine.Use(errorMiddleware())
 	engine.Use(corsMiddleware(cfg))
 
-	if cfg.BearerToken != "" {
-		engine.Use(bearerAuthMiddleware(cfg.BearerToken))
+	engine.Use(bearerAuthMiddleware(cfg))
+	engine.Use(cacheBypassMiddleware())
+	engine.Use(debugRecorderMiddleware())
+	var shedder *loadShedder
+	if cfg.ShedUtilization > 0 {
+		shedder = newLoadShedder(store.PoolStats, cfg.ShedUtilization, cfg.ShedAcquireWait, cfg.ShedCooldown)
+	}
+	engine.Use(loadShedMiddleware(shedder))
+	engine.Use(concurrencyMiddleware(
+		newClassLimiter(classLight, cfg.LightConcurrency, cfg.LightQueue),
+		newClassLimiter(classHeavy, cfg.HeavyConcurrency, cfg.HeavyQueue),
+	))
+
+	blob := httpclient.New(httpclient.Options{
+		Timeout:          20 * time.Second,
+		Retries:          2,
+		MaxResponseBytes: blobMaxBytes,
+		Observe:          observeOutbound,
+	})
+	server := &Server{
+		cfg:      cfg,
+		store:    store,
+		engine:   engine,
+		grids:    grid.NewCache(blob, 32),
+		thumbs:   grid.NewThumbnailCache(256),
+		coverage: grid.NewCoverageCache(64),
+		contours: grid.NewContoursCache(blob, cfg.ContoursInlineMaxBytes, 16),
+		status:   newStatusPage(cfg.StatusRateLimit),
 	}
-
-	server := &Server{cfg: cfg, store: store, engine: engine}
 	server.registerRoutes()
-	return server
+	if err := registerMeasurementCacheMetrics(prometheus.DefaultRegisterer, store); err != nil {
+		return nil, err
+	}
+	return server, nil
 }
 
 // Engine exposes the underlying gin engine (for tests).
@@ -51,6 +96,9 @@ func (s *Server) Run(ctx context.Context) error {
 		Handler: s.engine,
 	}
 
+	go s.warmUp(ctx)
+	go s.watchGridTables(ctx)
+
 	errCh := make(chan error, 1)
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
@@ -69,23 +117,54 @@ func (s *Server) Run(ctx context.Context) error {
 	}
 }
 
+// warmUp pre-dials database connections within the configured bound and then
+// marks the server ready. Failures only degrade to lazy connections.
+func (s *Server) warmUp(ctx context.Context) {
+	defer s.ready.Store(true)
+	if s.cfg.DBWarmupTimeout <= 0 {
+		return
+	}
+
+	warmCtx, cancel := context.WithTimeout(ctx, s.cfg.DBWarmupTimeout)
+	defer cancel()
+
+	start := time.Now()
+	n, err := s.store.Warm(warmCtx)
+	if err != nil {
+		log.Printf("warning: db warm-up incomplete after %s (%d connections): %v; continuing with lazy connections", time.Since(start).Round(time.Millisecond), n, err)
+		return
+	}
+	log.Printf("db warm-up established %d connections in %s", n, time.Since(start).Round(time.Millisecond))
+}
+
 func (s *Server) registerRoutes() {
 	s.engine.GET("/healthz", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
 
+	s.engine.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	s.engine.GET("/readyz", func(c *gin.Context) {
+		if !s.ready.Load() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "warming"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "grid": s.gridMode()})
+	})
+
 	// Legacy endpoints (v0) - with deprecation warnings
 	legacy := s.engine.Group("/")
 	legacy.Use(deprecationMiddleware())
+	legacy.Use(s.timeZoneMiddleware())
 	{
 		legacy.GET("/sensor", deprecatedHandler("/api/v1/core/sensors", s.handleListSensors))
 		legacy.GET("/sensor/:sensor_id", deprecatedHandler("/api/v1/core/sensors/:sensor_id", s.handleGetSensor))
 		legacy.GET("/now", deprecatedHandler("/api/v1/realtime/now", s.handleLatest))
 		legacy.GET("/grid/latest", deprecatedHandler("/api/v1/realtime/now", s.handleGridLatest))
-		legacy.GET("/grid/available", deprecatedHandler("/api/v1/grid/timestamps", s.handleGridAvailable))
-		legacy.GET("/grid/:timestamp", deprecatedHandler("/api/v1/grid/:timestamp", s.handleGridByTimestamp))
-		legacy.GET("/dashboard/summary", deprecatedHandler("", s.handleDashboardSummary)) // No v1 equivalent yet
-		legacy.GET("/snapshot", deprecatedHandler("", s.handleSnapshotAt))                // No v1 equivalent yet
+		legacy.GET("/grid/available", s.requireGrid(), deprecatedHandler("/api/v1/grid/timestamps", s.handleGridAvailable))
+		legacy.GET("/grid/:timestamp", s.requireGrid(), deprecatedHandler("/api/v1/grid/:timestamp", s.handleGridByTimestamp))
+		legacy.GET("/dashboard/summary", deprecatedHandler("/api/v1/dashboard/summary", s.handleDashboardSummary))
+		legacy.GET("/snapshot", deprecatedHandler("/api/v1/realtime/snapshot", s.handleSnapshotAt))
 	}
 
 	// New versioned API routes
@@ -98,73 +177,98 @@ func (s *Server) handleSnapshotAt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "ts query parameter required (RFC3339)"})
 		return
 	}
-	ts, err := time.Parse(time.RFC3339, tsStr)
+	ts, err := parseTimestamp(tsStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ts format, expected RFC3339"})
 		return
 	}
 
-	useClean := true
-	if cleanStr := c.Query("clean"); cleanStr != "" {
-		if val, err := strconv.ParseBool(cleanStr); err == nil {
-			useClean = val
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid clean parameter"})
-			return
-		}
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolveLocation, err := parseHistoricalLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	snaps, err := s.store.SnapshotAtTimestamp(ctx, ts, useClean)
+	snaps, err := s.snapshotAt(ctx, ts, mode, resolveLocation, db.SnapshotFilter{})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
 	// Build response: include requested timestamp and measurements
-	c.JSON(http.StatusOK, gin.H{
+	s.respondJSON(c, gin.H{
 		"requested_ts": ts.Format(time.RFC3339),
+		"clean_mode":   mode,
 		"measurements": snaps,
 	})
 }
 
-func bearerAuthMiddleware(expected string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		auth := c.GetHeader("Authorization")
-		if !strings.HasPrefix(auth, "Bearer ") {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
+// corsPolicy is a set of allowed origins for a class of routes.
+type corsPolicy []string
+
+func newCORSPolicy(origins string) corsPolicy {
+	var p corsPolicy
+	for _, allowed := range strings.Split(origins, ",") {
+		if allowed = strings.TrimSpace(allowed); allowed != "" {
+			p = append(p, allowed)
 		}
-		token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
-		if token != expected {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
+	}
+	return p
+}
+
+func (p corsPolicy) allows(origin string) bool {
+	for _, allowed := range p {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// isWriteRequest reports whether a request (or the request a preflight is
+// asking about) targets a mutating or admin route.
+func isWriteRequest(c *gin.Context) bool {
+	method := c.Request.Method
+	if method == http.MethodOptions {
+		if requested := c.GetHeader("Access-Control-Request-Method"); requested != "" {
+			method = requested
 		}
-		c.Next()
 	}
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return true
+	}
+	return strings.HasPrefix(c.Request.URL.Path, "/api/v1/admin")
 }
 
 func corsMiddleware(cfg config.Config) gin.HandlerFunc {
+	public := newCORSPolicy(cfg.CORSAllowedOrigins)
+	write := newCORSPolicy(cfg.CORSWriteOrigins)
+	maxAge := strconv.Itoa(cfg.CORSMaxAge)
+
 	return func(c *gin.Context) {
 		origin := c.GetHeader("Origin")
 
-		// Check if origin is allowed
-		allowedOrigins := strings.Split(cfg.CORSAllowedOrigins, ",")
-		allowOrigin := false
-
-		for _, allowed := range allowedOrigins {
-			allowed = strings.TrimSpace(allowed)
-			if allowed == "*" || allowed == origin {
-				allowOrigin = true
-				break
-			}
+		// Public reads and mutating/admin routes use separate origin lists
+		policy := public
+		if isWriteRequest(c) {
+			policy = write
 		}
 
-		if allowOrigin {
+		if policy.allows(origin) {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
+		c.Header("Vary", "Origin")
 
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
@@ -174,6 +278,9 @@ func corsMiddleware(cfg config.Config) gin.HandlerFunc {
 		}
 
 		if c.Request.Method == "OPTIONS" {
+			if cfg.CORSMaxAge > 0 {
+				c.Header("Access-Control-Max-Age", maxAge)
+			}
 			c.AbortWithStatus(http.StatusNoContent)
 			return
 		}
@@ -194,6 +301,8 @@ func deprecationMiddleware() gin.HandlerFunc {
 // deprecatedHandler wraps a handler and adds deprecation headers
 func deprecatedHandler(newEndpoint string, handler gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		legacyUsage.record(c)
+
 		// Mark this endpoint as deprecated
 		c.Header("X-Deprecated-Endpoint", "true")
 
@@ -230,9 +339,9 @@ func (s *Server) handleListSensors(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	sensors, err := s.store.ListSensors(ctx)
+	sensors, err := s.store.ListSensors(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -246,14 +355,25 @@ func (s *Server) handleGetSensor(c *gin.Context) {
 		return
 	}
 
-	useClean := true
-	if cleanStr := c.Query("clean"); cleanStr != "" {
-		if val, err := strconv.ParseBool(cleanStr); err == nil {
-			useClean = val
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid clean parameter"})
-			return
-		}
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable, err := parseVariable(c, mode.strict())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Unlike the v1 endpoints, last_n here keeps its historical meaning: the
+	// first rows of the range in the requested order, so the oldest ones
+	// unless order=desc.
+	descending, err := parseOrder(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := s.cfg.DefaultLimit
@@ -265,9 +385,9 @@ func (s *Server) handleGetSensor(c *gin.Context) {
 		}
 		limit = parsed
 	}
+	limit = clampLimit(limit, s.cfg.MaxRows)
 
 	var since *time.Time
-	var until *time.Time
 
 	if daysStr := c.Query("last_n_days"); daysStr != "" {
 		days, err := strconv.Atoi(daysStr)
@@ -279,48 +399,93 @@ func (s *Server) handleGetSensor(c *gin.Context) {
 		since = &t
 	}
 
-	if startStr := c.Query("start"); startStr != "" {
-		t, err := time.Parse(time.RFC3339, startStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start timestamp"})
-			return
-		}
-		tt := t.UTC()
-		since = &tt
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start != nil {
+		since = rng.Start
+	}
+	until := &rng.End
+	if err := checkRangeSpan(timeRange{Start: since, End: rng.End}, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
 	}
 
-	if endStr := c.Query("end"); endStr != "" {
-		t, err := time.Parse(time.RFC3339, endStr)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end timestamp"})
+	var cursor *measurementCursor
+	if token := c.Query("cursor"); token != "" {
+		cur, err := decodeMeasurementCursor(token)
+		if err != nil || cur.SensorID != sensorID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
 			return
 		}
-		tt := t.UTC()
-		until = &tt
-	}
-
-	if since == nil && until == nil && limit <= 0 {
-		limit = s.cfg.DefaultLimit
+		cursor = &cur
+	}
+
+	if rng.Future {
+		c.JSON(http.StatusOK, gin.H{
+			"sensor_id":    sensorID,
+			"clean":        mode != cleanFalse,
+			"clean_mode":   mode,
+			"count":        0,
+			"measurements": []db.Measurement{},
+			"warning":      rng.Warning,
+		})
+		return
 	}
 
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	measurements, err := s.store.FetchMeasurements(ctx, db.MeasurementQuery{
-		SensorID: sensorID,
-		UseClean: useClean,
-		Limit:    limit,
-		Since:    since,
-		Until:    until,
-	})
+	q := db.MeasurementQuery{
+		SensorID:   sensorID,
+		Limit:      limit,
+		Since:      since,
+		Until:      until,
+		Variable:   variable,
+		Descending: descending,
+	}
+	useClean, err := s.useCleanFor(ctx, mode, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	q.UseClean = useClean
+
+	// Pages resume by (ts, id) keyset: rows of several sources can share the
+	// boundary timestamp, so a start-based link would skip some of them.
+	if cursor != nil {
+		if cursor.UseClean != useClean {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor was issued for a different clean mode"})
+			return
+		}
+		q.After = &cursor.MeasurementCursor
+	}
+	q.Limit = limit + 1
+
+	measurements, err := s.store.FetchMeasurements(ctx, q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	if len(measurements) > limit {
+		measurements = measurements[:limit]
+		if !descending {
+			last := measurements[limit-1]
+			setNextLink(c, measurementCursor{
+				SensorID:          sensorID,
+				UseClean:          useClean,
+				MeasurementCursor: db.MeasurementCursor{TS: last.Timestamp, ID: last.ID},
+			}.encode(), since)
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"sensor_id":    sensorID,
 		"clean":        useClean,
+		"clean_mode":   mode,
 		"count":        len(measurements),
 		"measurements": measurements,
 	})
@@ -330,9 +495,9 @@ func (s *Server) handleLatest(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	latest, err := s.store.LatestClean(ctx)
+	latest, err := s.store.LatestClean(ctx, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -340,8 +505,7 @@ func (s *Server) handleLatest(c *gin.Context) {
 }
 
 func (s *Server) handleGridLatest(c *gin.Context) {
-	gridURL := strings.TrimRight(s.cfg.BlobBaseURL, "/") + "/" + strings.TrimLeft(s.cfg.GridLatestPath, "/")
-	c.JSON(http.StatusOK, gin.H{"grid_url": gridURL})
+	c.JSON(http.StatusOK, gin.H{"grid_url": s.gridLatestURL()})
 }
 
 func (s *Server) handleGridAvailable(c *gin.Context) {
@@ -350,7 +514,7 @@ func (s *Server) handleGridAvailable(c *gin.Context) {
 
 	timestamps, err := s.store.GetAvailableGridTimestamps(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
@@ -379,7 +543,7 @@ func (s *Server) handleGridByTimestamp(c *gin.Context) {
 		return
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	timestamp, err := parseTimestamp(timestampStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
 		return
@@ -390,7 +554,7 @@ func (s *Server) handleGridByTimestamp(c *gin.Context) {
 
 	gridInfo, err := s.store.GetGridByTimestamp(ctx, timestamp)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "grid not found for timestamp"})
+		c.Error(err)
 		return
 	}
 
@@ -416,31 +580,28 @@ func (s *Server) handleDashboardSummary(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	averages, err := s.store.GetAverages(ctx)
+	resp, err := s.dashboardSummaryDocument(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	// Attempt to retrieve grid latest pointer to extract any preview URL
-	gridURL := strings.TrimRight(s.cfg.BlobBaseURL, "/") + "/" + strings.TrimLeft(s.cfg.GridLatestPath, "/")
+	c.JSON(http.StatusOK, resp)
+}
+
+// dashboardSummaryDocument builds the dashboard summary response, shared with
+// the bootstrap endpoint.
+func (s *Server) dashboardSummaryDocument(ctx context.Context) (gin.H, error) {
+	averages, err := s.store.GetAverages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// There is no grid pointer to fetch without the grid ETL
 	previewURL := ""
-	if gridURL != "" {
-		// fetch pointer JSON from blob store (best-effort)
-		client := &http.Client{Timeout: 10 * time.Second}
-		if resp, err := client.Get(gridURL); err == nil {
-			defer resp.Body.Close()
-			if resp.StatusCode == http.StatusOK {
-				var ptr map[string]interface{}
-				if err := encjson.NewDecoder(resp.Body).Decode(&ptr); err == nil {
-					// ETL may store grid_preview_jpeg_url or preview_jpeg_url
-					if v, ok := ptr["grid_preview_jpeg_url"].(string); ok && v != "" {
-						previewURL = v
-					} else if v, ok := ptr["preview_jpeg_url"].(string); ok && v != "" {
-						previewURL = v
-					}
-				}
-			}
+	if !s.gridDisabled.Load() {
+		if previewURL, err = s.gridPreviewURL(ctx); err != nil {
+			log.Printf("warning: grid preview lookup: %v", err)
 		}
 	}
 
@@ -468,9 +629,14 @@ func (s *Server) handleDashboardSummary(c *gin.Context) {
 		}
 	}
 
+	if raining, err := s.countRaining(ctx, nil); err == nil {
+		resp["raining_sensors"] = raining
+		resp["rain_threshold_mm"] = s.cfg.RainThreshold
+	}
+
 	if previewURL != "" {
 		resp["grid_preview_jpeg_url"] = previewURL
 	}
 
-	c.JSON(http.StatusOK, resp)
+	return resp, nil
 }
@@ -0,0 +1,249 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// statusCacheTTL is how long a status document is served before the checks
+// run again, successful or not.
+const statusCacheTTL = 60 * time.Second
+
+// statusCheckTimeout bounds one refresh of the status checks.
+const statusCheckTimeout = 5 * time.Second
+
+// Status page states, worst last.
+const (
+	stateGreen  = "green"
+	stateYellow = "yellow"
+	stateRed    = "red"
+)
+
+// statusRank orders states so the overall state is the worst check.
+var statusRank = map[string]int{stateGreen: 0, stateYellow: 1, stateRed: 2}
+
+// statusDoc is the public status document.
+type statusDoc struct {
+	State       string           `json:"state"`
+	GeneratedAt time.Time        `json:"generated_at"`
+	Stale       bool             `json:"stale"`
+	Checks      map[string]gin.H `json:"checks"`
+}
+
+// withCheck returns a copy of d with one check replaced and the overall
+// state recomputed.
+func (d statusDoc) withCheck(name string, check gin.H) statusDoc {
+	checks := make(map[string]gin.H, len(d.Checks)+1)
+	for k, v := range d.Checks {
+		checks[k] = v
+	}
+	checks[name] = check
+	d.Checks = checks
+	d.State = worstState(checks)
+	return d
+}
+
+func worstState(checks map[string]gin.H) string {
+	worst := stateGreen
+	for _, check := range checks {
+		if st, _ := check["state"].(string); statusRank[st] > statusRank[worst] {
+			worst = st
+		}
+	}
+	return worst
+}
+
+// statusPage caches the status document and rate limits its endpoint
+// independently of the concurrency classes.
+type statusPage struct {
+	mu      sync.Mutex
+	doc     *statusDoc
+	checked time.Time
+	limiter *tokenBucket
+}
+
+func newStatusPage(rate float64) *statusPage {
+	return &statusPage{limiter: newTokenBucket(rate, math.Max(1, rate))}
+}
+
+// handleV1Status serves the public status page document: API, database,
+// latest measurement and grid ages, sensors reporting in the last hour and
+// the last ingest cycle, each as green/yellow/red. It needs no token, is
+// cached for a minute and, while the database is down, keeps serving the
+// last known checks with stale=true.
+// GET /api/v1/status
+func (s *Server) handleV1Status(c *gin.Context) {
+	if !s.status.limiter.allow(time.Now()) {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "status page rate limit exceeded"})
+		return
+	}
+
+	doc := s.statusDocument()
+	c.Header("Cache-Control", "public, max-age="+strconv.Itoa(int(statusCacheTTL/time.Second/2)))
+	c.JSON(http.StatusOK, doc)
+}
+
+// statusDocument returns the cached document, refreshing it once the TTL
+// has passed. Concurrent pollers wait for a single refresh.
+func (s *Server) statusDocument() statusDoc {
+	p := s.status
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.doc != nil && now.Sub(p.checked) < statusCacheTTL {
+		return *p.doc
+	}
+	p.checked = now
+
+	// Detached from the request so one poller disconnecting does not fail
+	// the refresh every waiting poller shares.
+	ctx, cancel := context.WithTimeout(context.Background(), statusCheckTimeout)
+	defer cancel()
+
+	doc, err := s.checkStatus(ctx, now)
+	if err == nil {
+		p.doc = &doc
+		return doc
+	}
+
+	log.Printf("status page: checks failed: %v", err)
+	dbDown := gin.H{"state": stateRed, "error": "database unavailable"}
+	if p.doc == nil {
+		doc = statusDoc{
+			GeneratedAt: now.UTC(),
+			Checks:      map[string]gin.H{"api": {"state": stateGreen}},
+		}.withCheck("db", dbDown)
+	} else {
+		doc = p.doc.withCheck("db", dbDown)
+		doc.Stale = true
+	}
+	p.doc = &doc
+	return doc
+}
+
+// checkStatus runs the checks against the database.
+func (s *Server) checkStatus(ctx context.Context, now time.Time) (statusDoc, error) {
+	th := s.cfg.Status
+	facts, err := s.store.GetStatusFacts(ctx, now.Add(-time.Hour))
+	if err != nil {
+		return statusDoc{}, err
+	}
+
+	checks := map[string]gin.H{
+		"api":                {"state": stateGreen},
+		"db":                 {"state": stateGreen},
+		"latest_measurement": ageCheck(facts.LatestMeasurement, now, th.MeasurementAgeWarn, th.MeasurementAgeCrit),
+		"sensors_reporting":  reportingCheck(facts.SensorsReporting, facts.SensorsTotal, th),
+		"last_ingest":        ingestCheck(facts.LastIngest),
+	}
+
+	if !s.gridDisabled.Load() {
+		var latest *time.Time
+		run, err := s.store.GetLatestGrid(ctx)
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+		case err != nil:
+			return statusDoc{}, err
+		default:
+			latest = &run.Timestamp
+		}
+		checks["latest_grid"] = ageCheck(latest, now, th.GridAgeWarn, th.GridAgeCrit)
+	}
+
+	return statusDoc{
+		State:       worstState(checks),
+		GeneratedAt: now.UTC(),
+		Checks:      checks,
+	}, nil
+}
+
+// ageCheck grades how long ago ts was; a missing ts is red.
+func ageCheck(ts *time.Time, now time.Time, warn, crit time.Duration) gin.H {
+	if ts == nil {
+		return gin.H{"state": stateRed, "ts": nil, "age_seconds": nil}
+	}
+	age := now.Sub(*ts)
+	state := stateGreen
+	switch {
+	case age > crit:
+		state = stateRed
+	case age > warn:
+		state = stateYellow
+	}
+	return gin.H{"state": state, "ts": ts.UTC().Format(time.RFC3339), "age_seconds": int64(age / time.Second)}
+}
+
+// reportingCheck grades the share of sensors that reported in the last hour.
+func reportingCheck(reporting, total int, th config.StatusThresholds) gin.H {
+	check := gin.H{"reporting": reporting, "total": total, "ratio": nil}
+	if total == 0 {
+		check["state"] = stateRed
+		return check
+	}
+	ratio := float64(reporting) / float64(total)
+	check["ratio"] = ratio
+	switch {
+	case ratio < th.ReportingCrit:
+		check["state"] = stateRed
+	case ratio < th.ReportingWarn:
+		check["state"] = stateYellow
+	default:
+		check["state"] = stateGreen
+	}
+	return check
+}
+
+// ingestCheck grades the latest watcher cycle: red when it failed, yellow
+// before the first one.
+func ingestCheck(run *db.IngestRun) gin.H {
+	if run == nil {
+		return gin.H{"state": stateYellow, "run": nil}
+	}
+	state := stateGreen
+	if !run.Success {
+		state = stateRed
+	}
+	return gin.H{"state": state, "run": run}
+}
+
+// tokenBucket is a minimal token bucket refilled at rate tokens per second
+// up to burst.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst}
+}
+
+// allow takes a token if one is available at now.
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.last.IsZero() {
+		b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
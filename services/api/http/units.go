@@ -0,0 +1,107 @@
+package http
+
+import (
+	encjson "encoding/json"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unitsContextKey holds the rainfall unit a response is rendered in, set by
+// unitsMiddleware on the routes that accept units.
+const unitsContextKey = "units"
+
+// Output units accepted by the units parameter. Storage is always metric.
+const (
+	unitsMM     = "mm"
+	unitsInches = "in"
+)
+
+// mmPerInch converts millimetres to inches.
+const mmPerInch = 25.4
+
+// plainDepthKeys are depth fields of unit-aware responses whose names carry
+// no _mm suffix: the stats summary and the realtime network averages. They
+// are converted in place rather than renamed.
+var plainDepthKeys = map[string]bool{
+	"min": true, "max": true, "mean": true, "sum": true, "p95": true,
+	"3h": true, "6h": true, "12h": true, "24h": true,
+}
+
+// unitsMiddleware resolves the units parameter (mm or in, default mm) and
+// rejects anything else before the handler runs.
+func unitsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		units := strings.ToLower(c.DefaultQuery("units", unitsMM))
+		if units != unitsMM && units != unitsInches {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid units, expected mm or in"})
+			return
+		}
+		c.Set(unitsContextKey, units)
+		c.Next()
+	}
+}
+
+// convertUnits converts a generic JSON document to inches: fields ending in
+// _mm or _mm_h are renamed to _in or _in_h and plainDepthKeys keep their
+// names, all rounded to 3 decimals, and meta.units is set. Free-form sensor
+// metadata is left alone.
+func convertUnits(doc any) {
+	convertDepths(doc)
+	if m, ok := doc.(map[string]any); ok {
+		if meta, ok := m["meta"].(map[string]any); ok {
+			meta["units"] = unitsInches
+		}
+	}
+}
+
+func convertDepths(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		renamed := make(map[string]any)
+		for k, field := range v {
+			if k == "metadata" {
+				continue
+			}
+			switch {
+			case strings.HasSuffix(k, "_mm"):
+				renamed[strings.TrimSuffix(k, "_mm")+"_in"] = toInches(field)
+				delete(v, k)
+			case strings.HasSuffix(k, "_mm_h"):
+				renamed[strings.TrimSuffix(k, "_mm_h")+"_in_h"] = toInches(field)
+				delete(v, k)
+			case plainDepthKeys[k]:
+				v[k] = toInches(field)
+			default:
+				convertDepths(field)
+			}
+		}
+		for k, field := range renamed {
+			v[k] = field
+		}
+	case []any:
+		for _, item := range v {
+			convertDepths(item)
+		}
+	}
+}
+
+// toInches converts a numeric JSON value, or a list of them, from mm.
+// Anything else, e.g. null, is returned unchanged.
+func toInches(v any) any {
+	switch v := v.(type) {
+	case encjson.Number:
+		mm, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		return math.Round(mm/mmPerInch*1000) / 1000
+	case []any:
+		for i, item := range v {
+			v[i] = toInches(item)
+		}
+	}
+	return v
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var deprecatedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "shizuku_api",
+	Name:      "deprecated_requests_total",
+	Help:      "Requests served by legacy v0 endpoints, by route.",
+}, []string{"endpoint"})
+
+// endpointUsage aggregates legacy calls for one route.
+type endpointUsage struct {
+	Endpoint      string    `json:"endpoint"`
+	Count         int64     `json:"count"`
+	UniqueClients int       `json:"unique_clients"`
+	LastSeen      time.Time `json:"last_seen"`
+
+	clients map[string]struct{}
+}
+
+// usageTracker counts calls to deprecated endpoints since process start so we
+// can see who still depends on v0 before the sunset.
+type usageTracker struct {
+	started time.Time
+
+	mu        sync.Mutex
+	endpoints map[string]*endpointUsage
+}
+
+// maxTrackedClients bounds the per-endpoint client set.
+const maxTrackedClients = 10000
+
+var legacyUsage = &usageTracker{started: time.Now().UTC(), endpoints: make(map[string]*endpointUsage)}
+
+// record logs a structured deprecation event and updates the counters.
+func (t *usageTracker) record(c *gin.Context) {
+	endpoint := c.FullPath()
+	ip := c.ClientIP()
+	ua := c.Request.UserAgent()
+
+	log.Printf("deprecated_endpoint_used endpoint=%s path=%s client_ip=%s user_agent=%q", endpoint, c.Request.URL.Path, ip, ua)
+	deprecatedRequests.WithLabelValues(endpoint).Inc()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	u, ok := t.endpoints[endpoint]
+	if !ok {
+		u = &endpointUsage{Endpoint: endpoint, clients: make(map[string]struct{})}
+		t.endpoints[endpoint] = u
+	}
+	u.Count++
+	u.LastSeen = time.Now().UTC()
+	if len(u.clients) < maxTrackedClients {
+		u.clients[ip+"|"+ua] = struct{}{}
+	}
+}
+
+// snapshot returns per-endpoint usage ordered by call count, highest first.
+func (t *usageTracker) snapshot() []endpointUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]endpointUsage, 0, len(t.endpoints))
+	for _, u := range t.endpoints {
+		cp := *u
+		cp.UniqueClients = len(u.clients)
+		cp.clients = nil
+		out = append(out, cp)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	return out
+}
@@ -0,0 +1,325 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/timeutil"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// maxRepairBatch caps how many measurements one repair request may change.
+const maxRepairBatch = 1000
+
+// measurementRepairRequest is one entry of the repair endpoint's body. Exactly
+// one of SetValueMM and SetNull must be given.
+type measurementRepairRequest struct {
+	SensorID   string   `json:"sensor_id"`
+	TS         string   `json:"ts"`
+	SetValueMM *float64 `json:"set_value_mm"`
+	SetNull    bool     `json:"set_null"`
+	Reason     string   `json:"reason"`
+}
+
+// handleV1AdminGridDuplicates lists grid timestamps that have more than one run
+// GET /api/v1/admin/grid/duplicates
+func (s *Server) handleV1AdminGridDuplicates(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	groups, err := s.store.ListDuplicateGridRuns(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": groups,
+		"meta": gin.H{
+			"count": len(groups),
+		},
+	})
+}
+
+// handleV1AdminUsage reports legacy endpoint usage since the process started
+// GET /api/v1/admin/usage
+func (s *Server) handleV1AdminUsage(c *gin.Context) {
+	s.respondJSON(c, gin.H{
+		"data": gin.H{
+			"deprecated": legacyUsage.snapshot(),
+		},
+		"meta": gin.H{
+			"since": formatTimestamp(legacyUsage.started),
+		},
+	})
+}
+
+// handleV1AdminRepairMeasurements corrects or nulls specific stored
+// measurements, auditing each change with its reason
+// PATCH /api/v1/admin/measurements?tz=America/Bogota
+func (s *Server) handleV1AdminRepairMeasurements(c *gin.Context) {
+	var reqs []measurementRepairRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body, expected an array of repairs"})
+		return
+	}
+	if len(reqs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one repair is required"})
+		return
+	}
+	if len(reqs) > maxRepairBatch {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many repairs, maximum is %d", maxRepairBatch)})
+		return
+	}
+
+	// Timestamps without an offset are only accepted when the request
+	// declares the zone they were written in.
+	var zone *time.Location
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tz, expected an IANA zone like America/Bogota"})
+			return
+		}
+		zone = loc
+	}
+
+	repairs := make([]db.MeasurementRepair, 0, len(reqs))
+	for i, req := range reqs {
+		if req.SensorID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repair %d: sensor_id is required", i)})
+			return
+		}
+		ts, err := timeutil.ParseUTC(req.TS, zone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repair %d: %v", i, err)})
+			return
+		}
+		if (req.SetValueMM == nil) == !req.SetNull {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repair %d: exactly one of set_value_mm or set_null is required", i)})
+			return
+		}
+		if req.SetValueMM != nil && *req.SetValueMM < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repair %d: set_value_mm must not be negative", i)})
+			return
+		}
+		reason := strings.TrimSpace(req.Reason)
+		if reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repair %d: reason is required", i)})
+			return
+		}
+		repairs = append(repairs, db.MeasurementRepair{
+			SensorID:  req.SensorID,
+			Timestamp: ts,
+			ValueMM:   req.SetValueMM,
+			Reason:    reason,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	results, err := s.store.RepairMeasurements(ctx, repairs)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	applied := 0
+	for _, r := range results {
+		if r.Status == db.RepairApplied {
+			applied++
+		}
+	}
+	// A batch in which nothing matched most likely names the wrong sensor or
+	// timestamps; mixed batches report not_found per entry instead.
+	if applied == 0 {
+		c.Error(fmt.Errorf("measurements to repair %w", db.ErrNotFound))
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": results,
+		"meta": gin.H{
+			"requested": len(results),
+			"applied":   applied,
+			"not_found": len(results) - applied,
+		},
+	})
+}
+
+// Retention jobs matching up to retentionSyncRows rows run inside the request
+// with retentionSyncTimeout; larger ones continue in the background.
+const (
+	retentionBatchSize    = 5000
+	retentionSyncRows     = 100000
+	retentionSyncTimeout  = 2 * time.Minute
+	retentionAsyncTimeout = 6 * time.Hour
+)
+
+// retentionRequest is the body of the retention endpoint.
+type retentionRequest struct {
+	Table  string `json:"table"`
+	Before string `json:"before"`
+	DryRun bool   `json:"dry_run"`
+}
+
+// handleV1AdminRunRetention deletes rows older than before from a measurement
+// table in bounded batches. Small jobs finish within the request; large ones
+// return 202 with a job to poll. Only one job runs at a time.
+// POST /api/v1/admin/retention/run
+func (s *Server) handleV1AdminRunRetention(c *gin.Context) {
+	var req retentionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+		return
+	}
+	if req.Table == "" || req.Before == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "table and before are required"})
+		return
+	}
+	if !containsString(db.RetentionTables, req.Table) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported table, expected one of: " + strings.Join(db.RetentionTables, ", ")})
+		return
+	}
+	before, err := parseTimestamp(req.Before)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid before timestamp: " + err.Error()})
+		return
+	}
+
+	startCtx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.store.StartRetention(startCtx, req.Table, before, req.DryRun)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if req.DryRun {
+		s.respondJSON(c, gin.H{"data": run.Job})
+		return
+	}
+
+	if run.Job.MatchedRows > retentionSyncRows {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), retentionAsyncTimeout)
+			defer cancel()
+			job, err := run.Run(ctx, retentionBatchSize)
+			if err != nil {
+				log.Printf("retention job %d failed after %d rows: %v", job.ID, job.DeletedRows, err)
+				return
+			}
+			log.Printf("retention job %d deleted %d rows from %s", job.ID, job.DeletedRows, job.Table)
+		}()
+		c.Header("Location", fmt.Sprintf("/api/v1/admin/retention/jobs/%d", run.Job.ID))
+		c.JSON(http.StatusAccepted, gin.H{"data": run.Job})
+		return
+	}
+
+	ctx, cancelRun := context.WithTimeout(c.Request.Context(), retentionSyncTimeout)
+	defer cancelRun()
+	job, err := run.Run(ctx, retentionBatchSize)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	log.Printf("retention job %d deleted %d rows from %s", job.ID, job.DeletedRows, job.Table)
+	s.respondJSON(c, gin.H{"data": job})
+}
+
+// handleV1AdminRetentionJob reports the progress of a retention job
+// GET /api/v1/admin/retention/jobs/:id
+func (s *Server) handleV1AdminRetentionJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	job, err := s.store.GetRetentionJob(ctx, id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{"data": job})
+}
+
+// feedHealthWindow is the span ingest_log cycles and errors are counted over.
+const feedHealthWindow = 24 * time.Hour
+
+// handleV1AdminFeeds lists the feeds the watcher ingests with their health:
+// credentials are redacted from URLs, last_success_age_seconds is derived
+// from the last successful cycle and error counts cover the last 24 hours.
+// GET /api/v1/admin/feeds
+func (s *Server) handleV1AdminFeeds(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	feeds, err := s.store.ListFeeds(ctx, now.Add(-feedHealthWindow))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	data := make([]gin.H, 0, len(feeds))
+	for _, f := range feeds {
+		var age *int64
+		if f.LastSuccessAt != nil {
+			secs := int64(now.Sub(*f.LastSuccessAt) / time.Second)
+			age = &secs
+		}
+		data = append(data, gin.H{
+			"name":                     f.Name,
+			"url":                      redactURL(f.URL),
+			"network":                  f.Network,
+			"cadence_seconds":          f.CadenceSeconds,
+			"last_success_at":          f.LastSuccessAt,
+			"last_success_age_seconds": age,
+			"last_error_at":            f.LastErrorAt,
+			"last_error":               f.LastError,
+			"registered_at":            f.RegisteredAt,
+			"cycles_24h":               f.Cycles,
+			"errors_24h":               f.Errors,
+		})
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": data,
+		"meta": gin.H{
+			"count":        len(data),
+			"generated_at": formatTimestamp(now),
+		},
+	})
+}
+
+// redactURL hides the password and credential-like query parameters of a
+// feed URL.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[invalid]"
+	}
+	q := u.Query()
+	for key := range q {
+		switch strings.ToLower(key) {
+		case "token", "key", "apikey", "api_key", "access_token", "password", "secret":
+			q.Set(key, "xxxxx")
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.Redacted()
+}
@@ -0,0 +1,52 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRepairMeasurementsRejectsInvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	// Validation runs before the store is touched, so no store is needed.
+	s := &Server{}
+	engine := gin.New()
+	engine.Use(errorMiddleware())
+	engine.PATCH("/api/v1/admin/measurements", s.handleV1AdminRepairMeasurements)
+
+	tooMany := "[" + strings.Repeat(`{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"r"},`, maxRepairBatch) +
+		`{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"r"}]`
+
+	for name, tc := range map[string]struct {
+		query, body, wantErr string
+	}{
+		"not an array":    {body: `{"sensor_id":"a"}`, wantErr: "expected an array"},
+		"empty":           {body: `[]`, wantErr: "at least one repair"},
+		"too many":        {body: tooMany, wantErr: fmt.Sprintf("maximum is %d", maxRepairBatch)},
+		"bad tz":          {query: "?tz=Mars/Olympus", body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00","set_null":true,"reason":"r"}]`, wantErr: "invalid tz"},
+		"no sensor":       {body: `[{"ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"r"}]`, wantErr: "sensor_id is required"},
+		"naive ts":        {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00","set_null":true,"reason":"r"}]`, wantErr: "repair 0:"},
+		"both set":        {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_value_mm":1,"set_null":true,"reason":"r"}]`, wantErr: "exactly one of"},
+		"neither set":     {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","reason":"r"}]`, wantErr: "exactly one of"},
+		"negative value":  {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_value_mm":-1,"reason":"r"}]`, wantErr: "must not be negative"},
+		"blank reason":    {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"  "}]`, wantErr: "reason is required"},
+		"second is wrong": {body: `[{"sensor_id":"a","ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"r"},{"sensor_id":"","ts":"2025-10-01T12:00:00Z","set_null":true,"reason":"r"}]`, wantErr: "repair 1: sensor_id"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/admin/measurements"+tc.query, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			engine.ServeHTTP(rec, req)
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantErr) {
+				t.Errorf("body %s does not mention %q", rec.Body.String(), tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// maxBatchSensors caps how many sensors a single batch request may name.
+const maxBatchSensors = 20
+
+// batchStore is the part of the store a batch request reads; tests
+// substitute a fake to make individual sensors fail.
+type batchStore interface {
+	GetSensor(ctx context.Context, sensorID string) (*db.Sensor, error)
+	HasMeasurements(ctx context.Context, q db.MeasurementQuery) (bool, error)
+	FetchMeasurements(ctx context.Context, q db.MeasurementQuery) ([]db.Measurement, error)
+}
+
+// batchQuery is the per-sensor query shared by every sensor of a batch.
+type batchQuery struct {
+	mode     cleanMode
+	variable string
+	start    time.Time
+	end      time.Time
+	// limit caps each sensor's series; newest keeps the latest rows of the
+	// window instead of the earliest.
+	limit  int
+	newest bool
+}
+
+// batchResult is the outcome of fetchBatch.
+type batchResult struct {
+	results           []sensorResult
+	warnings          []sensorWarning
+	cleanBySensor     map[string]bool
+	truncatedBySensor map[string]bool
+}
+
+// parseBatchLimit reads limit (the earliest rows of each series) or last_n
+// (the latest), defaulting to limit=def. Both are capped at maxRows.
+func parseBatchLimit(c *gin.Context, def, maxRows int) (int, bool, error) {
+	limitStr, lastNStr := c.Query("limit"), c.Query("last_n")
+	if limitStr != "" && lastNStr != "" {
+		return 0, false, errors.New("limit and last_n are mutually exclusive")
+	}
+	raw, newest, name := limitStr, false, "limit"
+	if lastNStr != "" {
+		raw, newest, name = lastNStr, true, "last_n"
+	}
+	if raw == "" {
+		return clampLimit(def, maxRows), false, nil
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil || val <= 0 {
+		return 0, false, errors.New("invalid " + name)
+	}
+	return clampLimit(val, maxRows), newest, nil
+}
+
+// fetchBatch reads one series per sensor through fanOutSensors. Each series
+// is fetched with one row beyond the limit so a cut series can be reported
+// in truncatedBySensor; series are always returned in chronological order.
+func fetchBatch(ctx context.Context, store batchStore, ids []string, bq batchQuery) (batchResult, error) {
+	var mu sync.Mutex
+	cleanBySensor := make(map[string]bool, len(ids))
+	truncatedBySensor := make(map[string]bool, len(ids))
+	results, warnings, err := fanOutSensors(ctx, ids, func(ctx context.Context, sensorID string) (any, error) {
+		if _, err := store.GetSensor(ctx, sensorID); err != nil {
+			return nil, err
+		}
+		start, end := bq.start, bq.end
+		q := db.MeasurementQuery{
+			SensorID:   sensorID,
+			Limit:      bq.limit + 1,
+			Since:      &start,
+			Until:      &end,
+			Variable:   bq.variable,
+			Descending: bq.newest,
+		}
+		useClean, err := useCleanWith(ctx, store, bq.mode, q)
+		if err != nil {
+			return nil, err
+		}
+		q.UseClean = useClean
+		rows, err := store.FetchMeasurements(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		truncated := len(rows) > bq.limit
+		if truncated {
+			rows = rows[:bq.limit]
+		}
+		if bq.newest {
+			slices.Reverse(rows)
+		}
+		mu.Lock()
+		cleanBySensor[sensorID] = useClean
+		truncatedBySensor[sensorID] = truncated
+		mu.Unlock()
+		return rows, nil
+	})
+	if err != nil {
+		return batchResult{}, err
+	}
+	return batchResult{
+		results:           results,
+		warnings:          warnings,
+		cleanBySensor:     cleanBySensor,
+		truncatedBySensor: truncatedBySensor,
+	}, nil
+}
+
+// maxBucket is the widest bucket a series can be summed into.
+const maxBucket = 24 * time.Hour
+
+// measurementBucket is the rainfall summed over one bucket of a series.
+type measurementBucket struct {
+	Timestamp time.Time `json:"ts"`
+	// ValueMM is null when no reading in the bucket had a value.
+	ValueMM  *float64 `json:"value_mm"`
+	Readings int      `json:"readings"`
+}
+
+// parseBucket reads the optional bucket width, a multiple of the SIATA
+// reporting interval up to a day; 0 means no bucketing.
+func parseBucket(c *gin.Context) (time.Duration, error) {
+	raw := c.Query("bucket")
+	if raw == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 || d > maxBucket || d%units.SIATAInterval != 0 {
+		return 0, errors.New("invalid bucket, expected a multiple of 5m up to 24h")
+	}
+	return d, nil
+}
+
+// bucketSeries sums a chronological series into buckets of width d aligned
+// to UTC midnight. Buckets without readings are left out.
+func bucketSeries(rows []db.Measurement, d time.Duration) []measurementBucket {
+	out := make([]measurementBucket, 0)
+	for _, m := range rows {
+		start := m.Timestamp.UTC().Truncate(d)
+		if n := len(out); n == 0 || !out[n-1].Timestamp.Equal(start) {
+			out = append(out, measurementBucket{Timestamp: start})
+		}
+		b := &out[len(out)-1]
+		if m.ValueMM == nil {
+			continue
+		}
+		sum := *m.ValueMM
+		if b.ValueMM != nil {
+			sum += *b.ValueMM
+		}
+		b.ValueMM = &sum
+		b.Readings++
+	}
+	return out
+}
+
+// parseSensorIDs reads a comma-separated ids parameter, de-duplicating while
+// preserving order.
+func parseSensorIDs(raw string) []string {
+	seen := make(map[string]bool)
+	ids := make([]string, 0)
+	for _, id := range strings.Split(raw, ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// handleV1BatchMeasurements returns measurement series for several sensors,
+// keeping successful series when individual sensors fail. With bucket, each
+// series is summed into buckets of that width after the row limit applies
+// GET /api/v1/core/measurements?ids=a,b,c&start=...&end=...&limit=...|last_n=...&bucket=1h&clean=true|false|auto&variable=precipitacion&no_coverage=false
+func (s *Server) handleV1BatchMeasurements(c *gin.Context) {
+	ids := parseSensorIDs(c.Query("ids"))
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids query parameter is required"})
+		return
+	}
+	if len(ids) > maxBatchSensors {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many sensors requested, maximum is " + strconv.Itoa(maxBatchSensors)})
+		return
+	}
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable, err := parseVariable(c, mode.strict())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	noCoverage, err := parseNoCoverage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	limit, newest, err := parseBatchLimit(c, s.cfg.DefaultLimit, s.cfg.MaxRows)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bucket, err := parseBucket(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	batch, err := fetchBatch(ctx, s.store, ids, batchQuery{
+		mode:     mode,
+		variable: variable,
+		start:    *rng.Start,
+		end:      rng.End,
+		limit:    limit,
+		newest:   newest,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	results, warnings, cleanBySensor := batch.results, batch.warnings, batch.cleanBySensor
+	if bucket > 0 {
+		for i := range results {
+			results[i].Data = bucketSeries(results[i].Data.([]db.Measurement), bucket)
+		}
+	}
+
+	meta := gin.H{
+		"requested":           len(ids),
+		"returned":            len(results),
+		"clean_mode":          mode,
+		"variable":            variable,
+		"start":               formatTimestamp(*rng.Start),
+		"end":                 formatTimestamp(rng.End),
+		"truncated_by_sensor": batch.truncatedBySensor,
+	}
+	if bucket > 0 {
+		meta["bucket"] = bucket.String()
+	}
+	if newest {
+		meta["last_n"] = limit
+	} else {
+		meta["limit"] = limit
+	}
+	if mode == cleanAuto {
+		meta["clean_by_sensor"] = cleanBySensor
+	} else {
+		meta["clean"] = mode.strict()
+	}
+
+	if !noCoverage {
+		// Clean data lags raw; report how far QC has processed each sensor
+		// read from the clean table so clients can tell a backlog from a
+		// dry spell.
+		cleanIDs := make([]string, 0, len(cleanBySensor))
+		for _, id := range ids {
+			if cleanBySensor[id] {
+				cleanIDs = append(cleanIDs, id)
+			}
+		}
+		if len(cleanIDs) > 0 {
+			coverage, err := s.store.CleanCoverageUntil(ctx, cleanIDs)
+			if err != nil {
+				c.Error(err)
+				return
+			}
+			until := make(map[string]*time.Time, len(cleanIDs))
+			for _, id := range cleanIDs {
+				if ts, ok := coverage[id]; ok {
+					until[id] = &ts
+				} else {
+					until[id] = nil
+				}
+			}
+			meta["clean_coverage_until"] = until
+		}
+	}
+
+	s.respondJSON(c, gin.H{
+		"data":     results,
+		"partial":  len(warnings) > 0,
+		"warnings": warnings,
+		"meta":     meta,
+	})
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// fakeBatchStore serves hourly rows per sensor from memory. Sensors listed
+// in fail return that error from FetchMeasurements.
+type fakeBatchStore struct {
+	rows map[string][]db.Measurement
+	fail map[string]error
+}
+
+func (f *fakeBatchStore) GetSensor(_ context.Context, sensorID string) (*db.Sensor, error) {
+	if _, ok := f.rows[sensorID]; !ok {
+		if _, ok := f.fail[sensorID]; !ok {
+			return nil, db.ErrNotFound
+		}
+	}
+	return &db.Sensor{ID: sensorID}, nil
+}
+
+func (f *fakeBatchStore) HasMeasurements(_ context.Context, q db.MeasurementQuery) (bool, error) {
+	return len(f.rows[q.SensorID]) > 0, nil
+}
+
+func (f *fakeBatchStore) FetchMeasurements(_ context.Context, q db.MeasurementQuery) ([]db.Measurement, error) {
+	if err, ok := f.fail[q.SensorID]; ok {
+		return nil, err
+	}
+	rows := slices.Clone(f.rows[q.SensorID])
+	if q.Descending {
+		slices.Reverse(rows)
+	}
+	if q.Limit > 0 && len(rows) > q.Limit {
+		rows = rows[:q.Limit]
+	}
+	return rows, nil
+}
+
+var batchT0 = time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func hourlyRows(sensorID string, n int) []db.Measurement {
+	rows := make([]db.Measurement, n)
+	for i := range rows {
+		v := float64(i)
+		rows[i] = db.Measurement{ID: int64(i + 1), SensorID: sensorID, Timestamp: batchT0.Add(time.Duration(i) * time.Hour), ValueMM: &v}
+	}
+	return rows
+}
+
+func timestamps(rows []db.Measurement) []time.Time {
+	out := make([]time.Time, len(rows))
+	for i, m := range rows {
+		out[i] = m.Timestamp
+	}
+	return out
+}
+
+func TestFetchBatchIsolatesFailingSensor(t *testing.T) {
+	store := &fakeBatchStore{
+		rows: map[string][]db.Measurement{"a": hourlyRows("a", 3), "c": hourlyRows("c", 2)},
+		fail: map[string]error{"b": errors.New("statement timeout")},
+	}
+	batch, err := fetchBatch(context.Background(), store, []string{"a", "b", "c", "missing"}, batchQuery{
+		mode: cleanFalse, start: batchT0, end: batchT0.Add(24 * time.Hour), limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, r := range batch.results {
+		got = append(got, r.SensorID)
+	}
+	if want := []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Errorf("results: got %v, want %v", got, want)
+	}
+	if len(batch.warnings) != 2 {
+		t.Fatalf("warnings: got %+v, want b and missing", batch.warnings)
+	}
+	if w := batch.warnings[0]; w.SensorID != "b" || w.Code != "internal" {
+		t.Errorf("warning for b: got %+v", w)
+	}
+	if w := batch.warnings[1]; w.SensorID != "missing" || w.Code != "not_found" {
+		t.Errorf("warning for missing: got %+v", w)
+	}
+	if _, ok := batch.truncatedBySensor["b"]; ok {
+		t.Error("failed sensor reported in truncated_by_sensor")
+	}
+}
+
+func TestFetchBatchUnavailableIsFatal(t *testing.T) {
+	store := &fakeBatchStore{
+		rows: map[string][]db.Measurement{"a": hourlyRows("a", 3)},
+		fail: map[string]error{"b": db.ErrUnavailable},
+	}
+	_, err := fetchBatch(context.Background(), store, []string{"a", "b"}, batchQuery{mode: cleanFalse, limit: 10})
+	if !errors.Is(err, db.ErrUnavailable) {
+		t.Errorf("got %v, want db.ErrUnavailable", err)
+	}
+}
+
+func TestFetchBatchTruncation(t *testing.T) {
+	store := &fakeBatchStore{rows: map[string][]db.Measurement{
+		"long":  hourlyRows("long", 5),
+		"exact": hourlyRows("exact", 3),
+	}}
+	ids := []string{"long", "exact"}
+
+	for _, tc := range []struct {
+		name   string
+		newest bool
+		want   []time.Time
+	}{
+		{"limit keeps the earliest rows", false, timestamps(hourlyRows("long", 3))},
+		{"last_n keeps the latest rows in order", true, timestamps(hourlyRows("long", 5)[2:])},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			batch, err := fetchBatch(context.Background(), store, ids, batchQuery{mode: cleanFalse, limit: 3, newest: tc.newest})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := timestamps(batch.results[0].Data.([]db.Measurement)); !slices.Equal(got, tc.want) {
+				t.Errorf("long: got %v, want %v", got, tc.want)
+			}
+			if !batch.truncatedBySensor["long"] {
+				t.Error("long: not reported as truncated")
+			}
+			if batch.truncatedBySensor["exact"] {
+				t.Error("exact: a series of exactly limit rows reported as truncated")
+			}
+		})
+	}
+}
+
+func TestParseBatchLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		query      string
+		wantLimit  int
+		wantNewest bool
+		wantErr    bool
+	}{
+		{"", 100, false, false},
+		{"limit=20", 20, false, false},
+		{"last_n=20", 20, true, false},
+		{"limit=5000", 1000, false, false},
+		{"limit=0", 0, false, true},
+		{"last_n=x", 0, false, true},
+		{"limit=5&last_n=5", 0, false, true},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/?"+tc.query, nil)
+		limit, newest, err := parseBatchLimit(c, 100, 1000)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: err = %v, want error %t", tc.query, err, tc.wantErr)
+			continue
+		}
+		if limit != tc.wantLimit || newest != tc.wantNewest {
+			t.Errorf("%q: got (%d, %t), want (%d, %t)", tc.query, limit, newest, tc.wantLimit, tc.wantNewest)
+		}
+	}
+}
+
+func TestBucketSeries(t *testing.T) {
+	v := func(f float64) *float64 { return &f }
+	at := func(min int) time.Time { return batchT0.Add(time.Duration(min) * time.Minute) }
+	rows := []db.Measurement{
+		{Timestamp: at(0), ValueMM: v(0.2)},
+		{Timestamp: at(5), ValueMM: v(0.3)},
+		{Timestamp: at(55), ValueMM: nil},
+		{Timestamp: at(60), ValueMM: nil},
+		{Timestamp: at(185), ValueMM: v(1)},
+	}
+	got := bucketSeries(rows, time.Hour)
+	if len(got) != 3 {
+		t.Fatalf("got %d buckets, want 3: %+v", len(got), got)
+	}
+	if !got[0].Timestamp.Equal(at(0)) || got[0].ValueMM == nil || *got[0].ValueMM != 0.5 || got[0].Readings != 2 {
+		t.Errorf("first bucket: got %+v, want 0.5 mm from 2 readings at %v", got[0], at(0))
+	}
+	if got[1].ValueMM != nil || got[1].Readings != 0 {
+		t.Errorf("bucket of null readings: got %+v, want a null value", got[1])
+	}
+	if !got[2].Timestamp.Equal(at(180)) || *got[2].ValueMM != 1 {
+		t.Errorf("last bucket: got %+v, want 1 mm at %v", got[2], at(180))
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/errgroup"
+)
+
+// bootstrapSection builds one section of the bootstrap document.
+type bootstrapSection func(ctx context.Context) (gin.H, error)
+
+// handleV1Bootstrap returns everything the dashboard needs on first paint in
+// one document. Sections are built concurrently under a shared deadline and
+// each has exactly the shape of its standalone endpoint:
+//
+//   - sensors: GET /api/v1/core/sensors
+//   - realtime: GET /api/v1/realtime/now
+//   - averages: GET /api/v1/dashboard/summary (data)
+//   - grid_timestamps: GET /api/v1/grid/timestamps (page 1)
+//   - facets: GET /api/v1/core/sources over the last API_DEFAULT_DAYS
+//
+// A failing section is replaced by {"error", "code"} while the others are
+// still returned.
+// GET /api/v1/bootstrap
+func (s *Server) handleV1Bootstrap(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	facetsStart := now.AddDate(0, 0, -s.cfg.DefaultDays)
+	sections := map[string]bootstrapSection{
+		"sensors": func(ctx context.Context) (gin.H, error) {
+			return s.sensorsDocument(ctx, nil)
+		},
+		"realtime": func(ctx context.Context) (gin.H, error) {
+			return s.realtimeNowDocument(ctx, false, nil)
+		},
+		"averages": s.dashboardSummaryDocument,
+		"grid_timestamps": func(ctx context.Context) (gin.H, error) {
+			return s.gridTimestampsDocument(ctx, 1, 20, nil, nil, nil, false)
+		},
+		"facets": func(ctx context.Context) (gin.H, error) {
+			return s.networkSourcesDocument(ctx, timeRange{Start: &facetsStart, End: now})
+		},
+	}
+
+	var (
+		mu  sync.Mutex
+		doc = make(gin.H, len(sections))
+		g   errgroup.Group
+	)
+	for name, build := range sections {
+		g.Go(func() error {
+			section, err := build(ctx)
+			if err != nil {
+				_, code := statusForError(err)
+				section = gin.H{"error": err.Error(), "code": code}
+			}
+			mu.Lock()
+			doc[name] = section
+			mu.Unlock()
+			// Section failures are reported inline and never cancel the others.
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	doc["generated_at"] = formatTimestamp(now)
+	// Short enough that realtime data stays fresh, long enough to absorb
+	// reload bursts.
+	c.Header("Cache-Control", "public, max-age=5")
+	s.respondJSON(c, doc)
+}
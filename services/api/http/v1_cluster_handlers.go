@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/geohash"
+)
+
+// Geohash precision bounds for sensor clusters: 3 (~156 km cells) to 8
+// (~38 m cells).
+const (
+	minClusterPrecision     = 3
+	maxClusterPrecision     = 8
+	defaultClusterPrecision = 5
+)
+
+// sensorCluster is one geohash cell holding at least one sensor.
+type sensorCluster struct {
+	Geohash     string   `json:"geohash"`
+	Lat         float64  `json:"lat"`
+	Lon         float64  `json:"lon"`
+	SensorCount int      `json:"sensor_count"`
+	SensorID    *string  `json:"sensor_id,omitempty"`
+	LatestMean  *float64 `json:"latest_mean_mm,omitempty"`
+	LatestCount *int     `json:"latest_count,omitempty"`
+
+	latestSum float64
+}
+
+// handleV1SensorClusters groups sensors by geohash cell for low zoom levels.
+// Each cluster carries the centroid of its sensors, their count and, for a
+// single-sensor cell, the sensor id. include=latest adds the mean latest clean
+// value of the cell's sensors that have one.
+// GET /api/v1/core/sensors/clusters?bbox=min_lon,min_lat,max_lon,max_lat&precision=5&include=latest
+func (s *Server) handleV1SensorClusters(c *gin.Context) {
+	precision := defaultClusterPrecision
+	if v := c.Query("precision"); v != "" {
+		p, err := strconv.Atoi(v)
+		if err != nil || p < minClusterPrecision || p > maxClusterPrecision {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid precision, expected an integer between 3 and 8"})
+			return
+		}
+		precision = p
+	}
+
+	bbox, err := parseBBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	include, err := parseInclude(c, "latest")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sensors, err := s.store.ListSensors(ctx, bbox)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var latest map[string]float64
+	if include["latest"] {
+		rows, err := s.store.LatestClean(ctx, bbox)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		latest = make(map[string]float64, len(rows))
+		for _, m := range rows {
+			if m.ValueMM != nil {
+				latest[m.SensorID] = *m.ValueMM
+			}
+		}
+	}
+
+	byHash := make(map[string]*sensorCluster)
+	for _, sensor := range sensors {
+		hash := geohash.Encode(sensor.Lat, sensor.Lon, precision)
+		cl, ok := byHash[hash]
+		if !ok {
+			cl = &sensorCluster{Geohash: hash}
+			byHash[hash] = cl
+		}
+		// Accumulate sums; they become means below.
+		cl.Lat += sensor.Lat
+		cl.Lon += sensor.Lon
+		cl.SensorCount++
+		if cl.SensorCount == 1 {
+			id := sensor.ID
+			cl.SensorID = &id
+		} else {
+			cl.SensorID = nil
+		}
+		if v, ok := latest[sensor.ID]; ok {
+			if cl.LatestCount == nil {
+				cl.LatestCount = new(int)
+			}
+			*cl.LatestCount++
+			cl.latestSum += v
+		}
+	}
+
+	clusters := make([]sensorCluster, 0, len(byHash))
+	for _, cl := range byHash {
+		cl.Lat /= float64(cl.SensorCount)
+		cl.Lon /= float64(cl.SensorCount)
+		if cl.LatestCount != nil {
+			mean := cl.latestSum / float64(*cl.LatestCount)
+			cl.LatestMean = &mean
+		}
+		clusters = append(clusters, *cl)
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].Geohash < clusters[j].Geohash })
+
+	meta := gin.H{
+		"precision": precision,
+		"count":     len(clusters),
+	}
+	if bbox != nil {
+		meta["bbox"] = bbox
+	}
+	s.respondJSON(c, gin.H{
+		"data": clusters,
+		"meta": meta,
+	})
+}
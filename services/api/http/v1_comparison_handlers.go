@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/rainfall"
+)
+
+// comparisonPeriods maps the accepted period values to calendar days.
+var comparisonPeriods = map[string]int{"1d": 1, "7d": 7, "30d": 30}
+
+// handleV1Comparison compares clean rainfall totals of the current period
+// with the preceding one, network-wide and per city. Periods are local
+// calendar days in tz; an unfinished current period is compared against the
+// same elapsed slice of the previous period.
+// GET /api/v1/core/comparison?period=7d&tz=America/Bogota
+func (s *Server) handleV1Comparison(c *gin.Context) {
+	period := c.DefaultQuery("period", "7d")
+	days, ok := comparisonPeriods[period]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid period, expected 1d, 7d or 30d"})
+		return
+	}
+
+	loc, err := parseLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	cur, prev := rainfall.ComparisonWindows(time.Now(), days, loc)
+	totals, err := s.store.CompareCityTotals(ctx, cur.Start, cur.End, prev.Start, prev.End)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var netCur, netPrev float64
+	var sensorsCur, sensorsPrev int
+	cities := make([]gin.H, 0, len(totals))
+	for _, t := range totals {
+		netCur += t.CurrentMM
+		netPrev += t.PreviousMM
+		sensorsCur += t.CurrentSensors
+		sensorsPrev += t.PreviousSensors
+
+		var city *string
+		if t.City != "" {
+			city = &t.City
+		}
+		cities = append(cities, gin.H{
+			"city":             city,
+			"current_mm":       t.CurrentMM,
+			"previous_mm":      t.PreviousMM,
+			"change_pct":       rainfall.PercentChange(t.CurrentMM, t.PreviousMM),
+			"current_sensors":  t.CurrentSensors,
+			"previous_sensors": t.PreviousSensors,
+		})
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": gin.H{
+			"network": gin.H{
+				"current_mm":       netCur,
+				"previous_mm":      netPrev,
+				"change_pct":       rainfall.PercentChange(netCur, netPrev),
+				"current_sensors":  sensorsCur,
+				"previous_sensors": sensorsPrev,
+			},
+			"cities": cities,
+		},
+		"meta": gin.H{
+			"period":   period,
+			"tz":       loc.String(),
+			"current":  cur,
+			"previous": prev,
+		},
+	})
+}
@@ -0,0 +1,123 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/rainfall"
+)
+
+// Sensor context methods: a sensor with at least contextMinYears of history is
+// compared with the same calendar day of earlier years; a shorter history is
+// widened to days within contextWindowDays of it.
+const (
+	contextMinYears   = 2
+	contextWindowDays = 7
+
+	contextMethodCalendarDay = "calendar_day"
+	contextMethodWindow      = "calendar_window"
+)
+
+// handleV1SensorContext compares a sensor's clean total for one local day with
+// the distribution of its totals on the same calendar day in earlier years:
+// p10/p50/p90 and the percentile rank of the day. The day defaults to today
+// in tz; a day still in progress counts up to now.
+// GET /api/v1/core/sensors/:id/context?date=2024-05-01&tz=America/Bogota
+func (s *Server) handleV1SensorContext(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	loc, err := parseLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now().In(loc)
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if v := c.Query("date"); v != "" {
+		d, err := time.ParseInLocation(time.DateOnly, v, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date, expected YYYY-MM-DD"})
+			return
+		}
+		if d.After(now) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "date must not be in the future"})
+			return
+		}
+		day = d
+	}
+	end := day.AddDate(0, 0, 1)
+	if end.After(now) {
+		end = now
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	availability, err := s.store.GetSensorAvailability(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	method, window := contextMethodCalendarDay, 0
+	first := availability.Clean.FirstTS
+	if first == nil || first.After(day.AddDate(-contextMinYears, 0, 0)) {
+		method, window = contextMethodWindow, contextWindowDays
+	}
+
+	total, count, err := s.store.SensorDayTotal(ctx, sensorID, day, end)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	history, err := s.store.SensorCalendarDayTotals(ctx, sensorID, day, loc.String(), window)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	values := make([]float64, len(history))
+	years := make(map[int]bool)
+	for i, h := range history {
+		values[i] = h.TotalMM
+		years[h.Date.Year()] = true
+	}
+
+	var rank *float64
+	if total != nil {
+		rank = rainfall.PercentileRank(values, *total)
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": gin.H{
+			"date":              day.Format(time.DateOnly),
+			"total_mm":          total,
+			"measurement_count": count,
+			"partial":           end.Before(day.AddDate(0, 0, 1)),
+			"historical": gin.H{
+				"p10":     rainfall.Percentile(values, 10),
+				"p50":     rainfall.Percentile(values, 50),
+				"p90":     rainfall.Percentile(values, 90),
+				"samples": len(values),
+				"years":   len(years),
+			},
+			"percentile_rank": rank,
+		},
+		"meta": gin.H{
+			"sensor_id":   sensorID,
+			"tz":          loc.String(),
+			"method":      method,
+			"window_days": window,
+		},
+	})
+}
@@ -2,34 +2,291 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// maxSensorQueryLen bounds the sensor search term.
+const maxSensorQueryLen = 100
+
+// Sensor list pages default to one large enough for the whole network, so
+// clients that never page keep getting every sensor.
+const (
+	defaultSensorPageLimit = 500
+	maxSensorPageLimit     = 1000
+)
+
+// Page sizes of the sensor change history.
+const (
+	defaultSensorChangesLimit = 100
+	maxSensorChangesLimit     = 1000
+)
+
+// Result sizes of the nearest-sensors lookup; larger limits are capped.
+const (
+	defaultNearestLimit = 5
+	maxNearestLimit     = 50
 )
 
-// handleV1ListSensors returns all sensors
-// GET /api/v1/core/sensors
+// handleV1ListSensors returns one page of sensors ordered by id, optionally
+// only those inside bbox, using the pagination envelope of the grid
+// timestamps listing, or a GeoJSON FeatureCollection with format=geojson. A
+// non-empty q searches names, barrios and cities instead, ignoring case and
+// accents; matches come best first with the field that matched, unpaged.
+// GET /api/v1/core/sensors?bbox=min_lon,min_lat,max_lon,max_lat&page=1&limit=500&format=geojson&q=estrella
 func (s *Server) handleV1ListSensors(c *gin.Context) {
+	switch c.Query("format") {
+	case "", "json":
+	case "geojson":
+		s.handleV1SensorsGeoJSON(c)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, expected json or geojson"})
+		return
+	}
+
+	bbox, err := parseBBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q, err := parseSensorQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil && val > 0 {
+			page = val
+		}
+	}
+
+	limit := defaultSensorPageLimit
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 && val <= maxSensorPageLimit {
+			limit = val
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if q != "" {
+		matches, err := s.store.SearchSensors(ctx, q, bbox)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		meta := gin.H{
+			"count": len(matches),
+			"q":     q,
+		}
+		if bbox != nil {
+			meta["bbox"] = bbox
+		}
+		s.respondJSON(c, gin.H{
+			"data": matches,
+			"meta": meta,
+		})
+		return
+	}
+
+	sensors, total, err := s.store.ListSensorsPage(ctx, bbox, limit, (page-1)*limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	meta := gin.H{
+		"count": len(sensors),
+	}
+	if bbox != nil {
+		meta["bbox"] = bbox
+	}
+	s.respondJSON(c, gin.H{
+		"data": sensors,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total_count": total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+		"meta": meta,
+	})
+}
+
+// parseSensorQuery reads the sensor search term q, empty when not searching.
+func parseSensorQuery(c *gin.Context) (string, error) {
+	q := strings.TrimSpace(c.Query("q"))
+	if utf8.RuneCountInString(q) > maxSensorQueryLen {
+		return "", fmt.Errorf("q must be at most %d characters", maxSensorQueryLen)
+	}
+	return q, nil
+}
+
+// handleV1SensorsGeoJSON returns all sensors as a GeoJSON FeatureCollection of
+// Points. Sensors at 0,0 (no known position) are left out so they do not
+// render at Null Island; excluded_sensors counts them. q searches as on the
+// JSON listing and adds a match property.
+// GET /api/v1/core/sensors.geojson?bbox=min_lon,min_lat,max_lon,max_lat&q=estrella
+func (s *Server) handleV1SensorsGeoJSON(c *gin.Context) {
+	bbox, err := parseBBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q, err := parseSensorQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var matches []db.SensorMatch
+	if q != "" {
+		matches, err = s.store.SearchSensors(ctx, q, bbox)
+	} else {
+		var sensors []db.Sensor
+		sensors, err = s.store.ListSensors(ctx, bbox)
+		for _, sensor := range sensors {
+			matches = append(matches, db.SensorMatch{Sensor: sensor})
+		}
+	}
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	fc := newFeatureCollection(len(matches))
+	excluded := 0
+	for _, m := range matches {
+		if atNullIsland(m.Lat, m.Lon) {
+			excluded++
+			continue
+		}
+		f := sensorFeature(m.Sensor)
+		if m.Match != "" {
+			f.Properties["match"] = m.Match
+		}
+		fc.Features = append(fc.Features, f)
+	}
+
+	c.Header("Content-Type", geoJSONContentType)
+	s.respondJSON(c, &struct {
+		featureCollection
+		ExcludedSensors int `json:"excluded_sensors"`
+	}{fc, excluded})
+}
+
+// handleV1SensorsInBBox returns the sensors inside a viewport rectangle, for
+// clients that load sensors as the map moves. All four bounds are required.
+// GET /api/v1/core/sensors/bbox?min_lon=-75.7&min_lat=6.1&max_lon=-75.4&max_lat=6.4
+func (s *Server) handleV1SensorsInBBox(c *gin.Context) {
+	bbox, err := parseBBoxParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
-	sensors, err := s.store.ListSensors(ctx)
+	sensors, err := s.store.ListSensorsInBBox(ctx, bbox)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	s.respondJSON(c, gin.H{
 		"data": sensors,
 		"meta": gin.H{
 			"count": len(sensors),
+			"bbox":  bbox,
+		},
+	})
+}
+
+// handleV1NearestSensors returns the sensors closest to a point by
+// great-circle distance, nearest first, each with distance_km.
+// GET /api/v1/core/sensors/nearest?lat=6.25&lon=-75.57&limit=5
+func (s *Server) handleV1NearestSensors(c *gin.Context) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat is required and must be within [-90, 90]"})
+		return
+	}
+	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lon is required and must be within [-180, 180]"})
+		return
+	}
+
+	limit := defaultNearestLimit
+	if l := c.Query("limit"); l != "" {
+		val, err := strconv.Atoi(l)
+		if err != nil || val <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(val, maxNearestLimit)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sensors, err := s.store.NearestSensors(ctx, lat, lon, limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": sensors,
+		"meta": gin.H{
+			"count":  len(sensors),
+			"origin": gin.H{"lat": lat, "lon": lon},
+			"limit":  limit,
 		},
 	})
 }
 
-// handleV1GetSensor returns details for a specific sensor
-// GET /api/v1/core/sensors/:id
+// sensorsDocument builds the unpaged sensor list embedded by the bootstrap
+// endpoint.
+func (s *Server) sensorsDocument(ctx context.Context, bbox *db.BBox) (gin.H, error) {
+	sensors, err := s.store.ListSensors(ctx, bbox)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := gin.H{
+		"count": len(sensors),
+	}
+	if bbox != nil {
+		meta["bbox"] = bbox
+	}
+	return gin.H{
+		"data": sensors,
+		"meta": meta,
+	}, nil
+}
+
+// handleV1GetSensor returns details for a specific sensor. include=quality
+// adds current_quality, the latest non-null raw quality score.
+// GET /api/v1/core/sensors/:id?include=quality
 func (s *Server) handleV1GetSensor(c *gin.Context) {
 	sensorID := c.Param("id")
 	if sensorID == "" {
@@ -37,21 +294,389 @@ func (s *Server) handleV1GetSensor(c *gin.Context) {
 		return
 	}
 
+	include, err := parseInclude(c, "quality")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
 
 	sensor, err := s.store.GetSensor(ctx, sensorID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if sensor == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "sensor not found"})
+	if !include["quality"] {
+		s.respondJSON(c, gin.H{
+			"data": sensor,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": sensor,
+	quality, err := s.store.CurrentQuality(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	s.respondJSON(c, gin.H{
+		"data": struct {
+			*db.Sensor
+			CurrentQuality *int `json:"current_quality"`
+		}{sensor, quality},
 	})
 }
+
+// handleV1SensorAvailability returns the data extents of a sensor and the
+// variables available in its raw rows
+// GET /api/v1/core/sensors/:id/availability
+func (s *Server) handleV1SensorAvailability(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	noCoverage, err := parseNoCoverage(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	availability, err := s.store.GetSensorAvailability(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	resp := gin.H{"data": availability}
+	if !noCoverage {
+		resp["meta"] = gin.H{"clean_coverage_until": availability.Clean.LastTS}
+	}
+	s.respondJSON(c, resp)
+}
+
+// handleV1SensorLocations returns the recorded location history of a sensor.
+// Sensors that never moved since history tracking began have a single entry,
+// or none if they predate it.
+// GET /api/v1/core/sensors/:id/locations
+func (s *Server) handleV1SensorLocations(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sensor, err := s.store.GetSensor(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	locations, err := s.store.ListSensorLocations(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": locations,
+		"meta": gin.H{
+			"sensor_id": sensorID,
+			"count":     len(locations),
+			"current":   gin.H{"lat": sensor.Lat, "lon": sensor.Lon},
+		},
+	})
+}
+
+// handleV1SensorChanges returns the metadata changes the watcher recorded for
+// a sensor (renames, barrio or comuna reassignments), newest first, using the
+// pagination envelope of the sensor list.
+// GET /api/v1/core/sensors/:id/changes?page=1&limit=100
+func (s *Server) handleV1SensorChanges(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil && val > 0 {
+			page = val
+		}
+	}
+
+	limit := defaultSensorChangesLimit
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 && val <= maxSensorChangesLimit {
+			limit = val
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	changes, total, err := s.store.ListSensorChanges(ctx, sensorID, limit, (page-1)*limit)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": changes,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total_count": total,
+			"total_pages": (total + limit - 1) / limit,
+		},
+		"meta": gin.H{
+			"sensor_id": sensorID,
+			"count":     len(changes),
+		},
+	})
+}
+
+// handleV1SensorGridAggregates returns the sensor's aggregate in each grid run
+// of the window next to the run's network average; runs the sensor did not
+// contribute to appear as gaps
+// GET /api/v1/core/sensors/:id/grid-aggregates?start=...&end=...
+func (s *Server) handleV1SensorGridAggregates(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	var after *time.Time
+	if token := c.Query("cursor"); token != "" {
+		ts, err := decodeRunCursor(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		after = &ts
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	// One extra run tells whether another page exists.
+	limit := s.cfg.MaxRows
+	points, err := s.store.GetSensorGridAggregateHistory(ctx, sensorID, *rng.Start, rng.End, after, limit+1)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if len(points) > limit {
+		points = points[:limit]
+		setNextLink(c, encodeRunCursor(points[limit-1].Timestamp), rng.Start)
+	}
+
+	contributed := 0
+	for _, p := range points {
+		if p.Contributed {
+			contributed++
+		}
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": points,
+		"meta": gin.H{
+			"sensor_id":   sensorID,
+			"runs":        len(points),
+			"contributed": contributed,
+			"start":       formatTimestamp(*rng.Start),
+			"end":         formatTimestamp(rng.End),
+		},
+	})
+}
+
+// handleV1SensorMeasurements returns one page of a sensor's measurement
+// series, oldest first, using the same pagination envelope as the grid
+// timestamps listing. Pages are pinned to meta.as_of, which later pages pass
+// back. pagination.next_cursor, passed back as cursor, fetches the next page
+// by keyset instead of offset, which stays fast deep into long series.
+// Requests preferring Accept: text/csv get the CSV export instead.
+// GET /api/v1/core/sensors/:id/measurements?start=...&end=...&clean=true&page=1&limit=200&as_of=...&cursor=...
+func (s *Server) handleV1SensorMeasurements(c *gin.Context) {
+	c.Header("Vary", "Accept")
+	if c.NegotiateFormat(gin.MIMEJSON, csvContentType) == csvContentType {
+		s.handleV1SensorMeasurementsCSV(c)
+		return
+	}
+
+	sensorID := c.Param("id")
+
+	page := 1
+	if p := c.Query("page"); p != "" {
+		if val, err := strconv.Atoi(p); err == nil && val > 0 {
+			page = val
+		}
+	}
+
+	limit := s.cfg.DefaultLimit
+	if l := c.Query("limit"); l != "" {
+		if val, err := strconv.Atoi(l); err == nil && val > 0 && val <= s.cfg.MaxRows {
+			limit = val
+		}
+	}
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable, err := parseVariable(c, mode.strict())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var cursor *measurementCursor
+	if token := c.Query("cursor"); token != "" {
+		cur, err := decodeMeasurementCursor(token)
+		if err != nil || cur.SensorID != sensorID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if c.Query("page") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor and page cannot be combined"})
+			return
+		}
+		cursor = &cur
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	q := db.MeasurementQuery{
+		SensorID: sensorID,
+		Limit:    limit,
+		Offset:   (page - 1) * limit,
+		Since:    rng.Start,
+		Until:    &rng.End,
+		Variable: variable,
+		AsOf:     &asOf,
+	}
+	q.UseClean, err = s.useCleanFor(ctx, mode, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	var (
+		measurements []db.Measurement
+		pagination   gin.H
+		more         bool
+	)
+	if cursor != nil {
+		if cursor.UseClean != q.UseClean {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor was issued for a different clean mode"})
+			return
+		}
+		// Keyset pages skip the row count, which is as slow as the offset
+		// scan they replace; one extra row tells whether another page exists.
+		q.After = &cursor.MeasurementCursor
+		q.Offset = 0
+		q.Limit = limit + 1
+		measurements, err = s.store.FetchMeasurements(ctx, q)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		if more = len(measurements) > limit; more {
+			measurements = measurements[:limit]
+		}
+		pagination = gin.H{"limit": limit}
+	} else {
+		var total int
+		measurements, total, err = s.store.FetchMeasurementsPage(ctx, q)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		more = q.Offset+len(measurements) < total
+		pagination = gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total_count": total,
+			"total_pages": (total + limit - 1) / limit,
+		}
+	}
+	if more && len(measurements) > 0 {
+		last := measurements[len(measurements)-1]
+		pagination["next_cursor"] = measurementCursor{
+			SensorID:          sensorID,
+			UseClean:          q.UseClean,
+			MeasurementCursor: db.MeasurementCursor{TS: last.Timestamp, ID: last.ID},
+		}.encode()
+	} else {
+		pagination["next_cursor"] = nil
+	}
+
+	resp := gin.H{
+		"data":       measurements,
+		"pagination": pagination,
+		"meta": gin.H{
+			"sensor_id":  sensorID,
+			"start":      formatTimestamp(*rng.Start),
+			"end":        formatTimestamp(rng.End),
+			"clean_mode": mode,
+			"clean":      q.UseClean,
+			"as_of":      formatTimestamp(asOf),
+		},
+	}
+	if rng.Warning != "" {
+		resp["warning"] = rng.Warning
+	}
+	s.respondJSON(c, resp)
+}
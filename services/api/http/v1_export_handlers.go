@@ -0,0 +1,397 @@
+package http
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+// measurementCSVHeader is the column layout of measurement CSV exports.
+var measurementCSVHeader = []string{"sensor_id", "ts", "value_mm", "qc_flags", "imputation_method", "source"}
+
+// writeMeasurementCSV renders one measurement row.
+func writeMeasurementCSV(w *export.CSVWriter, m db.Measurement) error {
+	qc := ""
+	if m.QCFlags != nil {
+		qc = strconv.Itoa(int(*m.QCFlags))
+	}
+	return w.Write([]string{
+		m.SensorID,
+		w.Time(m.Timestamp),
+		w.FloatPtr(m.ValueMM),
+		qc,
+		derefString(m.ImputationMethod),
+		derefString(m.Source),
+	})
+}
+
+// sensorMeasurementCSVHeader is the column layout of single-sensor exports,
+// which leave out the sensor id and the raw-only source.
+var sensorMeasurementCSVHeader = []string{"ts", "value_mm", "qc_flags", "imputation_method"}
+
+// csvContentType is the media type of CSV exports, also accepted by
+// content negotiation on the measurements endpoint.
+const csvContentType = "text/csv"
+
+// writeSensorMeasurementCSV renders one single-sensor measurement row.
+func writeSensorMeasurementCSV(w *export.CSVWriter, m db.Measurement) error {
+	qc := ""
+	if m.QCFlags != nil {
+		qc = strconv.Itoa(int(*m.QCFlags))
+	}
+	return w.Write([]string{
+		w.Time(m.Timestamp),
+		w.FloatPtr(m.ValueMM),
+		qc,
+		derefString(m.ImputationMethod),
+	})
+}
+
+// sanitizeFilename keeps a sensor id safe to quote in Content-Disposition.
+func sanitizeFilename(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// exportCheckpointRows is how many rows an export writes between resume
+// cursor comments.
+const exportCheckpointRows = 1000
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// handleV1ExportMeasurementsCSV streams the measurements of several sensors as
+// CSV. With resumable=true the stream carries "# resume_after=<cursor>" lines
+// after fully written rows; passing that cursor back as resume_after continues
+// the export right after that row, without repeating the header.
+// GET /api/v1/core/measurements.csv?ids=a,b&start=...&end=...&clean=true&delimiter=;&decimal=,&timestamp=excel&tz=America/Bogota&bom=true&resumable=true&resume_after=...
+func (s *Server) handleV1ExportMeasurementsCSV(c *gin.Context) {
+	ids := parseSensorIDs(c.Query("ids"))
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids query parameter is required"})
+		return
+	}
+	if len(ids) > maxBatchSensors {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many sensors requested, maximum is " + strconv.Itoa(maxBatchSensors)})
+		return
+	}
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable, err := parseVariable(c, mode.strict())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := parseCSVOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attribution, err := parseCSVAttribution(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	var resume *measurementCursor
+	if token := c.Query("resume_after"); token != "" {
+		cur, err := decodeMeasurementCursor(token)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resume_after cursor"})
+			return
+		}
+		if !containsString(ids, cur.SensorID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "resume_after cursor does not belong to the requested sensors"})
+			return
+		}
+		resume = &cur
+		// The continuation is appended to a partial file that already starts
+		// with the BOM and header.
+		opts.BOM = false
+	}
+
+	resumable := resume != nil
+	if raw := c.Query("resumable"); raw != "" {
+		resumable, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid resumable parameter"})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	c.Header("Content-Type", csvContentType+"; charset=utf-8")
+	c.Header("X-Clean-Mode", string(mode))
+	c.Header("Content-Disposition", `attachment; filename="measurements.csv"`)
+	c.Status(http.StatusOK)
+
+	w, err := export.NewCSVWriter(c.Writer, opts)
+	if err != nil {
+		return
+	}
+	if resume == nil {
+		if attribution {
+			if err := s.writeAttributionComments(w); err != nil {
+				return
+			}
+		}
+		if err := w.Write(measurementCSVHeader); err != nil {
+			return
+		}
+	}
+
+	checkpoint := func(cur measurementCursor) error {
+		if !resumable {
+			return nil
+		}
+		if err := w.Comment("resume_after=" + cur.encode()); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}
+
+	// Headers are already sent, so failures can only truncate the stream.
+	for _, id := range ids {
+		if resume != nil && id != resume.SensorID {
+			// Sensors before the cursor were fully delivered.
+			continue
+		}
+		q := db.MeasurementQuery{
+			SensorID: id,
+			Since:    rng.Start,
+			Until:    &rng.End,
+			Variable: variable,
+		}
+		if resume != nil {
+			// Keep the table the interrupted stream used, even in auto mode.
+			q.UseClean = resume.UseClean
+			q.After = &resume.MeasurementCursor
+			resume = nil
+		} else {
+			q.UseClean, err = s.useCleanFor(ctx, mode, q)
+		}
+
+		var last *measurementCursor
+		rows := 0
+		if err == nil {
+			err = s.store.StreamMeasurements(ctx, q, func(m db.Measurement) error {
+				if err := writeMeasurementCSV(w, m); err != nil {
+					return err
+				}
+				last = &measurementCursor{SensorID: id, UseClean: q.UseClean, MeasurementCursor: db.MeasurementCursor{TS: m.Timestamp, ID: m.ID}}
+				rows++
+				if rows%exportCheckpointRows == 0 {
+					return checkpoint(*last)
+				}
+				return nil
+			})
+		}
+		if err != nil {
+			log.Printf("csv export aborted at sensor %s: %v", id, err)
+			break
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+		if last != nil && rows%exportCheckpointRows != 0 {
+			if err := checkpoint(*last); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+	_ = w.Flush()
+}
+
+// handleV1SensorMeasurementsCSV streams one sensor's series as CSV without
+// buffering it, for loading into pandas and the like. It takes the
+// parameters of GET /sensor/:sensor_id: start/end (start defaults to
+// API_DEFAULT_DAYS before end), last_n (the newest that many rows, capped at
+// API_MAX_ROWS; the whole range when omitted), clean and variable, plus order
+// (asc or desc) and the CSV locale options. Also served for Accept: text/csv
+// on the JSON endpoint.
+// GET /api/v1/core/sensors/:id/measurements.csv?start=...&end=...&last_n=...&order=asc&clean=true
+func (s *Server) handleV1SensorMeasurementsCSV(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable, err := parseVariable(c, mode.strict())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 0
+	if limitStr := c.Query("last_n"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid last_n"})
+			return
+		}
+		limit = clampLimit(parsed, s.cfg.MaxRows)
+	}
+
+	descending, err := parseOrder(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts, err := parseCSVOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	attribution, err := parseCSVAttribution(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	q := db.MeasurementQuery{
+		SensorID: sensorID,
+		Limit:    limit,
+		Since:    rng.Start,
+		Until:    &rng.End,
+		Variable: variable,
+	}
+	q.UseClean, err = s.useCleanFor(ctx, mode, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	// last_n keeps the newest rows, so they are read newest first; in
+	// chronological order they are buffered (at most API_MAX_ROWS) and
+	// reversed before anything is written.
+	q.Descending = descending || limit > 0
+	var newest []db.Measurement
+	buffered := limit > 0 && !descending && !rng.Future
+	if buffered {
+		newest, err = s.store.FetchMeasurements(ctx, q)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		slices.Reverse(newest)
+	}
+
+	c.Header("Content-Type", csvContentType+"; charset=utf-8")
+	c.Header("X-Clean-Mode", string(mode))
+	c.Header("Content-Disposition", `attachment; filename="`+sanitizeFilename(sensorID)+`.csv"`)
+	c.Status(http.StatusOK)
+
+	w, err := export.NewCSVWriter(c.Writer, opts)
+	if err != nil {
+		return
+	}
+	if attribution {
+		if err := s.writeAttributionComments(w); err != nil {
+			return
+		}
+	}
+	if err := w.Write(sensorMeasurementCSVHeader); err != nil {
+		return
+	}
+	if rng.Future {
+		_ = w.Flush()
+		return
+	}
+	if buffered {
+		for _, m := range newest {
+			if err := writeSensorMeasurementCSV(w, m); err != nil {
+				return
+			}
+		}
+		_ = w.Flush()
+		return
+	}
+
+	// Headers are already sent, so failures can only truncate the stream.
+	rows := 0
+	err = s.store.StreamMeasurements(ctx, q, func(m db.Measurement) error {
+		if err := writeSensorMeasurementCSV(w, m); err != nil {
+			return err
+		}
+		rows++
+		if rows%exportCheckpointRows == 0 {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("csv export of sensor %s aborted: %v", sensorID, err)
+	}
+	_ = w.Flush()
+}
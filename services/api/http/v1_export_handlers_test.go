@@ -0,0 +1,51 @@
+package http
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/export"
+)
+
+// Both export row layouts format through the shared writer, so the locale
+// options reach every number and timestamp column.
+func TestMeasurementCSVRowsUseWriterLocale(t *testing.T) {
+	bogota, err := time.LoadLocation("America/Bogota")
+	if err != nil {
+		t.Skip(err)
+	}
+	value, qc, source := 1.5, int32(4), "current"
+	m := db.Measurement{
+		SensorID:  "siata_1",
+		Timestamp: time.Date(2025, 10, 1, 17, 5, 0, 0, time.UTC),
+		ValueMM:   &value,
+		QCFlags:   &qc,
+		Source:    &source,
+	}
+
+	for _, tc := range []struct {
+		name  string
+		write func(*export.CSVWriter, db.Measurement) error
+		want  string
+	}{
+		{"network export", writeMeasurementCSV, "siata_1;2025-10-01 12:05:00;1,5;4;;current\n"},
+		{"sensor export", writeSensorMeasurementCSV, "2025-10-01 12:05:00;1,5;4;\n"},
+	} {
+		var buf bytes.Buffer
+		w, err := export.NewCSVWriter(&buf, export.CSVOptions{Delimiter: ';', DecimalComma: true, ExcelTime: true, Location: bogota})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tc.write(w, m); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
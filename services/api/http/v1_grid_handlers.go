@@ -2,15 +2,19 @@ package http
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
 )
 
 // handleV1GridTimestamps returns paginated list of grid timestamps with aggregate stats
-// GET /api/v1/grid/timestamps?page=1&limit=20&start=2024-01-01T00:00:00Z&end=2024-12-31T23:59:59Z
+// GET /api/v1/grid/timestamps?page=1&limit=20&start=2024-01-01T00:00:00Z&end=2024-12-31T23:59:59Z&as_of=...
 func (s *Server) handleV1GridTimestamps(c *gin.Context) {
 	// Parse pagination parameters
 	page := 1
@@ -27,25 +31,32 @@ func (s *Server) handleV1GridTimestamps(c *gin.Context) {
 		}
 	}
 
-	offset := (page - 1) * limit
-
 	// Parse optional time range filters
-	var startTime, endTime *time.Time
-	if start := c.Query("start"); start != "" {
-		if t, err := time.Parse(time.RFC3339, start); err == nil {
-			startTime = &t
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid start time format, expected RFC3339"})
-			return
-		}
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
 	}
-	if end := c.Query("end"); end != "" {
-		if t, err := time.Parse(time.RFC3339, end); err == nil {
-			endTime = &t
-		} else {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid end time format, expected RFC3339"})
-			return
-		}
+	startTime, endTime := rng.Start, &rng.End
+
+	asOf, err := parseAsOf(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rng.Future {
+		s.respondJSON(c, gin.H{
+			"data": []db.GridTimestampResult{},
+			"pagination": gin.H{
+				"page":        page,
+				"limit":       limit,
+				"total_count": 0,
+				"total_pages": 0,
+			},
+			"warning": rng.Warning,
+		})
+		return
 	}
 
 	// Parse include_sensors parameter (defaults to false for performance)
@@ -57,14 +68,28 @@ func (s *Server) handleV1GridTimestamps(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	// Get paginated grid runs with aggregates
-	result, err := s.store.ListGridTimestampsWithAggregates(ctx, limit, offset, startTime, endTime, includeSensors)
+	doc, err := s.gridTimestampsDocument(ctx, page, limit, startTime, endTime, &asOf, includeSensors)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	s.respondJSON(c, doc)
+}
+
+// gridTimestampsDocument builds one page of the grid timestamps response,
+// shared with the bootstrap endpoint. A nil asOf lists every run.
+func (s *Server) gridTimestampsDocument(ctx context.Context, page, limit int, start, end, asOf *time.Time, includeSensors bool) (gin.H, error) {
+	if s.gridDisabled.Load() {
+		return nil, errGridDisabled
+	}
+	// Get paginated grid runs with aggregates
+	result, err := s.store.ListGridTimestampsWithAggregates(ctx, limit, (page-1)*limit, start, end, asOf, includeSensors)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := gin.H{
 		"data": result.Grids,
 		"pagination": gin.H{
 			"page":        page,
@@ -72,11 +97,16 @@ func (s *Server) handleV1GridTimestamps(c *gin.Context) {
 			"total_count": result.TotalCount,
 			"total_pages": (result.TotalCount + limit - 1) / limit,
 		},
-	})
+	}
+	if asOf != nil {
+		doc["meta"] = gin.H{"as_of": formatTimestamp(*asOf)}
+	}
+	return doc, nil
 }
 
-// handleV1GridByTimestamp returns grid data for a specific timestamp
-// GET /api/v1/grid/:timestamp
+// handleV1GridByTimestamp returns grid data for a specific timestamp. With
+// include=provenance the run is wrapped with its measurement provenance.
+// GET /api/v1/grid/:timestamp?include=provenance
 func (s *Server) handleV1GridByTimestamp(c *gin.Context) {
 	timestampStr := c.Param("timestamp")
 	if timestampStr == "" {
@@ -84,41 +114,74 @@ func (s *Server) handleV1GridByTimestamp(c *gin.Context) {
 		return
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	timestamp, err := parseTimestamp(timestampStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
 		return
 	}
 
+	include, err := parseInclude(c, "provenance")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	includeProvenance := include["provenance"]
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
 	grid, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	if !includeProvenance {
+		s.respondJSON(c, gin.H{
+			"data": grid,
+		})
 		return
 	}
 
-	if grid == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "grid not found for timestamp"})
+	provenance, err := s.store.GetGridProvenance(ctx, grid.ID)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": grid,
+	s.respondJSON(c, gin.H{
+		"data": gin.H{
+			"grid":       grid,
+			"provenance": provenance,
+		},
 	})
 }
 
-// handleV1GridSensorAggregates returns sensor aggregates for a specific grid timestamp
-// GET /api/v1/grid/:timestamp/sensors
+// handleV1GridSensorAggregates returns sensor aggregates for a specific grid
+// timestamp, or with format=geojson a FeatureCollection of Points that maps
+// can bind directly. Aggregates without coordinates are left out of the
+// GeoJSON and counted in meta.skipped_sensors.
+// GET /api/v1/grid/:timestamp/sensors?rain_only=true&format=geojson
 func (s *Server) handleV1GridSensorAggregates(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "geojson" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid format, expected json or geojson"})
+		return
+	}
+
+	rainOnly, err := parseRainOnly(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	timestampStr := c.Param("timestamp")
 	if timestampStr == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "timestamp is required"})
 		return
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	timestamp, err := parseTimestamp(timestampStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
 		return
@@ -129,14 +192,42 @@ func (s *Server) handleV1GridSensorAggregates(c *gin.Context) {
 
 	aggregates, err := s.store.GetSensorAggregatesByTimestamp(ctx, timestamp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	if rainOnly {
+		aggregates = s.filterRaining(aggregates)
+	}
+
+	if format == "geojson" {
+		fc := newFeatureCollection(len(aggregates))
+		skipped := 0
+		for _, agg := range aggregates {
+			f, ok := aggregateFeature(agg)
+			if !ok {
+				skipped++
+				continue
+			}
+			fc.Features = append(fc.Features, f)
+		}
+
+		c.Header("Content-Type", geoJSONContentType)
+		s.respondJSON(c, &struct {
+			featureCollection
+			Meta gin.H `json:"meta"`
+		}{fc, gin.H{
+			"timestamp":       formatTimestamp(timestamp),
+			"count":           len(fc.Features),
+			"skipped_sensors": skipped,
+		}})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	s.respondJSON(c, gin.H{
 		"data": aggregates,
 		"meta": gin.H{
-			"timestamp": timestamp.Format(time.RFC3339),
+			"timestamp": formatTimestamp(timestamp),
 			"count":     len(aggregates),
 		},
 	})
@@ -151,7 +242,7 @@ func (s *Server) handleV1GridContours(c *gin.Context) {
 		return
 	}
 
-	timestamp, err := time.Parse(time.RFC3339, timestampStr)
+	timestamp, err := parseTimestamp(timestampStr)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
 		return
@@ -162,19 +253,243 @@ func (s *Server) handleV1GridContours(c *gin.Context) {
 
 	grid, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if grid == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "grid not found"})
+	s.respondJSON(c, gin.H{
+		"data": gin.H{
+			"contours_url": grid.BlobURLContours,
+			"timestamp":    formatTimestamp(timestamp),
+		},
+	})
+}
+
+// handleV1GridDiff returns the cell-wise difference (to minus from) between two grids
+// GET /api/v1/grid/diff?from=2024-01-01T00:00:00Z&to=2024-01-01T01:00:00Z
+func (s *Server) handleV1GridDiff(c *gin.Context) {
+	fromStr, toStr := c.Query("from"), c.Query("to")
+	if fromStr == "" || toStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to query parameters are required (RFC3339)"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"contours_url": grid.BlobURLContours,
-			"timestamp":    timestamp.Format(time.RFC3339),
+	from, err := parseTimestamp(fromStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from timestamp, expected RFC3339"})
+		return
+	}
+	to, err := parseTimestamp(toStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to timestamp, expected RFC3339"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	earlier, ok := s.loadGrid(ctx, c, from)
+	if !ok {
+		return
+	}
+	later, ok := s.loadGrid(ctx, c, to)
+	if !ok {
+		return
+	}
+
+	diff, err := grid.Diff(later, earlier)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": diff,
+		"meta": gin.H{
+			"from":  formatTimestamp(from),
+			"to":    formatTimestamp(to),
+			"shape": []int{len(diff.Y), len(diff.X)},
+		},
+	})
+}
+
+// loadGrid resolves the done grid run at timestamp and returns its parsed grid
+// from the cache, writing an error response when it cannot.
+func (s *Server) loadGrid(ctx context.Context, c *gin.Context, timestamp time.Time) (*grid.Grid, bool) {
+	run, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
+	if err != nil {
+		c.Error(err)
+		return nil, false
+	}
+	if run.BlobURLJSON == nil || *run.BlobURLJSON == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "grid has no JSON artifact for timestamp " + formatTimestamp(timestamp), "code": "not_found"})
+		return nil, false
+	}
+
+	g, err := s.grids.Get(ctx, *run.BlobURLJSON)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return g, true
+}
+
+// handleV1GridThumbnail renders a small PNG preview of a grid run
+// GET /api/v1/grid/:timestamp/thumbnail.png?width=256
+func (s *Server) handleV1GridThumbnail(c *gin.Context) {
+	timestamp, err := parseTimestamp(c.Param("timestamp"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
+		return
+	}
+
+	width := 256
+	if w := c.Query("width"); w != "" {
+		val, err := strconv.Atoi(w)
+		if err != nil || val < 64 || val > 1024 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "width must be an integer between 64 and 1024"})
+			return
+		}
+		width = val
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if run.BlobURLJSON == nil || *run.BlobURLJSON == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "grid has no JSON artifact", "code": "not_found"})
+		return
+	}
+
+	data, ok := s.thumbs.Get(run.ID, width)
+	if !ok {
+		g, err := s.grids.Get(ctx, *run.BlobURLJSON)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+		data, err = grid.RenderPNG(g, width)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		s.thumbs.Put(run.ID, width, data)
+	}
+
+	// Done runs never change, so thumbnails can be cached by clients indefinitely
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// handleV1GridCoverage returns the distance from each grid cell to the nearest
+// contributing sensor, optionally classed into near/medium/far bands
+// GET /api/v1/grid/:timestamp/coverage?format=distance|bands&near=2000&far=5000
+func (s *Server) handleV1GridCoverage(c *gin.Context) {
+	timestamp, err := parseTimestamp(c.Param("timestamp"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "distance")
+	if format != "distance" && format != "bands" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be \"distance\" or \"bands\""})
+		return
+	}
+
+	near, far := 2000.0, 5000.0
+	if v := c.Query("near"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "near must be a positive distance in metres"})
+			return
+		}
+		near = f
+	}
+	if v := c.Query("far"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "far must be a positive distance in metres"})
+			return
+		}
+		far = f
+	}
+	if near >= far {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "near must be less than far"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if run.BlobURLJSON == nil || *run.BlobURLJSON == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "grid has no JSON artifact", "code": "not_found"})
+		return
+	}
+
+	g, err := s.grids.Get(ctx, *run.BlobURLJSON)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	dist, ok := s.coverage.Get(run.ID)
+	if !ok {
+		aggregates, err := s.store.GetSensorAggregatesByGridRunID(ctx, run.ID, nil)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		points := make([]grid.Point, 0, len(aggregates))
+		for _, agg := range aggregates {
+			if agg.MeasurementCount > 0 && agg.Sensor != nil {
+				points = append(points, grid.Point{Lon: agg.Sensor.Lon, Lat: agg.Sensor.Lat})
+			}
+		}
+		dist, err = grid.DistanceGrid(g, points)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "code": "not_found"})
+			return
+		}
+		s.coverage.Put(run.ID, dist)
+	}
+
+	data := gin.H{
+		"res_m":      g.ResM,
+		"bbox_3857":  g.BBox3857,
+		"bbox_wgs84": g.BBoxWGS84,
+		"x":          g.X,
+		"y":          g.Y,
+	}
+	if format == "bands" {
+		data["bands"] = grid.Classify(dist, near, far)
+		data["legend"] = []gin.H{
+			{"band": grid.BandNear, "name": "near", "max_distance_m": near},
+			{"band": grid.BandMedium, "name": "medium", "max_distance_m": far},
+			{"band": grid.BandFar, "name": "far"},
+		}
+	} else {
+		data["distance_m"] = dist
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	s.respondJSON(c, gin.H{
+		"data": data,
+		"meta": gin.H{
+			"timestamp": formatTimestamp(run.Timestamp),
+			"format":    format,
+			"near_m":    near,
+			"far_m":     far,
 		},
 	})
 }
@@ -182,3 +497,99 @@ func (s *Server) handleV1GridContours(c *gin.Context) {
 // Note: Preview JPEG URLs are not stored in the database.
 // They are available in the blob storage latest.json file
 // and can be accessed via the /api/v1/realtime/now endpoint.
+
+// gridValidationRow compares a contributing sensor's observed rate with the
+// interpolated grid cell it sits in.
+type gridValidationRow struct {
+	SensorID     string  `json:"sensor_id"`
+	Lon          float64 `json:"lon"`
+	Lat          float64 `json:"lat"`
+	ObservedMmH  float64 `json:"observed_mm_h"`
+	GridMmH      float64 `json:"interpolated_mm_h"`
+	ErrorMmH     float64 `json:"error_mm_h"`
+	Measurements int     `json:"measurement_count"`
+}
+
+// handleV1GridValidation samples a grid at each contributing sensor and
+// compares the cell with the sensor's avg_mm_h, reporting the bias (mean of
+// interpolated minus observed), MAE and RMSE over the sensors. Sensors outside
+// the grid or over a cell without a value are skipped and counted.
+// GET /api/v1/grid/:timestamp/validation
+func (s *Server) handleV1GridValidation(c *gin.Context) {
+	timestamp, err := parseTimestamp(c.Param("timestamp"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid timestamp format, expected RFC3339"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	run, err := s.store.GetGridRunByTimestamp(ctx, timestamp)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if run.BlobURLJSON == nil || *run.BlobURLJSON == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "grid has no JSON artifact", "code": "not_found"})
+		return
+	}
+
+	g, err := s.grids.Get(ctx, *run.BlobURLJSON)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	aggregates, err := s.store.GetSensorAggregatesByGridRunID(ctx, run.ID, nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	rows := make([]gridValidationRow, 0, len(aggregates))
+	skipped := 0
+	var sumErr, sumAbs, sumSq float64
+	for _, agg := range aggregates {
+		if agg.MeasurementCount == 0 || agg.Sensor == nil {
+			continue
+		}
+		value, ok := g.Sample(agg.Sensor.Lon, agg.Sensor.Lat)
+		if !ok {
+			skipped++
+			continue
+		}
+		e := value - agg.AvgMmH
+		sumErr += e
+		sumAbs += math.Abs(e)
+		sumSq += e * e
+		rows = append(rows, gridValidationRow{
+			SensorID:     agg.SensorID,
+			Lon:          agg.Sensor.Lon,
+			Lat:          agg.Sensor.Lat,
+			ObservedMmH:  agg.AvgMmH,
+			GridMmH:      value,
+			ErrorMmH:     e,
+			Measurements: agg.MeasurementCount,
+		})
+	}
+
+	var bias, mae, rmse *float64
+	if n := float64(len(rows)); n > 0 {
+		b, m, r := sumErr/n, sumAbs/n, math.Sqrt(sumSq/n)
+		bias, mae, rmse = &b, &m, &r
+	}
+
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	s.respondJSON(c, gin.H{
+		"data": rows,
+		"meta": gin.H{
+			"timestamp":       formatTimestamp(run.Timestamp),
+			"sensors":         len(rows),
+			"skipped_sensors": skipped,
+			"bias_mm_h":       bias,
+			"mae_mm_h":        mae,
+			"rmse_mm_h":       rmse,
+		},
+	})
+}
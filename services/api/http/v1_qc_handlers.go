@@ -0,0 +1,88 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// manualFlagRequest is the body accepted by the measurement flag endpoint.
+type manualFlagRequest struct {
+	SensorID string  `json:"sensor_id"`
+	TS       string  `json:"ts"`
+	Flag     string  `json:"flag"`
+	Note     *string `json:"note"`
+}
+
+// handleV1FlagMeasurement records a reviewer judgment on a raw measurement
+// POST /api/v1/core/measurements/flag
+func (s *Server) handleV1FlagMeasurement(c *gin.Context) {
+	var req manualFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+		return
+	}
+
+	if req.SensorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sensor_id is required"})
+		return
+	}
+	if req.Flag != "valid" && req.Flag != "invalid" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "flag must be \"valid\" or \"invalid\""})
+		return
+	}
+	ts, err := parseTimestamp(req.TS)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ts format, expected RFC3339"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	flag, err := s.store.UpsertManualFlag(ctx, db.ManualFlag{
+		SensorID:  req.SensorID,
+		Timestamp: ts,
+		Flag:      req.Flag,
+		Note:      req.Note,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": flag,
+	})
+}
+
+// handleV1ListManualFlags returns the reviewer judgments recorded for a sensor
+// GET /api/v1/core/sensors/:id/flags
+func (s *Server) handleV1ListManualFlags(c *gin.Context) {
+	sensorID := c.Param("id")
+	if sensorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sensor id is required"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	flags, err := s.store.ListManualFlags(ctx, sensorID)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": flags,
+		"meta": gin.H{
+			"sensor_id": sensorID,
+			"count":     len(flags),
+		},
+	})
+}
@@ -2,46 +2,439 @@ package http
 
 import (
 	"context"
+	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/grid"
 )
 
-// handleV1RealtimeNow returns the latest grid data with sensor aggregates
-// GET /api/v1/realtime/now
+// handleV1RealtimeNow returns the latest grid data with sensor aggregates, or
+// the latest clean value per sensor while grid mode is disabled. Clients
+// sending Accept: application/x-protobuf get the RealtimeNow message of
+// docs/realtime_now.proto instead of JSON
+// GET /api/v1/realtime/now?rain_only=true&bbox=min_lon,min_lat,max_lon,max_lat
 func (s *Server) handleV1RealtimeNow(c *gin.Context) {
+	rainOnly, err := parseRainOnly(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bbox, err := parseBBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
-	// Get latest successful grid run
-	grid, err := s.store.GetLatestGrid(ctx)
+	format := c.NegotiateFormat(gin.MIMEJSON, protobufContentType)
+	c.Header("Vary", "Accept")
+	// The RealtimeNow message has millimetre fields only.
+	if format == protobufContentType && c.GetString(unitsContextKey) == unitsInches {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "units=in is only available as JSON; protobuf responses are in mm"})
+		return
+	}
+
+	now, err := s.realtimeNowData(ctx, rainOnly, bbox)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	if grid == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "no grid data available"})
+	if format == protobufContentType {
+		c.Data(http.StatusOK, protobufContentType, now.protobuf())
 		return
 	}
+	s.respondJSON(c, now.document())
+}
+
+// realtimeNow is the assembled realtime now response. JSON and protobuf are
+// both rendered from it, so the two formats cannot diverge.
+type realtimeNow struct {
+	// Grid is nil in grid-disabled mode, where Latest replaces Aggregates.
+	Grid           *db.GridRun
+	Aggregates     []db.SensorAggregate
+	Latest         []db.Measurement
+	SensorsCount   int
+	NetworkCount   int
+	RainingSensors int
+	RainThreshold  float64
+	GeneratedAt    time.Time
+	BBox           *db.BBox
+}
 
-	// Get sensor aggregates for this grid
-	aggregates, err := s.store.GetSensorAggregatesByGridRunID(ctx, grid.ID)
+// realtimeNowDocument builds the realtime now response, shared with the
+// bootstrap endpoint.
+func (s *Server) realtimeNowDocument(ctx context.Context, rainOnly bool, bbox *db.BBox) (gin.H, error) {
+	now, err := s.realtimeNowData(ctx, rainOnly, bbox)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
+	}
+	return now.document(), nil
+}
+
+// realtimeNowData assembles the realtime now response: the latest grid run's
+// sensor aggregates or, while grid mode is disabled, the latest clean value
+// per sensor.
+func (s *Server) realtimeNowData(ctx context.Context, rainOnly bool, bbox *db.BBox) (*realtimeNow, error) {
+	now := &realtimeNow{RainThreshold: s.cfg.RainThreshold, BBox: bbox}
+
+	if s.gridDisabled.Load() {
+		latest, err := s.store.LatestClean(ctx, bbox)
+		if err != nil {
+			return nil, err
+		}
+		now.SensorsCount = len(latest)
+		if rainOnly {
+			since := time.Now().Add(-rainingWindow)
+			filtered := latest[:0]
+			for _, m := range latest {
+				if m.ValueMM != nil && *m.ValueMM >= s.cfg.RainThreshold && !m.Timestamp.Before(since) {
+					filtered = append(filtered, m)
+				}
+			}
+			latest = filtered
+		}
+		now.Latest = latest
+	} else {
+		// Get latest successful grid run
+		grid, err := s.store.GetLatestGrid(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		// Get sensor aggregates for this grid
+		aggregates, err := s.store.GetSensorAggregatesByGridRunID(ctx, grid.ID, bbox)
+		if err != nil {
+			return nil, err
+		}
+		now.Grid = grid
+		now.SensorsCount = len(aggregates)
+		if rainOnly {
+			aggregates = s.filterRaining(aggregates)
+		}
+		now.Aggregates = aggregates
+	}
+
+	var err error
+	if now.NetworkCount, err = s.store.CountSensors(ctx); err != nil {
+		return nil, err
+	}
+	if now.RainingSensors, err = s.countRaining(ctx, bbox); err != nil {
+		return nil, err
+	}
+	now.GeneratedAt = time.Now().UTC()
+	return now, nil
+}
+
+// document renders the JSON envelope.
+func (r *realtimeNow) document() gin.H {
+	meta := gin.H{
+		"sensors_count":     r.SensorsCount,
+		"network_count":     r.NetworkCount,
+		"raining_sensors":   r.RainingSensors,
+		"rain_threshold_mm": r.RainThreshold,
+		"generated_at":      formatTimestamp(r.GeneratedAt),
+	}
+	if r.Grid != nil {
+		meta["rain_threshold_mm_h"] = rainRateThreshold(r.RainThreshold)
+	}
+	if r.BBox != nil {
+		meta["bbox"] = r.BBox
+	}
+
+	if r.Grid == nil {
+		meta["grid_mode"] = "disabled"
+		return gin.H{
+			"data": gin.H{
+				"grid":   nil,
+				"latest": r.Latest,
+			},
+			"meta": meta,
+		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
+	meta["timestamp"] = formatTimestamp(r.Grid.Timestamp)
+	return gin.H{
 		"data": gin.H{
-			"grid":              grid,
-			"sensor_aggregates": aggregates,
+			"grid":              r.Grid,
+			"sensor_aggregates": r.Aggregates,
+		},
+		"meta": meta,
+	}
+}
+
+// realtimeSummary is the data of /api/v1/realtime/summary.
+type realtimeSummary struct {
+	Averages       realtimeAverages `json:"averages"`
+	RainingSensors int              `json:"raining_sensors"`
+	// LatestGrid is null in grid-disabled mode or before the first grid run.
+	LatestGrid         *db.LatestGridSummary `json:"latest_grid"`
+	GridPreviewJPEGURL *string               `json:"grid_preview_jpeg_url"`
+}
+
+// realtimeAverages holds the network mean clean value over trailing windows,
+// null for windows without measurements.
+type realtimeAverages struct {
+	H3  *float64 `json:"3h"`
+	H6  *float64 `json:"6h"`
+	H12 *float64 `json:"12h"`
+	H24 *float64 `json:"24h"`
+}
+
+// handleV1RealtimeSummary returns the dashboard headline figures: network
+// averages, the raining sensor count and the latest grid run's sensor count
+// and maximum rate. The preview URL is best-effort and null when the blob
+// store is slow or unreachable.
+// GET /api/v1/realtime/summary
+func (s *Server) handleV1RealtimeSummary(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	averages, err := s.store.GetAverages(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	raining, err := s.countRaining(ctx, nil)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	summary := realtimeSummary{
+		Averages: realtimeAverages{
+			H3:  averages.Avg3h,
+			H6:  averages.Avg6h,
+			H12: averages.Avg12h,
+			H24: averages.Avg24h,
+		},
+		RainingSensors: raining,
+	}
+
+	if !s.gridDisabled.Load() {
+		latest, err := s.store.GetLatestGridSummary(ctx)
+		switch {
+		case errors.Is(err, db.ErrNotFound):
+		case err != nil:
+			c.Error(err)
+			return
+		default:
+			summary.LatestGrid = latest
+		}
+
+		if previewURL, err := s.gridPreviewURL(ctx); err != nil {
+			log.Printf("warning: grid preview lookup: %v", err)
+		} else if previewURL != "" {
+			summary.GridPreviewJPEGURL = &previewURL
+		}
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": summary,
+		"meta": gin.H{
+			"rain_threshold_mm": s.cfg.RainThreshold,
+			"grid_mode":         s.gridMode(),
+			"generated_at":      time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
+
+// handleV1DashboardSummary is the v1 port of the legacy dashboard summary:
+// the 3h/6h/12h/24h network averages, the raining sensor count and, when the
+// grid ETL runs, the latest grid preview URL. The preview lookup is
+// best-effort under its own short timeout, derived from the request context
+// so a client disconnect cancels it too.
+// GET /api/v1/dashboard/summary
+func (s *Server) handleV1DashboardSummary(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	doc, err := s.dashboardSummaryDocument(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": doc,
+		"meta": gin.H{
+			"grid_mode":    s.gridMode(),
+			"generated_at": formatTimestamp(time.Now()),
 		},
+	})
+}
+
+// handleV1RealtimeContours returns the latest grid's contours FeatureCollection
+// inline. Documents over API_CONTOURS_INLINE_MAX_BYTES are not proxied: the
+// client is redirected to the blob with a too_large indicator instead
+// GET /api/v1/realtime/contours
+func (s *Server) handleV1RealtimeContours(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	run, err := s.store.GetLatestGrid(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	if run.BlobURLContours == nil || *run.BlobURLContours == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "latest grid has no contours", "code": "not_found"})
+		return
+	}
+
+	c.Header("X-Grid-Run-ID", strconv.Itoa(run.ID))
+	c.Header("X-Grid-Timestamp", formatTimestamp(run.Timestamp))
+
+	doc, err := s.contours.Get(ctx, run.ID, *run.BlobURLContours)
+	if errors.Is(err, grid.ErrContoursTooLarge) {
+		c.Header("Location", *run.BlobURLContours)
+		c.JSON(http.StatusTemporaryRedirect, gin.H{
+			"too_large":    true,
+			"max_bytes":    s.cfg.ContoursInlineMaxBytes,
+			"contours_url": *run.BlobURLContours,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "code": "unavailable"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/geo+json", doc)
+}
+
+// parseRainOnly reads the optional rain_only query flag.
+func parseRainOnly(c *gin.Context) (bool, error) {
+	raw := c.Query("rain_only")
+	if raw == "" {
+		return false, nil
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		return false, errors.New("invalid rain_only parameter")
+	}
+	return val, nil
+}
+
+// rainingWindow is how recent a sensor's latest clean value must be for it to
+// count as raining now.
+const rainingWindow = time.Hour
+
+// countRaining counts the sensors raining now, optionally within bbox.
+func (s *Server) countRaining(ctx context.Context, bbox *db.BBox) (int, error) {
+	return s.store.CountRainingSensors(ctx, s.cfg.RainThreshold, time.Now().Add(-rainingWindow), bbox)
+}
+
+// rainRateThreshold converts RAIN_THRESHOLD, a depth per SIATA reporting
+// interval, into the rate that grid aggregates are measured in.
+func rainRateThreshold(thresholdMM float64) float64 {
+	return units.Accumulation(thresholdMM).Over(units.SIATAInterval).MMPerHour()
+}
+
+// filterRaining keeps only the aggregates whose rate is at or above the rain
+// threshold expressed as a rate.
+func (s *Server) filterRaining(aggregates []db.SensorAggregate) []db.SensorAggregate {
+	threshold := rainRateThreshold(s.cfg.RainThreshold)
+	out := make([]db.SensorAggregate, 0, len(aggregates))
+	for _, agg := range aggregates {
+		if agg.AvgMmH >= threshold {
+			out = append(out, agg)
+		}
+	}
+	return out
+}
+
+// Classification window bounds; the window is the span rain is accumulated
+// over before it is converted to an intensity.
+const (
+	defaultClassificationWindow = 30 * time.Minute
+	maxClassificationWindow     = 24 * time.Hour
+)
+
+// handleV1RealtimeClassification labels every sensor with a rain class from
+// its clean accumulation over the latest window, converted to mm/h. Class
+// thresholds default to the legend's and can be overridden per class.
+// GET /api/v1/realtime/classification?window=30m&drizzle=0.1&rain=2.5&heavy=7.6&violent=50
+func (s *Server) handleV1RealtimeClassification(c *gin.Context) {
+	window := defaultClassificationWindow
+	if v := c.Query("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute || d > maxClassificationWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window, expected a duration between 1m and 24h"})
+			return
+		}
+		window = d
+	}
+
+	classes, err := parseRainClasses(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	end := time.Now().UTC()
+	start := end.Add(-window)
+	totals, err := s.store.SensorWindowTotals(ctx, start, end)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	data := make([]gin.H, 0, len(totals))
+	for _, t := range totals {
+		item := gin.H{
+			"sensor_id":         t.ID,
+			"name":              t.Name,
+			"lat":               t.Lat,
+			"lon":               t.Lon,
+			"city":              t.City,
+			"accumulation_mm":   t.AccumulationMM,
+			"measurement_count": t.MeasurementCount,
+			"intensity_mm_h":    nil,
+			"class":             rainClassUnknown,
+		}
+		if t.AccumulationMM != nil {
+			intensity := units.Accumulation(*t.AccumulationMM).Over(window).MMPerHour()
+			item["intensity_mm_h"] = intensity
+			item["class"] = classifyRain(classes, intensity)
+		}
+		data = append(data, item)
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": data,
 		"meta": gin.H{
-			"timestamp":     grid.Timestamp.Format(time.RFC3339),
-			"sensors_count": len(aggregates),
-			"generated_at":  time.Now().UTC().Format(time.RFC3339),
+			"start":  formatTimestamp(start),
+			"end":    formatTimestamp(end),
+			"window": window.String(),
+			"legend": rainLegend(classes),
+			"count":  len(data),
 		},
 	})
 }
+
+// handleV1RealtimeLegend returns the rain classes with their mm/h ranges and
+// colours, honouring the same threshold overrides as the classification.
+// GET /api/v1/realtime/legend?heavy=10
+func (s *Server) handleV1RealtimeLegend(c *gin.Context) {
+	classes, err := parseRainClasses(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.respondJSON(c, gin.H{"data": rainLegend(classes)})
+}
@@ -0,0 +1,54 @@
+package http
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+func TestRainRateThreshold(t *testing.T) {
+	// 0.1 mm per 5-minute reading is 1.2 mm/h.
+	if got := rainRateThreshold(0.1); math.Abs(got-1.2) > 1e-9 {
+		t.Errorf("rainRateThreshold(0.1) = %v, want 1.2", got)
+	}
+}
+
+// Aggregates carry rates; comparing them with the depth threshold directly
+// would call a 0.5 mm/h drizzle (0.04 mm per reading) rain.
+func TestFilterRainingComparesRates(t *testing.T) {
+	s := &Server{cfg: config.Config{RainThreshold: 0.1}}
+	aggregates := []db.SensorAggregate{
+		{SensorID: "drizzle", AvgMmH: 0.5},
+		{SensorID: "edge", AvgMmH: 1.2},
+		{SensorID: "rain", AvgMmH: 6},
+	}
+	var got []string
+	for _, agg := range s.filterRaining(aggregates) {
+		got = append(got, agg.SensorID)
+	}
+	if len(got) != 2 || got[0] != "edge" || got[1] != "rain" {
+		t.Errorf("got %v, want [edge rain]", got)
+	}
+}
+
+// The protobuf message is metric only, so inches cannot be honoured there.
+func TestRealtimeNowRejectsProtobufInches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	engine := gin.New()
+	engine.GET("/now", unitsMiddleware(), s.handleV1RealtimeNow)
+
+	req := httptest.NewRequest("GET", "/now?units=in", nil)
+	req.Header.Set("Accept", protobufContentType)
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("status %d, want 406", rec.Code)
+	}
+}
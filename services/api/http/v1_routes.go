@@ -1,31 +1,105 @@
 package http
 
 // registerV1Routes sets up the new v1 API structure
-// Groups: /api/v1/core, /api/v1/grid, /api/v1/realtime
+// Groups: /api/v1/core, /api/v1/grid, /api/v1/realtime, /api/v1/views, /api/v1/admin
 func (s *Server) registerV1Routes() {
 	v1 := s.engine.Group("/api/v1")
 	v1.Use(apiVersionMiddleware()) // Add X-API-Version: v1 header
+	v1.Use(s.timeZoneMiddleware())
+
+	// Public status page; exempt from the bearer token (see publicRoutes)
+	v1.GET("/status", s.handleV1Status)
 
 	// Core endpoints - sensor data and metadata
 	core := v1.Group("/core")
 	{
 		core.GET("/sensors", s.handleV1ListSensors)
+		core.GET("/sensors.geojson", s.handleV1SensorsGeoJSON)
+		core.GET("/sensors/clusters", s.handleV1SensorClusters)
+		core.GET("/sensors/status", s.handleV1SensorsStatus)
+		core.GET("/sensors/bbox", s.handleV1SensorsInBBox)
+		core.GET("/sensors/nearest", s.handleV1NearestSensors)
 		core.GET("/sensors/:id", s.handleV1GetSensor)
+		core.GET("/sensors/:id/measurements", unitsMiddleware(), expandFlagsMiddleware(), s.handleV1SensorMeasurements)
+		core.GET("/sensors/:id/measurements.csv", s.handleV1SensorMeasurementsCSV)
+		core.GET("/sensors/:id/flags", s.handleV1ListManualFlags)
+		core.GET("/sensors/:id/events", s.handleV1SensorEvents)
+		core.GET("/sensors/:id/stats", unitsMiddleware(), s.handleV1SensorStats)
+		core.GET("/sensors/:id/accumulation", unitsMiddleware(), s.handleV1SensorAccumulation)
+		core.GET("/sensors/:id/compare", expandFlagsMiddleware(), s.handleV1SensorCompare)
+		core.GET("/sensors/:id/sources", s.handleV1SensorSources)
+		core.GET("/sensors/:id/availability", s.handleV1SensorAvailability)
+		core.GET("/sensors/:id/completeness", s.handleV1SensorCompleteness)
+		core.GET("/sensors/:id/quality", s.handleV1SensorQuality)
+		core.GET("/sensors/:id/locations", s.handleV1SensorLocations)
+		core.GET("/sensors/:id/changes", s.handleV1SensorChanges)
+		core.GET("/sensors/:id/grid-aggregates", s.requireGrid(), s.handleV1SensorGridAggregates)
+		core.GET("/sensors/:id/context", s.handleV1SensorContext)
+		core.GET("/snapshot", expandFlagsMiddleware(), s.handleV1Snapshot)
+		core.GET("/sources", s.handleV1NetworkSources)
+		core.GET("/qc-flags", s.handleV1QCFlags)
+		core.GET("/comparison", s.handleV1Comparison)
+		core.GET("/measurements", unitsMiddleware(), expandFlagsMiddleware(), s.handleV1BatchMeasurements)
+		core.GET("/measurements.csv", s.handleV1ExportMeasurementsCSV)
+		core.POST("/measurements/flag", requireScope(scopeWrite), s.handleV1FlagMeasurement)
 	}
 
 	// Grid endpoints - grid data with pagination and aggregates
 	grid := v1.Group("/grid")
+	grid.Use(s.requireGrid())
 	{
 		grid.GET("/timestamps", s.handleV1GridTimestamps)
+		grid.GET("/diff", s.handleV1GridDiff)
 		grid.GET("/:timestamp", s.handleV1GridByTimestamp)
 		grid.GET("/:timestamp/sensors", s.handleV1GridSensorAggregates)
 		grid.GET("/:timestamp/contours", s.handleV1GridContours)
+		grid.GET("/:timestamp/thumbnail.png", s.handleV1GridThumbnail)
+		grid.GET("/:timestamp/coverage", s.handleV1GridCoverage)
+		grid.GET("/:timestamp/validation", s.handleV1GridValidation)
 		// Note: Preview JPEG URLs are available in the /realtime/now endpoint's latest.json
 	}
 
 	// Realtime endpoints - latest data
 	realtime := v1.Group("/realtime")
 	{
-		realtime.GET("/now", s.handleV1RealtimeNow)
+		realtime.GET("/now", unitsMiddleware(), s.handleV1RealtimeNow)
+		realtime.GET("/summary", unitsMiddleware(), s.handleV1RealtimeSummary)
+		realtime.GET("/snapshot", expandFlagsMiddleware(), s.handleV1RealtimeSnapshot)
+		realtime.GET("/contours", s.requireGrid(), s.handleV1RealtimeContours)
+		realtime.GET("/classification", s.handleV1RealtimeClassification)
+		realtime.GET("/legend", s.handleV1RealtimeLegend)
+	}
+
+	// Dashboard - headline figures in the shape of the legacy dashboard summary
+	dashboard := v1.Group("/dashboard")
+	{
+		dashboard.GET("/summary", unitsMiddleware(), s.handleV1DashboardSummary)
+	}
+
+	// Bootstrap - first-paint dashboard sections in one response
+	v1.GET("/bootstrap", s.handleV1Bootstrap)
+
+	// Metrics endpoints - data gauges in Prometheus text format
+	v1.GET("/metrics/rainfall", rainfallMetricsHandler(s.store, func() bool { return !s.gridDisabled.Load() }))
+
+	// Saved views - named query definitions executed server-side
+	views := v1.Group("/views")
+	{
+		views.GET("", s.handleV1ListViews)
+		views.POST("", requireScope(scopeWrite), s.handleV1CreateView)
+		views.GET("/:slug", s.handleV1GetView)
+		views.DELETE("/:slug", requireScope(scopeWrite), s.handleV1DeleteView)
+		views.GET("/:slug/execute", s.handleV1ExecuteView)
+	}
+
+	// Admin endpoints - maintenance tooling, write token required
+	admin := v1.Group("/admin", requireScope(scopeWrite))
+	{
+		admin.GET("/grid/duplicates", s.requireGrid(), s.handleV1AdminGridDuplicates)
+		admin.GET("/usage", s.handleV1AdminUsage)
+		admin.GET("/feeds", s.handleV1AdminFeeds)
+		admin.PATCH("/measurements", s.handleV1AdminRepairMeasurements)
+		admin.POST("/retention/run", s.handleV1AdminRunRetention)
+		admin.GET("/retention/jobs/:id", s.handleV1AdminRetentionJob)
 	}
 }
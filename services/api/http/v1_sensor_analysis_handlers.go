@@ -0,0 +1,481 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/rainfall"
+)
+
+// handleV1SensorEvents splits a sensor's clean series into rain events
+// GET /api/v1/core/sensors/:id/events?start=...&end=...&min_gap=6h&min_total=1
+func (s *Server) handleV1SensorEvents(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	minGap, minTotal, err := parseEventThresholds(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	detector := rainfall.EventDetector{MinGap: minGap, MinTotal: minTotal}
+	samples := 0
+	err = s.store.StreamMeasurements(ctx, db.MeasurementQuery{
+		SensorID: sensorID,
+		UseClean: true,
+		Since:    rng.Start,
+		Until:    &rng.End,
+	}, func(m db.Measurement) error {
+		if m.ValueMM == nil {
+			return nil
+		}
+		detector.Add(m.Timestamp, *m.ValueMM)
+		samples++
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	events := detector.Finish(rng.End)
+
+	s.respondJSON(c, gin.H{
+		"data": events,
+		"meta": gin.H{
+			"sensor_id": sensorID,
+			"start":     formatTimestamp(*rng.Start),
+			"end":       formatTimestamp(rng.End),
+			"min_gap":   minGap.String(),
+			"min_total": minTotal,
+			"samples":   samples,
+			"count":     len(events),
+		},
+	})
+}
+
+// parseEventThresholds reads the min_gap and min_total event detection
+// parameters, defaulting to a 6h gap and no minimum total.
+func parseEventThresholds(c *gin.Context) (time.Duration, float64, error) {
+	minGap := 6 * time.Hour
+	if v := c.Query("min_gap"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return 0, 0, errors.New("invalid min_gap, expected a positive duration like 6h")
+		}
+		minGap = d
+	}
+
+	minTotal := 0.0
+	if v := c.Query("min_total"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return 0, 0, errors.New("invalid min_total, expected a non-negative number")
+		}
+		minTotal = f
+	}
+
+	return minGap, minTotal, nil
+}
+
+// handleV1SensorStats returns min, max, mean, sum and p95 of a sensor's
+// value_mm over a window, computed in the database. exclude_imputed=true
+// leaves out imputed clean rows.
+// GET /api/v1/core/sensors/:id/stats?start=...&end=...&clean=true&exclude_imputed=true
+func (s *Server) handleV1SensorStats(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	excludeImputed := false
+	if raw := c.Query("exclude_imputed"); raw != "" {
+		excludeImputed, err = strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid exclude_imputed parameter"})
+			return
+		}
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	q := db.MeasurementQuery{
+		SensorID: sensorID,
+		Since:    rng.Start,
+		Until:    &rng.End,
+	}
+	q.UseClean, err = s.useCleanFor(ctx, mode, q)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	stats, err := s.store.SensorStats(ctx, q, excludeImputed)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": stats,
+		"meta": gin.H{
+			"sensor_id":       sensorID,
+			"start":           formatTimestamp(*rng.Start),
+			"end":             formatTimestamp(rng.End),
+			"clean_mode":      mode,
+			"clean":           q.UseClean,
+			"exclude_imputed": excludeImputed,
+		},
+	})
+}
+
+// defaultCompareEpsilon is the raw/clean difference, in mm, above which the
+// compare summary counts a point as modified.
+const defaultCompareEpsilon = 0.001
+
+// compareSummary tallies how the clean series departs from the raw one.
+type compareSummary struct {
+	Points int `json:"points"`
+	// Imputed counts clean rows with an imputation_method.
+	Imputed int `json:"imputed"`
+	// Dropped counts raw values without a clean value at the same timestamp.
+	Dropped int `json:"dropped"`
+	// Modified counts non-imputed points whose values differ beyond epsilon.
+	Modified  int `json:"modified"`
+	RawOnly   int `json:"raw_only"`
+	CleanOnly int `json:"clean_only"`
+}
+
+func summarizeComparison(points []db.ComparePoint, epsilon float64) compareSummary {
+	sum := compareSummary{Points: len(points)}
+	for _, p := range points {
+		switch {
+		case p.InRaw && !p.InClean:
+			sum.RawOnly++
+		case p.InClean && !p.InRaw:
+			sum.CleanOnly++
+		}
+		switch {
+		case p.ImputationMethod != nil:
+			sum.Imputed++
+		case p.RawValueMM != nil && p.CleanValueMM == nil:
+			sum.Dropped++
+		case p.RawValueMM != nil && math.Abs(*p.CleanValueMM-*p.RawValueMM) > epsilon:
+			sum.Modified++
+		}
+	}
+	return sum
+}
+
+// handleV1SensorCompare returns a sensor's raw and clean series merged on
+// timestamp, each timestamp once with both values, for checking what the ETL
+// changed. The summary counts imputed, dropped and modified points.
+// GET /api/v1/core/sensors/:id/compare?start=...&end=...&epsilon=0.001
+func (s *Server) handleV1SensorCompare(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	epsilon := defaultCompareEpsilon
+	if raw := c.Query("epsilon"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid epsilon, expected a non-negative number of mm"})
+			return
+		}
+		epsilon = v
+	}
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	points, err := s.store.CompareRawClean(ctx, sensorID, *rng.Start, rng.End, s.cfg.MaxRows+1)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	truncated := len(points) > s.cfg.MaxRows
+	if truncated {
+		points = points[:s.cfg.MaxRows]
+	}
+
+	s.respondJSON(c, gin.H{
+		"data":    points,
+		"summary": summarizeComparison(points, epsilon),
+		"meta": gin.H{
+			"sensor_id":  sensorID,
+			"start":      formatTimestamp(*rng.Start),
+			"end":        formatTimestamp(rng.End),
+			"epsilon_mm": epsilon,
+			"truncated":  truncated,
+		},
+	})
+}
+
+// accumulationWindows are the accepted accumulation windows.
+var accumulationWindows = map[string]time.Duration{
+	"1h":  time.Hour,
+	"3h":  3 * time.Hour,
+	"6h":  6 * time.Hour,
+	"12h": 12 * time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// accumulationMaxGap is the longest span one sample may cover: two SIATA
+// reporting intervals, so a single late report still counts as covered.
+const accumulationMaxGap = 10 * time.Minute
+
+// handleV1SensorAccumulation returns the clean rainfall that fell at a sensor
+// over a trailing window, with the span its samples actually cover so
+// clients can flag totals weakened by data gaps
+// GET /api/v1/core/sensors/:id/accumulation?window=24h
+func (s *Server) handleV1SensorAccumulation(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	window := c.DefaultQuery("window", "24h")
+	span, ok := accumulationWindows[window]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window, expected 1h, 3h, 6h, 12h, 24h or 7d"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 20*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	end := time.Now().UTC()
+	start := end.Add(-span)
+	acc := rainfall.Accumulator{Start: start, End: end, MaxGap: accumulationMaxGap}
+	err := s.store.StreamMeasurements(ctx, db.MeasurementQuery{
+		SensorID: sensorID,
+		UseClean: true,
+		Since:    &start,
+		Until:    &end,
+	}, func(m db.Measurement) error {
+		if m.ValueMM != nil {
+			acc.Add(m.Timestamp, *m.ValueMM)
+		}
+		return nil
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": acc.Finish(),
+		"meta": gin.H{
+			"sensor_id":              sensorID,
+			"window":                 window,
+			"start":                  formatTimestamp(start),
+			"end":                    formatTimestamp(end),
+			"max_sample_gap_seconds": int64(accumulationMaxGap / time.Second),
+		},
+	})
+}
+
+// handleV1SensorCompleteness compares a sensor's clean sample count per local
+// day with the count expected at expected_interval (default the 5-minute
+// SIATA cadence), so sensors that silently dropped out show up as low ratios.
+// GET /api/v1/core/sensors/:id/completeness?start=...&end=...&expected_interval=5m&tz=America/Bogota
+func (s *Server) handleV1SensorCompleteness(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	interval := units.SIATAInterval
+	if v := c.Query("expected_interval"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second || d > 24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid expected_interval, expected a duration between 1s and 24h like 5m"})
+			return
+		}
+		interval = d
+	}
+
+	loc, err := parseLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	days, err := s.store.SensorCompleteness(ctx, sensorID, *rng.Start, rng.End, loc.String(), interval)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	expected, actual := 0, 0
+	for _, d := range days {
+		expected += d.Expected
+		actual += d.Actual
+	}
+	var ratio *float64
+	if expected > 0 {
+		r := float64(actual) / float64(expected)
+		ratio = &r
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": days,
+		"meta": gin.H{
+			"sensor_id":                 sensorID,
+			"start":                     formatTimestamp(*rng.Start),
+			"end":                       formatTimestamp(rng.End),
+			"tz":                        loc.String(),
+			"expected_interval_seconds": int64(interval / time.Second),
+			"expected":                  expected,
+			"actual":                    actual,
+			"ratio":                     ratio,
+		},
+	})
+}
+
+// handleV1SensorQuality summarises the provider quality score of a sensor's
+// raw rows: mean, minimum, a histogram of the values and the fraction of rows
+// without one. Ranges where every row lacks a quality report null statistics
+// with a note rather than zeros.
+// GET /api/v1/core/sensors/:id/quality?start=...&end=...
+func (s *Server) handleV1SensorQuality(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+	if rng.Start == nil {
+		start := rng.End.AddDate(0, 0, -s.cfg.DefaultDays)
+		rng.Start = &start
+	}
+	if err := checkRangeSpan(rng, s.cfg.MaxRangeDays); err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	summary, err := s.store.SensorQuality(ctx, sensorID, *rng.Start, rng.End)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	meta := gin.H{
+		"sensor_id": sensorID,
+		"start":     formatTimestamp(*rng.Start),
+		"end":       formatTimestamp(rng.End),
+	}
+	switch {
+	case summary.Rows == 0:
+		meta["note"] = "no raw measurements in range"
+	case summary.NullRows == summary.Rows:
+		meta["note"] = "no raw measurement in range reports a quality"
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": summary,
+		"meta": meta,
+	})
+}
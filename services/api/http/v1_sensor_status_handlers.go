@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reporting states of /api/v1/core/sensors/status.
+const (
+	sensorStateOK            = "ok"
+	sensorStateStale         = "stale"
+	sensorStateOffline       = "offline"
+	sensorStateNeverReported = "never_reported"
+)
+
+// sensorStatus is a sensor's reporting state. GapSeconds is the time since
+// LastTS, nil with it.
+type sensorStatus struct {
+	ID         string     `json:"id"`
+	Name       *string    `json:"name,omitempty"`
+	City       *string    `json:"city,omitempty"`
+	LastTS     *time.Time `json:"last_ts"`
+	GapSeconds *int64     `json:"gap_seconds"`
+	State      string     `json:"state"`
+}
+
+// sensorState classifies the gap since a sensor's latest measurement: ok up
+// to staleAfter, stale up to offlineAfter and offline beyond.
+func sensorState(gap, staleAfter, offlineAfter time.Duration) string {
+	switch {
+	case gap > offlineAfter:
+		return sensorStateOffline
+	case gap > staleAfter:
+		return sensorStateStale
+	}
+	return sensorStateOK
+}
+
+// handleV1SensorsStatus lists every sensor with its latest raw measurement
+// time, the gap since then and a state derived from API_SENSOR_STALE_AFTER
+// and API_SENSOR_OFFLINE_AFTER; sensors without measurements are
+// never_reported.
+// GET /api/v1/core/sensors/status
+func (s *Server) handleV1SensorsStatus(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	seen, err := s.store.ListSensorLastSeen(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	counts := map[string]int{
+		sensorStateOK:            0,
+		sensorStateStale:         0,
+		sensorStateOffline:       0,
+		sensorStateNeverReported: 0,
+	}
+	statuses := make([]sensorStatus, 0, len(seen))
+	for _, ls := range seen {
+		st := sensorStatus{ID: ls.ID, Name: ls.Name, City: ls.City, LastTS: ls.LastTS, State: sensorStateNeverReported}
+		if ls.LastTS != nil {
+			gap := now.Sub(*ls.LastTS)
+			gapSeconds := int64(gap / time.Second)
+			st.GapSeconds = &gapSeconds
+			st.State = sensorState(gap, s.cfg.SensorStaleAfter, s.cfg.SensorOfflineAfter)
+		}
+		counts[st.State]++
+		statuses = append(statuses, st)
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": statuses,
+		"meta": gin.H{
+			"generated_at":          formatTimestamp(now),
+			"stale_after_seconds":   int64(s.cfg.SensorStaleAfter / time.Second),
+			"offline_after_seconds": int64(s.cfg.SensorOfflineAfter / time.Second),
+			"counts":                counts,
+		},
+	})
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+// defaultSnapshotMaxAge is how old a sensor's latest reading may be, relative
+// to the requested ts, before the v1 snapshot reports it as missing.
+const defaultSnapshotMaxAge = 2 * time.Hour
+
+// snapshotRow is a v1 snapshot entry. AgeSeconds is how long before the
+// requested ts the sensor's latest reading was taken; it is kept when the
+// reading itself is dropped as stale.
+type snapshotRow struct {
+	db.SensorSnapshot
+	AgeSeconds *int64 `json:"age_seconds"`
+	Stale      bool   `json:"stale,omitempty"`
+}
+
+// parseHistoricalLocation reads the optional historical_location flag.
+func parseHistoricalLocation(c *gin.Context) (bool, error) {
+	v := c.Query("historical_location")
+	if v == "" {
+		return false, nil
+	}
+	val, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New("invalid historical_location parameter")
+	}
+	return val, nil
+}
+
+// parseSnapshotTS reads the required ts of the v1 snapshot endpoints and
+// rejects instants past the clock-skew tolerance. It writes the 400 itself and
+// reports whether the handler may continue.
+func parseSnapshotTS(c *gin.Context) (time.Time, bool) {
+	tsStr := c.Query("ts")
+	if tsStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ts query parameter required (RFC3339)"})
+		return time.Time{}, false
+	}
+	ts, err := parseTimestamp(tsStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ts format, expected RFC3339"})
+		return time.Time{}, false
+	}
+	if ts.After(time.Now().Add(maxFutureSkew)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ts is in the future; snapshots only cover past readings"})
+		return time.Time{}, false
+	}
+	return ts, true
+}
+
+// snapshotAt returns the latest measurement at or before ts of every sensor
+// the filter keeps. In auto mode sensors without a clean reading fall back to
+// their raw one.
+func (s *Server) snapshotAt(ctx context.Context, ts time.Time, mode cleanMode, resolveLocation bool, filter db.SnapshotFilter) ([]db.SensorSnapshot, error) {
+	snaps, err := s.store.SnapshotAtTimestamp(ctx, ts, mode != cleanFalse, resolveLocation, filter)
+	if err != nil {
+		return nil, err
+	}
+	if mode != cleanAuto {
+		return snaps, nil
+	}
+
+	// Fill sensors without a clean reading from the raw table.
+	raw, err := s.store.SnapshotAtTimestamp(ctx, ts, false, resolveLocation, filter)
+	if err != nil {
+		return nil, err
+	}
+	rawByID := make(map[string]db.SensorSnapshot, len(raw))
+	for _, r := range raw {
+		rawByID[r.ID] = r
+	}
+	for i, snap := range snaps {
+		if r, ok := rawByID[snap.ID]; ok && snap.Ts == nil {
+			snaps[i] = r
+		}
+	}
+	return snaps, nil
+}
+
+// handleV1Snapshot returns one row per sensor with its latest measurement at
+// or before ts (nearest-before, never after). Readings older than max_age
+// before ts come back with null measurement fields and stale=true; max_age=0
+// disables the cut-off. ids and bbox narrow the sensors covered; meta then
+// reports how many sensors were requested and how many matched.
+// GET /api/v1/core/snapshot?ts=...&clean=true&max_age=2h&historical_location=false&ids=a,b&bbox=min_lon,min_lat,max_lon,max_lat
+func (s *Server) handleV1Snapshot(c *gin.Context) {
+	ts, ok := parseSnapshotTS(c)
+	if !ok {
+		return
+	}
+
+	maxAge := defaultSnapshotMaxAge
+	if v := c.Query("max_age"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid max_age, expected a duration such as 2h or 0 to disable"})
+			return
+		}
+		maxAge = d
+	}
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolveLocation, err := parseHistoricalLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bbox, err := parseBBox(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := db.SnapshotFilter{IDs: parseSensorIDs(c.Query("ids")), BBox: bbox}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	snaps, err := s.snapshotAt(ctx, ts, mode, resolveLocation, filter)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	rows := make([]snapshotRow, 0, len(snaps))
+	stale := 0
+	for _, snap := range snaps {
+		row := snapshotRow{SensorSnapshot: snap}
+		if snap.Ts != nil {
+			age := int64(ts.Sub(*snap.Ts) / time.Second)
+			row.AgeSeconds = &age
+			if maxAge > 0 && ts.Sub(*snap.Ts) > maxAge {
+				row.Stale = true
+				row.Ts, row.ValueMM, row.QCFlags, row.Imputation, row.Quality, row.Source = nil, nil, nil, nil, nil, nil
+				stale++
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	meta := gin.H{
+		"requested_ts":    formatTimestamp(ts),
+		"match":           "nearest_before",
+		"description":     "each sensor's latest measurement at or before requested_ts; readings older than max_age are reported as null with stale=true",
+		"max_age_seconds": int64(maxAge / time.Second),
+		"clean_mode":      mode,
+		"sensors":         len(rows),
+		"stale_sensors":   stale,
+	}
+	if len(filter.IDs) > 0 {
+		meta["requested_sensors"] = len(filter.IDs)
+	}
+	if len(filter.IDs) > 0 || bbox != nil {
+		meta["matched_sensors"] = len(rows)
+	}
+	if bbox != nil {
+		meta["bbox"] = bbox
+	}
+	s.respondJSON(c, gin.H{
+		"data": rows,
+		"meta": meta,
+	})
+}
+
+// handleV1RealtimeSnapshot is the v1 port of the legacy GET /snapshot: the
+// latest measurement of every sensor at or before ts, as returned by
+// SnapshotAtTimestamp, under the data/meta envelope. Sensors without a reading
+// are listed without measurement fields; meta counts both groups.
+// GET /api/v1/realtime/snapshot?ts=...&clean=true&historical_location=false
+func (s *Server) handleV1RealtimeSnapshot(c *gin.Context) {
+	ts, ok := parseSnapshotTS(c)
+	if !ok {
+		return
+	}
+
+	mode, err := s.resolveClean(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolveLocation, err := parseHistoricalLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	snaps, err := s.snapshotAt(ctx, ts, mode, resolveLocation, db.SnapshotFilter{})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	withMeasurement := 0
+	for _, snap := range snaps {
+		if snap.Ts != nil {
+			withMeasurement++
+		}
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": snaps,
+		"meta": gin.H{
+			"requested_ts":             formatTimestamp(ts),
+			"clean_mode":               mode,
+			"sensors_total":            len(snaps),
+			"sensors_with_measurement": withMeasurement,
+		},
+	})
+}
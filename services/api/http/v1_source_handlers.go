@@ -0,0 +1,85 @@
+package http
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleV1SensorSources returns the per-source composition of a sensor's raw rows
+// GET /api/v1/core/sensors/:id/sources?start=...&end=...
+func (s *Server) handleV1SensorSources(c *gin.Context) {
+	sensorID := c.Param("id")
+
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	if _, err := s.store.GetSensor(ctx, sensorID); err != nil {
+		c.Error(err)
+		return
+	}
+
+	sources, err := s.store.SensorSourceBreakdown(ctx, sensorID, rng.Start, rng.End)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": sources,
+		"meta": sourcesMeta(rng, gin.H{"sensor_id": sensorID}),
+	})
+}
+
+// handleV1NetworkSources returns the per-source composition of all raw rows
+// GET /api/v1/core/sources?start=...&end=...
+func (s *Server) handleV1NetworkSources(c *gin.Context) {
+	rng, err := parseTimeRange(c)
+	if err != nil {
+		respondRangeError(c, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	doc, err := s.networkSourcesDocument(ctx, rng)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, doc)
+}
+
+// networkSourcesDocument builds the network source breakdown response, shared
+// with the bootstrap endpoint.
+func (s *Server) networkSourcesDocument(ctx context.Context, rng timeRange) (gin.H, error) {
+	sources, err := s.store.NetworkSourceBreakdown(ctx, rng.Start, rng.End)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"data": sources,
+		"meta": sourcesMeta(rng, gin.H{}),
+	}, nil
+}
+
+func sourcesMeta(rng timeRange, meta gin.H) gin.H {
+	if rng.Start != nil {
+		meta["start"] = formatTimestamp(*rng.Start)
+	}
+	meta["end"] = formatTimestamp(rng.End)
+	if rng.Warning != "" {
+		meta["warning"] = rng.Warning
+	}
+	return meta
+}
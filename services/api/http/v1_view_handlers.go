@@ -0,0 +1,360 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+var viewSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// sensorArity describes how many sensors a view endpoint accepts.
+type sensorArity int
+
+const (
+	sensorsNone sensorArity = iota
+	sensorsOne
+	sensorsMany
+)
+
+// viewEndpoint describes an endpoint a saved view can run against: the
+// handler of the live route, the filters it accepts and the param helpers
+// used to validate them.
+type viewEndpoint struct {
+	handle   func(s *Server, c *gin.Context)
+	sensors  sensorArity // a single sensor is passed as the :id parameter
+	filters  []string
+	windowed bool
+	bucketed bool
+	// grid and units mirror the requireGrid and unitsMiddleware of the live
+	// route, which execution does not run through.
+	grid  bool
+	units bool
+	// validate runs the param helpers with the server's defaults, so a view
+	// is checked exactly as the deployment will execute it.
+	validate func(s *Server, c *gin.Context) error
+}
+
+var viewEndpoints = map[string]viewEndpoint{
+	"measurements": {
+		handle:   (*Server).handleV1BatchMeasurements,
+		sensors:  sensorsMany,
+		filters:  []string{"start", "end", "clean", "variable"},
+		windowed: true,
+		bucketed: true,
+		units:    true,
+		validate: func(s *Server, c *gin.Context) error {
+			mode, err := s.resolveClean(c)
+			if err != nil {
+				return err
+			}
+			if _, err := parseVariable(c, mode.strict()); err != nil {
+				return err
+			}
+			if _, err := parseBucket(c); err != nil {
+				return err
+			}
+			_, err = parseTimeRange(c)
+			return err
+		},
+	},
+	"grid_timestamps": {
+		handle:   (*Server).handleV1GridTimestamps,
+		sensors:  sensorsNone,
+		grid:     true,
+		filters:  []string{"start", "end", "page", "limit", "include_sensors"},
+		windowed: true,
+		validate: func(s *Server, c *gin.Context) error {
+			_, err := parseTimeRange(c)
+			return err
+		},
+	},
+	"realtime_now": {
+		handle:  (*Server).handleV1RealtimeNow,
+		sensors: sensorsNone,
+		units:   true,
+		filters: []string{"bbox", "rain_only"},
+		validate: func(s *Server, c *gin.Context) error {
+			if _, err := parseRainOnly(c); err != nil {
+				return err
+			}
+			_, err := parseBBox(c)
+			return err
+		},
+	},
+	"sensor_events": {
+		handle:   (*Server).handleV1SensorEvents,
+		sensors:  sensorsOne,
+		filters:  []string{"start", "end", "min_gap", "min_total"},
+		windowed: true,
+		validate: func(s *Server, c *gin.Context) error {
+			if _, _, err := parseEventThresholds(c); err != nil {
+				return err
+			}
+			_, err := parseTimeRange(c)
+			return err
+		},
+	},
+}
+
+// viewRequest is the body accepted by the view creation endpoint.
+type viewRequest struct {
+	Slug     string            `json:"slug"`
+	Name     string            `json:"name"`
+	Endpoint string            `json:"endpoint"`
+	Sensors  []string          `json:"sensors"`
+	Filters  map[string]string `json:"filters"`
+	Window   string            `json:"window"`
+	Bucket   string            `json:"bucket"`
+}
+
+// resolveView turns a stored view into the path parameters and query string
+// of the live endpoint it targets. Relative windows are resolved against now.
+func resolveView(endpoint string, def db.ViewDefinition) (gin.Params, url.Values, error) {
+	spec, ok := viewEndpoints[endpoint]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown endpoint %q", endpoint)
+	}
+
+	query := url.Values{}
+	for key, val := range def.Filters {
+		if !containsString(spec.filters, key) {
+			return nil, nil, fmt.Errorf("filter %q is not supported by %s (allowed: %s)", key, endpoint, strings.Join(spec.filters, ", "))
+		}
+		query.Set(key, val)
+	}
+
+	if def.Window != "" {
+		if !spec.windowed {
+			return nil, nil, fmt.Errorf("window is not supported by %s", endpoint)
+		}
+		if query.Has("start") || query.Has("end") {
+			return nil, nil, errors.New("window cannot be combined with start or end")
+		}
+		d, err := time.ParseDuration(def.Window)
+		if err != nil || d <= 0 {
+			return nil, nil, errors.New("invalid window, expected a positive duration like 24h")
+		}
+		query.Set("start", time.Now().UTC().Add(-d).Format(time.RFC3339))
+	}
+
+	if def.Bucket != "" {
+		if !spec.bucketed {
+			return nil, nil, fmt.Errorf("bucket is not supported by %s", endpoint)
+		}
+		query.Set("bucket", def.Bucket)
+	}
+
+	var params gin.Params
+	switch spec.sensors {
+	case sensorsNone:
+		if len(def.Sensors) > 0 {
+			return nil, nil, fmt.Errorf("%s does not take sensors", endpoint)
+		}
+	case sensorsOne:
+		if len(def.Sensors) != 1 {
+			return nil, nil, fmt.Errorf("%s requires exactly one sensor", endpoint)
+		}
+		params = gin.Params{{Key: "id", Value: def.Sensors[0]}}
+	case sensorsMany:
+		ids := parseSensorIDs(strings.Join(def.Sensors, ","))
+		if len(ids) == 0 {
+			return nil, nil, fmt.Errorf("%s requires at least one sensor", endpoint)
+		}
+		if len(ids) > maxBatchSensors {
+			return nil, nil, fmt.Errorf("too many sensors, maximum is %d", maxBatchSensors)
+		}
+		query.Set("ids", strings.Join(ids, ","))
+	}
+
+	return params, query, nil
+}
+
+// validateView checks a view definition by running the target endpoint's own
+// param helpers against the query it would execute.
+func (s *Server) validateView(endpoint string, def db.ViewDefinition) error {
+	params, query, err := resolveView(endpoint, def)
+	if err != nil {
+		return err
+	}
+	probe := &gin.Context{Request: &http.Request{URL: &url.URL{RawQuery: query.Encode()}}, Params: params}
+	return viewEndpoints[endpoint].validate(s, probe)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// handleV1CreateView validates and stores a saved view
+// POST /api/v1/views
+func (s *Server) handleV1CreateView(c *gin.Context) {
+	var req viewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON body"})
+		return
+	}
+
+	if !viewSlugPattern.MatchString(req.Slug) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug must be 1-63 lowercase letters, digits or dashes"})
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	if _, ok := viewEndpoints[req.Endpoint]; !ok {
+		names := make([]string, 0, len(viewEndpoints))
+		for name := range viewEndpoints {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "endpoint must be one of: " + strings.Join(names, ", ")})
+		return
+	}
+
+	def := db.ViewDefinition{Sensors: req.Sensors, Filters: req.Filters, Window: req.Window, Bucket: req.Bucket}
+	if err := s.validateView(req.Endpoint, def); err != nil {
+		var rerr *rangeError
+		if errors.As(err, &rerr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view: " + rerr.Message, "start": rerr.Start, "end": rerr.End})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view: " + err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	view, err := s.store.CreateView(ctx, db.SavedView{
+		Slug:       req.Slug,
+		Name:       strings.TrimSpace(req.Name),
+		Endpoint:   req.Endpoint,
+		Definition: def,
+	})
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": view,
+	})
+}
+
+// handleV1ListViews returns all saved views
+// GET /api/v1/views
+func (s *Server) handleV1ListViews(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	views, err := s.store.ListViews(ctx)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": views,
+		"meta": gin.H{
+			"count": len(views),
+		},
+	})
+}
+
+// handleV1GetView returns a single saved view definition
+// GET /api/v1/views/:slug
+func (s *Server) handleV1GetView(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	view, err := s.store.GetView(ctx, c.Param("slug"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	s.respondJSON(c, gin.H{
+		"data": view,
+	})
+}
+
+// handleV1DeleteView removes a saved view
+// DELETE /api/v1/views/:slug
+func (s *Server) handleV1DeleteView(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	if err := s.store.DeleteView(ctx, c.Param("slug")); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// handleV1ExecuteView runs a saved view through the handler of the live
+// endpoint it targets, so the result is identical to calling that endpoint
+// directly
+// GET /api/v1/views/:slug/execute
+func (s *Server) handleV1ExecuteView(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	view, err := s.store.GetView(ctx, c.Param("slug"))
+	cancel()
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	params, query, err := resolveView(view.Endpoint, view.Definition)
+	if err != nil {
+		// Definitions are validated on creation; this only trips if the
+		// endpoint catalogue changed underneath a stored view.
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "saved view is no longer valid: " + err.Error()})
+		return
+	}
+	spec := viewEndpoints[view.Endpoint]
+	if spec.grid && s.gridDisabled.Load() {
+		c.Error(errGridDisabled)
+		return
+	}
+
+	c.Header("X-Saved-View", view.Slug)
+	vc := viewContext(c, params, query)
+	if spec.units {
+		vc.Set(unitsContextKey, unitsMM)
+	}
+	spec.handle(s, vc)
+	for _, e := range vc.Errors {
+		c.Errors = append(c.Errors, e)
+	}
+}
+
+// viewContext derives the context a view's target handler runs on: a copy of
+// the request carrying the view's query and path parameters, writing to c's
+// response and seeing c's keys (token scopes, local_time, tz). A fresh
+// context is needed because c has already cached its own query string.
+func viewContext(c *gin.Context, params gin.Params, query url.Values) *gin.Context {
+	req := c.Request.Clone(c.Request.Context())
+	req.URL.RawQuery = query.Encode()
+	vc := &gin.Context{Request: req, Writer: c.Writer, Params: params}
+	for k, v := range c.Keys {
+		vc.Set(k, v)
+	}
+	return vc
+}
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
+)
+
+func TestResolveView(t *testing.T) {
+	params, query, err := resolveView("sensor_events", db.ViewDefinition{
+		Sensors: []string{"siata/1"},
+		Window:  "24h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := params.ByName("id"); got != "siata/1" {
+		t.Errorf("id param: got %q, want the raw sensor id", got)
+	}
+	if !query.Has("start") || query.Has("end") {
+		t.Errorf("window: got query %v, want only start", query)
+	}
+
+	_, query, err = resolveView("measurements", db.ViewDefinition{
+		Sensors: []string{"a", "b", "a"},
+		Bucket:  "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := query.Get("ids"); got != "a,b" {
+		t.Errorf("ids: got %q, want a,b", got)
+	}
+	if got := query.Get("bucket"); got != "1h" {
+		t.Errorf("bucket: got %q, want 1h", got)
+	}
+}
+
+func TestValidateViewRejects(t *testing.T) {
+	s := &Server{cfg: config.Config{DefaultClean: "true"}}
+	for name, tc := range map[string]struct {
+		endpoint string
+		def      db.ViewDefinition
+	}{
+		"bucket on an unbucketed endpoint": {"realtime_now", db.ViewDefinition{Bucket: "1h"}},
+		"bucket not a multiple of 5m":      {"measurements", db.ViewDefinition{Sensors: []string{"a"}, Bucket: "7m"}},
+		"bucket as a filter":               {"measurements", db.ViewDefinition{Sensors: []string{"a"}, Filters: map[string]string{"bucket": "1h"}}},
+		"sensors on a network endpoint":    {"grid_timestamps", db.ViewDefinition{Sensors: []string{"a"}}},
+		"two sensors for events":           {"sensor_events", db.ViewDefinition{Sensors: []string{"a", "b"}}},
+		"window with start":                {"measurements", db.ViewDefinition{Sensors: []string{"a"}, Window: "1h", Filters: map[string]string{"start": "2025-10-01T00:00:00Z"}}},
+		"invalid clean":                    {"measurements", db.ViewDefinition{Sensors: []string{"a"}, Filters: map[string]string{"clean": "maybe"}}},
+	} {
+		if err := s.validateView(tc.endpoint, tc.def); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	if err := s.validateView("measurements", db.ViewDefinition{Sensors: []string{"a"}, Bucket: "1h", Window: "24h"}); err != nil {
+		t.Errorf("valid bucketed view rejected: %v", err)
+	}
+}
+
+// A view omitting clean is validated under the deployment default, the mode
+// it will run with: a raw-only variable is rejected where clean is the
+// default and accepted where raw is.
+func TestValidateViewUsesDefaultClean(t *testing.T) {
+	def := db.ViewDefinition{Sensors: []string{"a"}, Filters: map[string]string{"variable": "temperatura"}}
+	for _, tc := range []struct {
+		defaultClean string
+		wantErr      bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"auto", false},
+	} {
+		s := &Server{cfg: config.Config{DefaultClean: tc.defaultClean}}
+		if err := s.validateView("measurements", def); (err != nil) != tc.wantErr {
+			t.Errorf("default clean=%s: err %v, want error %t", tc.defaultClean, err, tc.wantErr)
+		}
+	}
+
+	// An explicit clean filter still wins over the default.
+	def.Filters["clean"] = "true"
+	s := &Server{cfg: config.Config{DefaultClean: "false"}}
+	if err := s.validateView("measurements", def); err == nil {
+		t.Error("clean=true with a raw-only variable accepted under default clean=false")
+	}
+}
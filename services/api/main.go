@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"log"
+	"os"
 	"os/signal"
 	"syscall"
 
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/migrate"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/config"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/db"
 	httpserver "github.com/02loveslollipop/Shizuku-precipitation-viewer/services/api/http"
@@ -16,17 +18,35 @@ func main() {
 	if err != nil {
 		log.Fatalf("config error: %v", err)
 	}
+	log.Printf("config: %s", cfg.Redacted())
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
-	store, err := db.New(ctx, cfg.DatabaseURL)
+	// "api migrate up|down|status" manages the schema without serving.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate.Command(ctx, cfg.DatabaseURL, os.Args[2:], os.Stdout); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+	if cfg.RunMigrations {
+		if err := migrate.Run(ctx, cfg.DatabaseURL); err != nil {
+			log.Fatalf("migration error: %v", err)
+		}
+	}
+
+	store, err := db.New(ctx, cfg.DatabaseURL, cfg.DBMinConns)
 	if err != nil {
 		log.Fatalf("db connection error: %v", err)
 	}
 	defer store.Close()
+	store.EnableMeasurementCache(cfg.MeasurementCacheTTL)
 
-	srv := httpserver.New(cfg, store)
+	srv, err := httpserver.New(cfg, store)
+	if err != nil {
+		log.Fatalf("server setup error: %v", err)
+	}
 	log.Printf("REST API listening on %s", cfg.ListenAddr())
 
 	if err := srv.Run(ctx); err != nil {
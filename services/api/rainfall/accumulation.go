@@ -0,0 +1,81 @@
+package rainfall
+
+import "time"
+
+// Accumulation is the rainfall that fell over a window, with how much of the
+// window the samples actually cover.
+type Accumulation struct {
+	TotalMM           float64    `json:"total_mm"`
+	SampleCount       int        `json:"sample_count"`
+	FirstTS           *time.Time `json:"first_ts"`
+	LastTS            *time.Time `json:"last_ts"`
+	CoveredSeconds    int64      `json:"covered_seconds"`
+	CoverageRatio     float64    `json:"coverage_ratio"`
+	LongestGapSeconds int64      `json:"longest_gap_seconds"`
+}
+
+// Accumulator integrates a time-ordered series of per-interval depths over
+// [Start, End] step-wise: each sample contributes its depth and covers the
+// interval back to the previous sample, but never more than MaxGap, so a
+// sample arriving after an outage does not paper over it. Time after the last
+// sample is uncovered. Feed samples with Add and collect the result with
+// Finish.
+type Accumulator struct {
+	Start, End time.Time
+	MaxGap     time.Duration
+
+	acc     Accumulation
+	prevTS  time.Time
+	covered time.Duration
+	longest time.Duration
+}
+
+// Add consumes the next sample; samples outside the window are ignored.
+func (a *Accumulator) Add(ts time.Time, valueMM float64) {
+	if ts.Before(a.Start) || ts.After(a.End) {
+		return
+	}
+
+	from := a.Start
+	if !a.prevTS.IsZero() {
+		from = a.prevTS
+	}
+	span := ts.Sub(from)
+	if span > a.longest {
+		a.longest = span
+	}
+	if span > a.MaxGap {
+		span = a.MaxGap
+	}
+	a.covered += span
+	a.prevTS = ts
+
+	a.acc.TotalMM += valueMM
+	a.acc.SampleCount++
+	if a.acc.FirstTS == nil {
+		first := ts
+		a.acc.FirstTS = &first
+	}
+	last := ts
+	a.acc.LastTS = &last
+}
+
+// Finish returns the accumulation over the window.
+func (a *Accumulator) Finish() Accumulation {
+	acc := a.acc
+	tail := a.End.Sub(a.Start)
+	if !a.prevTS.IsZero() {
+		tail = a.End.Sub(a.prevTS)
+	}
+	longest := a.longest
+	if tail > longest {
+		longest = tail
+	}
+
+	acc.CoveredSeconds = int64(a.covered / time.Second)
+	acc.LongestGapSeconds = int64(longest / time.Second)
+	if window := a.End.Sub(a.Start); window > 0 {
+		acc.CoverageRatio = float64(a.covered) / float64(window)
+	}
+	return acc
+}
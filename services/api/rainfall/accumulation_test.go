@@ -0,0 +1,119 @@
+package rainfall
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// sample is a per-interval depth at an offset from the window start.
+type sample struct {
+	at time.Duration
+	mm float64
+}
+
+func accumulate(window, maxGap time.Duration, samples []sample) Accumulation {
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	acc := Accumulator{Start: start, End: start.Add(window), MaxGap: maxGap}
+	for _, s := range samples {
+		acc.Add(start.Add(s.at), s.mm)
+	}
+	return acc.Finish()
+}
+
+// every returns samples of mm every step in [from, to].
+func every(from, to, step time.Duration, mm float64) []sample {
+	var out []sample
+	for at := from; at <= to; at += step {
+		out = append(out, sample{at, mm})
+	}
+	return out
+}
+
+func TestAccumulatorGappySeries(t *testing.T) {
+	const step = 5 * time.Minute
+	for _, tc := range []struct {
+		name        string
+		samples     []sample
+		wantTotal   float64
+		wantCount   int
+		wantCovered time.Duration
+		wantLongest time.Duration
+	}{
+		{
+			name:        "complete",
+			samples:     every(step, time.Hour, step, 0.5),
+			wantTotal:   6,
+			wantCount:   12,
+			wantCovered: time.Hour,
+			wantLongest: step,
+		},
+		{
+			name:        "no samples",
+			wantCovered: 0,
+			wantLongest: time.Hour,
+		},
+		{
+			// A 30-minute outage in the middle counts for MaxGap only, while
+			// the sample after it still contributes its depth.
+			name:        "outage in the middle",
+			samples:     append(every(step, 15*time.Minute, step, 1), every(45*time.Minute, time.Hour, step, 1)...),
+			wantTotal:   7,
+			wantCount:   7,
+			wantCovered: 15*time.Minute + 10*time.Minute + 15*time.Minute,
+			wantLongest: 30 * time.Minute,
+		},
+		{
+			name:        "late start",
+			samples:     every(40*time.Minute, time.Hour, step, 2),
+			wantTotal:   10,
+			wantCount:   5,
+			wantCovered: 10*time.Minute + 20*time.Minute,
+			wantLongest: 40 * time.Minute,
+		},
+		{
+			// Time after the last sample is uncovered.
+			name:        "early stop",
+			samples:     every(step, 20*time.Minute, step, 0.25),
+			wantTotal:   1,
+			wantCount:   4,
+			wantCovered: 20 * time.Minute,
+			wantLongest: 40 * time.Minute,
+		},
+		{
+			name:        "irregular sampling",
+			samples:     []sample{{2 * time.Minute, 0.2}, {9 * time.Minute, 0.7}, {11 * time.Minute, 0.1}, {58 * time.Minute, 0.3}},
+			wantTotal:   1.3,
+			wantCount:   4,
+			wantCovered: 2*time.Minute + 7*time.Minute + 2*time.Minute + 10*time.Minute,
+			wantLongest: 47 * time.Minute,
+		},
+		{
+			name:        "samples outside the window",
+			samples:     []sample{{-step, 9}, {30 * time.Minute, 1}, {time.Hour + step, 9}},
+			wantTotal:   1,
+			wantCount:   1,
+			wantCovered: 10 * time.Minute,
+			wantLongest: 30 * time.Minute,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := accumulate(time.Hour, 10*time.Minute, tc.samples)
+			if math.Abs(got.TotalMM-tc.wantTotal) > 1e-9 || got.SampleCount != tc.wantCount {
+				t.Errorf("total %v mm from %d samples, want %v from %d", got.TotalMM, got.SampleCount, tc.wantTotal, tc.wantCount)
+			}
+			if got.CoveredSeconds != int64(tc.wantCovered/time.Second) {
+				t.Errorf("covered %ds, want %v", got.CoveredSeconds, tc.wantCovered)
+			}
+			if want := float64(tc.wantCovered) / float64(time.Hour); math.Abs(got.CoverageRatio-want) > 1e-9 {
+				t.Errorf("coverage ratio %v, want %v", got.CoverageRatio, want)
+			}
+			if got.LongestGapSeconds != int64(tc.wantLongest/time.Second) {
+				t.Errorf("longest gap %ds, want %v", got.LongestGapSeconds, tc.wantLongest)
+			}
+			if (got.FirstTS == nil) != (tc.wantCount == 0) || (got.LastTS == nil) != (tc.wantCount == 0) {
+				t.Errorf("first/last %v/%v with %d samples", got.FirstTS, got.LastTS, tc.wantCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package rainfall
+
+import "time"
+
+// Window is a half-open [Start, End) time span.
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ComparisonWindows returns the current period of days local calendar days
+// ending at now (started at midnight in loc, days-1 days ago) and the same
+// slice of the preceding period. A current period that is only partly
+// elapsed is compared against the equally long start of the previous one,
+// e.g. today until 10:00 against yesterday until 10:00, never a full day.
+func ComparisonWindows(now time.Time, days int, loc *time.Location) (current, previous Window) {
+	local := now.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	curStart := midnight.AddDate(0, 0, -(days - 1))
+	prevStart := curStart.AddDate(0, 0, -days)
+	elapsed := now.Sub(curStart)
+
+	current = Window{Start: curStart.UTC(), End: now.UTC()}
+	previous = Window{Start: prevStart.UTC(), End: prevStart.Add(elapsed).UTC()}
+	return current, previous
+}
+
+// PercentChange is the relative change from previous to current in percent,
+// or nil when previous is zero and the change is undefined.
+func PercentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	pct := (current - previous) / previous * 100
+	return &pct
+}
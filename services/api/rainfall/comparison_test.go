@@ -0,0 +1,90 @@
+package rainfall
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComparisonWindowsPartialPeriod(t *testing.T) {
+	bogota := time.FixedZone("COT", -5*60*60)
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 10, day, hour, min, 0, 0, bogota).UTC()
+	}
+
+	for _, tc := range []struct {
+		name         string
+		now          time.Time
+		days         int
+		wantCurrent  Window
+		wantPrevious Window
+	}{
+		{
+			// Today until 10:00 against yesterday until 10:00, not all of it.
+			name:         "day until 10:00",
+			now:          at(15, 10, 0),
+			days:         1,
+			wantCurrent:  Window{at(15, 0, 0), at(15, 10, 0)},
+			wantPrevious: Window{at(14, 0, 0), at(14, 10, 0)},
+		},
+		{
+			// Local midnight is 05:00 UTC, so 03:00 UTC is still the 14th.
+			name:         "UTC date ahead of the local date",
+			now:          time.Date(2025, 10, 15, 3, 0, 0, 0, time.UTC),
+			days:         1,
+			wantCurrent:  Window{at(14, 0, 0), at(14, 22, 0)},
+			wantPrevious: Window{at(13, 0, 0), at(13, 22, 0)},
+		},
+		{
+			name:         "week six and a half days in",
+			now:          at(15, 12, 30),
+			days:         7,
+			wantCurrent:  Window{at(9, 0, 0), at(15, 12, 30)},
+			wantPrevious: Window{at(2, 0, 0), at(8, 12, 30)},
+		},
+		{
+			name:         "at local midnight both windows are empty",
+			now:          at(15, 0, 0),
+			days:         1,
+			wantCurrent:  Window{at(15, 0, 0), at(15, 0, 0)},
+			wantPrevious: Window{at(14, 0, 0), at(14, 0, 0)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cur, prev := ComparisonWindows(tc.now, tc.days, bogota)
+			if !cur.Start.Equal(tc.wantCurrent.Start) || !cur.End.Equal(tc.wantCurrent.End) {
+				t.Errorf("current %s–%s, want %s–%s", cur.Start, cur.End, tc.wantCurrent.Start, tc.wantCurrent.End)
+			}
+			if !prev.Start.Equal(tc.wantPrevious.Start) || !prev.End.Equal(tc.wantPrevious.End) {
+				t.Errorf("previous %s–%s, want %s–%s", prev.Start, prev.End, tc.wantPrevious.Start, tc.wantPrevious.End)
+			}
+			if got, want := prev.End.Sub(prev.Start), cur.End.Sub(cur.Start); got != want {
+				t.Errorf("previous spans %s, current %s; want equal lengths", got, want)
+			}
+			if cur.Start.Location() != time.UTC || prev.End.Location() != time.UTC {
+				t.Error("windows are not in UTC")
+			}
+		})
+	}
+}
+
+func TestPercentChange(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	for _, tc := range []struct {
+		current, previous float64
+		want              *float64
+	}{
+		{current: 15, previous: 10, want: ptr(50)},
+		{current: 5, previous: 10, want: ptr(-50)},
+		{current: 0, previous: 4, want: ptr(-100)},
+		{current: 3, previous: 0, want: nil},
+		{current: 0, previous: 0, want: nil},
+	} {
+		got := PercentChange(tc.current, tc.previous)
+		switch {
+		case tc.want == nil && got != nil:
+			t.Errorf("PercentChange(%v, %v) = %v, want nil", tc.current, tc.previous, *got)
+		case tc.want != nil && (got == nil || *got != *tc.want):
+			t.Errorf("PercentChange(%v, %v) = %v, want %v", tc.current, tc.previous, got, *tc.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package rainfall holds hydrological computations over measurement series
+// that are easier to express in Go than in SQL.
+package rainfall
+
+import (
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+)
+
+// Event is a contiguous period of rainfall separated from its neighbours by at
+// least the detector's minimum dry gap.
+type Event struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	DurationSeconds  int64     `json:"duration_seconds"`
+	TotalMM          float64   `json:"total_mm"`
+	PeakIntensityMmH float64   `json:"peak_intensity_mm_h"`
+	PeakTime         time.Time `json:"peak_time"`
+	SampleCount      int       `json:"sample_count"`
+	Ongoing          bool      `json:"ongoing"`
+}
+
+// EventDetector splits a time-ordered series of per-interval accumulations
+// into rain events. Feed samples with Add and collect results with Finish.
+type EventDetector struct {
+	MinGap   time.Duration
+	MinTotal float64
+
+	events []Event
+	cur    *Event
+	prevTS time.Time
+}
+
+// Add consumes the next sample. Samples must arrive in ascending time order;
+// values <= 0 count as dry.
+func (d *EventDetector) Add(ts time.Time, valueMM float64) {
+	// The first sample of a series is assumed to cover one SIATA interval.
+	interval := units.SIATAInterval
+	if !d.prevTS.IsZero() && ts.After(d.prevTS) {
+		interval = ts.Sub(d.prevTS)
+	}
+	d.prevTS = ts
+
+	if valueMM <= 0 {
+		return
+	}
+
+	if d.cur != nil && ts.Sub(d.cur.End) >= d.MinGap {
+		d.close()
+	}
+	if d.cur == nil {
+		d.cur = &Event{Start: ts, PeakTime: ts}
+	}
+
+	d.cur.End = ts
+	d.cur.TotalMM += valueMM
+	d.cur.SampleCount++
+	if intensity := units.Accumulation(valueMM).Over(interval).MMPerHour(); intensity > d.cur.PeakIntensityMmH {
+		d.cur.PeakIntensityMmH = intensity
+		d.cur.PeakTime = ts
+	}
+}
+
+func (d *EventDetector) close() {
+	ev := *d.cur
+	d.cur = nil
+	ev.DurationSeconds = int64(ev.End.Sub(ev.Start) / time.Second)
+	if ev.TotalMM >= d.MinTotal {
+		d.events = append(d.events, ev)
+	}
+}
+
+// Finish closes any open event and returns the detected events. An event
+// whose trailing dry period has not reached MinGap by rangeEnd is marked
+// ongoing, since more rain may still extend it.
+func (d *EventDetector) Finish(rangeEnd time.Time) []Event {
+	if d.cur != nil {
+		d.cur.Ongoing = rangeEnd.Sub(d.cur.End) < d.MinGap
+		d.close()
+	}
+	if d.events == nil {
+		return []Event{}
+	}
+	return d.events
+}
@@ -0,0 +1,191 @@
+package rainfall
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// wantEvent is the part of an Event the table checks, as offsets from the
+// series start.
+type wantEvent struct {
+	start, end time.Duration
+	totalMM    float64
+	samples    int
+	ongoing    bool
+}
+
+func detect(minGap time.Duration, minTotal float64, rangeEnd time.Duration, samples []sample) []Event {
+	start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+	d := EventDetector{MinGap: minGap, MinTotal: minTotal}
+	for _, s := range samples {
+		d.Add(start.Add(s.at), s.mm)
+	}
+	return d.Finish(start.Add(rangeEnd))
+}
+
+func concat(parts ...[]sample) []sample {
+	var out []sample
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestEventDetector(t *testing.T) {
+	const step = 5 * time.Minute
+	for _, tc := range []struct {
+		name     string
+		minGap   time.Duration
+		minTotal float64
+		rangeEnd time.Duration
+		samples  []sample
+		want     []wantEvent
+	}{
+		{
+			name:     "dry series",
+			minGap:   time.Hour,
+			rangeEnd: 6 * time.Hour,
+			samples:  every(0, 2*time.Hour, step, 0),
+			want:     nil,
+		},
+		{
+			name:     "gap longer than min gap splits",
+			minGap:   time.Hour,
+			rangeEnd: 6 * time.Hour,
+			samples: concat(
+				every(0, 30*time.Minute, step, 0.5),
+				every(35*time.Minute, 2*time.Hour, step, 0),
+				every(2*time.Hour+5*time.Minute, 2*time.Hour+15*time.Minute, step, 1),
+			),
+			want: []wantEvent{
+				{start: 0, end: 30 * time.Minute, totalMM: 3.5, samples: 7},
+				{start: 2*time.Hour + 5*time.Minute, end: 2*time.Hour + 15*time.Minute, totalMM: 3, samples: 3},
+			},
+		},
+		{
+			name:     "dry spell shorter than min gap does not split",
+			minGap:   time.Hour,
+			rangeEnd: 6 * time.Hour,
+			samples: []sample{
+				{0, 1},
+				{55 * time.Minute, 1},
+			},
+			want: []wantEvent{
+				{start: 0, end: 55 * time.Minute, totalMM: 2, samples: 2},
+			},
+		},
+		{
+			name:     "dry spell of exactly min gap splits",
+			minGap:   time.Hour,
+			rangeEnd: 6 * time.Hour,
+			samples: []sample{
+				{0, 1},
+				{time.Hour, 1},
+			},
+			want: []wantEvent{
+				{start: 0, end: 0, totalMM: 1, samples: 1},
+				{start: time.Hour, end: time.Hour, totalMM: 1, samples: 1},
+			},
+		},
+		{
+			name:     "event below min total is dropped",
+			minGap:   time.Hour,
+			minTotal: 1,
+			rangeEnd: 6 * time.Hour,
+			samples: []sample{
+				{0, 0.2},
+				{5 * time.Minute, 0.3},
+				{3 * time.Hour, 2},
+			},
+			want: []wantEvent{
+				{start: 3 * time.Hour, end: 3 * time.Hour, totalMM: 2, samples: 1},
+			},
+		},
+		{
+			name:     "event exactly at min total is kept",
+			minGap:   time.Hour,
+			minTotal: 1,
+			rangeEnd: 6 * time.Hour,
+			samples: []sample{
+				{0, 0.5},
+				{5 * time.Minute, 0.5},
+			},
+			want: []wantEvent{
+				{start: 0, end: 5 * time.Minute, totalMM: 1, samples: 2},
+			},
+		},
+		{
+			name:     "event open at range end is ongoing",
+			minGap:   time.Hour,
+			rangeEnd: 6 * time.Hour,
+			samples: concat(
+				every(0, 10*time.Minute, step, 1),
+				every(5*time.Hour+30*time.Minute, 5*time.Hour+50*time.Minute, step, 0.5),
+			),
+			want: []wantEvent{
+				{start: 0, end: 10 * time.Minute, totalMM: 3, samples: 3},
+				{start: 5*time.Hour + 30*time.Minute, end: 5*time.Hour + 50*time.Minute, totalMM: 2.5, samples: 5, ongoing: true},
+			},
+		},
+		{
+			name:     "trailing dry spell of min gap closes the last event",
+			minGap:   time.Hour,
+			rangeEnd: 2 * time.Hour,
+			samples:  every(0, time.Hour, step, 0.5),
+			want: []wantEvent{
+				{start: 0, end: time.Hour, totalMM: 6.5, samples: 13},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got := detect(tc.minGap, tc.minTotal, tc.rangeEnd, tc.samples)
+			if got == nil {
+				t.Fatal("Finish returned nil, want a non-nil slice")
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %d events, want %d: %+v", len(got), len(tc.want), got)
+			}
+			start := time.Date(2025, 10, 1, 0, 0, 0, 0, time.UTC)
+			for i, w := range tc.want {
+				ev := got[i]
+				if !ev.Start.Equal(start.Add(w.start)) || !ev.End.Equal(start.Add(w.end)) {
+					t.Errorf("event %d: span %s–%s, want +%s–+%s", i, ev.Start, ev.End, w.start, w.end)
+				}
+				if ev.DurationSeconds != int64((w.end-w.start)/time.Second) {
+					t.Errorf("event %d: duration %ds, want %s", i, ev.DurationSeconds, w.end-w.start)
+				}
+				if math.Abs(ev.TotalMM-w.totalMM) > 1e-9 {
+					t.Errorf("event %d: total %v, want %v", i, ev.TotalMM, w.totalMM)
+				}
+				if ev.SampleCount != w.samples {
+					t.Errorf("event %d: %d samples, want %d", i, ev.SampleCount, w.samples)
+				}
+				if ev.Ongoing != w.ongoing {
+					t.Errorf("event %d: ongoing %v, want %v", i, ev.Ongoing, w.ongoing)
+				}
+			}
+		})
+	}
+}
+
+func TestEventDetectorPeakIntensity(t *testing.T) {
+	got := detect(time.Hour, 0, 6*time.Hour, []sample{
+		{0, 0.5},
+		{5 * time.Minute, 2},
+		{10 * time.Minute, 1},
+		// A 10-minute interval halves the intensity of the same depth.
+		{20 * time.Minute, 3},
+	})
+	if len(got) != 1 {
+		t.Fatalf("got %d events, want 1", len(got))
+	}
+	ev := got[0]
+	// 2 mm in 5 min = 24 mm/h beats 3 mm in 10 min = 18 mm/h.
+	if math.Abs(ev.PeakIntensityMmH-24) > 1e-9 {
+		t.Errorf("peak intensity %v, want 24", ev.PeakIntensityMmH)
+	}
+	if want := time.Date(2025, 10, 1, 0, 5, 0, 0, time.UTC); !ev.PeakTime.Equal(want) {
+		t.Errorf("peak time %s, want %s", ev.PeakTime, want)
+	}
+}
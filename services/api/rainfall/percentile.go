@@ -0,0 +1,39 @@
+package rainfall
+
+import "sort"
+
+// Percentile returns the p-th percentile (0-100) of values using linear
+// interpolation between closest ranks, or nil for an empty slice. values is
+// sorted in place.
+func Percentile(values []float64, p float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	pos := p / 100 * float64(len(values)-1)
+	lo := int(pos)
+	v := values[lo]
+	if lo+1 < len(values) {
+		v += (pos - float64(lo)) * (values[lo+1] - v)
+	}
+	return &v
+}
+
+// PercentileRank is the share of values below x, counting ties as half, in
+// percent; nil for an empty slice.
+func PercentileRank(values []float64, x float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	var below, equal int
+	for _, v := range values {
+		switch {
+		case v < x:
+			below++
+		case v == x:
+			equal++
+		}
+	}
+	rank := (float64(below) + float64(equal)/2) / float64(len(values)) * 100
+	return &rank
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/siata"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/utils"
+)
+
+// historicFeedMaxBytes caps the historic feed document, which holds every
+// station's full series.
+const historicFeedMaxBytes = 1 << 30
+
+// backfillChunk is how many measurements each backfill transaction inserts.
+// A failed run keeps the chunks already committed, and a re-run resumes
+// after them.
+const backfillChunk = 5000
+
+// runBackfill loads the SIATA historic feed into raw_measurements with
+// source 'historic', within [BackfillStart, BackfillEnd). Readings go
+// through the same keep rules as live ingestion, so a series is thinned to
+// what the watcher would have stored polling it every MinInterval.
+func runBackfill(parent context.Context, cfg config.Config) error {
+	ctx, cancel := context.WithTimeout(parent, cfg.BackfillTimeout)
+	defer cancel()
+
+	retrievalTS := time.Now().UTC().Truncate(time.Second)
+	// Retries stay with siata.RetryPolicy; BackfillTimeout bounds the fetch.
+	client := httpclient.New(httpclient.Options{
+		MaxResponseBytes: historicFeedMaxBytes,
+		Observe:          observeFeedRequest,
+	})
+	retry := siata.RetryPolicy{MaxRetries: cfg.MaxRetries, BaseDelay: cfg.RetryBaseDelay}
+
+	pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return dbError(err)
+	}
+	defer pool.Close()
+
+	if !cfg.DryRun {
+		release, ok, err := db.TryRunLock(ctx, pool)
+		if err != nil {
+			return dbError(err)
+		}
+		if !ok {
+			log.Printf("another run holds the lock; skipping the backfill")
+			return nil
+		}
+		defer release()
+	}
+
+	all, err := siata.FetchHistoricStations(ctx, client, cfg.HistoricURL, retry)
+	if err != nil {
+		cycleErrors.WithLabelValues("fetch").Inc()
+		return err
+	}
+	stationsFetched.Add(float64(len(all)))
+	log.Printf("backfill: fetched %d historic stations", len(all))
+
+	stations := make([]models.HistoricStation, 0, len(all))
+	for _, st := range all {
+		if err := utils.ValidateHistoricStation(st); err != nil {
+			log.Printf("warning: skipping historic station %s: %v", st.Code, err)
+			continue
+		}
+		stations = append(stations, st)
+	}
+
+	sensorRows := utils.BuildHistoricSensorRows(stations)
+	candidates, badDates := utils.BuildHistoricCandidates(stations, cfg.HistoricZone, cfg.BackfillStart, cfg.BackfillEnd, cfg.NullSentinels, retrievalTS)
+	if badDates > 0 {
+		log.Printf("warning: skipped %d historic slots with unparseable dates", badDates)
+	}
+
+	if err := backfillWrite(ctx, cfg, pool, sensorRows, candidates); err != nil {
+		cycleErrors.WithLabelValues("db").Inc()
+		return err
+	}
+	lastSuccess.SetToCurrentTime()
+	return nil
+}
+
+// backfillWrite upserts the historic sensors and inserts the candidates that
+// pass FilterHistoricMeasurements.
+func backfillWrite(ctx context.Context, cfg config.Config, pool *pgxpool.Pool, sensorRows []models.SensorRow, candidates []models.MeasurementCandidate) error {
+	metadataChanges, err := diffSensorMetadata(ctx, pool, sensorRows)
+	if err != nil {
+		return err
+	}
+
+	if cfg.DryRun {
+		log.Printf("dry-run: skipping upsert of %d historic sensors (%d metadata changes)", len(sensorRows), len(metadataChanges))
+	} else {
+		if err := db.SyncSensors(ctx, pool, sensorRows, metadataChanges, nil, time.Now().UTC()); err != nil {
+			return err
+		}
+		sensorsUpserted.Add(float64(len(sensorRows)))
+	}
+
+	last, err := db.FetchLastMeasurements(ctx, pool, utils.SensorIDs(sensorRows), db.SourceHistoric)
+	if err != nil {
+		return err
+	}
+	kept := utils.FilterHistoricMeasurements(candidates, last, cfg.MinInterval, cfg.ValueEpsilon)
+	candidatesFiltered.Add(float64(len(candidates) - len(kept)))
+
+	byReason := utils.CountByReason(kept)
+	log.Printf("backfill: %d of %d readings to insert (dry-run=%v): %d %s, %d %s, %d %s", len(kept), len(candidates), cfg.DryRun,
+		byReason[utils.ReasonNewSensor], utils.ReasonNewSensor,
+		byReason[utils.ReasonInterval], utils.ReasonInterval,
+		byReason[utils.ReasonValueChanged], utils.ReasonValueChanged)
+	if cfg.DryRun {
+		return nil
+	}
+
+	pending := utils.FilteredCandidates(kept)
+	for start := 0; start < len(pending); start += backfillChunk {
+		chunk := pending[start:min(start+backfillChunk, len(pending))]
+		if err := db.InsertMeasurements(ctx, pool, chunk, db.SourceHistoric); err != nil {
+			return err
+		}
+		measurementsInserted.Add(float64(len(chunk)))
+	}
+	log.Printf("backfill: inserted %d historic measurements", len(pending))
+	return nil
+}
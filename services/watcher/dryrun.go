@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/utils"
+)
+
+// dryRunCycle is the DRY_RUN_FORMAT=json document of one cycle: the report of
+// every feed, in configuration order, for CI to diff against expectations.
+type dryRunCycle struct {
+	Feeds []dryRunReport `json:"feeds"`
+}
+
+// dryRunReport summarizes the decisions a cycle would have made for one feed.
+// Error is set instead when fetching or reading the feed failed.
+type dryRunReport struct {
+	Feed            string               `json:"feed"`
+	Error           string               `json:"error,omitempty"`
+	RetrievalTS     time.Time            `json:"retrieval_ts"`
+	Network         string               `json:"network"`
+	Stations        int                  `json:"stations"`
+	SkippedStations int                  `json:"skipped_stations"`
+	Sensors         int                  `json:"sensors"`
+	LocationChanges int                  `json:"location_changes"`
+	Candidates      int                  `json:"candidates"`
+	Filtered        int                  `json:"filtered"`
+	Pending         int                  `json:"pending"`
+	ByReason        map[utils.Reason]int `json:"by_reason"`
+	Measurements    []dryRunMeasurement  `json:"measurements"`
+}
+
+// dryRunMeasurement is one candidate the cycle would insert.
+type dryRunMeasurement struct {
+	SensorID    string       `json:"sensor_id"`
+	TS          time.Time    `json:"ts"`
+	RetrievedAt time.Time    `json:"retrieved_at"`
+	Value       *float64     `json:"value"`
+	Reason      utils.Reason `json:"reason"`
+}
+
+// add records a pending measurement and the reason it was kept.
+func (r *dryRunReport) add(m utils.FilteredMeasurement) {
+	r.ByReason[m.Reason]++
+	r.Measurements = append(r.Measurements, dryRunMeasurement{
+		SensorID:    m.Candidate.SensorID,
+		TS:          m.Candidate.TS,
+		RetrievedAt: m.Candidate.RetrievedAt,
+		Value:       m.Candidate.Value,
+		Reason:      m.Reason,
+	})
+}
+
+// write emits the cycle as one indented JSON document.
+func (r *dryRunCycle) write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,110 @@
+// Package chaos injects feed and database failures and latency so staging
+// can rehearse the watcher's failure handling. A nil *Injector is inert.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInjected is returned for injected database failures.
+var ErrInjected = errors.New("chaos: injected database error")
+
+// Config holds the failure rates (0-1) and the latency added before each
+// feed request and database call.
+type Config struct {
+	FeedErrorRate float64
+	DBErrorRate   float64
+	Latency       time.Duration
+}
+
+// Enabled reports whether any injection is configured.
+func (c Config) Enabled() bool {
+	return c.FeedErrorRate > 0 || c.DBErrorRate > 0 || c.Latency > 0
+}
+
+// Injector applies a Config. Methods on a nil Injector do nothing.
+type Injector struct {
+	cfg Config
+}
+
+// New returns an Injector for cfg, or nil when cfg injects nothing.
+func New(cfg Config) *Injector {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &Injector{cfg: cfg}
+}
+
+// sleep waits for the configured latency or until ctx is done.
+func (in *Injector) sleep(ctx context.Context) error {
+	if in.cfg.Latency <= 0 {
+		return nil
+	}
+	t := time.NewTimer(in.cfg.Latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// DB guards a database call: it adds latency and fails with ErrInjected at
+// the configured rate.
+func (in *Injector) DB(ctx context.Context) error {
+	if in == nil {
+		return nil
+	}
+	if err := in.sleep(ctx); err != nil {
+		return err
+	}
+	if rand.Float64() < in.cfg.DBErrorRate {
+		return ErrInjected
+	}
+	return nil
+}
+
+// Transport decorates base so feed requests are delayed and answered with a
+// synthetic 503 at the configured rate, exercising the fetch retries.
+func (in *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if in == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{in: in, base: base}
+}
+
+type transport struct {
+	in   *Injector
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.in.sleep(req.Context()); err != nil {
+		return nil, err
+	}
+	if rand.Float64() < t.in.cfg.FeedErrorRate {
+		const body = "chaos: injected feed error"
+		return &http.Response{
+			Status:        "503 Service Unavailable",
+			StatusCode:    http.StatusServiceUnavailable,
+			Proto:         req.Proto,
+			ProtoMajor:    req.ProtoMajor,
+			ProtoMinor:    req.ProtoMinor,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader(body)),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return t.base.RoundTrip(req)
+}
@@ -8,23 +8,128 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/units"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/chaos"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/siata"
 )
 
 const (
 	defaultCurrentURL     = "https://siata.gov.co/data/siata_app/Pluviometrica.json"
+	defaultFeedName       = "siata_current"
 	defaultMinInterval    = 5 * time.Minute
 	defaultRequestTimeout = 30 * time.Second
 	defaultValueEpsilon   = 0.01
+	defaultAlignCadence   = units.SIATAInterval
+	defaultMoveThreshold  = 25.0
+	defaultMaxRetries     = 3
+	defaultRetryBaseDelay = time.Second
+	defaultMetricsLinger  = 30 * time.Second
+	defaultStaleAfter     = 2 * time.Hour
+	defaultFetchLimit     = 3
+
+	defaultHistoricZone    = "America/Bogota"
+	defaultBackfillTimeout = 10 * time.Minute
+)
+
+// Run modes.
+const (
+	ModeIngest   = "ingest"
+	ModeBackfill = "backfill"
+)
+
+// Timestamp alignment policies applied to measurement timestamps.
+const (
+	AlignNone    = "none"
+	AlignMinute  = "minute"
+	AlignCadence = "cadence"
+)
+
+// Dry-run report formats.
+const (
+	DryRunText = "text"
+	DryRunJSON = "json"
 )
 
+// Feed is one current-conditions feed ingested by a cycle.
+type Feed struct {
+	// Name identifies the feed in shizuku.feeds and ingest_log.
+	Name string
+	URL  string
+}
+
 // Config holds runtime configuration for the watcher service.
 type Config struct {
-	DatabaseURL    string
-	CurrentURL     string
-	MinInterval    time.Duration
-	RequestTimeout time.Duration
+	DatabaseURL string
+	CurrentURL  string
+	// FeedName identifies the feed in shizuku.feeds and ingest_log.
+	FeedName string
+	// ExtraFeeds are ingested in the same cycle as CurrentURL.
+	ExtraFeeds []Feed
+	// FetchConcurrency bounds how many feeds a cycle fetches at once.
+	FetchConcurrency int
+	MinInterval      time.Duration
+	RequestTimeout   time.Duration
+	// MaxRetries is how many times a failed feed request is retried on
+	// network errors and 5xx/429 responses.
+	MaxRetries int
+	// RetryBaseDelay is the first retry backoff; it doubles per attempt.
+	RetryBaseDelay time.Duration
 	ValueEpsilon   float64
-	DryRun         bool
+	// NullSentinels are feed values meaning "no data", stored as NULL. Empty
+	// keeps the default rule that treats anything <= -900 as missing.
+	NullSentinels []float64
+	DryRun        bool
+	// DryRunFormat is DryRunText (log lines) or DryRunJSON (one report on
+	// stdout).
+	DryRunFormat string
+	// TSAlignment is one of AlignNone, AlignMinute or AlignCadence.
+	TSAlignment  string
+	AlignCadence time.Duration
+	// LoopInterval, when positive, keeps the watcher running and starts a
+	// cycle on every tick; zero runs a single cycle and exits.
+	LoopInterval time.Duration
+	// MoveThresholdM is the coordinate change (metres) recorded as a relocation.
+	MoveThresholdM float64
+	// StaleAfter is how long a sensor may go without a reading before the
+	// cycle reports it as stale; zero disables the check.
+	StaleAfter time.Duration
+	// MarkStale also records the staleness in the sensors' metadata.
+	MarkStale bool
+	// RecordFixtures, when set, is a directory each feed response is written
+	// to as a replayable fixture.
+	RecordFixtures string
+	// ReplayFixtures, when set, replaces the live feed with the fixtures
+	// recorded in this directory.
+	ReplayFixtures string
+	// MetricsAddr, when set, serves Prometheus metrics on /metrics.
+	MetricsAddr string
+	// MetricsLinger keeps a one-shot run's metrics listener up after the
+	// cycle so it can be scraped.
+	MetricsLinger time.Duration
+	// PushgatewayURL, when set, pushes a one-shot run's metrics there
+	// instead of lingering.
+	PushgatewayURL string
+	// Environment names the deployment (WATCHER_ENV); chaos injection is
+	// refused in "production".
+	Environment string
+	// Chaos configures failure injection for staging rehearsals.
+	Chaos chaos.Config
+	// RunMigrations applies pending schema migrations before the first
+	// cycle.
+	RunMigrations bool
+
+	// Mode is ModeIngest (poll the current feed) or ModeBackfill (load the
+	// historic feed once).
+	Mode        string
+	HistoricURL string
+	// HistoricZone is the zone of the historic feed's offset-less dates.
+	HistoricZone *time.Location
+	// BackfillStart and BackfillEnd bound the backfilled timestamps to
+	// [start, end); zero leaves a side open.
+	BackfillStart   time.Time
+	BackfillEnd     time.Time
+	BackfillTimeout time.Duration
 }
 
 // Load reads configuration from environment variables (optionally .env).
@@ -53,6 +158,30 @@ func Load() (Config, error) {
 		cfg.CurrentURL = defaultCurrentURL
 	}
 
+	cfg.FeedName = strings.TrimSpace(os.Getenv("WATCHER_FEED_NAME"))
+	if cfg.FeedName == "" {
+		cfg.FeedName = defaultFeedName
+	}
+
+	if v := strings.TrimSpace(os.Getenv("WATCHER_EXTRA_FEEDS")); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			name, feedURL, ok := strings.Cut(strings.TrimSpace(part), "=")
+			if !ok {
+				return cfg, fmt.Errorf("invalid WATCHER_EXTRA_FEEDS entry %q (expected name=url)", part)
+			}
+			cfg.ExtraFeeds = append(cfg.ExtraFeeds, Feed{Name: strings.TrimSpace(name), URL: strings.TrimSpace(feedURL)})
+		}
+	}
+
+	cfg.FetchConcurrency = defaultFetchLimit
+	if v := strings.TrimSpace(os.Getenv("WATCHER_FETCH_CONCURRENCY")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_FETCH_CONCURRENCY: %s", v)
+		}
+		cfg.FetchConcurrency = n
+	}
+
 	cfg.MinInterval = defaultMinInterval
 	if v := strings.TrimSpace(os.Getenv("WATCHER_MIN_INTERVAL")); v != "" {
 		d, err := time.ParseDuration(v)
@@ -71,6 +200,24 @@ func Load() (Config, error) {
 		cfg.RequestTimeout = d
 	}
 
+	cfg.MaxRetries = defaultMaxRetries
+	if v := strings.TrimSpace(os.Getenv("WATCHER_MAX_RETRIES")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_MAX_RETRIES: %s", v)
+		}
+		cfg.MaxRetries = n
+	}
+
+	cfg.RetryBaseDelay = defaultRetryBaseDelay
+	if v := strings.TrimSpace(os.Getenv("WATCHER_RETRY_BASE_DELAY")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_RETRY_BASE_DELAY: %w", err)
+		}
+		cfg.RetryBaseDelay = d
+	}
+
 	cfg.ValueEpsilon = defaultValueEpsilon
 	if v := strings.TrimSpace(os.Getenv("WATCHER_VALUE_EPSILON")); v != "" {
 		f, err := strconv.ParseFloat(v, 64)
@@ -80,8 +227,172 @@ func Load() (Config, error) {
 		cfg.ValueEpsilon = f
 	}
 
+	if v := strings.TrimSpace(os.Getenv("WATCHER_NULL_SENTINELS")); v != "" {
+		for _, part := range strings.Split(v, ",") {
+			f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid WATCHER_NULL_SENTINELS: %s", v)
+			}
+			cfg.NullSentinels = append(cfg.NullSentinels, f)
+		}
+	}
+
+	cfg.TSAlignment = AlignNone
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("WATCHER_TS_ALIGNMENT"))); v != "" {
+		switch v {
+		case AlignNone, AlignMinute, AlignCadence:
+			cfg.TSAlignment = v
+		default:
+			return cfg, fmt.Errorf("invalid WATCHER_TS_ALIGNMENT: %s (expected none, minute or cadence)", v)
+		}
+	}
+
+	cfg.AlignCadence = defaultAlignCadence
+	if v := strings.TrimSpace(os.Getenv("WATCHER_ALIGN_CADENCE")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, fmt.Errorf("invalid WATCHER_ALIGN_CADENCE: %s", v)
+		}
+		cfg.AlignCadence = d
+	}
+
+	if v := strings.TrimSpace(os.Getenv("WATCHER_LOOP_INTERVAL")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_LOOP_INTERVAL: %w", err)
+		}
+		cfg.LoopInterval = d
+	}
+
+	cfg.MoveThresholdM = defaultMoveThreshold
+	if v := strings.TrimSpace(os.Getenv("WATCHER_MOVE_THRESHOLD_M")); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			return cfg, fmt.Errorf("invalid WATCHER_MOVE_THRESHOLD_M: %s", v)
+		}
+		cfg.MoveThresholdM = f
+	}
+
+	cfg.StaleAfter = defaultStaleAfter
+	if v := strings.TrimSpace(os.Getenv("WATCHER_STALE_AFTER")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_STALE_AFTER: %w", err)
+		}
+		cfg.StaleAfter = d
+	}
+	markStale := strings.TrimSpace(os.Getenv("WATCHER_MARK_STALE"))
+	cfg.MarkStale = markStale == "1" || strings.EqualFold(markStale, "true")
+
+	cfg.MetricsAddr = strings.TrimSpace(os.Getenv("WATCHER_METRICS_ADDR"))
+	cfg.PushgatewayURL = strings.TrimSpace(os.Getenv("WATCHER_PUSHGATEWAY_URL"))
+	cfg.MetricsLinger = defaultMetricsLinger
+	if v := strings.TrimSpace(os.Getenv("WATCHER_METRICS_LINGER")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_METRICS_LINGER: %w", err)
+		}
+		cfg.MetricsLinger = d
+	}
+
+	cfg.Environment = strings.ToLower(strings.TrimSpace(os.Getenv("WATCHER_ENV")))
+	for _, rate := range []struct {
+		env string
+		dst *float64
+	}{
+		{"CHAOS_FEED_ERROR_RATE", &cfg.Chaos.FeedErrorRate},
+		{"CHAOS_DB_ERROR_RATE", &cfg.Chaos.DBErrorRate},
+	} {
+		if v := strings.TrimSpace(os.Getenv(rate.env)); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s: %s", rate.env, v)
+			}
+			*rate.dst = f
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("CHAOS_LATENCY_MS")); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid CHAOS_LATENCY_MS: %s", v)
+		}
+		cfg.Chaos.Latency = time.Duration(ms) * time.Millisecond
+	}
+
+	cfg.Mode = ModeIngest
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("WATCHER_MODE"))); v != "" {
+		switch v {
+		case ModeIngest, ModeBackfill:
+			cfg.Mode = v
+		default:
+			return cfg, fmt.Errorf("invalid WATCHER_MODE: %s (expected ingest or backfill)", v)
+		}
+	}
+
+	cfg.HistoricURL = strings.TrimSpace(os.Getenv("HISTORIC_URL"))
+	if cfg.HistoricURL == "" {
+		cfg.HistoricURL = siata.DefaultHistoricURL
+	}
+
+	zone := strings.TrimSpace(os.Getenv("WATCHER_HISTORIC_TZ"))
+	if zone == "" {
+		zone = defaultHistoricZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid WATCHER_HISTORIC_TZ: %s", zone)
+	}
+	cfg.HistoricZone = loc
+
+	for _, bound := range []struct {
+		env string
+		dst *time.Time
+	}{
+		{"WATCHER_BACKFILL_START", &cfg.BackfillStart},
+		{"WATCHER_BACKFILL_END", &cfg.BackfillEnd},
+	} {
+		if v := strings.TrimSpace(os.Getenv(bound.env)); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid %s: %s (expected RFC3339)", bound.env, v)
+			}
+			*bound.dst = t.UTC()
+		}
+	}
+
+	cfg.BackfillTimeout = defaultBackfillTimeout
+	if v := strings.TrimSpace(os.Getenv("WATCHER_BACKFILL_TIMEOUT")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid WATCHER_BACKFILL_TIMEOUT: %w", err)
+		}
+		cfg.BackfillTimeout = d
+	}
+
+	cfg.RecordFixtures = strings.TrimSpace(os.Getenv("RECORD_FIXTURES"))
+	cfg.ReplayFixtures = strings.TrimSpace(os.Getenv("REPLAY_FIXTURES"))
+
+	runMigrations := strings.TrimSpace(os.Getenv("RUN_MIGRATIONS"))
+	cfg.RunMigrations = runMigrations == "1" || strings.EqualFold(runMigrations, "true")
+
 	dryRun := strings.TrimSpace(os.Getenv("DRY_RUN"))
 	cfg.DryRun = dryRun == "1" || strings.EqualFold(dryRun, "true")
 
-	return cfg, nil
+	cfg.DryRunFormat = DryRunText
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("DRY_RUN_FORMAT"))); v != "" {
+		switch v {
+		case DryRunText, DryRunJSON:
+			cfg.DryRunFormat = v
+		default:
+			return cfg, fmt.Errorf("invalid DRY_RUN_FORMAT: %s (expected text or json)", v)
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Feeds returns every feed a cycle ingests: CurrentURL first, then
+// ExtraFeeds.
+func (c Config) Feeds() []Feed {
+	return append([]Feed{{Name: c.FeedName, URL: c.CurrentURL}}, c.ExtraFeeds...)
 }
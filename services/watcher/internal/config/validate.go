@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/siata"
+)
+
+// ValidationError lists every configuration problem found at startup.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks values that parse but cannot work, reporting all problems
+// at once.
+func (c Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if u, err := url.Parse(c.DatabaseURL); err != nil || (u.Scheme != "postgresql" && u.Scheme != "postgres") || u.Host == "" {
+		add("DATABASE_URL must be a postgresql:// URL with a host")
+	}
+	if path, isFile, err := siata.FilePath(c.CurrentURL); isFile {
+		if err != nil {
+			add("CURRENT_URL: %v", err)
+		} else if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+			add("CURRENT_URL file %s must be a readable file", path)
+		}
+	} else if u, err := url.Parse(c.CurrentURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("CURRENT_URL must be an absolute http(s) or file:// URL, got %q", c.CurrentURL)
+	}
+	seen := map[string]bool{c.FeedName: true}
+	for _, f := range c.ExtraFeeds {
+		if f.Name == "" || seen[f.Name] {
+			add("WATCHER_EXTRA_FEEDS names must be non-empty and distinct from each other and WATCHER_FEED_NAME, got %q", f.Name)
+		}
+		seen[f.Name] = true
+		if path, isFile, err := siata.FilePath(f.URL); isFile {
+			if err != nil {
+				add("WATCHER_EXTRA_FEEDS %s: %v", f.Name, err)
+			} else if fi, err := os.Stat(path); err != nil || fi.IsDir() {
+				add("WATCHER_EXTRA_FEEDS %s file %s must be a readable file", f.Name, path)
+			}
+		} else if u, err := url.Parse(f.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("WATCHER_EXTRA_FEEDS %s must be an absolute http(s) or file:// URL, got %q", f.Name, f.URL)
+		}
+	}
+	if len(c.ExtraFeeds) > 0 && (c.RecordFixtures != "" || c.ReplayFixtures != "") {
+		add("RECORD_FIXTURES and REPLAY_FIXTURES only support a single feed; unset WATCHER_EXTRA_FEEDS")
+	}
+	if c.FetchConcurrency < 1 {
+		add("WATCHER_FETCH_CONCURRENCY must be at least 1, got %d", c.FetchConcurrency)
+	}
+	if c.MinInterval <= 0 {
+		add("WATCHER_MIN_INTERVAL must be positive, got %s", c.MinInterval)
+	}
+	if c.RequestTimeout <= 0 {
+		add("WATCHER_REQUEST_TIMEOUT must be positive, got %s", c.RequestTimeout)
+	}
+	if c.MaxRetries < 0 || c.MaxRetries > 10 {
+		add("WATCHER_MAX_RETRIES must be between 0 and 10, got %d", c.MaxRetries)
+	}
+	if c.RetryBaseDelay < 0 {
+		add("WATCHER_RETRY_BASE_DELAY must not be negative, got %s", c.RetryBaseDelay)
+	}
+	if c.LoopInterval < 0 {
+		add("WATCHER_LOOP_INTERVAL must not be negative, got %s", c.LoopInterval)
+	}
+	if c.ValueEpsilon < 0 {
+		add("WATCHER_VALUE_EPSILON must not be negative, got %g", c.ValueEpsilon)
+	}
+	if c.TSAlignment == AlignCadence && c.AlignCadence > c.MinInterval {
+		add("WATCHER_ALIGN_CADENCE (%s) exceeds WATCHER_MIN_INTERVAL (%s); forced inserts would collapse onto one timestamp", c.AlignCadence, c.MinInterval)
+	}
+	if c.StaleAfter < 0 {
+		add("WATCHER_STALE_AFTER must not be negative, got %s", c.StaleAfter)
+	}
+
+	if c.MetricsLinger < 0 {
+		add("WATCHER_METRICS_LINGER must not be negative, got %s", c.MetricsLinger)
+	}
+	if c.PushgatewayURL != "" {
+		if u, err := url.Parse(c.PushgatewayURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("WATCHER_PUSHGATEWAY_URL must be an absolute http(s) URL, got %q", c.PushgatewayURL)
+		}
+	}
+
+	for _, rate := range []struct {
+		env   string
+		value float64
+	}{
+		{"CHAOS_FEED_ERROR_RATE", c.Chaos.FeedErrorRate},
+		{"CHAOS_DB_ERROR_RATE", c.Chaos.DBErrorRate},
+	} {
+		if rate.value < 0 || rate.value > 1 {
+			add("%s must be between 0 and 1, got %g", rate.env, rate.value)
+		}
+	}
+	if c.Chaos.Latency < 0 {
+		add("CHAOS_LATENCY_MS must not be negative, got %s", c.Chaos.Latency)
+	}
+	if c.Chaos.Enabled() && c.Environment == "production" {
+		add("CHAOS_* failure injection is not allowed with WATCHER_ENV=production")
+	}
+
+	if c.Mode == ModeBackfill {
+		if u, err := url.Parse(c.HistoricURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("HISTORIC_URL must be an absolute http(s) URL, got %q", c.HistoricURL)
+		}
+		if !c.BackfillStart.IsZero() && !c.BackfillEnd.IsZero() && !c.BackfillStart.Before(c.BackfillEnd) {
+			add("WATCHER_BACKFILL_START (%s) must be before WATCHER_BACKFILL_END (%s)", c.BackfillStart.Format(time.RFC3339), c.BackfillEnd.Format(time.RFC3339))
+		}
+		if c.BackfillTimeout <= 0 {
+			add("WATCHER_BACKFILL_TIMEOUT must be positive, got %s", c.BackfillTimeout)
+		}
+		if c.LoopInterval > 0 {
+			add("WATCHER_LOOP_INTERVAL cannot be combined with backfill mode")
+		}
+	}
+
+	if c.RecordFixtures != "" && c.ReplayFixtures != "" {
+		add("RECORD_FIXTURES and REPLAY_FIXTURES cannot be combined")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// Redacted renders the resolved configuration for the startup log with the
+// database password stripped.
+func (c Config) Redacted() string {
+	dbURL := redactURL(c.DatabaseURL)
+	return fmt.Sprintf(
+		"database_url=%s current_url=%s feed_name=%s extra_feeds=%s fetch_concurrency=%d min_interval=%s request_timeout=%s max_retries=%d retry_base_delay=%s value_epsilon=%g null_sentinels=%v "+
+			"ts_alignment=%s align_cadence=%s move_threshold_m=%g loop_interval=%s stale_after=%s mark_stale=%v "+
+			"metrics_addr=%q metrics_linger=%s pushgateway_url=%s record_fixtures=%q replay_fixtures=%q dry_run=%v dry_run_format=%s run_migrations=%v "+
+			"mode=%s historic_url=%s historic_tz=%s backfill_start=%s backfill_end=%s backfill_timeout=%s",
+		dbURL, redactURL(c.CurrentURL), c.FeedName, redactFeeds(c.ExtraFeeds), c.FetchConcurrency, c.MinInterval, c.RequestTimeout, c.MaxRetries, c.RetryBaseDelay, c.ValueEpsilon, c.NullSentinels,
+		c.TSAlignment, c.AlignCadence, c.MoveThresholdM, c.LoopInterval, c.StaleAfter, c.MarkStale,
+		c.MetricsAddr, c.MetricsLinger, redactURL(c.PushgatewayURL), c.RecordFixtures, c.ReplayFixtures, c.DryRun, c.DryRunFormat, c.RunMigrations,
+		c.Mode, redactURL(c.HistoricURL), c.HistoricZone, formatBound(c.BackfillStart), formatBound(c.BackfillEnd), c.BackfillTimeout,
+	)
+}
+
+// redactURL strips the password from raw, or reports it as invalid.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[invalid]"
+	}
+	return u.Redacted()
+}
+
+// redactFeeds renders the extra feeds as name=url with passwords stripped.
+func redactFeeds(feeds []Feed) string {
+	parts := make([]string, len(feeds))
+	for i, f := range feeds {
+		parts[i] = f.Name + "=" + redactURL(f.URL)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// formatBound renders an optional backfill bound.
+func formatBound(t time.Time) string {
+	if t.IsZero() {
+		return "(open)"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -10,20 +11,42 @@ import (
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
 )
 
-// UpsertSensors inserts/updates sensor metadata records.
+// Values of raw_measurements.source.
+const (
+	SourceCurrent  = "current"
+	SourceHistoric = "historic"
+)
+
+// UpsertSensors inserts/updates sensor metadata records in one transaction.
 func UpsertSensors(ctx context.Context, pool *pgxpool.Pool, sensors []models.SensorRow) error {
-	if len(sensors) == 0 {
+	return SyncSensors(ctx, pool, sensors, nil, nil, time.Time{})
+}
+
+// SyncSensors upserts the sensors and appends their metadata and location
+// changes as of changedAt in one transaction. The changes are diffed against
+// the rows before the upsert, so committing the upsert without its history
+// would lose that history for good.
+func SyncSensors(ctx context.Context, pool *pgxpool.Pool, sensors []models.SensorRow, metadata []models.MetadataChange, locations []models.LocationChange, changedAt time.Time) error {
+	batch := &pgx.Batch{}
+	queueSensorUpserts(batch, sensors)
+	queueMetadataChanges(batch, metadata, changedAt)
+	queueLocationChanges(batch, locations, changedAt)
+	if batch.Len() == 0 {
 		return nil
 	}
+	return execBatchTx(ctx, pool, batch)
+}
 
-	batch := &pgx.Batch{}
+// queueSensorUpserts queues the statements of UpsertSensors.
+func queueSensorUpserts(batch *pgx.Batch, sensors []models.SensorRow) {
 	query := `INSERT INTO shizuku.sensors (id, name, provider_id, lat, lon, elevation_m, city, subbasin, barrio, metadata, created_at, updated_at)
-VALUES ($1,$2,$3,$4,$5,NULL,$6,$7,$8,$9,NOW(),NOW())
+VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,NOW(),NOW())
 ON CONFLICT (id) DO UPDATE
 SET name = EXCLUDED.name,
     provider_id = EXCLUDED.provider_id,
     lat = EXCLUDED.lat,
     lon = EXCLUDED.lon,
+    elevation_m = COALESCE(EXCLUDED.elevation_m, shizuku.sensors.elevation_m),
     city = EXCLUDED.city,
     subbasin = EXCLUDED.subbasin,
     barrio = EXCLUDED.barrio,
@@ -31,23 +54,13 @@ SET name = EXCLUDED.name,
     updated_at = NOW()`
 
 	for _, s := range sensors {
-		batch.Queue(query, s.ID, s.Name, s.ProviderID, s.Lat, s.Lon, s.City, s.Subbasin, s.Barrio, s.Metadata)
+		batch.Queue(query, s.ID, s.Name, s.ProviderID, s.Lat, s.Lon, s.ElevationM, s.City, s.Subbasin, s.Barrio, s.Metadata)
 	}
-
-	res := pool.SendBatch(ctx, batch)
-	defer res.Close()
-
-	for range sensors {
-		if _, err := res.Exec(); err != nil {
-			return err
-		}
-	}
-
-	return nil
 }
 
-// FetchLastMeasurements loads the most recent stored values per sensor.
-func FetchLastMeasurements(ctx context.Context, pool *pgxpool.Pool, sensorIDs []string) (map[string]models.LastMeasurement, error) {
+// FetchLastMeasurements loads the most recent stored values per sensor for
+// one source.
+func FetchLastMeasurements(ctx context.Context, pool *pgxpool.Pool, sensorIDs []string, source string) (map[string]models.LastMeasurement, error) {
 	result := make(map[string]models.LastMeasurement, len(sensorIDs))
 	if len(sensorIDs) == 0 {
 		return result, nil
@@ -56,8 +69,8 @@ func FetchLastMeasurements(ctx context.Context, pool *pgxpool.Pool, sensorIDs []
 	rows, err := pool.Query(ctx, `
 SELECT DISTINCT ON (sensor_id) sensor_id, value_mm, ts
 FROM shizuku.raw_measurements
-WHERE sensor_id = ANY($1) AND source = 'current'
-ORDER BY sensor_id, ts DESC`, sensorIDs)
+WHERE sensor_id = ANY($1) AND source = $2
+ORDER BY sensor_id, ts DESC`, sensorIDs, source)
 	if err != nil {
 		return nil, err
 	}
@@ -76,31 +89,165 @@ ORDER BY sensor_id, ts DESC`, sensorIDs)
 	return result, rows.Err()
 }
 
-// InsertMeasurements writes new measurement entries to raw_measurements.
-func InsertMeasurements(ctx context.Context, pool *pgxpool.Pool, measurements []models.MeasurementCandidate) error {
+// InsertMeasurements writes new measurement entries to raw_measurements under
+// source. The candidate's RetrievedAt is stored as ingested_at; re-fetches
+// landing on an existing (sensor, ts) replace the value and ingested_at. All
+// rows commit in one transaction or none do.
+func InsertMeasurements(ctx context.Context, pool *pgxpool.Pool, measurements []models.MeasurementCandidate, source string) error {
 	if len(measurements) == 0 {
 		return nil
 	}
 
 	batch := &pgx.Batch{}
 	query := `INSERT INTO shizuku.raw_measurements (sensor_id, ts, value_mm, quality, variable, source, ingested_at, created_at, updated_at)
-VALUES ($1,$2,$3,NULL,'precipitacion','current',NOW(),NOW(),NOW())
+VALUES ($1,$2,$3,NULL,'precipitacion',$5,$4,NOW(),NOW())
 ON CONFLICT (sensor_id, ts, source) DO UPDATE
 SET value_mm = EXCLUDED.value_mm,
+    ingested_at = EXCLUDED.ingested_at,
     updated_at = NOW()`
 
 	for _, m := range measurements {
-		batch.Queue(query, m.SensorID, m.TS, m.Value)
+		ingestedAt := m.RetrievedAt
+		if ingestedAt.IsZero() {
+			ingestedAt = m.TS
+		}
+		batch.Queue(query, m.SensorID, m.TS, m.Value, ingestedAt, source)
 	}
 
-	res := pool.SendBatch(ctx, batch)
-	defer res.Close()
+	return execBatchTx(ctx, pool, batch)
+}
 
-	for range measurements {
+// execBatchTx runs batch inside one transaction and commits only if every
+// queued statement succeeds, so a failing row leaves nothing committed.
+func execBatchTx(ctx context.Context, pool *pgxpool.Pool, batch *pgx.Batch) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	res := tx.SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
 		if _, err := res.Exec(); err != nil {
-			return err
+			res.Close()
+			return fmt.Errorf("batch statement %d: %w", i+1, err)
 		}
 	}
+	if err := res.Close(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// FetchSensorLocations loads the current stored coordinates per sensor.
+func FetchSensorLocations(ctx context.Context, pool *pgxpool.Pool, sensorIDs []string) (map[string]models.Location, error) {
+	result := make(map[string]models.Location, len(sensorIDs))
+	if len(sensorIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := pool.Query(ctx, `SELECT id, lat, lon FROM shizuku.sensors WHERE id = ANY($1)`, sensorIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var loc models.Location
+		if err := rows.Scan(&id, &loc.Lat, &loc.Lon); err != nil {
+			return nil, err
+		}
+		result[id] = loc
+	}
+
+	return result, rows.Err()
+}
 
-	return nil
+// FetchSensorMetadata loads the stored descriptive fields per sensor, for
+// diffing against the feed before an upsert. Coordinates are left zero;
+// FetchSensorLocations covers them.
+func FetchSensorMetadata(ctx context.Context, pool *pgxpool.Pool, sensorIDs []string) (map[string]models.SensorRow, error) {
+	result := make(map[string]models.SensorRow, len(sensorIDs))
+	if len(sensorIDs) == 0 {
+		return result, nil
+	}
+
+	rows, err := pool.Query(ctx, `
+SELECT id, COALESCE(name, ''), COALESCE(provider_id, ''), COALESCE(city, ''), COALESCE(subbasin, ''), COALESCE(barrio, ''), metadata
+FROM shizuku.sensors
+WHERE id = ANY($1)`, sensorIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row models.SensorRow
+		if err := rows.Scan(&row.ID, &row.Name, &row.ProviderID, &row.City, &row.Subbasin, &row.Barrio, &row.Metadata); err != nil {
+			return nil, err
+		}
+		result[row.ID] = row
+	}
+
+	return result, rows.Err()
+}
+
+// RecordMetadataChanges appends the changes to sensor_changes as of
+// changedAt, all in one transaction.
+func RecordMetadataChanges(ctx context.Context, pool *pgxpool.Pool, changes []models.MetadataChange, changedAt time.Time) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	queueMetadataChanges(batch, changes, changedAt)
+	return execBatchTx(ctx, pool, batch)
+}
+
+// queueMetadataChanges queues the statements of RecordMetadataChanges.
+func queueMetadataChanges(batch *pgx.Batch, changes []models.MetadataChange, changedAt time.Time) {
+	query := `INSERT INTO shizuku.sensor_changes (sensor_id, field, old_value, new_value, changed_at)
+VALUES ($1,$2,$3,$4,$5)`
+
+	for _, ch := range changes {
+		batch.Queue(query, ch.SensorID, ch.Field, ch.Old, ch.New, changedAt)
+	}
+}
+
+// RecordLocationChanges appends sensor_location_history rows effective from
+// the given time, all in one transaction. For a moved sensor without any
+// history yet, its previous location is first recorded as effective from the
+// sensor's creation. Must run after UpsertSensors so new sensors exist.
+func RecordLocationChanges(ctx context.Context, pool *pgxpool.Pool, changes []models.LocationChange, effectiveFrom time.Time) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	queueLocationChanges(batch, changes, effectiveFrom)
+	return execBatchTx(ctx, pool, batch)
+}
+
+// queueLocationChanges queues the statements of RecordLocationChanges.
+func queueLocationChanges(batch *pgx.Batch, changes []models.LocationChange, effectiveFrom time.Time) {
+	seedQuery := `INSERT INTO shizuku.sensor_location_history (sensor_id, lat, lon, effective_from)
+SELECT s.id, $2, $3, s.created_at
+FROM shizuku.sensors s
+WHERE s.id = $1
+  AND NOT EXISTS (SELECT 1 FROM shizuku.sensor_location_history h WHERE h.sensor_id = s.id)
+ON CONFLICT (sensor_id, effective_from) DO NOTHING`
+
+	insertQuery := `INSERT INTO shizuku.sensor_location_history (sensor_id, lat, lon, effective_from)
+VALUES ($1,$2,$3,$4)
+ON CONFLICT (sensor_id, effective_from) DO UPDATE
+SET lat = EXCLUDED.lat,
+    lon = EXCLUDED.lon`
+
+	for _, ch := range changes {
+		if ch.Previous != nil {
+			batch.Queue(seedQuery, ch.SensorID, ch.Previous.Lat, ch.Previous.Lon)
+		}
+		batch.Queue(insertQuery, ch.SensorID, ch.Current.Lat, ch.Current.Lon, effectiveFrom)
+	}
 }
@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/testsupport"
+)
+
+// schemaPath is db/schema.sql relative to this package.
+const schemaPath = "../../../../db/schema.sql"
+
+func newTestPool(t *testing.T) (context.Context, *pgxpool.Pool) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	t.Cleanup(cancel)
+
+	pool, _, cleanup, err := testsupport.NewTestDB(ctx, schemaPath)
+	if errors.Is(err, testsupport.ErrNoTestDatabase) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("test database: %v", err)
+	}
+	t.Cleanup(cleanup)
+	return ctx, pool
+}
+
+func countRows(t *testing.T, ctx context.Context, pool *pgxpool.Pool, table string) int {
+	t.Helper()
+	n, err := testsupport.CountRows(ctx, pool, table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestInsertMeasurementsFailingRowCommitsNothing(t *testing.T) {
+	ctx, pool := newTestPool(t)
+	if err := UpsertSensors(ctx, pool, []models.SensorRow{{ID: "s1", Name: "one", Lat: 6.25, Lon: -75.56}}); err != nil {
+		t.Fatal(err)
+	}
+
+	v := 0.5
+	ts := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	err := InsertMeasurements(ctx, pool, []models.MeasurementCandidate{
+		{SensorID: "s1", Value: &v, TS: ts},
+		// Violates the sensors foreign key.
+		{SensorID: "unknown", Value: &v, TS: ts},
+		{SensorID: "s1", Value: &v, TS: ts.Add(5 * time.Minute)},
+	}, "current")
+	if err == nil {
+		t.Fatal("insert with a failing row succeeded")
+	}
+	if n := countRows(t, ctx, pool, "raw_measurements"); n != 0 {
+		t.Errorf("%d rows committed, want none", n)
+	}
+}
+
+func TestUpsertSensorsFailingRowCommitsNothing(t *testing.T) {
+	ctx, pool := newTestPool(t)
+	err := UpsertSensors(ctx, pool, []models.SensorRow{
+		{ID: "s1", Name: "one", Lat: 6.25, Lon: -75.56},
+		// Postgres rejects NUL bytes in text.
+		{ID: "s2", Name: "bad\x00name", Lat: 6.21, Lon: -75.60},
+	})
+	if err == nil {
+		t.Fatal("upsert with a failing row succeeded")
+	}
+	if n := countRows(t, ctx, pool, "sensors"); n != 0 {
+		t.Errorf("%d sensors committed, want none", n)
+	}
+}
+
+// The change history is diffed before the upsert, so a failing history insert
+// must take the upsert down with it; otherwise the next cycle sees no change.
+func TestSyncSensorsFailingHistoryRollsBackUpsert(t *testing.T) {
+	name := "bad\x00name"
+	for _, tc := range []struct {
+		name      string
+		metadata  []models.MetadataChange
+		locations []models.LocationChange
+	}{
+		// Postgres rejects NUL bytes in text.
+		{name: "metadata", metadata: []models.MetadataChange{{SensorID: "s1", Field: "name", New: &name}}},
+		// Violates the sensors foreign key.
+		{name: "location", locations: []models.LocationChange{{SensorID: "unknown", Current: models.Location{Lat: 6.2, Lon: -75.5}}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, pool := newTestPool(t)
+			err := SyncSensors(ctx, pool, []models.SensorRow{{ID: "s1", Name: "one", Lat: 6.25, Lon: -75.56}},
+				tc.metadata, tc.locations, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC))
+			if err == nil {
+				t.Fatal("sync with a failing history row succeeded")
+			}
+			for _, table := range []string{"sensors", "sensor_changes", "sensor_location_history"} {
+				if n := countRows(t, ctx, pool, table); n != 0 {
+					t.Errorf("%d %s rows committed, want none", n, table)
+				}
+			}
+		})
+	}
+}
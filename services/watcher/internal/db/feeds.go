@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Feed is the definition of an ingested feed as registered in shizuku.feeds.
+type Feed struct {
+	Name    string
+	URL     string
+	Cadence time.Duration
+}
+
+// CycleOutcome is the result of one watcher cycle for a feed.
+type CycleOutcome struct {
+	StartedAt time.Time
+	Network   string
+	Stations  int
+	// Skipped counts stations rejected for invalid coordinates.
+	Skipped  int
+	Inserted int
+	Err      error
+}
+
+// RegisterFeed upserts the feed definition by name, keeping its health
+// columns.
+func RegisterFeed(ctx context.Context, pool *pgxpool.Pool, feed Feed) error {
+	_, err := pool.Exec(ctx, `
+INSERT INTO shizuku.feeds (name, url, cadence_seconds)
+VALUES ($1, $2, $3)
+ON CONFLICT (name) DO UPDATE
+SET url = EXCLUDED.url,
+    cadence_seconds = EXCLUDED.cadence_seconds,
+    updated_at = NOW()`,
+		feed.Name, feed.URL, int(feed.Cadence/time.Second))
+	return err
+}
+
+// RecordCycle stores the outcome of a cycle in ingest_log and updates the
+// feed's last success or last error.
+func RecordCycle(ctx context.Context, pool *pgxpool.Pool, feedName string, out CycleOutcome) error {
+	var errText *string
+	if out.Err != nil {
+		msg := out.Err.Error()
+		errText = &msg
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+INSERT INTO shizuku.ingest_log (feed_name, started_at, success, stations, skipped, inserted, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		feedName, out.StartedAt, out.Err == nil, out.Stations, out.Skipped, out.Inserted, errText); err != nil {
+		return err
+	}
+
+	if out.Err == nil {
+		_, err = tx.Exec(ctx, `
+UPDATE shizuku.feeds
+SET last_success_at = NOW(), network = COALESCE(NULLIF($2, ''), network), updated_at = NOW()
+WHERE name = $1`, feedName, out.Network)
+	} else {
+		_, err = tx.Exec(ctx, `
+UPDATE shizuku.feeds
+SET last_error_at = NOW(), last_error = $2, updated_at = NOW()
+WHERE name = $1`, feedName, errText)
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// runLockKey is the advisory lock key serialising watcher runs.
+const runLockKey int64 = 0x5348495a57415443 // "SHIZWATC"
+
+// TryRunLock takes the watcher run lock on a dedicated connection without
+// waiting. ok is false when another run holds it. On success the returned
+// release must be called to unlock and return the connection to the pool.
+func TryRunLock(ctx context.Context, pool *pgxpool.Pool) (release func(), ok bool, err error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, runLockKey).Scan(&ok); err != nil {
+		conn.Release()
+		return nil, false, err
+	}
+	if !ok {
+		conn.Release()
+		return nil, false, nil
+	}
+
+	release = func() {
+		// Unlock even when the run's context has expired.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(ctx, `SELECT pg_advisory_unlock($1)`, runLockKey); err != nil {
+			// Closing the session drops the lock with it.
+			_ = conn.Conn().Close(ctx)
+		}
+		conn.Release()
+	}
+	return release, true, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// FetchLastReadings loads the latest non-null measurement of every sensor
+// with measurements under source, whether or not the feed still lists it.
+// Unlike FetchLastMeasurements it skips null values, so a sensor that only
+// reports "no data" keeps the time of its last real reading.
+func FetchLastReadings(ctx context.Context, pool *pgxpool.Pool, source string) (map[string]models.LastMeasurement, error) {
+	rows, err := pool.Query(ctx, `
+SELECT s.id, last.value_mm, last.ts
+FROM shizuku.sensors s
+CROSS JOIN LATERAL (
+    SELECT r.value_mm, r.ts
+    FROM shizuku.raw_measurements r
+    WHERE r.sensor_id = s.id AND r.source = $1 AND r.value_mm IS NOT NULL
+    ORDER BY r.ts DESC
+    LIMIT 1
+) last`, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]models.LastMeasurement)
+	for rows.Next() {
+		var sensorID string
+		var value *float64
+		var ts time.Time
+		if err := rows.Scan(&sensorID, &value, &ts); err != nil {
+			return nil, err
+		}
+		result[sensorID] = models.LastMeasurement{Value: value, TS: ts}
+	}
+	return result, rows.Err()
+}
+
+// MarkStaleSensors sets stale=true and last_reading_at in the metadata of the
+// stale sensors and removes both keys from every other sensor carrying them,
+// in one statement. lastReadings maps the stale sensor ids to their last
+// reading time.
+func MarkStaleSensors(ctx context.Context, pool *pgxpool.Pool, lastReadings map[string]time.Time) error {
+	ids := make([]string, 0, len(lastReadings))
+	times := make([]time.Time, 0, len(lastReadings))
+	for id, ts := range lastReadings {
+		ids = append(ids, id)
+		times = append(times, ts)
+	}
+	_, err := pool.Exec(ctx, `
+UPDATE shizuku.sensors
+SET metadata = CASE
+        WHEN id = ANY($1) THEN COALESCE(metadata, '{}'::jsonb) || jsonb_build_object(
+            'stale', true,
+            'last_reading_at', ($2::timestamptz[])[array_position($1::text[], id)])
+        ELSE metadata - 'stale' - 'last_reading_at'
+    END,
+    updated_at = NOW()
+WHERE id = ANY($1) OR metadata ? 'stale'`, ids, times)
+	return err
+}
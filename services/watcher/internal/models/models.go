@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // CurrentResponse models the JSON payload returned by the SIATA current feed.
 type CurrentResponse struct {
@@ -19,6 +24,56 @@ type Station struct {
 	Name      string   `json:"nombre"`
 	Subbasin  string   `json:"subcuenca"`
 	Value     *float64 `json:"valor"`
+	// Elevation is the station altitude in metres; only some stations
+	// carry it.
+	Elevation *float64 `json:"altitud"`
+}
+
+// HistoricStation is one station of the SIATA historic (Vaisala) feed, a JSON
+// array of stations each carrying its own time series.
+type HistoricStation struct {
+	Code      json.Number    `json:"codigo"`
+	Name      string         `json:"nombre"`
+	Latitude  Number         `json:"latitud"`
+	Longitude Number         `json:"longitud"`
+	City      string         `json:"ciudad"`
+	Subbasin  string         `json:"subcuenca"`
+	Slots     []HistoricSlot `json:"datos"`
+}
+
+// HistoricSlot holds the readings of a station at one feed timestamp. Date
+// is local SIATA time without an offset.
+type HistoricSlot struct {
+	Date   string          `json:"fecha"`
+	Values []HistoricValue `json:"datos"`
+}
+
+// HistoricValue is a single variable reading within a slot.
+type HistoricValue struct {
+	Value    Number `json:"valor"`
+	Quality  Number `json:"calidad"`
+	Variable string `json:"variableConsulta"`
+}
+
+// Number is a feed number that may arrive as a JSON number, a numeric
+// string, an empty string or null; the last two decode as missing.
+type Number struct {
+	Value *float64
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Number) UnmarshalJSON(b []byte) error {
+	raw := strings.Trim(strings.TrimSpace(string(b)), `"`)
+	if raw == "" || raw == "null" {
+		n.Value = nil
+		return nil
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+	if err != nil {
+		return err
+	}
+	n.Value = &f
+	return nil
 }
 
 // SensorRow captures the normalized sensor metadata for DB operations.
@@ -28,6 +83,7 @@ type SensorRow struct {
 	Name       string
 	Lat        float64
 	Lon        float64
+	ElevationM *float64
 	City       string
 	Subbasin   string
 	Barrio     string
@@ -35,10 +91,13 @@ type SensorRow struct {
 }
 
 // MeasurementCandidate encapsulates a normalized measurement ready for insertion.
+// TS is the (possibly aligned) measurement time; RetrievedAt keeps the
+// original fetch time and is stored as ingested_at.
 type MeasurementCandidate struct {
-	SensorID string
-	Value    *float64
-	TS       time.Time
+	SensorID    string
+	Value       *float64
+	TS          time.Time
+	RetrievedAt time.Time
 }
 
 // LastMeasurement represents the most recent stored measurement for comparison.
@@ -46,3 +105,27 @@ type LastMeasurement struct {
 	Value *float64
 	TS    time.Time
 }
+
+// Location is a sensor position in WGS84 degrees.
+type Location struct {
+	Lat float64
+	Lon float64
+}
+
+// LocationChange records a sensor observed at a new position. Previous is nil
+// for sensors seen for the first time.
+type LocationChange struct {
+	SensorID string
+	Current  Location
+	Previous *Location
+}
+
+// MetadataChange records one sensor metadata field whose stored value differs
+// from the feed's. Field is a sensors column or metadata.<key>; Old and New
+// are nil when the value is unset.
+type MetadataChange struct {
+	SensorID string
+	Field    string
+	Old      *string
+	New      *string
+}
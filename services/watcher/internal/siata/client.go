@@ -1,35 +1,68 @@
 package siata
 
 import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
 )
 
-// FetchCurrentStations retrieves the current SIATA stations payload.
-func FetchCurrentStations(ctx context.Context, client *http.Client, url string) (models.CurrentResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// acceptEncoding lists the content encodings contentReader can decode.
+const acceptEncoding = "gzip, deflate"
+
+// FetchCurrentStations retrieves the current SIATA stations payload, retrying
+// transient failures according to retry. Compressed responses are decoded
+// transparently. A file:// url is read from disk, see FileSource.
+func FetchCurrentStations(ctx context.Context, client *http.Client, url string, retry RetryPolicy) (models.CurrentResponse, error) {
+	path, isFile, err := FilePath(url)
 	if err != nil {
 		return models.CurrentResponse{}, err
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return models.CurrentResponse{}, fmt.Errorf("request current feed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return models.CurrentResponse{}, fmt.Errorf("unexpected status %s", resp.Status)
+	if isFile {
+		return (&FileSource{Path: path}).Fetch(ctx)
 	}
+	src := &HTTPSource{Client: client, URL: url, Retry: retry}
+	return src.Fetch(ctx)
+}
 
-	var payload models.CurrentResponse
-	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
-		return models.CurrentResponse{}, fmt.Errorf("decode payload: %w", err)
+// contentReader wraps body in a decompressor for the Content-Encoding value.
+// Closing the returned reader closes the decompressor; the caller still owns
+// body. "deflate" accepts both the zlib-wrapped form HTTP specifies and the
+// raw stream some servers send instead.
+func contentReader(encoding string, body io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case "gzip", "x-gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decode gzip body: %w", err)
+		}
+		return r, nil
+	case "deflate":
+		br := bufio.NewReader(body)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			r, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("decode deflate body: %w", err)
+			}
+			return r, nil
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
 	}
+}
 
-	return payload, nil
+// isZlibHeader reports whether b starts a zlib stream (RFC 1950): deflate
+// method with a header checksum divisible by 31.
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
 }
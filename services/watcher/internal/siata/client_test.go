@@ -0,0 +1,73 @@
+package siata
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/testsupport"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+var testPayload = models.CurrentResponse{
+	Network: "fixture",
+	Stations: []models.Station{
+		testsupport.Station(101, 6.25, -75.56, ptr(0.5)),
+		testsupport.Station(102, 6.21, -75.60, nil),
+	},
+}
+
+func TestFetchCurrentStationsDecodesCompressedBodies(t *testing.T) {
+	for _, encoding := range []string{"", "gzip", "deflate"} {
+		t.Run("encoding="+encoding, func(t *testing.T) {
+			feed, err := testsupport.NewSIATAServer(testPayload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer feed.Close()
+			feed.SetEncoding(encoding)
+
+			got, err := FetchCurrentStations(context.Background(), http.DefaultClient, feed.URL, RetryPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, testPayload) {
+				t.Errorf("got %+v, want %+v", got, testPayload)
+			}
+		})
+	}
+}
+
+func TestContentReader(t *testing.T) {
+	raw := []byte(`{"red":"fixture"}`)
+	var deflated bytes.Buffer
+	zw, _ := flate.NewWriter(&deflated, flate.DefaultCompression)
+	_, _ = zw.Write(raw)
+	_ = zw.Close()
+
+	// Some servers send a raw deflate stream instead of the zlib wrapper.
+	r, err := contentReader("deflate", bytes.NewReader(deflated.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(got, raw) {
+		t.Errorf("raw deflate: got %q (%v), want %q", got, err, raw)
+	}
+	if err := r.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+
+	if _, err := contentReader("gzip", bytes.NewReader(raw)); err == nil {
+		t.Error("gzip header not checked")
+	}
+	if _, err := contentReader("br", bytes.NewReader(raw)); err == nil {
+		t.Error("unsupported encoding accepted")
+	}
+}
@@ -0,0 +1,61 @@
+package siata
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// FileSource reads a captured feed payload from disk on every Fetch, for
+// deterministic QA runs and offline replay. The file holds the response body
+// as SIATA serves it; a ".gz" file is treated as a gzip-encoded body.
+type FileSource struct {
+	Path string
+}
+
+// FilePath returns the path of a file:// feed URL. ok is false for any other
+// scheme. Both file:///abs/feed.json and the relative file:captures/feed.json
+// are accepted; a file URL naming a remote host is an error.
+func FilePath(rawURL string) (path string, ok bool, err error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "file" {
+		return "", false, nil
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", true, fmt.Errorf("file URL %q names host %q; use file:///path for local files", rawURL, u.Host)
+	}
+	path = u.Path
+	if u.Opaque != "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return "", true, fmt.Errorf("file URL %q has no path", rawURL)
+	}
+	return path, true, nil
+}
+
+// Fetch reads and decodes the file through the same path as a live
+// response.
+func (s *FileSource) Fetch(ctx context.Context) (models.CurrentResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return models.CurrentResponse{}, err
+	}
+	body, err := os.ReadFile(s.Path)
+	if err != nil {
+		return models.CurrentResponse{}, fmt.Errorf("read feed file: %w", err)
+	}
+	header := http.Header{}
+	if strings.HasSuffix(s.Path, ".gz") {
+		header.Set("Content-Encoding", "gzip")
+	}
+	payload, err := decodeResponse(http.StatusOK, "200 OK", header, body)
+	if err != nil {
+		return models.CurrentResponse{}, fmt.Errorf("feed file %s: %w", s.Path, err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,143 @@
+package siata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// fixtureVersion is bumped whenever the Fixture layout changes incompatibly.
+const fixtureVersion = 1
+
+// Fixture is one recorded feed response, stored as <seq>.json (six-digit,
+// zero-padded, starting at 000001) in the recording directory:
+//
+//	{
+//	  "version": 1,
+//	  "recorded_at": "2025-10-04T15:04:05.123Z",
+//	  "url": "https://siata.gov.co/...",
+//	  "status": 200,
+//	  "header": {"Content-Type": ["application/json"]},
+//	  "body": "<base64 of the exact response bytes>"
+//	}
+//
+// The body is base64 so replays are byte-identical even for malformed
+// payloads.
+type Fixture struct {
+	Version    int         `json:"version"`
+	RecordedAt time.Time   `json:"recorded_at"`
+	URL        string      `json:"url"`
+	Status     int         `json:"status"`
+	Header     http.Header `json:"header"`
+	Body       []byte      `json:"body"`
+}
+
+// ErrFixturesExhausted is returned once a FixtureSource has replayed every
+// fixture.
+var ErrFixturesExhausted = errors.New("no fixtures left to replay")
+
+var recordMu sync.Mutex
+
+// writeFixture stores fx under the next free sequence number in dir.
+func writeFixture(dir string, fx Fixture) (string, error) {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	paths, err := fixturePaths(dir)
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	if len(paths) > 0 {
+		last := strings.TrimSuffix(filepath.Base(paths[len(paths)-1]), ".json")
+		n, _ := strconv.Atoi(last)
+		next = n + 1
+	}
+
+	raw, err := json.MarshalIndent(fx, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%06d.json", next))
+	return path, os.WriteFile(path, raw, 0o644)
+}
+
+// fixturePaths lists the numbered fixture files of dir in sequence order.
+func fixturePaths(dir string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "[0-9][0-9][0-9][0-9][0-9][0-9].json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// ReadFixture loads one recorded fixture.
+func ReadFixture(path string) (Fixture, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	var fx Fixture
+	if err := json.Unmarshal(raw, &fx); err != nil {
+		return Fixture{}, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	if fx.Version != fixtureVersion {
+		return Fixture{}, fmt.Errorf("fixture %s has version %d, expected %d", path, fx.Version, fixtureVersion)
+	}
+	return fx, nil
+}
+
+// FixtureSource replays recorded fixtures in sequence order, one per Fetch.
+type FixtureSource struct {
+	mu    sync.Mutex
+	paths []string
+	next  int
+}
+
+// NewFixtureSource prepares a replay of the fixtures recorded in dir.
+func NewFixtureSource(dir string) (*FixtureSource, error) {
+	paths, err := fixturePaths(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no fixtures found in %s", dir)
+	}
+	return &FixtureSource{paths: paths}, nil
+}
+
+// Fetch decodes the next fixture through the same path as a live response.
+func (s *FixtureSource) Fetch(ctx context.Context) (models.CurrentResponse, error) {
+	s.mu.Lock()
+	if s.next >= len(s.paths) {
+		s.mu.Unlock()
+		return models.CurrentResponse{}, ErrFixturesExhausted
+	}
+	path := s.paths[s.next]
+	s.next++
+	s.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return models.CurrentResponse{}, err
+	}
+	fx, err := ReadFixture(path)
+	if err != nil {
+		return models.CurrentResponse{}, err
+	}
+	return decodeResponse(fx.Status, fmt.Sprintf("%d %s", fx.Status, http.StatusText(fx.Status)), fx.Header, fx.Body)
+}
@@ -0,0 +1,108 @@
+package siata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/testsupport"
+)
+
+// A recorded response replays to exactly what the live fetch decoded.
+func TestFixtureRoundTrip(t *testing.T) {
+	for _, encoding := range []string{"", "gzip"} {
+		t.Run("encoding="+encoding, func(t *testing.T) {
+			feed, err := testsupport.NewSIATAServer(testPayload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer feed.Close()
+			feed.SetEncoding(encoding)
+
+			dir := t.TempDir()
+			live, err := (&HTTPSource{Client: http.DefaultClient, URL: feed.URL, RecordDir: dir}).Fetch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			paths, err := fixturePaths(dir)
+			if err != nil || len(paths) != 1 {
+				t.Fatalf("fixtures: %v, %v", paths, err)
+			}
+			fx, err := ReadFixture(paths[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fx.Status != http.StatusOK || fx.Header.Get("Content-Encoding") != encoding || fx.URL != feed.URL {
+				t.Errorf("fixture metadata: status %d, encoding %q, url %s", fx.Status, fx.Header.Get("Content-Encoding"), fx.URL)
+			}
+
+			src, err := NewFixtureSource(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replayed, err := src.Fetch(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(live, replayed) {
+				t.Errorf("replay differs from live fetch:\n live:     %+v\n replayed: %+v", live, replayed)
+			}
+			if _, err := src.Fetch(context.Background()); !errors.Is(err, ErrFixturesExhausted) {
+				t.Errorf("after the last fixture: got %v, want ErrFixturesExhausted", err)
+			}
+		})
+	}
+}
+
+// Malformed and failed responses are recorded byte for byte and fail the
+// same way on replay.
+func TestFixtureRoundTripErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"truncated json", http.StatusOK, `{"estaciones": [{"codigo": 1`},
+		{"server error", http.StatusServiceUnavailable, "down"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+
+			dir := t.TempDir()
+			_, liveErr := (&HTTPSource{Client: http.DefaultClient, URL: srv.URL, RecordDir: dir}).Fetch(context.Background())
+			if liveErr == nil {
+				t.Fatal("live fetch succeeded")
+			}
+			paths, _ := fixturePaths(dir)
+			if len(paths) != 1 {
+				t.Fatalf("got %d fixtures, want 1", len(paths))
+			}
+			fx, err := ReadFixture(paths[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(fx.Body) != tc.body {
+				t.Errorf("recorded body %q, want %q", fx.Body, tc.body)
+			}
+
+			src, err := NewFixtureSource(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, replayErr := src.Fetch(context.Background())
+			// The live error additionally carries the retry summary.
+			if replayErr == nil || !strings.HasSuffix(liveErr.Error(), ": "+replayErr.Error()) {
+				t.Errorf("replay error %v does not match live error %v", replayErr, liveErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package siata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// DefaultHistoricURL is the open-data export of the SIATA Vaisala
+// precipitation history.
+const DefaultHistoricURL = "https://datosabiertos.metropol.gov.co/sites/default/files/uploaded_resources/Datos_SIATA_Vaisala_precipitacion_0.json"
+
+// FetchHistoricStations retrieves the SIATA historic feed, retrying
+// transient failures according to retry. The document holds the full history
+// of every station and can be large, so it is decoded as it streams.
+func FetchHistoricStations(ctx context.Context, client *http.Client, url string, retry RetryPolicy) ([]models.HistoricStation, error) {
+	return withRetry(ctx, retry, func() ([]models.HistoricStation, error) {
+		return fetchHistoricOnce(ctx, client, url)
+	})
+}
+
+func fetchHistoricOnce(ctx context.Context, client *http.Client, url string) ([]models.HistoricStation, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, &requestError{fmt.Errorf("request historic feed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &StatusError{Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	r, err := contentReader(resp.Header.Get("Content-Encoding"), resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var stations []models.HistoricStation
+	if err := json.NewDecoder(r).Decode(&stations); err != nil {
+		return nil, fmt.Errorf("decode historic feed: %w", err)
+	}
+	return stations, nil
+}
@@ -0,0 +1,109 @@
+package siata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxRetryDelay caps the exponential backoff between attempts.
+const maxRetryDelay = 30 * time.Second
+
+// RetryPolicy controls how transient feed failures are retried. The zero
+// value makes a single attempt.
+type RetryPolicy struct {
+	// MaxRetries is the number of attempts after the first one.
+	MaxRetries int
+	// BaseDelay is the wait before the first retry; it doubles after each
+	// further failure up to maxRetryDelay.
+	BaseDelay time.Duration
+}
+
+// StatusError reports a non-2xx feed response.
+type StatusError struct {
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status " + e.Status
+}
+
+// requestError wraps failures to reach the feed or read its body.
+type requestError struct {
+	err error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+func (e *requestError) Unwrap() error { return e.err }
+
+// retryable reports whether err is a network failure or a 5xx/429 response.
+// Other 4xx statuses and decode errors are permanent.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500 || statusErr.Code == http.StatusTooManyRequests
+	}
+	var reqErr *requestError
+	return errors.As(err, &reqErr)
+}
+
+// delay returns the backoff before retry number n (1-based).
+func (p RetryPolicy) delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryDelay)
+}
+
+// MaxDuration bounds the total time spent waiting between attempts, for
+// sizing the deadline of a fetch that may retry.
+func (p RetryPolicy) MaxDuration() time.Duration {
+	var total time.Duration
+	for n := 1; n <= p.MaxRetries; n++ {
+		total += p.delay(n)
+	}
+	return total
+}
+
+// withRetry runs fetch until it succeeds, fails permanently or runs out of
+// attempts under p. A retry is skipped when its backoff would outlast ctx's
+// deadline. The final error carries the number of attempts made.
+func withRetry[T any](ctx context.Context, p RetryPolicy, fetch func() (T, error)) (T, error) {
+	var zero T
+	var lastErr error
+	attempts := 0
+	for attempts <= p.MaxRetries {
+		if attempts > 0 {
+			wait := p.delay(attempts)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+				break
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return zero, fmt.Errorf("fetch failed after %d attempt(s): %w (last error: %v)", attempts, ctx.Err(), lastErr)
+			case <-timer.C:
+			}
+		}
+
+		attempts++
+		payload, err := fetch()
+		if err == nil {
+			return payload, nil
+		}
+		lastErr = err
+		if !retryable(err) || ctx.Err() != nil {
+			break
+		}
+		if attempts <= p.MaxRetries {
+			log.Printf("siata: attempt %d failed: %v", attempts, err)
+		}
+	}
+	return zero, fmt.Errorf("fetch failed after %d attempt(s): %w", attempts, lastErr)
+}
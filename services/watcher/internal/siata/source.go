@@ -0,0 +1,93 @@
+package siata
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// FeedSource yields the current stations payload, from SIATA or a replay.
+type FeedSource interface {
+	Fetch(ctx context.Context) (models.CurrentResponse, error)
+}
+
+// HTTPSource fetches the live feed. When RecordDir is set every response is
+// also written there as a numbered fixture for FixtureSource. Network errors
+// and 5xx/429 responses are retried according to Retry.
+type HTTPSource struct {
+	Client    *http.Client
+	URL       string
+	RecordDir string
+	Retry     RetryPolicy
+}
+
+// Fetch retrieves and decodes the feed.
+func (s *HTTPSource) Fetch(ctx context.Context) (models.CurrentResponse, error) {
+	return withRetry(ctx, s.Retry, func() (models.CurrentResponse, error) {
+		return s.fetchOnce(ctx)
+	})
+}
+
+func (s *HTTPSource) fetchOnce(ctx context.Context) (models.CurrentResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return models.CurrentResponse{}, err
+	}
+	// Setting the header ourselves turns off the transport's implicit gzip
+	// handling, so decodeResponse sees (and fixtures record) the raw bytes.
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return models.CurrentResponse{}, &requestError{fmt.Errorf("request current feed: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return models.CurrentResponse{}, &requestError{fmt.Errorf("read current feed: %w", err)}
+	}
+
+	if s.RecordDir != "" {
+		fx := Fixture{
+			Version:    fixtureVersion,
+			RecordedAt: time.Now().UTC(),
+			URL:        s.URL,
+			Status:     resp.StatusCode,
+			Header:     resp.Header,
+			Body:       body,
+		}
+		if _, err := writeFixture(s.RecordDir, fx); err != nil {
+			return models.CurrentResponse{}, fmt.Errorf("record fixture: %w", err)
+		}
+	}
+
+	return decodeResponse(resp.StatusCode, resp.Status, resp.Header, body)
+}
+
+// decodeResponse is the single decode path shared by live fetches and
+// fixture replays, so both see exactly the same bytes. Compressed bodies are
+// recorded as received and decompressed here.
+func decodeResponse(status int, statusText string, header http.Header, body []byte) (models.CurrentResponse, error) {
+	if status < 200 || status >= 300 {
+		return models.CurrentResponse{}, &StatusError{Code: status, Status: statusText}
+	}
+
+	r, err := contentReader(header.Get("Content-Encoding"), bytes.NewReader(body))
+	if err != nil {
+		return models.CurrentResponse{}, err
+	}
+	defer r.Close()
+
+	var payload models.CurrentResponse
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return models.CurrentResponse{}, fmt.Errorf("decode payload: %w", err)
+	}
+	return payload, nil
+}
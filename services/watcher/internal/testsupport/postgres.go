@@ -0,0 +1,79 @@
+package testsupport
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/pgtest"
+)
+
+// DatabaseURLEnv optionally names a disposable Postgres (with PostGIS) for
+// integration runs. NewTestDB drops the shizuku schema of that database, so
+// it must never point at a shared one. Without it a container is started.
+const DatabaseURLEnv = "WATCHER_TEST_DATABASE_URL"
+
+// ErrNoTestDatabase is returned when DatabaseURLEnv is unset and no container
+// could be started; callers should skip rather than fail.
+var ErrNoTestDatabase = pgtest.ErrUnavailable
+
+// NewTestDB provides a disposable database (see pgtest.Start), recreates the
+// shizuku schema from schemaPath and returns a pool bound to it along with
+// its URL. The returned cleanup drops the schema, closes the pool and stops
+// the container, if one was started.
+func NewTestDB(ctx context.Context, schemaPath string) (*pgxpool.Pool, string, func(), error) {
+	url, stop, err := pgtest.Start(ctx, DatabaseURLEnv)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	pool, err := resetSchema(ctx, url, schemaPath)
+	if err != nil {
+		stop()
+		return nil, "", nil, err
+	}
+	cleanup := func() {
+		_, _ = pool.Exec(context.Background(), `DROP SCHEMA IF EXISTS shizuku CASCADE`)
+		pool.Close()
+		stop()
+	}
+	return pool, url, cleanup, nil
+}
+
+// resetSchema recreates the shizuku schema of url from schemaPath.
+func resetSchema(ctx context.Context, url, schemaPath string) (*pgxpool.Pool, error) {
+	schema, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = "shizuku,public"
+
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := pool.Exec(ctx, `DROP SCHEMA IF EXISTS shizuku CASCADE; CREATE SCHEMA shizuku`); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("reset schema: %w", err)
+	}
+	if _, err := pool.Exec(ctx, string(schema)); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("apply %s: %w", schemaPath, err)
+	}
+
+	return pool, nil
+}
+
+// CountRows returns the row count of a shizuku table.
+func CountRows(ctx context.Context, pool *pgxpool.Pool, table string) (int, error) {
+	var n int
+	err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM shizuku."+table).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,177 @@
+// Package testsupport provides the fakes used to exercise the watcher
+// pipeline end to end without touching SIATA or production Postgres.
+package testsupport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// SIATAServer is an httptest server standing in for the SIATA current feed.
+// It serves the configured payload with an ETag and answers 304 to matching
+// If-None-Match requests; latency and failures can be injected between cycles.
+type SIATAServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	payload  []byte
+	etag     string
+	version  int
+	latency  time.Duration
+	encoding string
+	failures []int
+	requests int
+}
+
+// NewSIATAServer starts a mock feed serving payload.
+func NewSIATAServer(payload models.CurrentResponse) (*SIATAServer, error) {
+	s := &SIATAServer{}
+	if err := s.SetPayload(payload); err != nil {
+		return nil, err
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s, nil
+}
+
+// NewSIATAServerFromFile starts a mock feed serving a recorded fixture file.
+func NewSIATAServerFromFile(path string) (*SIATAServer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var payload models.CurrentResponse
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	return NewSIATAServer(payload)
+}
+
+// SetPayload replaces the served payload and rotates the ETag.
+func (s *SIATAServer) SetPayload(payload models.CurrentResponse) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version++
+	s.payload = raw
+	s.etag = fmt.Sprintf(`"fixture-%d"`, s.version)
+	return nil
+}
+
+// SetLatency delays every response by d.
+func (s *SIATAServer) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetEncoding compresses responses with "gzip" or "deflate" for requests
+// whose Accept-Encoding allows it; "" serves them uncompressed.
+func (s *SIATAServer) SetEncoding(encoding string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encoding = encoding
+}
+
+// FailNext makes the next len(statuses) requests fail with the given HTTP
+// statuses, in order.
+func (s *SIATAServer) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// Requests returns how many requests the server has received.
+func (s *SIATAServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *SIATAServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests++
+	latency := s.latency
+	payload, etag, encoding := s.payload, s.etag, s.encoding
+	status := 0
+	if len(s.failures) > 0 {
+		status, s.failures = s.failures[0], s.failures[1:]
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if status != 0 {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if encoding != "" && strings.Contains(r.Header.Get("Accept-Encoding"), encoding) {
+		compressed, err := compress(encoding, payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Encoding", encoding)
+		payload = compressed
+	}
+	_, _ = w.Write(payload)
+}
+
+// compress encodes payload with gzip or deflate (zlib-wrapped, per HTTP).
+func compress(encoding string, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var zw io.WriteCloser
+	switch encoding {
+	case "gzip":
+		zw = gzip.NewWriter(&buf)
+	case "deflate":
+		zw = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Station builds a feed entry with the fields the watcher reads.
+func Station(code int, lat, lon float64, value *float64) models.Station {
+	return models.Station{
+		Code:      code,
+		Name:      fmt.Sprintf("Fixture %d", code),
+		City:      "Medellín",
+		Latitude:  lat,
+		Longitude: lon,
+		Value:     value,
+	}
+}
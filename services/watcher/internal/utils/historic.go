@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/timeutil"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// historicVariable is the only variable taken from the historic feed.
+const historicVariable = "precipitacion"
+
+// HistoricSensorID is the sensor id of a historic (Vaisala) station.
+func HistoricSensorID(st models.HistoricStation) string {
+	return "vaisala_" + st.Code.String()
+}
+
+// ValidateHistoricStation applies ValidateStation to a historic station,
+// which may also lack coordinates altogether.
+func ValidateHistoricStation(st models.HistoricStation) error {
+	if st.Latitude.Value == nil || st.Longitude.Value == nil {
+		return errors.New("missing coordinates")
+	}
+	return ValidateStation(models.Station{Latitude: *st.Latitude.Value, Longitude: *st.Longitude.Value})
+}
+
+// BuildHistoricSensorRows converts historic stations into sensor rows. The
+// stations must have passed ValidateHistoricStation.
+func BuildHistoricSensorRows(stations []models.HistoricStation) []models.SensorRow {
+	rows := make([]models.SensorRow, 0, len(stations))
+	for _, st := range stations {
+		rows = append(rows, models.SensorRow{
+			ID:         HistoricSensorID(st),
+			ProviderID: st.Code.String(),
+			Name:       st.Name,
+			Lat:        *st.Latitude.Value,
+			Lon:        *st.Longitude.Value,
+			City:       st.City,
+			Subbasin:   st.Subbasin,
+			Metadata: map[string]any{
+				"source":    "historic",
+				"network":   "vaisala",
+				"subcuenca": st.Subbasin,
+			},
+		})
+	}
+	return rows
+}
+
+// BuildHistoricCandidates flattens the precipitation readings of stations
+// with timestamps in [start, end) into measurement candidates; a zero start
+// or end leaves that side open. Slot dates carry no offset and are read in
+// zone. Values go through NormalizeValue like the current feed's, and
+// retrievedAt is stored as ingested_at. Slots whose date cannot be parsed
+// are counted in skipped.
+func BuildHistoricCandidates(stations []models.HistoricStation, zone *time.Location, start, end time.Time, sentinels []float64, retrievedAt time.Time) (candidates []models.MeasurementCandidate, skipped int) {
+	for _, st := range stations {
+		id := HistoricSensorID(st)
+		for _, slot := range st.Slots {
+			ts, err := timeutil.ParseUTC(slot.Date, zone)
+			if err != nil {
+				skipped++
+				continue
+			}
+			if (!start.IsZero() && ts.Before(start)) || (!end.IsZero() && !ts.Before(end)) {
+				continue
+			}
+			for _, v := range slot.Values {
+				if v.Variable != historicVariable {
+					continue
+				}
+				candidates = append(candidates, models.MeasurementCandidate{
+					SensorID:    id,
+					Value:       NormalizeValue(v.Value.Value, sentinels),
+					TS:          ts,
+					RetrievedAt: retrievedAt,
+				})
+			}
+		}
+	}
+	return candidates, skipped
+}
+
+// FilterHistoricMeasurements applies the FilterNewMeasurements rules to a
+// series: candidates are replayed per sensor in time order, and each kept one
+// becomes the "last" measurement the next is compared against, exactly as if
+// the watcher had seen them one cycle at a time. Candidates at or before the
+// sensor's stored last measurement are skipped, so re-running a backfill only
+// adds what is missing. last is not modified.
+func FilterHistoricMeasurements(
+	candidates []models.MeasurementCandidate,
+	last map[string]models.LastMeasurement,
+	minInterval time.Duration,
+	epsilon float64,
+) []FilteredMeasurement {
+	sorted := slices.Clone(candidates)
+	slices.SortStableFunc(sorted, func(a, b models.MeasurementCandidate) int {
+		return cmp.Or(cmp.Compare(a.SensorID, b.SensorID), a.TS.Compare(b.TS))
+	})
+
+	seen := make(map[string]models.LastMeasurement, len(last))
+	for id, m := range last {
+		seen[id] = m
+	}
+
+	out := make([]FilteredMeasurement, 0, len(sorted))
+	for _, cand := range sorted {
+		if prev, ok := seen[cand.SensorID]; ok && !cand.TS.After(prev.TS) {
+			continue
+		}
+		if reason := ClassifyCandidate(cand, seen, minInterval, epsilon); reason != "" {
+			out = append(out, FilteredMeasurement{Candidate: cand, Reason: reason})
+			seen[cand.SensorID] = models.LastMeasurement{Value: cand.Value, TS: cand.TS}
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// StaleSensor is a sensor whose last reading is older than the staleness
+// threshold.
+type StaleSensor struct {
+	SensorID string
+	LastTS   time.Time
+	Age      time.Duration
+}
+
+// StaleSensors returns the sensors of last whose latest reading is more than
+// after older than now, oldest first. last maps sensor ids to their last
+// stored reading; pending readings about to be inserted count as well, except
+// those without a value, since a gauge that only sends sentinels has stopped
+// reporting too.
+func StaleSensors(last map[string]models.LastMeasurement, pending []models.MeasurementCandidate, now time.Time, after time.Duration) []StaleSensor {
+	latest := make(map[string]time.Time, len(last))
+	for id, m := range last {
+		latest[id] = m.TS
+	}
+	for _, cand := range pending {
+		if cand.Value != nil && cand.TS.After(latest[cand.SensorID]) {
+			latest[cand.SensorID] = cand.TS
+		}
+	}
+
+	var stale []StaleSensor
+	for id, ts := range latest {
+		if age := now.Sub(ts); age > after {
+			stale = append(stale, StaleSensor{SensorID: id, LastTS: ts, Age: age})
+		}
+	}
+	slices.SortFunc(stale, func(a, b StaleSensor) int {
+		return cmp.Or(a.LastTS.Compare(b.LastTS), cmp.Compare(a.SensorID, b.SensorID))
+	})
+	return stale
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+func TestStaleSensors(t *testing.T) {
+	v := 0.2
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	last := map[string]models.LastMeasurement{
+		"fresh":         {Value: &v, TS: now.Add(-10 * time.Minute)},
+		"dead":          {Value: &v, TS: now.Add(-5 * time.Hour)},
+		"older":         {Value: &v, TS: now.Add(-30 * time.Hour)},
+		"at-threshold":  {Value: &v, TS: now.Add(-2 * time.Hour)},
+		"revived":       {Value: &v, TS: now.Add(-3 * time.Hour)},
+		"sentinel-only": {Value: &v, TS: now.Add(-3 * time.Hour)},
+	}
+	pending := []models.MeasurementCandidate{
+		{SensorID: "revived", Value: &v, TS: now},
+		// A reading without a value does not count as reporting.
+		{SensorID: "sentinel-only", Value: nil, TS: now},
+		{SensorID: "brand-new", Value: &v, TS: now},
+	}
+
+	got := StaleSensors(last, pending, now, 2*time.Hour)
+
+	want := []StaleSensor{
+		{SensorID: "older", LastTS: now.Add(-30 * time.Hour), Age: 30 * time.Hour},
+		{SensorID: "dead", LastTS: now.Add(-5 * time.Hour), Age: 5 * time.Hour},
+		{SensorID: "sentinel-only", LastTS: now.Add(-3 * time.Hour), Age: 3 * time.Hour},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].SensorID != want[i].SensorID || !got[i].LastTS.Equal(want[i].LastTS) || got[i].Age != want[i].Age {
+			t.Errorf("stale[%d]: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -1,14 +1,65 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
 )
 
+// ServiceArea is a generous box around the Valle de Aburrá; stations far
+// outside it usually have garbage or swapped coordinates.
+var ServiceArea = struct{ MinLat, MaxLat, MinLon, MaxLon float64 }{
+	MinLat: 5.5, MaxLat: 7.0, MinLon: -76.2, MaxLon: -74.8,
+}
+
+// StationError is a station rejected by ValidateStation.
+type StationError struct {
+	Code int
+	Err  error
+}
+
+// ValidateStation rejects stations whose coordinates cannot be real: latitude
+// outside [-90, 90], longitude outside [-180, 180], or exactly 0,0.
+func ValidateStation(st models.Station) error {
+	switch {
+	case math.IsNaN(st.Latitude) || st.Latitude < -90 || st.Latitude > 90:
+		return fmt.Errorf("latitude %g out of range [-90, 90]", st.Latitude)
+	case math.IsNaN(st.Longitude) || st.Longitude < -180 || st.Longitude > 180:
+		return fmt.Errorf("longitude %g out of range [-180, 180]", st.Longitude)
+	case st.Latitude == 0 && st.Longitude == 0:
+		return errors.New("coordinates are 0,0")
+	}
+	return nil
+}
+
+// OutsideServiceArea reports whether a station lies outside ServiceArea.
+func OutsideServiceArea(st models.Station) bool {
+	return st.Latitude < ServiceArea.MinLat || st.Latitude > ServiceArea.MaxLat ||
+		st.Longitude < ServiceArea.MinLon || st.Longitude > ServiceArea.MaxLon
+}
+
+// SplitValidStations separates stations that pass ValidateStation from the
+// rejected ones.
+func SplitValidStations(stations []models.Station) ([]models.Station, []StationError) {
+	valid := make([]models.Station, 0, len(stations))
+	var invalid []StationError
+	for _, st := range stations {
+		if err := ValidateStation(st); err != nil {
+			invalid = append(invalid, StationError{Code: st.Code, Err: err})
+			continue
+		}
+		valid = append(valid, st)
+	}
+	return valid, invalid
+}
+
 // BuildSensorRows converts feed stations into database-ready sensor rows.
 func BuildSensorRows(stations []models.Station) []models.SensorRow {
 	rows := make([]models.SensorRow, 0, len(stations))
@@ -27,6 +78,7 @@ func BuildSensorRows(stations []models.Station) []models.SensorRow {
 			Name:       st.Name,
 			Lat:        st.Latitude,
 			Lon:        st.Longitude,
+			ElevationM: st.Elevation,
 			City:       st.City,
 			Subbasin:   st.Subbasin,
 			Barrio:     st.Barrio,
@@ -45,60 +97,239 @@ func SensorIDs(rows []models.SensorRow) []string {
 	return ids
 }
 
-// BuildMeasurementCandidates normalizes station values into measurement candidates.
-func BuildMeasurementCandidates(stations []models.Station, retrievalTS time.Time) []models.MeasurementCandidate {
+// BuildMeasurementCandidates normalizes station values into measurement
+// candidates, mapping sentinels to nil as NormalizeValue does.
+func BuildMeasurementCandidates(stations []models.Station, retrievalTS time.Time, sentinels []float64) []models.MeasurementCandidate {
+	// The current feed carries no per-station time, so candidates are stamped
+	// with the retrieval instant. Stored timestamps are always UTC; feed
+	// timestamps, should a feed provide them, must go through
+	// timeutil.ParseUTC with the feed's declared zone.
+	retrievalTS = retrievalTS.UTC()
 	candidates := make([]models.MeasurementCandidate, 0, len(stations))
 	for _, st := range stations {
 		id := fmt.Sprintf("pluvio_%d", st.Code)
-		value := NormalizeValue(st.Value)
+		value := NormalizeValue(st.Value, sentinels)
 		candidates = append(candidates, models.MeasurementCandidate{
-			SensorID: id,
-			Value:    value,
-			TS:       retrievalTS,
+			SensorID:    id,
+			Value:       value,
+			TS:          retrievalTS,
+			RetrievedAt: retrievalTS,
 		})
 	}
 	return candidates
 }
 
-// NormalizeValue cleans raw sensor values; -999 sentinel -> nil.
-func NormalizeValue(v *float64) *float64 {
+// AlignTimestamp applies the timestamp alignment policy: config.AlignNone
+// keeps ts, config.AlignMinute floors it to the minute and config.AlignCadence
+// floors it to a multiple of cadence (e.g. the 5-minute SIATA cycle).
+func AlignTimestamp(ts time.Time, policy string, cadence time.Duration) time.Time {
+	switch policy {
+	case config.AlignMinute:
+		return ts.Truncate(time.Minute)
+	case config.AlignCadence:
+		if cadence > 0 {
+			return ts.Truncate(cadence)
+		}
+	}
+	return ts
+}
+
+// AlignCandidates rewrites candidate timestamps with AlignTimestamp, leaving
+// RetrievedAt untouched.
+func AlignCandidates(candidates []models.MeasurementCandidate, policy string, cadence time.Duration) {
+	for i := range candidates {
+		candidates[i].TS = AlignTimestamp(candidates[i].TS, policy, cadence)
+	}
+}
+
+// HaversineMeters returns the great-circle distance between two locations.
+func HaversineMeters(a, b models.Location) float64 {
+	const earthRadius = 6371008.8
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// DetectLocationChanges compares feed rows with the stored sensor locations
+// and returns sensors that are new or moved by more than thresholdM metres.
+func DetectLocationChanges(rows []models.SensorRow, stored map[string]models.Location, thresholdM float64) []models.LocationChange {
+	changes := make([]models.LocationChange, 0)
+	for _, row := range rows {
+		current := models.Location{Lat: row.Lat, Lon: row.Lon}
+		prev, ok := stored[row.ID]
+		if !ok {
+			changes = append(changes, models.LocationChange{SensorID: row.ID, Current: current})
+			continue
+		}
+		if HaversineMeters(prev, current) > thresholdM {
+			p := prev
+			changes = append(changes, models.LocationChange{SensorID: row.ID, Current: current, Previous: &p})
+		}
+	}
+	return changes
+}
+
+// DetectMetadataChanges compares feed rows with the stored sensor metadata
+// and returns the fields that changed, in a stable order. Sensors not stored
+// yet have nothing to compare against and are skipped. Values differing only
+// in case or whitespace count as unchanged.
+func DetectMetadataChanges(rows []models.SensorRow, stored map[string]models.SensorRow) []models.MetadataChange {
+	changes := make([]models.MetadataChange, 0)
+	for _, row := range rows {
+		prev, ok := stored[row.ID]
+		if !ok {
+			continue
+		}
+		add := func(field string, old, cur *string) {
+			if !sameText(old, cur) {
+				changes = append(changes, models.MetadataChange{SensorID: row.ID, Field: field, Old: old, New: cur})
+			}
+		}
+		add("name", textPtr(prev.Name), textPtr(row.Name))
+		add("provider_id", textPtr(prev.ProviderID), textPtr(row.ProviderID))
+		add("city", textPtr(prev.City), textPtr(row.City))
+		add("subbasin", textPtr(prev.Subbasin), textPtr(row.Subbasin))
+		add("barrio", textPtr(prev.Barrio), textPtr(row.Barrio))
+
+		keys := make([]string, 0, len(row.Metadata)+len(prev.Metadata))
+		for k := range row.Metadata {
+			keys = append(keys, k)
+		}
+		for k := range prev.Metadata {
+			if _, ok := row.Metadata[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			add("metadata."+k, metadataText(prev.Metadata, k), metadataText(row.Metadata, k))
+		}
+	}
+	return changes
+}
+
+// textPtr returns nil for a blank string, so unset and empty compare equal.
+func textPtr(s string) *string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return &s
+}
+
+// metadataText renders a metadata value for comparison and storage, nil when
+// the key is absent or null.
+func metadataText(m map[string]any, key string) *string {
+	v, ok := m[key]
+	if !ok || v == nil {
+		return nil
+	}
+	return textPtr(fmt.Sprint(v))
+}
+
+// sameText reports whether two values are equal ignoring case and
+// differences in whitespace.
+func sameText(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return strings.EqualFold(strings.Join(strings.Fields(*a), " "), strings.Join(strings.Fields(*b), " "))
+}
+
+// NormalizeValue cleans raw sensor values: values in sentinels (e.g. -999,
+// -9999, 999) become nil. With no sentinels configured, anything <= -900 is
+// treated as the -999 sentinel.
+func NormalizeValue(v *float64, sentinels []float64) *float64 {
 	if v == nil {
 		return nil
 	}
-	if *v <= -900 {
+	if len(sentinels) == 0 {
+		if *v <= -900 {
+			return nil
+		}
+	} else if slices.Contains(sentinels, *v) {
 		return nil
 	}
 	val := *v
 	return &val
 }
 
-// FilterNewMeasurements selects candidates that should be inserted.
+// Reason is why a measurement candidate is kept for insertion.
+type Reason string
+
+// Keep reasons reported by ClassifyCandidate.
+const (
+	ReasonNewSensor    Reason = "new_sensor"
+	ReasonInterval     Reason = "interval_elapsed"
+	ReasonValueChanged Reason = "value_changed"
+)
+
+// ClassifyCandidate returns why cand should be inserted given the sensor's
+// last stored measurement, or "" when it should be skipped: a sensor with no
+// stored measurement is new, then an elapsed minInterval forces an insert,
+// and otherwise only a value change beyond epsilon is kept.
+func ClassifyCandidate(cand models.MeasurementCandidate, last map[string]models.LastMeasurement, minInterval time.Duration, epsilon float64) Reason {
+	prev, ok := last[cand.SensorID]
+	switch {
+	case !ok:
+		return ReasonNewSensor
+	case cand.TS.Sub(prev.TS) >= minInterval:
+		return ReasonInterval
+	case !ValuesEqual(prev.Value, cand.Value, epsilon):
+		return ReasonValueChanged
+	}
+	return ""
+}
+
+// FilteredMeasurement is a candidate kept for insertion together with the
+// reason it was kept.
+type FilteredMeasurement struct {
+	Candidate models.MeasurementCandidate
+	Reason    Reason
+}
+
+// FilterNewMeasurements selects candidates that should be inserted, as
+// decided by ClassifyCandidate, keeping each one's reason.
+//
+// Under timestamp alignment two fetches can collapse onto the same aligned ts
+// as the stored measurement. Such a candidate is kept only if its value
+// differs, and the insert upsert then overwrites the stored row, so the later
+// value wins; an unchanged value is skipped.
 func FilterNewMeasurements(
 	candidates []models.MeasurementCandidate,
 	last map[string]models.LastMeasurement,
 	minInterval time.Duration,
 	epsilon float64,
-) []models.MeasurementCandidate {
-	out := make([]models.MeasurementCandidate, 0, len(candidates))
+) []FilteredMeasurement {
+	out := make([]FilteredMeasurement, 0, len(candidates))
 	for _, cand := range candidates {
-		prev, ok := last[cand.SensorID]
-		if !ok {
-			out = append(out, cand)
-			continue
-		}
-
-		if cand.TS.Sub(prev.TS) >= minInterval {
-			out = append(out, cand)
-			continue
+		if reason := ClassifyCandidate(cand, last, minInterval, epsilon); reason != "" {
+			out = append(out, FilteredMeasurement{Candidate: cand, Reason: reason})
 		}
+	}
+	return out
+}
 
-		if !ValuesEqual(prev.Value, cand.Value, epsilon) {
-			out = append(out, cand)
-		}
+// FilteredCandidates unwraps the candidates of filtered, in order.
+func FilteredCandidates(filtered []FilteredMeasurement) []models.MeasurementCandidate {
+	out := make([]models.MeasurementCandidate, len(filtered))
+	for i, f := range filtered {
+		out[i] = f.Candidate
 	}
 	return out
 }
 
+// CountByReason tallies filtered measurements per keep reason.
+func CountByReason(filtered []FilteredMeasurement) map[Reason]int {
+	counts := make(map[Reason]int)
+	for _, f := range filtered {
+		counts[f.Reason]++
+	}
+	return counts
+}
+
 // ValuesEqual compares two optional float values with tolerance.
 func ValuesEqual(a, b *float64, epsilon float64) bool {
 	switch {
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	configured := []float64{-999, -9999, 999}
+
+	for _, tc := range []struct {
+		name      string
+		in        *float64
+		sentinels []float64
+		wantNil   bool
+	}{
+		{"nil stays nil", nil, configured, true},
+		{"-999", ptr(-999), configured, true},
+		{"-9999", ptr(-9999), configured, true},
+		{"999", ptr(999), configured, true},
+		{"normal value", ptr(1.5), configured, false},
+		{"zero", ptr(0), configured, false},
+		{"unlisted negative", ptr(-900), configured, false},
+		{"default: -999", ptr(-999), nil, true},
+		{"default: anything at or below -900", ptr(-900), nil, true},
+		{"default: 999 is a value", ptr(999), nil, false},
+		{"default: normal value", ptr(1.5), nil, false},
+	} {
+		got := NormalizeValue(tc.in, tc.sentinels)
+		if (got == nil) != tc.wantNil {
+			t.Errorf("%s: got %v, want nil %t", tc.name, ValuePtrString(got), tc.wantNil)
+			continue
+		}
+		if got != nil && *got != *tc.in {
+			t.Errorf("%s: got %g, want %g", tc.name, *got, *tc.in)
+		}
+	}
+}
+
+func TestFilterNewMeasurementsReasons(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	t0 := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	last := map[string]models.LastMeasurement{
+		"interval": {Value: ptr(1), TS: t0.Add(-time.Hour)},
+		"changed":  {Value: ptr(1), TS: t0.Add(-5 * time.Minute)},
+		"same":     {Value: ptr(1), TS: t0.Add(-5 * time.Minute)},
+		"to-null":  {Value: ptr(1), TS: t0.Add(-5 * time.Minute)},
+	}
+	candidates := []models.MeasurementCandidate{
+		{SensorID: "new", Value: ptr(0), TS: t0},
+		{SensorID: "interval", Value: ptr(1), TS: t0},
+		{SensorID: "changed", Value: ptr(1.5), TS: t0},
+		{SensorID: "same", Value: ptr(1.0005), TS: t0},
+		{SensorID: "to-null", Value: nil, TS: t0},
+	}
+
+	filtered := FilterNewMeasurements(candidates, last, 30*time.Minute, 0.001)
+	got := make(map[string]Reason, len(filtered))
+	for _, f := range filtered {
+		got[f.Candidate.SensorID] = f.Reason
+	}
+	want := map[string]Reason{
+		"new":      ReasonNewSensor,
+		"interval": ReasonInterval,
+		"changed":  ReasonValueChanged,
+		"to-null":  ReasonValueChanged,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("reasons: got %v, want %v", got, want)
+	}
+
+	kept := FilteredCandidates(filtered)
+	if len(kept) != 4 || kept[0].SensorID != "new" || kept[3].SensorID != "to-null" {
+		t.Errorf("FilteredCandidates: got %v, want the kept candidates in input order", kept)
+	}
+	counts := CountByReason(filtered)
+	if want := map[Reason]int{ReasonNewSensor: 1, ReasonInterval: 1, ReasonValueChanged: 2}; !maps.Equal(counts, want) {
+		t.Errorf("CountByReason: got %v, want %v", counts, want)
+	}
+}
+
+func TestAlignTimestamp(t *testing.T) {
+	ts := time.Date(2025, 10, 1, 12, 3, 40, 500, time.UTC)
+	for _, tc := range []struct {
+		policy  string
+		cadence time.Duration
+		want    time.Time
+	}{
+		{config.AlignNone, 5 * time.Minute, ts},
+		{config.AlignMinute, 5 * time.Minute, time.Date(2025, 10, 1, 12, 3, 0, 0, time.UTC)},
+		{config.AlignCadence, 5 * time.Minute, time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)},
+		{config.AlignCadence, 0, ts},
+	} {
+		if got := AlignTimestamp(ts, tc.policy, tc.cadence); !got.Equal(tc.want) {
+			t.Errorf("AlignTimestamp(%s, %s) = %s, want %s", tc.policy, tc.cadence, got, tc.want)
+		}
+	}
+}
+
+func TestAlignCandidatesCollapsesFetches(t *testing.T) {
+	ptr := func(v float64) *float64 { return &v }
+	aligned := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two fetches inside the same 5-minute cycle.
+	first := []models.MeasurementCandidate{
+		{SensorID: "a", Value: ptr(1), TS: aligned.Add(70 * time.Second), RetrievedAt: aligned.Add(75 * time.Second)},
+	}
+	second := []models.MeasurementCandidate{
+		{SensorID: "a", Value: ptr(1), TS: aligned.Add(220 * time.Second), RetrievedAt: aligned.Add(225 * time.Second)},
+		{SensorID: "b", Value: ptr(2), TS: aligned.Add(220 * time.Second), RetrievedAt: aligned.Add(225 * time.Second)},
+	}
+	AlignCandidates(first, config.AlignCadence, 5*time.Minute)
+	AlignCandidates(second, config.AlignCadence, 5*time.Minute)
+
+	for _, c := range append(first, second...) {
+		if !c.TS.Equal(aligned) {
+			t.Errorf("%s: aligned ts %s, want %s", c.SensorID, c.TS, aligned)
+		}
+	}
+	if want := aligned.Add(225 * time.Second); !second[0].RetrievedAt.Equal(want) {
+		t.Errorf("RetrievedAt rewritten to %s, want %s", second[0].RetrievedAt, want)
+	}
+
+	// The first fetch is stored; the second lands on the same aligned ts.
+	last := map[string]models.LastMeasurement{
+		"a": {Value: first[0].Value, TS: first[0].TS},
+		"b": {Value: ptr(2), TS: aligned},
+	}
+	if got := FilterNewMeasurements(second, last, 5*time.Minute, 0.001); len(got) != 0 {
+		t.Errorf("unchanged values on the collapsed ts kept: %+v", got)
+	}
+
+	second[0].Value = ptr(1.5)
+	got := FilterNewMeasurements(second, last, 5*time.Minute, 0.001)
+	if len(got) != 1 || got[0].Candidate.SensorID != "a" || got[0].Reason != ReasonValueChanged {
+		t.Errorf("changed value on the collapsed ts: got %+v, want only a as %s", got, ReasonValueChanged)
+	}
+}
@@ -2,15 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/migrate"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/chaos"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/siata"
 	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/utils"
 )
@@ -29,61 +37,403 @@ func run() error {
 	if err != nil {
 		return err
 	}
+	// "watcher backfill" is shorthand for WATCHER_MODE=backfill.
+	if len(os.Args) > 1 && os.Args[1] == config.ModeBackfill {
+		cfg.Mode = config.ModeBackfill
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+	}
+	log.Printf("config: %s", cfg.Redacted())
+	if cfg.Chaos.Enabled() {
+		log.Printf("warning: chaos injection enabled: feed_error_rate=%g db_error_rate=%g latency=%s", cfg.Chaos.FeedErrorRate, cfg.Chaos.DBErrorRate, cfg.Chaos.Latency)
+	}
+
+	registerMetrics()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// "watcher migrate up|down|status" manages the schema without running.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return migrate.Command(ctx, cfg.DatabaseURL, os.Args[2:], os.Stdout)
+	}
+	if cfg.RunMigrations {
+		if cfg.DryRun {
+			log.Printf("dry-run: skipping migrations")
+		} else if err := migrate.Run(ctx, cfg.DatabaseURL); err != nil {
+			return err
+		}
+	}
+
+	if cfg.MetricsAddr != "" {
+		go serveMetrics(ctx, cfg.MetricsAddr)
+	}
+
+	if cfg.LoopInterval > 0 {
+		return runLoop(ctx, cfg)
+	}
+
+	once := runCycle
+	if cfg.Mode == config.ModeBackfill {
+		once = runBackfill
+	}
+	err = once(ctx, cfg)
+	switch {
+	case cfg.PushgatewayURL != "":
+		if perr := pushMetrics(ctx, cfg.PushgatewayURL); perr != nil {
+			log.Printf("push metrics: %v", perr)
+		}
+	case cfg.MetricsAddr != "" && cfg.MetricsLinger > 0:
+		// A one-shot run is gone before a scrape interval passes; stay up
+		// long enough to be scraped once.
+		log.Printf("keeping metrics up for %s", cfg.MetricsLinger)
+		select {
+		case <-ctx.Done():
+		case <-time.After(cfg.MetricsLinger):
+		}
+	}
+	return err
+}
+
+// runLoop runs a cycle immediately and then on every tick of
+// cfg.LoopInterval until ctx is cancelled by SIGINT or SIGTERM. A failed
+// cycle is logged and the loop carries on; a signal cancels the cycle in
+// flight.
+func runLoop(ctx context.Context, cfg config.Config) error {
+	log.Printf("running every %s until interrupted", cfg.LoopInterval)
+	ticker := time.NewTicker(cfg.LoopInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := runCycle(ctx, cfg); err != nil && ctx.Err() == nil {
+			log.Printf("cycle failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("shutting down")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// currentFeedMaxBytes caps the current feed document; a normal one is a few
+// hundred kilobytes.
+const currentFeedMaxBytes = 64 << 20
+
+// runCycle performs one fetch/dedup/insert pass over every configured feed,
+// under a fresh deadline derived from parent. Feeds are fetched concurrently
+// and written one at a time (see runFeeds); a failing feed is recorded and
+// reported without stopping the others. It is separate from run so the
+// pipeline can be driven against the testsupport mock feed and a disposable
+// database.
+func runCycle(parent context.Context, cfg config.Config) error {
+	start := time.Now()
+	defer func() { cycleDuration.Observe(time.Since(start).Seconds()) }()
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.RequestTimeout+10*time.Second)
+	retry := siata.RetryPolicy{MaxRetries: cfg.MaxRetries, BaseDelay: cfg.RetryBaseDelay}
+	// Leave room for every attempt and its backoff; fetches overlap, but
+	// each feed's writes come after the previous feed's.
+	feeds := cfg.Feeds()
+	fetchBudget := time.Duration(cfg.MaxRetries+1)*cfg.RequestTimeout + retry.MaxDuration()
+	ctx, cancel := context.WithTimeout(parent, fetchBudget+time.Duration(len(feeds))*10*time.Second)
 	defer cancel()
 
-	client := &http.Client{Timeout: cfg.RequestTimeout}
-	retrievalTS := time.Now().UTC().Truncate(time.Second)
+	inj := chaos.New(cfg.Chaos)
+	client := httpclient.New(httpclient.Options{
+		Timeout:          cfg.RequestTimeout,
+		MaxResponseBytes: currentFeedMaxBytes,
+		Wrap:             inj.Transport,
+		Observe:          observeFeedRequest,
+	})
+	sources := make(map[string]siata.FeedSource, len(feeds))
+	for _, feed := range feeds {
+		source, err := feedSource(cfg, feed, client, retry)
+		if err != nil {
+			return err
+		}
+		sources[feed.Name] = source
+	}
+
+	pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	if err != nil {
+		return dbError(err)
+	}
+	defer pool.Close()
+
+	// Overlapping runs would race on the same measurement rows; only one
+	// writing run proceeds at a time.
+	if !cfg.DryRun {
+		if err := inj.DB(ctx); err != nil {
+			return dbError(err)
+		}
+		release, ok, err := db.TryRunLock(ctx, pool)
+		if err != nil {
+			return dbError(err)
+		}
+		if !ok {
+			log.Printf("another run holds the lock; skipping this cycle")
+			return nil
+		}
+		defer release()
+	}
+
+	// A JSON dry run collects one report per feed and prints them together
+	// once every feed is through.
+	var reports map[string]*dryRunReport
+	if cfg.DryRun && cfg.DryRunFormat == config.DryRunJSON {
+		reports = make(map[string]*dryRunReport, len(feeds))
+	}
+
+	var errs []error
+	runFeeds(ctx, feeds, cfg.FetchConcurrency,
+		func(ctx context.Context, feed config.Feed) fetchedFeed {
+			ctx, cancel := context.WithTimeout(ctx, fetchBudget)
+			defer cancel()
+			res := fetchedFeed{feed: feed, retrievalTS: time.Now().UTC().Truncate(time.Second)}
+			res.payload, res.err = sources[feed.Name].Fetch(ctx)
+			return res
+		},
+		func(ctx context.Context, res fetchedFeed) {
+			var report *dryRunReport
+			if reports != nil {
+				report = &dryRunReport{
+					Feed:         res.feed.Name,
+					RetrievalTS:  res.retrievalTS,
+					ByReason:     make(map[utils.Reason]int),
+					Measurements: []dryRunMeasurement{},
+				}
+				reports[res.feed.Name] = report
+			}
+			if err := writeFeed(ctx, cfg, pool, inj, res, report); err != nil {
+				errs = append(errs, fmt.Errorf("feed %s: %w", res.feed.Name, err))
+				if report != nil {
+					report.Error = err.Error()
+				}
+			}
+		},
+	)
+	if reports != nil {
+		doc := dryRunCycle{Feeds: make([]dryRunReport, 0, len(reports))}
+		for _, feed := range feeds {
+			if r, ok := reports[feed.Name]; ok {
+				doc.Feeds = append(doc.Feeds, *r)
+			}
+		}
+		if err := doc.write(os.Stdout); err != nil {
+			errs = append(errs, fmt.Errorf("write dry-run report: %w", err))
+		}
+	}
+	if len(errs) == 0 {
+		lastSuccess.SetToCurrentTime()
+	}
+	return errors.Join(errs...)
+}
+
+// feedSource returns where a feed is read from: the replayed fixtures, a
+// file:// path or the live URL.
+func feedSource(cfg config.Config, feed config.Feed, client *http.Client, retry siata.RetryPolicy) (siata.FeedSource, error) {
+	if cfg.ReplayFixtures != "" {
+		return siata.NewFixtureSource(cfg.ReplayFixtures)
+	}
+	if path, isFile, _ := siata.FilePath(feed.URL); isFile {
+		return &siata.FileSource{Path: path}, nil
+	}
+	return &siata.HTTPSource{
+		Client:    client,
+		URL:       feed.URL,
+		RecordDir: cfg.RecordFixtures,
+		Retry:     retry,
+	}, nil
+}
+
+// writeFeed is the write stage for one fetched feed: it ingests the payload
+// and, for live writing runs, records the outcome in the feed's health. A
+// non-nil report is filled instead of writing, see ingest.
+func writeFeed(ctx context.Context, cfg config.Config, pool *pgxpool.Pool, inj *chaos.Injector, res fetchedFeed, report *dryRunReport) error {
+	// Feed health is only tracked for live, writing runs.
+	_, fileFeed, _ := siata.FilePath(res.feed.URL)
+	track := !cfg.DryRun && cfg.ReplayFixtures == "" && !fileFeed
+	if track {
+		feed := db.Feed{Name: res.feed.Name, URL: res.feed.URL, Cadence: cfg.MinInterval}
+		if err := db.RegisterFeed(ctx, pool, feed); err != nil {
+			return dbError(err)
+		}
+	}
+
+	out := db.CycleOutcome{StartedAt: res.retrievalTS}
+	if res.err != nil {
+		cycleErrors.WithLabelValues("fetch").Inc()
+		out.Err = res.err
+	} else {
+		out.Err = ingest(ctx, cfg, pool, inj, res.feed.Name, res.payload, res.retrievalTS, &out, report)
+	}
+	if track {
+		// Record failures even when ctx is what ran out.
+		recordCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := db.RecordCycle(recordCtx, pool, res.feed.Name, out); err != nil {
+			log.Printf("record cycle for feed %s: %v", res.feed.Name, err)
+		}
+	}
+	return out.Err
+}
+
+// ingest writes sensors and new measurements from a fetched payload, filling
+// out with what it saw. In a JSON dry run the decisions go into report and
+// nothing is logged per measurement. Failures are counted as db errors.
+func ingest(ctx context.Context, cfg config.Config, pool *pgxpool.Pool, inj *chaos.Injector, feedName string, payload models.CurrentResponse, retrievalTS time.Time, out *db.CycleOutcome, report *dryRunReport) (err error) {
+	defer func() {
+		if err != nil {
+			cycleErrors.WithLabelValues("db").Inc()
+		}
+	}()
+	stationsFetched.Add(float64(len(payload.Stations)))
+	log.Printf("feed %s: fetched %d stations (network=%s)", feedName, len(payload.Stations), payload.Network)
+	out.Network = payload.Network
+	out.Stations = len(payload.Stations)
 
-	payload, err := siata.FetchCurrentStations(ctx, client, cfg.CurrentURL)
+	stations, invalid := utils.SplitValidStations(payload.Stations)
+	for _, inv := range invalid {
+		log.Printf("warning: skipping station %d: %v", inv.Code, inv.Err)
+	}
+	out.Skipped = len(invalid)
+	for _, st := range stations {
+		if utils.OutsideServiceArea(st) {
+			log.Printf("warning: station %d at (%.5f,%.5f) is far outside the service area; coordinates may be swapped", st.Code, st.Latitude, st.Longitude)
+		}
+	}
+	if len(invalid) > 0 {
+		log.Printf("skipped %d of %d stations with invalid coordinates", len(invalid), len(payload.Stations))
+	}
+
+	sensorRows := utils.BuildSensorRows(stations)
+	sensorIDs := utils.SensorIDs(sensorRows)
+
+	// Database calls below pass the chaos guard first; it is a no-op unless
+	// CHAOS_* is configured.
+	if err := inj.DB(ctx); err != nil {
+		return err
+	}
+	storedLocations, err := db.FetchSensorLocations(ctx, pool, sensorIDs)
 	if err != nil {
 		return err
 	}
-	log.Printf("fetched %d stations (network=%s)", len(payload.Stations), payload.Network)
+	locationChanges := utils.DetectLocationChanges(sensorRows, storedLocations, cfg.MoveThresholdM)
+	for _, ch := range locationChanges {
+		if ch.Previous != nil {
+			log.Printf("sensor %s moved %.0fm: (%.5f,%.5f) -> (%.5f,%.5f)", ch.SensorID, utils.HaversineMeters(*ch.Previous, ch.Current), ch.Previous.Lat, ch.Previous.Lon, ch.Current.Lat, ch.Current.Lon)
+		}
+	}
 
-	pool, err := pgxpool.New(ctx, cfg.DatabaseURL)
+	if err := inj.DB(ctx); err != nil {
+		return err
+	}
+	metadataChanges, err := diffSensorMetadata(ctx, pool, sensorRows)
 	if err != nil {
 		return err
 	}
-	defer pool.Close()
 
-	sensorRows := utils.BuildSensorRows(payload.Stations)
 	if cfg.DryRun {
-		log.Printf("dry-run: skipping sensor upsert (%d candidates)", len(sensorRows))
+		log.Printf("dry-run: skipping sensor upsert (%d candidates, %d location changes, %d metadata changes)", len(sensorRows), len(locationChanges), len(metadataChanges))
 	} else {
-		if err := db.UpsertSensors(ctx, pool, sensorRows); err != nil {
+		if err := inj.DB(ctx); err != nil {
 			return err
 		}
+		if err := db.SyncSensors(ctx, pool, sensorRows, metadataChanges, locationChanges, retrievalTS); err != nil {
+			return err
+		}
+		sensorsUpserted.Add(float64(len(sensorRows)))
 	}
 
-	sensorIDs := utils.SensorIDs(sensorRows)
-	lastMap, err := db.FetchLastMeasurements(ctx, pool, sensorIDs)
+	if err := inj.DB(ctx); err != nil {
+		return err
+	}
+	lastMap, err := db.FetchLastMeasurements(ctx, pool, sensorIDs, db.SourceCurrent)
 	if err != nil {
 		return err
 	}
 
-	candidates := utils.BuildMeasurementCandidates(payload.Stations, retrievalTS)
-	pending := utils.FilterNewMeasurements(candidates, lastMap, cfg.MinInterval, cfg.ValueEpsilon)
+	candidates := utils.BuildMeasurementCandidates(stations, retrievalTS, cfg.NullSentinels)
+	utils.AlignCandidates(candidates, cfg.TSAlignment, cfg.AlignCadence)
+	kept := utils.FilterNewMeasurements(candidates, lastMap, cfg.MinInterval, cfg.ValueEpsilon)
+	candidatesFiltered.Add(float64(len(candidates) - len(kept)))
+
+	// The staleness check only reports; its failure does not fail the cycle.
+	if cfg.StaleAfter > 0 {
+		if err := reportStale(ctx, cfg, pool, utils.FilteredCandidates(kept), retrievalTS); err != nil {
+			log.Printf("stale check: %v", err)
+		}
+	}
+
+	if report != nil {
+		report.Network = payload.Network
+		report.Stations = len(payload.Stations)
+		report.SkippedStations = len(invalid)
+		report.Sensors = len(sensorRows)
+		report.LocationChanges = len(locationChanges)
+		report.Candidates = len(candidates)
+		report.Filtered = len(candidates) - len(kept)
+		report.Pending = len(kept)
+		for _, m := range kept {
+			report.add(m)
+		}
+		return nil
+	}
+
+	pending := utils.FilteredCandidates(kept)
 
 	if len(pending) == 0 {
 		log.Printf("no new measurements to insert (retrieval=%s)", retrievalTS.Format(time.RFC3339))
 		return nil
 	}
 
-	log.Printf("prepared %d new measurements (dry-run=%v)", len(pending), cfg.DryRun)
+	byReason := utils.CountByReason(kept)
+	log.Printf("prepared %d new measurements (dry-run=%v): %d %s, %d %s, %d %s", len(pending), cfg.DryRun,
+		byReason[utils.ReasonNewSensor], utils.ReasonNewSensor,
+		byReason[utils.ReasonInterval], utils.ReasonInterval,
+		byReason[utils.ReasonValueChanged], utils.ReasonValueChanged)
 
 	if cfg.DryRun {
 		for _, cand := range pending {
-			log.Printf("dry-run: would insert sensor=%s ts=%s value=%s", cand.SensorID, cand.TS.Format(time.RFC3339), utils.ValuePtrString(cand.Value))
+			log.Printf("dry-run: would insert sensor=%s ts=%s retrieved=%s value=%s", cand.SensorID, cand.TS.Format(time.RFC3339), cand.RetrievedAt.Format(time.RFC3339), utils.ValuePtrString(cand.Value))
 		}
 		return nil
 	}
 
-	if err := db.InsertMeasurements(ctx, pool, pending); err != nil {
+	if err := inj.DB(ctx); err != nil {
+		return err
+	}
+	if err := db.InsertMeasurements(ctx, pool, pending, db.SourceCurrent); err != nil {
 		return err
 	}
 
+	out.Inserted = len(pending)
+	measurementsInserted.Add(float64(len(pending)))
 	log.Printf("inserted %d measurements", len(pending))
 	return nil
 }
+
+// diffSensorMetadata compares rows with the stored sensors before they are
+// upserted and logs every changed field, so renames and comuna reassignments
+// leave a trace instead of being overwritten silently.
+func diffSensorMetadata(ctx context.Context, pool *pgxpool.Pool, rows []models.SensorRow) ([]models.MetadataChange, error) {
+	stored, err := db.FetchSensorMetadata(ctx, pool, utils.SensorIDs(rows))
+	if err != nil {
+		return nil, err
+	}
+	changes := utils.DetectMetadataChanges(rows, stored)
+	for _, ch := range changes {
+		log.Printf("sensor_metadata_changed sensor=%s field=%s old=%q new=%q", ch.SensorID, ch.Field, textOrEmpty(ch.Old), textOrEmpty(ch.New))
+	}
+	return changes, nil
+}
+
+func textOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/testsupport"
+)
+
+// schemaPath is db/schema.sql relative to this package.
+const schemaPath = "../../db/schema.sql"
+
+func ptr(v float64) *float64 { return &v }
+
+// feedPayload is a two-station feed; the second station's value varies so
+// tests can trigger a value change.
+func feedPayload(second float64) models.CurrentResponse {
+	return models.CurrentResponse{
+		Network: "fixture",
+		Stations: []models.Station{
+			testsupport.Station(101, 6.25, -75.56, ptr(0.5)),
+			testsupport.Station(102, 6.21, -75.60, ptr(second)),
+		},
+	}
+}
+
+// harness wires run() to a mock feed and a disposable database.
+type harness struct {
+	t    *testing.T
+	ctx  context.Context
+	pool *pgxpool.Pool
+	feed *testsupport.SIATAServer
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	t.Cleanup(cancel)
+
+	pool, url, cleanup, err := testsupport.NewTestDB(ctx, schemaPath)
+	if errors.Is(err, testsupport.ErrNoTestDatabase) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("test database: %v", err)
+	}
+	t.Cleanup(cleanup)
+
+	feed, err := testsupport.NewSIATAServer(feedPayload(1.0))
+	if err != nil {
+		t.Fatalf("mock feed: %v", err)
+	}
+	t.Cleanup(feed.Close)
+
+	t.Setenv("DB_ENV_VARIABLE", "")
+	t.Setenv("DATABASE_URL", url)
+	t.Setenv("CURRENT_URL", feed.URL)
+	t.Setenv("WATCHER_EXTRA_FEEDS", "")
+	t.Setenv("WATCHER_FETCH_CONCURRENCY", "")
+	t.Setenv("WATCHER_MIN_INTERVAL", "1h")
+	t.Setenv("WATCHER_MAX_RETRIES", "0")
+	t.Setenv("WATCHER_STALE_AFTER", "0")
+	t.Setenv("WATCHER_LOOP_INTERVAL", "")
+	t.Setenv("WATCHER_MODE", "")
+	t.Setenv("WATCHER_METRICS_ADDR", "")
+	t.Setenv("RUN_MIGRATIONS", "false")
+	t.Setenv("DRY_RUN", "false")
+
+	return &harness{t: t, ctx: ctx, pool: pool, feed: feed}
+}
+
+// run executes one watcher run through run(), as the binary would.
+func (h *harness) run(dryRun bool) {
+	h.t.Helper()
+	if dryRun {
+		h.t.Setenv("DRY_RUN", "true")
+		defer h.t.Setenv("DRY_RUN", "false")
+	}
+	if err := run(); err != nil {
+		h.t.Fatalf("run: %v", err)
+	}
+	// Candidates are stamped with the retrieval second; the next run must
+	// land on a later one so its rows cannot collide with this run's.
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second)))
+}
+
+func (h *harness) count(table string) int {
+	h.t.Helper()
+	n, err := testsupport.CountRows(h.ctx, h.pool, table)
+	if err != nil {
+		h.t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func (h *harness) expect(table string, want int) {
+	h.t.Helper()
+	if got := h.count(table); got != want {
+		h.t.Errorf("%s: got %d rows, want %d", table, got, want)
+	}
+}
+
+func TestRunEndToEnd(t *testing.T) {
+	h := newHarness(t)
+
+	t.Run("dry run on an empty database writes nothing", func(t *testing.T) {
+		h.t = t
+		h.run(true)
+		h.expect("sensors", 0)
+		h.expect("raw_measurements", 0)
+		h.expect("ingest_log", 0)
+	})
+
+	t.Run("first cycle upserts sensors and inserts one reading each", func(t *testing.T) {
+		h.t = t
+		h.run(false)
+		h.expect("sensors", 2)
+		h.expect("raw_measurements", 2)
+		h.expect("sensor_location_history", 2)
+		h.expect("ingest_log", 1)
+
+		var name string
+		if err := h.pool.QueryRow(h.ctx, `SELECT name FROM shizuku.sensors WHERE id = 'pluvio_101'`).Scan(&name); err != nil {
+			t.Fatalf("read sensor: %v", err)
+		}
+		if name != "Fixture 101" {
+			t.Errorf("sensor name: got %q, want %q", name, "Fixture 101")
+		}
+	})
+
+	t.Run("unchanged feed is deduplicated", func(t *testing.T) {
+		h.t = t
+		h.run(false)
+		h.expect("sensors", 2)
+		h.expect("raw_measurements", 2)
+		h.expect("ingest_log", 2)
+	})
+
+	t.Run("changed value inserts only that station", func(t *testing.T) {
+		h.t = t
+		if err := h.feed.SetPayload(feedPayload(3.5)); err != nil {
+			t.Fatal(err)
+		}
+		h.run(false)
+		h.expect("raw_measurements", 3)
+
+		var value float64
+		err := h.pool.QueryRow(h.ctx, `
+			SELECT value_mm FROM shizuku.raw_measurements
+			WHERE sensor_id = 'pluvio_102' ORDER BY ts DESC LIMIT 1`).Scan(&value)
+		if err != nil {
+			t.Fatalf("read latest value: %v", err)
+		}
+		if value != 3.5 {
+			t.Errorf("latest value: got %v, want 3.5", value)
+		}
+	})
+
+	t.Run("dry run after a change is a no-op", func(t *testing.T) {
+		h.t = t
+		if err := h.feed.SetPayload(feedPayload(7.0)); err != nil {
+			t.Fatal(err)
+		}
+		h.run(true)
+		h.expect("raw_measurements", 3)
+		h.expect("ingest_log", 3)
+		h.expect("sensor_changes", 0)
+	})
+}
+
+// A three-feed cycle against feeds with artificial latencies ingests every
+// feed and takes about the slowest fetch plus the writes, not the sum of the
+// fetches.
+func TestRunOverlapsFeeds(t *testing.T) {
+	const unit = 400 * time.Millisecond
+	h := newHarness(t)
+	h.feed.SetLatency(3 * unit)
+
+	var extra []string
+	for i, latency := range []time.Duration{2 * unit, unit} {
+		payload := models.CurrentResponse{
+			Network:  "fixture",
+			Stations: []models.Station{testsupport.Station(201+i, 6.23, -75.58, ptr(1))},
+		}
+		feed, err := testsupport.NewSIATAServer(payload)
+		if err != nil {
+			t.Fatalf("mock feed: %v", err)
+		}
+		t.Cleanup(feed.Close)
+		feed.SetLatency(latency)
+		extra = append(extra, fmt.Sprintf("extra_%d=%s", i, feed.URL))
+	}
+	t.Setenv("WATCHER_EXTRA_FEEDS", strings.Join(extra, ","))
+
+	start := time.Now()
+	if err := run(); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	h.expect("sensors", 4)
+	h.expect("raw_measurements", 4)
+	h.expect("ingest_log", 3)
+	if sequential := 6 * unit; elapsed >= sequential {
+		t.Errorf("cycle took %s, at least the %s the fetches take back to back", elapsed, sequential)
+	}
+	t.Logf("three-feed cycle: %s (slowest fetch %s)", elapsed, 3*unit)
+}
+
+// captureStdout returns what fn prints to os.Stdout.
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	done := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- b
+	}()
+	defer func() {
+		os.Stdout = orig
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestDryRunJSONIsOneDocument(t *testing.T) {
+	h := newHarness(t)
+	// The configured feed finishes last, so writes happen out of
+	// configuration order.
+	h.feed.SetLatency(500 * time.Millisecond)
+
+	extra, err := testsupport.NewSIATAServer(models.CurrentResponse{
+		Network:  "fixture",
+		Stations: []models.Station{testsupport.Station(201, 6.23, -75.58, ptr(1))},
+	})
+	if err != nil {
+		t.Fatalf("mock feed: %v", err)
+	}
+	t.Cleanup(extra.Close)
+	t.Setenv("WATCHER_EXTRA_FEEDS", "extra="+extra.URL+",broken=http://127.0.0.1:1/feed.json")
+	t.Setenv("DRY_RUN", "true")
+	t.Setenv("DRY_RUN_FORMAT", "json")
+
+	var runErr error
+	out := captureStdout(t, func() { runErr = run() })
+	if runErr == nil || !strings.Contains(runErr.Error(), "feed broken") {
+		t.Errorf("run: %v, want the broken feed's error", runErr)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	var doc struct {
+		Feeds []struct {
+			Feed    string `json:"feed"`
+			Error   string `json:"error"`
+			Network string `json:"network"`
+			Pending int    `json:"pending"`
+		} `json:"feeds"`
+	}
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if dec.More() {
+		t.Errorf("stdout holds more than one JSON document:\n%s", out)
+	}
+
+	var names []string
+	for _, f := range doc.Feeds {
+		names = append(names, f.Feed)
+	}
+	if want := []string{"siata_current", "extra", "broken"}; !slices.Equal(names, want) {
+		t.Fatalf("feeds %v, want %v in configuration order", names, want)
+	}
+	if f := doc.Feeds[0]; f.Error != "" || f.Pending != 2 || f.Network != "fixture" {
+		t.Errorf("siata_current: %+v, want 2 pending", f)
+	}
+	if f := doc.Feeds[1]; f.Error != "" || f.Pending != 1 {
+		t.Errorf("extra: %+v, want 1 pending", f)
+	}
+	if f := doc.Feeds[2]; f.Error == "" || f.Pending != 0 {
+		t.Errorf("broken: %+v, want an error", f)
+	}
+	h.expect("raw_measurements", 0)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/internal/httpclient"
+)
+
+// registry holds the watcher's run metrics; registerMetrics adds them.
+var registry = prometheus.NewRegistry()
+
+var registerOnce sync.Once
+
+// registerMetrics registers the run metrics on registry. It is safe to call
+// from every run, which the end-to-end tests do.
+func registerMetrics() {
+	registerOnce.Do(func() {
+		registry.MustRegister(stationsFetched, sensorsUpserted, measurementsInserted, candidatesFiltered, cycleErrors, lastSuccess, staleSensors, cycleDuration, feedRequests)
+		cycleErrors.WithLabelValues("fetch")
+		cycleErrors.WithLabelValues("db")
+	})
+}
+
+var (
+	stationsFetched = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "stations_fetched_total",
+		Help:      "Stations received from the feed.",
+	})
+
+	sensorsUpserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "sensors_upserted_total",
+		Help:      "Sensor rows upserted.",
+	})
+
+	measurementsInserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "measurements_inserted_total",
+		Help:      "Measurements inserted into raw_measurements.",
+	})
+
+	candidatesFiltered = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "candidates_filtered_total",
+		Help:      "Measurement candidates dropped as unchanged within the minimum interval.",
+	})
+
+	cycleErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "errors_total",
+		Help:      "Failed cycles by stage (fetch or db).",
+	}, []string{"stage"})
+
+	lastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix time of the last cycle that completed without error.",
+	})
+
+	staleSensors = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "stale_sensors",
+		Help:      "Sensors without a reading for longer than WATCHER_STALE_AFTER at the last check.",
+	})
+
+	cycleDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "cycle_duration_seconds",
+		Help:      "Duration of watcher cycles.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	})
+
+	feedRequests = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "shizuku_watcher",
+		Name:      "feed_request_duration_seconds",
+		Help:      "Feed HTTP attempts, by host and status code; code is \"error\" for failed round trips.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"host", "code"})
+)
+
+// observeFeedRequest records one feed HTTP attempt.
+func observeFeedRequest(a httpclient.Attempt) {
+	code := "error"
+	if a.Err == nil {
+		code = strconv.Itoa(a.Status)
+	}
+	feedRequests.WithLabelValues(a.Host, code).Observe(a.Duration.Seconds())
+}
+
+// dbError counts err as a database failure and returns it.
+func dbError(err error) error {
+	cycleErrors.WithLabelValues("db").Inc()
+	return err
+}
+
+// serveMetrics exposes registry on addr under /metrics until ctx is done.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("serving metrics on %s/metrics", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("metrics listener failed: %v", err)
+	}
+}
+
+// pushMetrics sends registry to a Pushgateway under the shizuku_watcher job.
+func pushMetrics(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return push.New(url, "shizuku_watcher").Gatherer(registry).PushContext(ctx)
+}
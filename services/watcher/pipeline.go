@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+)
+
+// fetchedFeed is one feed's fetch result on its way to the write stage.
+type fetchedFeed struct {
+	feed config.Feed
+	// retrievalTS is taken just before the fetch and stamps the feed's
+	// measurements.
+	retrievalTS time.Time
+	payload     models.CurrentResponse
+	err         error
+}
+
+// runFeeds runs one cycle's pipeline: fetch is called for every feed, at most
+// limit at a time, and each result is handed to write as soon as it arrives.
+// write runs on a single goroutine, so writes are serialized (they share the
+// run lock and one pool) but overlap the fetches still in flight; a cycle
+// takes about max(fetch)+sum(write) rather than sum(fetch+write). Failures
+// travel inside fetchedFeed, so one bad feed does not cancel the others.
+func runFeeds(ctx context.Context, feeds []config.Feed, limit int, fetch func(context.Context, config.Feed) fetchedFeed, write func(context.Context, fetchedFeed)) {
+	g, gctx := errgroup.WithContext(ctx)
+	fetched := make(chan fetchedFeed, len(feeds))
+
+	g.Go(func() error {
+		defer close(fetched)
+		var fetchers errgroup.Group
+		fetchers.SetLimit(limit)
+		for _, feed := range feeds {
+			fetchers.Go(func() error {
+				fetched <- fetch(gctx, feed)
+				return nil
+			})
+		}
+		return fetchers.Wait()
+	})
+
+	g.Go(func() error {
+		for res := range fetched {
+			write(gctx, res)
+		}
+		return nil
+	})
+
+	// Neither stage returns an error; the group only joins them.
+	_ = g.Wait()
+}
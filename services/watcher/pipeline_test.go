@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
+)
+
+// latencyStages fakes the two pipeline stages with fixed sleeps and records
+// how many of each run at once.
+type latencyStages struct {
+	fetchLatency map[string]time.Duration
+	writeLatency time.Duration
+
+	fetching, maxFetching atomic.Int32
+	writing, maxWriting   atomic.Int32
+
+	mu      sync.Mutex
+	written []string
+}
+
+func raise(max *atomic.Int32, n int32) {
+	for {
+		cur := max.Load()
+		if n <= cur || max.CompareAndSwap(cur, n) {
+			return
+		}
+	}
+}
+
+func (ls *latencyStages) fetch(ctx context.Context, feed config.Feed) fetchedFeed {
+	raise(&ls.maxFetching, ls.fetching.Add(1))
+	defer ls.fetching.Add(-1)
+	select {
+	case <-time.After(ls.fetchLatency[feed.Name]):
+		return fetchedFeed{feed: feed}
+	case <-ctx.Done():
+		return fetchedFeed{feed: feed, err: ctx.Err()}
+	}
+}
+
+func (ls *latencyStages) write(_ context.Context, res fetchedFeed) {
+	raise(&ls.maxWriting, ls.writing.Add(1))
+	defer ls.writing.Add(-1)
+	time.Sleep(ls.writeLatency)
+	ls.mu.Lock()
+	ls.written = append(ls.written, res.feed.Name)
+	ls.mu.Unlock()
+}
+
+var threeFeeds = []config.Feed{{Name: "slow"}, {Name: "medium"}, {Name: "fast"}}
+
+func newLatencyStages(unit time.Duration) *latencyStages {
+	return &latencyStages{
+		fetchLatency: map[string]time.Duration{"slow": 3 * unit, "medium": 2 * unit, "fast": unit},
+		writeLatency: unit / 2,
+	}
+}
+
+// With fetches of 3, 2 and 1 units and writes of half a unit, a sequential
+// cycle takes 7.5 units; the pipeline takes max(fetch) plus the last write,
+// 3.5 units, because earlier writes happen while the slow feed is fetched.
+func TestRunFeedsOverlapsFetchesAndWrites(t *testing.T) {
+	const unit = 100 * time.Millisecond
+	ls := newLatencyStages(unit)
+
+	start := time.Now()
+	runFeeds(context.Background(), threeFeeds, 3, ls.fetch, ls.write)
+	elapsed := time.Since(start)
+
+	if elapsed > 6*unit {
+		t.Errorf("cycle took %s, want about %s (sequential would be %s)", elapsed, 7*unit/2, 15*unit/2)
+	}
+	if got := ls.maxFetching.Load(); got != 3 {
+		t.Errorf("at most %d fetches ran at once, want 3", got)
+	}
+	if got := ls.maxWriting.Load(); got != 1 {
+		t.Errorf("%d writes ran at once, want them serialized", got)
+	}
+	if want := []string{"fast", "medium", "slow"}; !slices.Equal(ls.written, want) {
+		t.Errorf("written in order %v, want arrival order %v", ls.written, want)
+	}
+}
+
+func TestRunFeedsRespectsFetchLimit(t *testing.T) {
+	ls := newLatencyStages(10 * time.Millisecond)
+	runFeeds(context.Background(), threeFeeds, 1, ls.fetch, ls.write)
+	if got := ls.maxFetching.Load(); got != 1 {
+		t.Errorf("%d fetches ran at once, want 1", got)
+	}
+	if len(ls.written) != 3 {
+		t.Errorf("wrote %v, want every feed", ls.written)
+	}
+}
+
+func TestRunFeedsWritesFailedFetches(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ls := newLatencyStages(time.Hour)
+	var failed []string
+	runFeeds(ctx, threeFeeds, 3, ls.fetch, func(_ context.Context, res fetchedFeed) {
+		if res.err != nil {
+			failed = append(failed, res.feed.Name)
+		}
+	})
+	if len(failed) != 3 {
+		t.Errorf("failed fetches reached the write stage for %v, want all three", failed)
+	}
+}
+
+// BenchmarkRunFeeds reports the cycle time of three feeds with artificial
+// latencies against the sequential time, as a cycle_ms/sequential_ms pair.
+func BenchmarkRunFeeds(b *testing.B) {
+	const unit = 20 * time.Millisecond
+	for i := 0; i < b.N; i++ {
+		ls := newLatencyStages(unit)
+		runFeeds(context.Background(), threeFeeds, 3, ls.fetch, ls.write)
+	}
+	b.ReportMetric(float64(b.Elapsed().Milliseconds())/float64(b.N), "cycle_ms")
+	b.ReportMetric(float64((15 * unit / 2).Milliseconds()), "sequential_ms")
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/config"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/db"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/models"
+	"github.com/02loveslollipop/Shizuku-precipitation-viewer/services/watcher/internal/utils"
+)
+
+// reportStale logs the sensors without a reading for longer than
+// cfg.StaleAfter, counting this cycle's pending measurements, and with
+// cfg.MarkStale records the result in the sensors' metadata. Every sensor of
+// the current feed is checked, not only those in this cycle's payload: a
+// listed sensor always gets a fresh timestamp, so a dead gauge shows up
+// either as one dropped from the feed or as one sending only "no data".
+func reportStale(ctx context.Context, cfg config.Config, pool *pgxpool.Pool, pending []models.MeasurementCandidate, now time.Time) error {
+	last, err := db.FetchLastReadings(ctx, pool, db.SourceCurrent)
+	if err != nil {
+		return err
+	}
+	stale := utils.StaleSensors(last, pending, now, cfg.StaleAfter)
+	staleSensors.Set(float64(len(stale)))
+
+	if len(stale) > 0 {
+		log.Printf("warning: %d sensors have no reading for more than %s", len(stale), cfg.StaleAfter)
+	}
+	for _, s := range stale {
+		log.Printf("warning: sensor %s is stale: last reading %s (%s ago)", s.SensorID, s.LastTS.Format(time.RFC3339), s.Age.Round(time.Minute))
+	}
+
+	if !cfg.MarkStale {
+		return nil
+	}
+	if cfg.DryRun {
+		log.Printf("dry-run: skipping stale metadata update (%d stale sensors)", len(stale))
+		return nil
+	}
+	lastReadings := make(map[string]time.Time, len(stale))
+	for _, s := range stale {
+		lastReadings[s.SensorID] = s.LastTS
+	}
+	return db.MarkStaleSensors(ctx, pool, lastReadings)
+}